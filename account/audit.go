@@ -0,0 +1,217 @@
+package account
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Audited operations
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+)
+
+// ErrAuditChainBroken is used when an audit entry does not match the hash chain
+var ErrAuditChainBroken = errors.New("audit chain broken")
+
+// AuditEntry records who changed what on an Account. Entries of an account are
+// chained: each one holds the hash of the previous one, so that altering or
+// removing an entry breaks the chain.
+type AuditEntry struct {
+	AccountID string        `json:"account_id" bson:"account_id"`
+	Sequence  int           `json:"sequence" bson:"sequence"`
+	Operation string        `json:"operation" bson:"operation"`
+	Actor     string        `json:"actor" bson:"actor"`
+	RequestID string        `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	At        time.Time     `json:"at" bson:"at"`
+	Changes   []FieldChange `json:"changes" bson:"changes"`
+	PrevHash  string        `json:"prev_hash" bson:"prev_hash"`
+	Hash      string        `json:"hash" bson:"hash"`
+}
+
+// FieldChange is the before and after values of a field, encoded as JSON
+type FieldChange struct {
+	Field  string `json:"field" bson:"field"`
+	Before string `json:"before,omitempty" bson:"before,omitempty"`
+	After  string `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// AuditHistory reads the AuditEntries
+type AuditHistory interface {
+	// History returns the entries of an account, newest first
+	History(ctx context.Context, accountID string, pagination Pagination) ([]*AuditEntry, error)
+}
+
+// AuditLog is the append-only storage of the AuditEntries
+type AuditLog interface {
+	AuditHistory
+	// Append chains the entry after the last one of its account and stores it
+	Append(ctx context.Context, e AuditEntry) error
+}
+
+// NewAuditEntry returns the unchained entry of an operation changing an Account
+// from before to after, made by the actor of the context
+func NewAuditEntry(ctx context.Context, operation string, id string, before, after *Account) AuditEntry {
+	return AuditEntry{
+		AccountID: id,
+		Operation: operation,
+		Actor:     ActorFromContext(ctx),
+		RequestID: RequestIDFromContext(ctx),
+		// stored with a millisecond precision, so truncated before being hashed
+		At:      time.Now().UTC().Truncate(time.Millisecond),
+		Changes: Diff(before, after),
+	}
+}
+
+// Chain sets the sequence, previous hash and hash of the entry so that it follows prev,
+// prev is nil for the first entry of an account
+func (e *AuditEntry) Chain(prev *AuditEntry) {
+	e.Sequence = 1
+	e.PrevHash = ""
+	if prev != nil {
+		e.Sequence = prev.Sequence + 1
+		e.PrevHash = prev.Hash
+	}
+	e.Hash = e.ComputeHash()
+}
+
+// ComputeHash returns the hash of the entry content and of the previous hash
+func (e AuditEntry) ComputeHash() string {
+	// an entry read back from the storage must hash the same as when written
+	e.Hash = ""
+	e.At = e.At.UTC()
+	if e.Changes == nil {
+		e.Changes = []FieldChange{}
+	}
+	b, _ := json.Marshal(e)
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAuditChain checks that consecutive entries of an account, oldest first, are correctly chained
+func VerifyAuditChain(entries []*AuditEntry) error {
+	for i, e := range entries {
+		if e.Hash != e.ComputeHash() {
+			return ErrAuditChainBroken
+		}
+		if i > 0 && (e.PrevHash != entries[i-1].Hash || e.Sequence != entries[i-1].Sequence+1) {
+			return ErrAuditChainBroken
+		}
+	}
+	return nil
+}
+
+// Diff returns the fields that differ between two versions of an Account,
+// a nil before or after stands for a created or deleted Account
+func Diff(before, after *Account) []FieldChange {
+	var b, a reflect.Value
+	if before != nil {
+		b = reflect.ValueOf(*before)
+	}
+	if after != nil {
+		a = reflect.ValueOf(*after)
+	}
+
+	t := reflect.TypeOf(Account{})
+	changes := []FieldChange{}
+	for i := 0; i < t.NumField(); i++ {
+		var bv, av interface{}
+		if b.IsValid() && !isZero(b.Field(i)) {
+			bv = b.Field(i).Interface()
+		}
+		if a.IsValid() && !isZero(a.Field(i)) {
+			av = a.Field(i).Interface()
+		}
+
+		if reflect.DeepEqual(bv, av) {
+			continue
+		}
+
+		changes = append(changes, FieldChange{
+			Field:  strings.Split(t.Field(i).Tag.Get("json"), ",")[0],
+			Before: encodeValue(bv),
+			After:  encodeValue(av),
+		})
+	}
+
+	return changes
+}
+
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+func encodeValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+type contextKey int
+
+const (
+	contextKeyActor contextKey = iota
+	contextKeyRequestID
+	contextKeyCreator
+)
+
+// anonymousActor is the actor of the changes made without one in their context
+const anonymousActor = "anonymous"
+
+// ContextWithActor returns a context holding the actor to audit, it is set by
+// the authentication of the request
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, contextKeyActor, actor)
+}
+
+// ActorFromContext returns the actor held by the context, anonymous if there is none
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(contextKeyActor).(string); ok && len(actor) > 0 {
+		return actor
+	}
+	return anonymousActor
+}
+
+// ContextWithCreator returns a context holding the account making the request,
+// it owns the accounts it creates and only writes those of its tenant. It is
+// set by the authentication of the request.
+func ContextWithCreator(ctx context.Context, accountID string) context.Context {
+	return context.WithValue(ctx, contextKeyCreator, accountID)
+}
+
+// CreatorFromContext returns the account held by the context, empty if there is none
+func CreatorFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKeyCreator).(string)
+	return id
+}
+
+// ContextWithRequestID returns a context holding the request ID to audit
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKeyRequestID, requestID)
+}
+
+// RequestIDFromContext returns the request ID held by the context
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(contextKeyRequestID).(string)
+	return requestID
+}
+
+type nopAuditLog struct{}
+
+func (nopAuditLog) Append(ctx context.Context, e AuditEntry) error {
+	return nil
+}
+
+func (nopAuditLog) History(ctx context.Context, accountID string, pagination Pagination) ([]*AuditEntry, error) {
+	return []*AuditEntry{}, nil
+}
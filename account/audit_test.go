@@ -0,0 +1,38 @@
+package account
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Diff(t *testing.T) {
+	before := &Account{AccountID: "1", Status: StatusActive}
+	after := &Account{AccountID: "1", Status: StatusClosed}
+
+	assert.Equal(t, []FieldChange{{Field: "status", Before: "\"active\"", After: "\"closed\""}}, Diff(before, after))
+	assert.Equal(t, []FieldChange{}, Diff(before, before))
+	assert.Len(t, Diff(nil, after), 2)
+	assert.Equal(t, "", Diff(before, nil)[0].After)
+}
+
+func Test_VerifyAuditChain(t *testing.T) {
+	first := &AuditEntry{AccountID: "1", Operation: OperationCreate, At: time.Now()}
+	first.Chain(nil)
+	second := &AuditEntry{AccountID: "1", Operation: OperationUpdate, At: time.Now()}
+	second.Chain(first)
+
+	assert.Equal(t, 2, second.Sequence)
+	assert.Equal(t, first.Hash, second.PrevHash)
+	assert.Nil(t, VerifyAuditChain([]*AuditEntry{first, second}))
+
+	first.Actor = "someone else"
+	assert.Equal(t, ErrAuditChainBroken, VerifyAuditChain([]*AuditEntry{first, second}))
+}
+
+func Test_ActorFromContext(t *testing.T) {
+	assert.Equal(t, "anonymous", ActorFromContext(context.Background()))
+	assert.Equal(t, "alice", ActorFromContext(ContextWithActor(context.Background(), "alice")))
+}
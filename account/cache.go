@@ -0,0 +1,228 @@
+package account
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// CachingRepository is a read-through cache of the Accounts by ID in front of
+// any Repository. It holds the most recently read Accounts, up to a size, each
+// one for a ttl. Its writes invalidate the Accounts they change, the writes of
+// another process do not: they are seen once the ttl expires.
+type CachingRepository struct {
+	Repository
+	size   int
+	ttl    time.Duration
+	hits   metrics.Counter
+	misses metrics.Counter
+
+	mtx sync.Mutex
+	// lru holds the *cacheEntry, the most recently used first
+	lru     *list.List
+	entries map[string]*list.Element
+	// calls are the reads in flight, the concurrent misses of an ID wait for the same one
+	calls map[string]*cacheCall
+}
+
+// FreshReader is a Repository which can read an Account bypassing its cache
+type FreshReader interface {
+	GetAccountFresh(ctx context.Context, id string) (*Account, error)
+}
+
+type cacheEntry struct {
+	id      string
+	account *Account
+	expires time.Time
+}
+
+type cacheCall struct {
+	done    chan struct{}
+	account *Account
+	err     error
+	// stale is set when the Account was written during the read, its result is then not cached
+	stale bool
+}
+
+// NewCachingRepository wraps a Repository with a cache of size Accounts, counting its hits and misses
+func NewCachingRepository(r Repository, size int, ttl time.Duration, hits, misses metrics.Counter) *CachingRepository {
+	return &CachingRepository{
+		Repository: r,
+		size:       size,
+		ttl:        ttl,
+		hits:       hits,
+		misses:     misses,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+		calls:      map[string]*cacheCall{},
+	}
+}
+
+// GetAccount returns the cached Account, or reads it once for all the concurrent misses
+func (r *CachingRepository) GetAccount(ctx context.Context, id string) (*Account, error) {
+	r.mtx.Lock()
+	if e, ok := r.entries[id]; ok {
+		entry := e.Value.(*cacheEntry)
+		if time.Now().Before(entry.expires) {
+			r.lru.MoveToFront(e)
+			r.mtx.Unlock()
+
+			r.hits.Add(1)
+			return clone(entry.account), nil
+		}
+		r.lru.Remove(e)
+		delete(r.entries, id)
+	}
+
+	r.misses.Add(1)
+	if c, ok := r.calls[id]; ok {
+		r.mtx.Unlock()
+
+		select {
+		case <-c.done:
+			// the read was given up by the context of its caller, not this one
+			if (errors.Is(c.err, context.Canceled) || errors.Is(c.err, context.DeadlineExceeded)) && ctx.Err() == nil {
+				return r.GetAccount(ctx, id)
+			}
+			return clone(c.account), c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	c := &cacheCall{done: make(chan struct{})}
+	r.calls[id] = c
+	r.mtx.Unlock()
+
+	c.account, c.err = r.Repository.GetAccount(ctx, id)
+
+	r.mtx.Lock()
+	if r.calls[id] == c {
+		delete(r.calls, id)
+	}
+	if !c.stale && c.err == nil && c.account != nil {
+		r.put(id, clone(c.account))
+	}
+	r.mtx.Unlock()
+	close(c.done)
+
+	return clone(c.account), c.err
+}
+
+// GetAccountFresh reads the Account from the wrapped Repository, without caching it
+func (r *CachingRepository) GetAccountFresh(ctx context.Context, id string) (*Account, error) {
+	return r.Repository.GetAccount(ctx, id)
+}
+
+// put caches an Account, and evicts the least recently used ones beyond the size
+func (r *CachingRepository) put(id string, a *Account) {
+	entry := &cacheEntry{id: id, account: a, expires: time.Now().Add(r.ttl)}
+	if e, ok := r.entries[id]; ok {
+		e.Value = entry
+		r.lru.MoveToFront(e)
+	} else {
+		r.entries[id] = r.lru.PushFront(entry)
+	}
+
+	for r.lru.Len() > r.size {
+		e := r.lru.Back()
+		r.lru.Remove(e)
+		delete(r.entries, e.Value.(*cacheEntry).id)
+	}
+}
+
+// invalidate drops an Account from the cache, and keeps a read in flight from caching it
+func (r *CachingRepository) invalidate(id string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if e, ok := r.entries[id]; ok {
+		r.lru.Remove(e)
+		delete(r.entries, id)
+	}
+	if c, ok := r.calls[id]; ok {
+		c.stale = true
+		delete(r.calls, id)
+	}
+}
+
+// UpdateAccount writes the Account, which is read again on its next GetAccount.
+// The cache is invalidated after the write, whether it failed or not, so that
+// a read during the write does not cache what was there before.
+func (r *CachingRepository) UpdateAccount(ctx context.Context, a Account) error {
+	defer r.invalidate(a.AccountID)
+
+	return r.Repository.UpdateAccount(ctx, a)
+}
+
+// CreateAccount creates the Account, dropping whatever a concurrent read cached for its ID
+func (r *CachingRepository) CreateAccount(ctx context.Context, a Account) (string, error) {
+	id, err := r.Repository.CreateAccount(ctx, a)
+	if err == nil {
+		r.invalidate(id)
+	}
+
+	return id, err
+}
+
+// DeleteAccount deletes the Account and drops it from the cache
+func (r *CachingRepository) DeleteAccount(ctx context.Context, id string) error {
+	defer r.invalidate(id)
+
+	return r.Repository.DeleteAccount(ctx, id)
+}
+
+// WriteAccounts applies the writes, dropping every written Account from the cache
+func (r *CachingRepository) WriteAccounts(ctx context.Context, writes []Write, unchanged []Account) error {
+	defer func() {
+		for _, w := range writes {
+			r.invalidate(w.Before.AccountID)
+		}
+	}()
+
+	return r.Repository.WriteAccounts(ctx, writes, unchanged)
+}
+
+// SearchAccounts searches with the wrapped Repository, when it is a Searcher
+func (r *CachingRepository) SearchAccounts(ctx context.Context, terms []string, limit int) ([]*Account, error) {
+	searcher, ok := r.Repository.(Searcher)
+	if !ok {
+		return nil, ErrSearchUnavailable
+	}
+
+	return searcher.SearchAccounts(ctx, terms, limit)
+}
+
+// clone copies an Account so that the callers can not change the cached one,
+// the values of its custom fields are shared
+func clone(a *Account) *Account {
+	if a == nil {
+		return nil
+	}
+
+	c := *a
+	if a.Labels != nil {
+		c.Labels = make(map[string]string, len(a.Labels))
+		for k, v := range a.Labels {
+			c.Labels[k] = v
+		}
+	}
+	if a.Annotations != nil {
+		c.Annotations = make(map[string]string, len(a.Annotations))
+		for k, v := range a.Annotations {
+			c.Annotations[k] = v
+		}
+	}
+	if a.Custom != nil {
+		c.Custom = make(map[string]interface{}, len(a.Custom))
+		for k, v := range a.Custom {
+			c.Custom[k] = v
+		}
+	}
+
+	return &c
+}
@@ -0,0 +1,205 @@
+package account
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestCache(r Repository, size int, ttl time.Duration) (*CachingRepository, *generic.Counter, *generic.Counter) {
+	hits, misses := generic.NewCounter("hits"), generic.NewCounter("misses")
+	return NewCachingRepository(r, size, ttl, hits, misses), hits, misses
+}
+
+func Test_CachingRepository_Should_Read_Through_And_Invalidate_On_Write(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "1").Return(&Account{AccountID: "1", Labels: map[string]string{"team": "core"}}, nil)
+	fakeRepo.On("UpdateAccount", Account{AccountID: "1"}).Return(nil)
+	cache, hits, misses := newTestCache(fakeRepo, 10, time.Minute)
+
+	a, err := cache.GetAccount(context.Background(), "1")
+	assert.Nil(t, err)
+	a.Labels["team"] = "changed"
+
+	a, err = cache.GetAccount(context.Background(), "1")
+	assert.Nil(t, err)
+	assert.Equal(t, "core", a.Labels["team"])
+	assert.Equal(t, 1.0, hits.Value())
+	assert.Equal(t, 1.0, misses.Value())
+	fakeRepo.AssertNumberOfCalls(t, "GetAccount", 1)
+
+	assert.Nil(t, cache.UpdateAccount(context.Background(), Account{AccountID: "1"}))
+	cache.GetAccount(context.Background(), "1")
+
+	fakeRepo.AssertNumberOfCalls(t, "GetAccount", 2)
+}
+
+func Test_CachingRepository_Should_Evict_And_Expire(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "1").Return(&Account{AccountID: "1"}, nil)
+	fakeRepo.On("GetAccount", "2").Return(&Account{AccountID: "2"}, nil)
+	cache, _, _ := newTestCache(fakeRepo, 1, time.Minute)
+
+	cache.GetAccount(context.Background(), "1")
+	cache.GetAccount(context.Background(), "2")
+	cache.GetAccount(context.Background(), "1")
+
+	fakeRepo.AssertNumberOfCalls(t, "GetAccount", 3)
+
+	cache, _, _ = newTestCache(fakeRepo, 10, time.Millisecond)
+	cache.GetAccount(context.Background(), "2")
+	time.Sleep(5 * time.Millisecond)
+	cache.GetAccount(context.Background(), "2")
+
+	fakeRepo.AssertNumberOfCalls(t, "GetAccount", 5)
+}
+
+func Test_CachingRepository_Should_Not_Cache_Errors(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "1").Return(nil, ErrNotFound)
+	cache, _, _ := newTestCache(fakeRepo, 10, time.Minute)
+
+	_, err := cache.GetAccount(context.Background(), "1")
+	assert.Equal(t, ErrNotFound, err)
+	cache.GetAccount(context.Background(), "1")
+
+	fakeRepo.AssertNumberOfCalls(t, "GetAccount", 2)
+}
+
+// blockingRepository holds its reads until it is released
+type blockingRepository struct {
+	Repository
+	release chan struct{}
+	mtx     sync.Mutex
+	reads   int
+}
+
+func (r *blockingRepository) GetAccount(ctx context.Context, id string) (*Account, error) {
+	r.mtx.Lock()
+	r.reads++
+	r.mtx.Unlock()
+
+	<-r.release
+	return &Account{AccountID: id}, nil
+}
+
+func Test_CachingRepository_Should_Coalesce_Concurrent_Misses(t *testing.T) {
+	r := &blockingRepository{release: make(chan struct{})}
+	cache, _, misses := newTestCache(r, 10, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a, err := cache.GetAccount(context.Background(), "1")
+			assert.Nil(t, err)
+			assert.Equal(t, "1", a.AccountID)
+		}()
+	}
+
+	for misses.Value() < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	close(r.release)
+	wg.Wait()
+
+	assert.Equal(t, 1, r.reads)
+}
+
+func Test_CachingRepository_Should_Not_Cache_A_Read_Overlapping_A_Write(t *testing.T) {
+	r := &blockingRepository{release: make(chan struct{})}
+	cache, _, _ := newTestCache(r, 10, time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		cache.GetAccount(context.Background(), "1")
+		close(done)
+	}()
+
+	for {
+		r.mtx.Lock()
+		reads := r.reads
+		r.mtx.Unlock()
+		if reads == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cache.invalidate("1")
+	close(r.release)
+	<-done
+
+	cache.GetAccount(context.Background(), "1")
+	assert.Equal(t, 2, r.reads)
+}
+
+// contextRepository holds its first read until the context of its caller is done
+type contextRepository struct {
+	Repository
+	started chan struct{}
+	mtx     sync.Mutex
+	reads   int
+}
+
+func (r *contextRepository) GetAccount(ctx context.Context, id string) (*Account, error) {
+	r.mtx.Lock()
+	r.reads++
+	first := r.reads == 1
+	r.mtx.Unlock()
+
+	if first {
+		close(r.started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &Account{AccountID: id}, nil
+}
+
+func Test_CachingRepository_Should_Read_Again_When_The_Reader_Gives_Up(t *testing.T) {
+	r := &contextRepository{started: make(chan struct{})}
+	cache, _, misses := newTestCache(r, 10, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := cache.GetAccount(ctx, "1")
+		done <- err
+	}()
+	<-r.started
+
+	waited := make(chan *Account)
+	go func() {
+		a, err := cache.GetAccount(context.Background(), "1")
+		assert.Nil(t, err)
+		waited <- a
+	}()
+	for misses.Value() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-done)
+	if a := <-waited; assert.NotNil(t, a) {
+		assert.Equal(t, "1", a.AccountID)
+	}
+	assert.Equal(t, 2, r.reads)
+}
+
+func Test_Service_Should_Read_The_Accounts_It_Writes_Bypassing_The_Cache(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "1").Return(&Account{AccountID: "1", Status: StatusActive, Version: 2}, nil)
+	fakeRepo.On("UpdateAccount", mock.MatchedBy(func(a Account) bool { return a.Version == 3 })).Return(nil)
+	cache, _, _ := newTestCache(fakeRepo, 10, time.Minute)
+
+	cache.GetAccount(context.Background(), "1")
+	svc := NewService(cache)
+	assert.Nil(t, svc.UpdateAccount(context.Background(), Account{AccountID: "1", Name: "new"}))
+
+	fakeRepo.AssertNumberOfCalls(t, "GetAccount", 2)
+}
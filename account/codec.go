@@ -0,0 +1,259 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedMediaType thrown when the Content-Type of a request body can not be decoded
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// ErrBodyTooLarge is used when a request body is larger than MaxBodySize
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// errBodyTooDeep is used when a request body nests more than MaxBodyDepth objects and arrays
+var errBodyTooDeep = errors.New("request body nested too deep")
+
+// Bounds of the request bodies, in bytes and in levels of nested objects and arrays
+const (
+	MaxBodySize  = 1 << 20
+	MaxBodyDepth = 32
+)
+
+// Codec writes the responses and reads the request bodies in a media type
+type Codec interface {
+	// ContentType is the Content-Type header of the responses
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// Codecs is a registry of Codecs keyed by media type
+type Codecs struct {
+	codecs map[string]Codec
+	// types are in registration order, the first one is the default
+	types []string
+}
+
+// NewCodecs returns an empty registry
+func NewCodecs() *Codecs {
+	return &Codecs{codecs: map[string]Codec{}}
+}
+
+// Register adds a Codec for its media types, such as "application/json"
+func (c *Codecs) Register(codec Codec, mediaTypes ...string) {
+	for _, t := range mediaTypes {
+		if _, ok := c.codecs[t]; !ok {
+			c.types = append(c.types, t)
+		}
+		c.codecs[t] = codec
+	}
+}
+
+// DefaultCodecs are the Codecs of the account handlers, JSON being the default
+var DefaultCodecs = func() *Codecs {
+	c := NewCodecs()
+	c.Register(jsonCodec{}, "application/json")
+	c.Register(msgpackCodec{}, "application/msgpack", "application/x-msgpack")
+	return c
+}()
+
+// Negotiate picks the Codec of the response from an Accept header, by
+// decreasing quality. Wildcards pick the first Codec registered for them, and
+// an empty header the default one.
+func (c *Codecs) Negotiate(accept string) (Codec, error) {
+	if len(strings.TrimSpace(accept)) == 0 {
+		return c.codecs[c.types[0]], nil
+	}
+
+	for _, r := range mediaRanges(accept) {
+		for _, t := range c.types {
+			if matchesRange(r, t) {
+				return c.codecs[t], nil
+			}
+		}
+	}
+
+	return nil, ErrNotAcceptable
+}
+
+// mediaRanges returns the media ranges of an Accept header by decreasing
+// quality, leaving out the malformed and the refused (q=0) ones
+func mediaRanges(accept string) []string {
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+
+	var ranges []accepted
+	for _, part := range strings.Split(accept, ",") {
+		t, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, accepted{t, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	types := make([]string, len(ranges))
+	for i, r := range ranges {
+		types[i] = r.mediaType
+	}
+
+	return types
+}
+
+// matchesRange reports whether a media type is in a media range, such as "text/*"
+func matchesRange(mediaRange, mediaType string) bool {
+	return mediaRange == mediaType || mediaRange == "*/*" ||
+		(strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")))
+}
+
+// ForContentType returns the Codec of a request body from its Content-Type header, JSON when it is empty
+func (c *Codecs) ForContentType(contentType string) (Codec, error) {
+	if len(strings.TrimSpace(contentType)) == 0 {
+		return c.codecs[c.types[0]], nil
+	}
+
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrUnsupportedMediaType
+	}
+	codec, ok := c.codecs[t]
+	if !ok {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	return codec, nil
+}
+
+type codecContextKey int
+
+const (
+	contextKeyResponseCodec codecContextKey = iota
+	contextKeyRequestCodec
+)
+
+// negotiate picks the Codecs of a request from its Accept and Content-Type
+// headers, so a request which can not be answered is rejected before its
+// endpoint is called
+func negotiate(codecs *Codecs, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		res, err := codecs.Negotiate(r.Header.Get("Accept"))
+		if err != nil {
+			encodeError(ctx, err, w)
+			return
+		}
+		ctx = context.WithValue(ctx, contextKeyResponseCodec, res)
+
+		if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+			req, err := codecs.ForContentType(r.Header.Get("Content-Type"))
+			if err != nil {
+				encodeError(ctx, err, w)
+				return
+			}
+			ctx = context.WithValue(ctx, contextKeyRequestCodec, req)
+			r.Body = http.MaxBytesReader(w, r.Body, MaxBodySize)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// responseCodec returns the Codec negotiated for the response, JSON by default
+func responseCodec(ctx context.Context) Codec {
+	if codec, ok := ctx.Value(contextKeyResponseCodec).(Codec); ok {
+		return codec
+	}
+	return jsonCodec{}
+}
+
+// decodeBody reads a request body with the Codec of its Content-Type, JSON by
+// default. It returns ErrBodyTooLarge or ErrInvalidBody when it can not.
+func decodeBody(ctx context.Context, r *http.Request, v interface{}) error {
+	codec, ok := ctx.Value(contextKeyRequestCodec).(Codec)
+	if !ok {
+		codec = jsonCodec{}
+	}
+
+	err := codec.Decode(r.Body, v)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &tooLarge):
+		return ErrBodyTooLarge
+	}
+	return ErrInvalidBody
+}
+
+// encode writes a response with the negotiated Codec
+func encode(ctx context.Context, w http.ResponseWriter, status int, response interface{}) error {
+	codec := responseCodec(ctx)
+
+	w.Header().Set("Content-Type", codec.ContentType())
+	w.Header().Add("Vary", "Accept")
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+
+	return codec.Encode(w, response)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return "application/json; charset=utf-8" }
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error { return json.NewEncoder(w).Encode(v) }
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return decodeJSON(b, v)
+}
+
+// decodeJSON decodes a JSON document of at most MaxBodyDepth nested objects and arrays
+func decodeJSON(b []byte, v interface{}) error {
+	depth, inString, escaped := 0, false, false
+	for _, c := range b {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			escaped = c == '\\'
+			inString = c != '"'
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			if depth++; depth > MaxBodyDepth {
+				return errBodyTooDeep
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+
+	return json.Unmarshal(b, v)
+}
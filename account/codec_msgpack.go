@@ -0,0 +1,118 @@
+package account
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// msgpackCodec writes the responses as MessagePack, with the field names of
+// their JSON form and their maps keys sorted, and reads the MessagePack bodies
+// as if they were JSON
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string { return "application/msgpack" }
+
+func (msgpackCodec) Encode(w io.Writer, v interface{}) error {
+	return newMsgpackEncoder(w).Encode(v)
+}
+
+func (msgpackCodec) Decode(r io.Reader, v interface{}) error {
+	var b bytes.Buffer
+	if err := msgpackToJSON(&b, msgpack.NewDecoder(r), 0); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b.Bytes(), v)
+}
+
+// newMsgpackEncoder returns an Encoder naming the fields by their json tags
+func newMsgpackEncoder(w io.Writer) *msgpack.Encoder {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+	return enc
+}
+
+// msgpackToJSON writes the value read by d as JSON, so that it is decoded as
+// the same body in JSON would be. Its maps and arrays are at most MaxBodyDepth
+// deep, and the keys of its maps are strings.
+func msgpackToJSON(b *bytes.Buffer, d *msgpack.Decoder, depth int) error {
+	c, err := d.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case msgpcode.IsFixedMap(c) || c == msgpcode.Map16 || c == msgpcode.Map32:
+		if depth == MaxBodyDepth {
+			return errBodyTooDeep
+		}
+
+		n, err := d.DecodeMapLen()
+		if err != nil {
+			return err
+		}
+		b.WriteByte('{')
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+
+			key, err := d.DecodeString()
+			if err != nil {
+				return err
+			}
+			if err := writeJSON(b, key); err != nil {
+				return err
+			}
+			b.WriteByte(':')
+
+			if err := msgpackToJSON(b, d, depth+1); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	case msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32:
+		if depth == MaxBodyDepth {
+			return errBodyTooDeep
+		}
+
+		n, err := d.DecodeArrayLen()
+		if err != nil {
+			return err
+		}
+		b.WriteByte('[')
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := msgpackToJSON(b, d, depth+1); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	default:
+		v, err := d.DecodeInterfaceLoose()
+		if err != nil {
+			return err
+		}
+		return writeJSON(b, v)
+	}
+
+	return nil
+}
+
+func writeJSON(b *bytes.Buffer, v interface{}) error {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.Write(j)
+	return err
+}
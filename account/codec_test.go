@@ -0,0 +1,172 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Codecs_Negotiate(t *testing.T) {
+	var flagtests = []struct {
+		accept   string
+		expected Codec
+	}{
+		{"", jsonCodec{}},
+		{"*/*", jsonCodec{}},
+		{"application/msgpack", msgpackCodec{}},
+		{"application/json;q=0.5, application/x-msgpack", msgpackCodec{}},
+		{"text/html, application/x-msgpack;q=0.8, application/json;q=0.9", jsonCodec{}},
+		{"application/json;q=0, application/*;q=0.1", jsonCodec{}},
+	}
+
+	for _, tt := range flagtests {
+		codec, err := DefaultCodecs.Negotiate(tt.accept)
+
+		assert.Nil(t, err, tt.accept)
+		assert.Equal(t, tt.expected, codec, tt.accept)
+	}
+
+	for _, accept := range []string{"text/html, application/json;q=0", "text/*", "application/yaml", "application/x-protobuf"} {
+		_, err := DefaultCodecs.Negotiate(accept)
+		assert.Equal(t, ErrNotAcceptable, err, accept)
+	}
+}
+
+func Test_Codecs_ForContentType(t *testing.T) {
+	codec, err := DefaultCodecs.ForContentType("")
+	assert.Nil(t, err)
+	assert.Equal(t, jsonCodec{}, codec)
+
+	codec, err = DefaultCodecs.ForContentType("application/msgpack; charset=binary")
+	assert.Nil(t, err)
+	assert.Equal(t, msgpackCodec{}, codec)
+
+	_, err = DefaultCodecs.ForContentType("text/html")
+	assert.Equal(t, ErrUnsupportedMediaType, err)
+}
+
+func Test_Codecs_Should_Read_What_They_Write(t *testing.T) {
+	created := time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC)
+	a := Account{
+		AccountID:     "1",
+		Name:          "John Doe",
+		Email:         "john@doe.com",
+		EmailVerified: true,
+		Version:       300,
+		Labels:        map[string]string{"team": "core"},
+		Custom:        map[string]interface{}{"score": 4.5, "seats": float64(-70000), "tags": []interface{}{"a", nil}},
+		CreatedAt:     &created,
+	}
+
+	for _, codec := range []Codec{jsonCodec{}, msgpackCodec{}} {
+		var b bytes.Buffer
+		err := codec.Encode(&b, a)
+		assert.Nil(t, err, codec.ContentType())
+
+		var decoded Account
+		err = codec.Decode(&b, &decoded)
+		assert.Nil(t, err, codec.ContentType())
+		assert.Equal(t, a, decoded, codec.ContentType())
+	}
+}
+
+func Test_MsgpackCodec_Encode(t *testing.T) {
+	var b bytes.Buffer
+	err := msgpackCodec{}.Encode(&b, map[string]interface{}{"a": 1, "b": []interface{}{true, -1, 1000}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x93, 0xc3, 0xff, 0xcd, 0x03, 0xe8}, b.Bytes())
+}
+
+func Test_Codecs_Should_Reject_Invalid_Data(t *testing.T) {
+	var v interface{}
+
+	assert.NotNil(t, msgpackCodec{}.Decode(bytes.NewReader([]byte{0xdb, 0xff, 0xff, 0xff, 0xff, 'a'}), &v))
+}
+
+func Test_Codecs_Should_Reject_Bodies_Nested_Too_Deep(t *testing.T) {
+	var deep, shallow interface{} = "x", "x"
+	for i := 0; i < MaxBodyDepth+1; i++ {
+		deep = map[string]interface{}{"a": []interface{}{deep}}
+	}
+	for i := 0; i < MaxBodyDepth/2; i++ {
+		shallow = map[string]interface{}{"a": []interface{}{shallow}}
+	}
+
+	for _, codec := range []Codec{jsonCodec{}, msgpackCodec{}} {
+		var b bytes.Buffer
+		assert.Nil(t, codec.Encode(&b, deep), codec.ContentType())
+
+		var v interface{}
+		assert.NotNil(t, codec.Decode(&b, &v), codec.ContentType())
+
+		b.Reset()
+		assert.Nil(t, codec.Encode(&b, shallow), codec.ContentType())
+		assert.Nil(t, codec.Decode(&b, &v), codec.ContentType())
+	}
+}
+
+func Test_MsgpackCodec_Should_Decode_Numbers_As_JSON_Does(t *testing.T) {
+	var b bytes.Buffer
+	assert.Nil(t, msgpackCodec{}.Encode(&b, map[string]interface{}{"custom": map[string]interface{}{"seats": 3, "tags": []interface{}{1, "a"}}}))
+
+	var a Account
+	assert.Nil(t, msgpackCodec{}.Decode(&b, &a))
+	assert.Equal(t, map[string]interface{}{"seats": 3.0, "tags": []interface{}{1.0, "a"}}, a.Custom)
+}
+
+func Test_MsgpackCodec_Should_Write_Only_The_Projected_Fields(t *testing.T) {
+	var b bytes.Buffer
+	err := msgpackCodec{}.Encode(&b, Projection{Fields: []string{"name"}, Value: []*Account{{AccountID: "1", Name: "a"}}})
+	assert.Nil(t, err)
+
+	var v interface{}
+	assert.Nil(t, msgpackCodec{}.Decode(&b, &v))
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "a"}}, v)
+}
+
+func Test_MakeHTTPHandler_Should_Negotiate_The_Media_Types(t *testing.T) {
+	h := MakeHTTPHandler(log.NewNopLogger(), Endpoints{
+		GetByID: func(ctx context.Context, request interface{}) (interface{}, error) {
+			return &Account{AccountID: "1"}, nil
+		},
+	})
+
+	r := httptest.NewRequest("GET", "/accounts/1", nil)
+	r.Header.Set("Accept", "application/msgpack")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var a Account
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/msgpack", w.Header().Get("Content-Type"))
+	assert.Nil(t, msgpackCodec{}.Decode(w.Body, &a))
+	assert.Equal(t, "1", a.AccountID)
+
+	r = httptest.NewRequest("GET", "/accounts/1", nil)
+	r.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+
+	r = httptest.NewRequest("POST", "/accounts/", bytes.NewBufferString("<account/>"))
+	r.Header.Set("Content-Type", "application/xml")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	r = httptest.NewRequest("POST", "/accounts/", bytes.NewBufferString(`{"name": "`+strings.Repeat("a", MaxBodySize)+`"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
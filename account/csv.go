@@ -0,0 +1,113 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidCSVRecord is used when a CSV record does not match its header
+var ErrInvalidCSVRecord = errors.New("csv record does not match header")
+
+// CSVHeader lists the columns used when Accounts are written as CSV, new
+// columns are appended so that existing ones keep their position
+var CSVHeader = []string{"account_id", "status", "email", "email_verified", "version", "updated_at", "parent_id", "merged_into", "labels", "annotations", "tenant_id", "custom", "name", "created_at"}
+
+// MarshalCSV returns the Account as a CSV record, in CSVHeader order
+func (a Account) MarshalCSV() []string {
+	var version string
+	if a.Version > 0 {
+		version = strconv.Itoa(a.Version)
+	}
+
+	return []string{a.AccountID, a.Status, a.Email, strconv.FormatBool(a.EmailVerified), version, marshalCSVTime(a.UpdatedAt), a.ParentID, a.MergedInto,
+		marshalCSVMap(a.Labels), marshalCSVMap(a.Annotations), a.TenantID, marshalCSVMap(a.Custom), a.Name, marshalCSVTime(a.CreatedAt)}
+}
+
+func marshalCSVTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func unmarshalCSVTime(column string, t **time.Time) error {
+	if len(column) == 0 {
+		return nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, column)
+	*t = &parsed
+	return err
+}
+
+// marshalCSVMap writes a map as a JSON object, an empty map as an empty column
+func marshalCSVMap(m interface{}) string {
+	if v := reflect.ValueOf(m); v.Len() == 0 {
+		return ""
+	}
+
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+func unmarshalCSVMap(column string, m interface{}) error {
+	if len(column) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal([]byte(column), m)
+}
+
+// UnmarshalCSV fills the Account from a CSV record, columns are matched by
+// header name and unknown ones are ignored
+func (a *Account) UnmarshalCSV(header, record []string) (err error) {
+	if len(header) != len(record) {
+		return ErrInvalidCSVRecord
+	}
+
+	for i, column := range header {
+		switch column {
+		case "account_id":
+			a.AccountID = record[i]
+		case "parent_id":
+			a.ParentID = record[i]
+		case "merged_into":
+			a.MergedInto = record[i]
+		case "labels":
+			err = unmarshalCSVMap(record[i], &a.Labels)
+		case "annotations":
+			err = unmarshalCSVMap(record[i], &a.Annotations)
+		case "tenant_id":
+			a.TenantID = record[i]
+		case "custom":
+			err = unmarshalCSVMap(record[i], &a.Custom)
+		case "name":
+			a.Name = record[i]
+		case "status":
+			a.Status = record[i]
+		case "email":
+			a.Email = record[i]
+		case "email_verified":
+			if len(record[i]) > 0 {
+				a.EmailVerified, err = strconv.ParseBool(record[i])
+			}
+		case "version":
+			if len(record[i]) > 0 {
+				a.Version, err = strconv.Atoi(record[i])
+			}
+		case "updated_at":
+			err = unmarshalCSVTime(record[i], &a.UpdatedAt)
+		case "created_at":
+			err = unmarshalCSVTime(record[i], &a.CreatedAt)
+		}
+		if err != nil {
+			return ErrInvalidCSVRecord
+		}
+	}
+
+	return nil
+}
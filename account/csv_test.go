@@ -0,0 +1,37 @@
+package account
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UnmarshalCSV(t *testing.T) {
+	var a Account
+
+	err := a.UnmarshalCSV([]string{"unknown", "account_id", "status"}, []string{"x", "1", StatusClosed})
+
+	assert.Nil(t, err)
+	assert.Equal(t, Account{AccountID: "1", Status: StatusClosed}, a)
+}
+
+func Test_UnmarshalCSV_Should_Return_ErrInvalidCSVRecord_When_Record_Does_Not_Match_Header(t *testing.T) {
+	var a Account
+
+	err := a.UnmarshalCSV(CSVHeader, []string{"1"})
+
+	assert.Equal(t, ErrInvalidCSVRecord, err)
+}
+
+func Test_MarshalCSV_Should_Round_Trip(t *testing.T) {
+	at := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	a := Account{AccountID: "1", Status: StatusActive, Email: "a@example.com", EmailVerified: true, Version: 3, CreatedAt: &at, UpdatedAt: &at, ParentID: "2", Name: "Acme, Inc.",
+		Labels: map[string]string{"tier": "gold"}, Annotations: map[string]string{"note": "a, \"quoted\" note"}}
+
+	var b Account
+	err := b.UnmarshalCSV(CSVHeader, a.MarshalCSV())
+
+	assert.Nil(t, err)
+	assert.Equal(t, a, b)
+}
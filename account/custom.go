@@ -0,0 +1,51 @@
+package account
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrInvalidCustomFields is used when the custom fields of an Account do not match the schema of its tenant
+var ErrInvalidCustomFields = errors.New("custom fields do not match the schema of the tenant")
+
+// ErrInvalidCustomFilter is used when filtering on a custom field the schema of the tenant does not index
+var ErrInvalidCustomFilter = errors.New("invalid custom field filter")
+
+// Schemas validates the custom fields of Accounts against the schema of their tenant
+type Schemas interface {
+	// ValidateCustom returns ErrInvalidCustomFields when the custom fields do not match the schema of the tenant
+	ValidateCustom(ctx context.Context, tenantID string, custom map[string]interface{}) error
+	// CustomFilter converts the string values of a filter to the types the schema of the tenant declares,
+	// it returns ErrInvalidCustomFilter when a field is not indexed
+	CustomFilter(ctx context.Context, tenantID string, filter map[string]interface{}) (map[string]interface{}, error)
+}
+
+// ServiceSchemas sets the Schemas the custom fields are validated against,
+// without it custom fields are not checked and can not be filtered on
+func ServiceSchemas(schemas Schemas) ServiceOption {
+	return func(s *service) { s.schemas = schemas }
+}
+
+// resolveCustom converts the custom fields filter to the types of the schema of the only tenant filtered on
+func (s service) resolveCustom(ctx context.Context, filter Filter) (Filter, error) {
+	if len(filter.Custom) == 0 {
+		return filter, nil
+	}
+	if len(filter.TenantIDs) != 1 {
+		return filter, ErrInvalidCustomFilter
+	}
+
+	custom, err := s.schemas.CustomFilter(ctx, filter.TenantIDs[0], filter.Custom)
+	filter.Custom = custom
+	return filter, err
+}
+
+type nopSchemas struct{}
+
+func (nopSchemas) ValidateCustom(ctx context.Context, tenantID string, custom map[string]interface{}) error {
+	return nil
+}
+
+func (nopSchemas) CustomFilter(ctx context.Context, tenantID string, filter map[string]interface{}) (map[string]interface{}, error) {
+	return nil, ErrInvalidCustomFilter
+}
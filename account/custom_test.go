@@ -0,0 +1,65 @@
+package account
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubSchemas only accepts the "plan" custom field, which is indexed
+type stubSchemas struct{}
+
+func (stubSchemas) ValidateCustom(ctx context.Context, tenantID string, custom map[string]interface{}) error {
+	for field := range custom {
+		if field != "plan" {
+			return ErrInvalidCustomFields
+		}
+	}
+	return nil
+}
+
+func (stubSchemas) CustomFilter(ctx context.Context, tenantID string, filter map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := filter["plan"]; !ok || len(filter) > 1 {
+		return nil, ErrInvalidCustomFilter
+	}
+	return map[string]interface{}{"plan": tenantID + ":" + filter["plan"].(string)}, nil
+}
+
+func Test_CreateAccount_Should_Return_ErrInvalidCustomFields(t *testing.T) {
+	svc := NewService(new(mockedAccountRepository), ServiceSchemas(stubSchemas{}))
+	_, err := svc.CreateAccount(context.Background(), Account{TenantID: "t1", Custom: map[string]interface{}{"seats": 3}})
+
+	assert.Equal(t, ErrInvalidCustomFields, err)
+}
+
+func Test_GetAccounts_Should_Convert_The_Custom_Filter(t *testing.T) {
+	p := Pagination{Size: 100}
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccounts", Filter{TenantIDs: []string{"t1"}, Custom: map[string]interface{}{"plan": "t1:pro"}}, p).Return([]*Account{}, nil)
+
+	svc := NewService(fakeRepo, ServiceSchemas(stubSchemas{}))
+	_, err := svc.GetAccounts(context.Background(), Filter{TenantIDs: []string{"t1"}, Custom: map[string]interface{}{"plan": "pro"}}, p)
+
+	assert.Nil(t, err)
+	fakeRepo.AssertExpectations(t)
+}
+
+func Test_GetAccounts_Should_Return_ErrInvalidCustomFilter(t *testing.T) {
+	var flagtests = []struct {
+		options []ServiceOption
+		filter  Filter
+	}{
+		{nil, Filter{TenantIDs: []string{"t1"}, Custom: map[string]interface{}{"plan": "pro"}}},
+		{[]ServiceOption{ServiceSchemas(stubSchemas{})}, Filter{Custom: map[string]interface{}{"plan": "pro"}}},
+		{[]ServiceOption{ServiceSchemas(stubSchemas{})}, Filter{TenantIDs: []string{"t1", "t2"}, Custom: map[string]interface{}{"plan": "pro"}}},
+		{[]ServiceOption{ServiceSchemas(stubSchemas{})}, Filter{TenantIDs: []string{"t1"}, Custom: map[string]interface{}{"seats": "3"}}},
+	}
+
+	for _, tt := range flagtests {
+		svc := NewService(new(mockedAccountRepository), tt.options...)
+		_, err := svc.GetAccounts(context.Background(), tt.filter, Pagination{Size: 100})
+
+		assert.Equal(t, ErrInvalidCustomFilter, err)
+	}
+}
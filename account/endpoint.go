@@ -2,25 +2,62 @@ package account
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
 )
 
 // Endpoints represent the order service endpoints
 type Endpoints struct {
-	GetByID endpoint.Endpoint
-	GetList endpoint.Endpoint
-	Update  endpoint.Endpoint
-	Create  endpoint.Endpoint
-	Delete  endpoint.Endpoint
+	GetByID    endpoint.Endpoint
+	GetList    endpoint.Endpoint
+	Export     endpoint.Endpoint
+	Update     endpoint.Endpoint
+	Create     endpoint.Endpoint
+	Delete     endpoint.Endpoint
+	History    endpoint.Endpoint
+	GetVersion endpoint.Endpoint
+	Revert     endpoint.Endpoint
+
+	Children    endpoint.Endpoint
+	Descendants endpoint.Endpoint
+	Merge       endpoint.Endpoint
+	Search      endpoint.Endpoint
+	Stats       endpoint.Endpoint
 }
 
+// responseFields are read for every response of GET /accounts/{id}: merged_into
+// for the redirection of a merged Account, version and updated_at for its
+// validators, see notModified
+var responseFields = []string{"merged_into", "version", "updated_at"}
+
 // MakeGetAccountEndpoint returns an endpoint used for getting an account
 func MakeGetAccountEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(GetAccountRequest)
 
-		return s.GetAccount(ctx, req.ID)
+		if !req.AsOf.IsZero() {
+			a, err := s.GetAccountAsOf(ctx, req.ID, req.AsOf)
+			return project(a, err, req.Fields)
+		}
+
+		if len(req.Fields) == 0 {
+			return s.GetAccount(ctx, req.ID)
+		}
+
+		// the response keeps the fields asked for, but the fields it is
+		// answered with are read too
+		fields := append(append([]string{}, req.Fields...), responseFields...)
+		filter := Filter{IDs: []string{req.ID}, Fields: fields}
+		accounts, err := s.GetAccounts(ctx, filter, Pagination{Size: 1})
+		if err == nil && len(accounts) == 0 {
+			err = ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return project(accounts[0], nil, req.Fields)
 	}
 }
 
@@ -29,7 +66,42 @@ func MakeGetAccountsEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(GetAccountsRequest)
 
-		return s.GetAccounts(ctx, req.Filter, req.Pagination)
+		page, err := s.GetAccountsPage(ctx, req.Filter, req.Pagination)
+		if err != nil {
+			return nil, err
+		}
+
+		items, _ := project(page.Items, nil, req.Fields)
+		return GetAccountsResponse{
+			Items: items,
+			Count: len(page.Items),
+			Total: page.Total,
+			Page:  page.Page,
+			Size:  page.Size,
+		}, nil
+	}
+}
+
+// project restricts a response to the fields asked for, if any
+func project(response interface{}, err error, fields []string) (interface{}, error) {
+	if err != nil || len(fields) == 0 {
+		return response, err
+	}
+
+	return Projection{Fields: fields, Value: response}, nil
+}
+
+// MakeExportAccountsEndpoint returns an endpoint used for streaming accounts
+func MakeExportAccountsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ExportAccountsRequest)
+
+		return ExportAccountsResponse{
+			Format: req.Format,
+			Each: func(fn func(*Account) error) error {
+				return s.ExportAccounts(ctx, req.Filter, fn)
+			},
+		}, nil
 	}
 }
 
@@ -60,9 +132,85 @@ func MakeDeleteAccountEndpoint(s Service) endpoint.Endpoint {
 	}
 }
 
+// MakeGetAccountHistoryEndpoint returns an endpoint used for getting the audit trail of an account
+func MakeGetAccountHistoryEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetAccountHistoryRequest)
+
+		return s.GetAccountHistory(ctx, req.ID, req.Pagination)
+	}
+}
+
+// MakeGetAccountVersionEndpoint returns an endpoint used for getting a previous version of an account
+func MakeGetAccountVersionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetAccountVersionRequest)
+
+		return s.GetAccountVersion(ctx, req.ID, req.Version)
+	}
+}
+
+// MakeRevertAccountEndpoint returns an endpoint used for reverting an account to a previous version
+func MakeRevertAccountEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RevertAccountRequest)
+
+		return nil, s.RevertAccount(ctx, req.ID, req.Version)
+	}
+}
+
+// MakeGetChildrenEndpoint returns an endpoint used for getting the direct children of an account
+func MakeGetChildrenEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetChildrenRequest)
+
+		return s.GetChildren(ctx, req.ID, req.Pagination)
+	}
+}
+
+// MakeGetDescendantsEndpoint returns an endpoint used for getting the descendants of an account
+func MakeGetDescendantsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetDescendantsRequest)
+
+		return s.GetDescendants(ctx, req.ID, req.Depth)
+	}
+}
+
+// MakeSearchAccountsEndpoint returns an endpoint used for searching accounts by name or email
+func MakeSearchAccountsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SearchAccountsRequest)
+
+		return s.SearchAccounts(ctx, req.Query, req.Limit)
+	}
+}
+
+// MakeGetAccountStatsEndpoint returns an endpoint used for counting accounts by group
+func MakeGetAccountStatsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetAccountStatsRequest)
+
+		return s.GetAccountStats(ctx, req.Filter, req.GroupBy)
+	}
+}
+
+// MakeMergeAccountsEndpoint returns an endpoint used for merging an account into another one
+func MakeMergeAccountsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(MergeAccountsRequest)
+
+		return s.MergeAccounts(ctx, req.ID, req.SourceID, req.Rules)
+	}
+}
+
 // GetAccountRequest represents the request parameters used for getting one Account
 type GetAccountRequest struct {
 	ID string `json:"id"`
+	// AsOf, when set, asks for the Account as it was at that time
+	AsOf time.Time `json:"as_of"`
+	// Fields, when set, are the only fields returned
+	Fields []string `json:"fields"`
 }
 
 // GetAccountsRequest represents the request parameters used for getting Accounts
@@ -71,6 +219,37 @@ type GetAccountsRequest struct {
 	Pagination
 }
 
+// GetAccountsResponse is the envelope of a page of Accounts. Count is the number
+// of items of the page, Total is only set when it was asked for, and the links
+// are set by the transport.
+type GetAccountsResponse struct {
+	Items interface{}       `json:"items"`
+	Count int               `json:"count"`
+	Total *int              `json:"total,omitempty"`
+	Page  int               `json:"page"`
+	Size  int               `json:"size"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+// ExportAccountsRequest represents the request parameters used for exporting Accounts
+type ExportAccountsRequest struct {
+	Filter
+	Format string
+}
+
+// ExportAccountsResponse holds the accounts to stream, they are only read from
+// the repository when Each is called
+type ExportAccountsResponse struct {
+	Format string
+	Each   func(fn func(*Account) error) error
+}
+
+// Export formats
+const (
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatCSV    = "csv"
+)
+
 // UpdateAccountRequest represents the request parameters used for updating Account
 type UpdateAccountRequest struct {
 	Account
@@ -86,16 +265,90 @@ type DeleteAccountRequest struct {
 	ID string `json:"id"`
 }
 
+// GetAccountHistoryRequest represents the request parameters used for getting the audit trail of an Account
+type GetAccountHistoryRequest struct {
+	ID string `json:"id"`
+	Pagination
+}
+
+// GetAccountVersionRequest represents the request parameters used for getting a version of an Account
+type GetAccountVersionRequest struct {
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+}
+
+// RevertAccountRequest represents the request parameters used for reverting an Account
+type RevertAccountRequest struct {
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+}
+
+// GetChildrenRequest represents the request parameters used for getting the children of an Account
+type GetChildrenRequest struct {
+	ID string `json:"id"`
+	Pagination
+}
+
+// GetAccountStatsRequest represents the request parameters used for counting Accounts
+type GetAccountStatsRequest struct {
+	Filter  Filter
+	GroupBy StatsGroup
+}
+
+// SearchAccountsRequest represents the request parameters used for searching Accounts
+type SearchAccountsRequest struct {
+	Query string
+	Limit int
+}
+
+// GetDescendantsRequest represents the request parameters used for getting the descendants of an Account
+type GetDescendantsRequest struct {
+	ID    string `json:"id"`
+	Depth int    `json:"depth"`
+}
+
+// MergeAccountsRequest represents the request parameters used for merging the source Account into the one of ID
+type MergeAccountsRequest struct {
+	ID       string     `json:"id"`
+	SourceID string     `json:"source_id"`
+	Rules    MergeRules `json:"rules"`
+}
+
 // Pagination ...
 type Pagination struct {
 	Size int
+	// Page is the index of the page, from 0
 	Page int
+	// Count asks for the total of the matching Accounts, counting them has a cost
+	Count bool
 }
 
 // DefaultPaginationSize ...
 const DefaultPaginationSize int = 100
 
+// MaxPaginationSize is the largest page that can be asked for
+const MaxPaginationSize int = 1000
+
+// AccountsPage is a page of Accounts, with their total when it was counted
+type AccountsPage struct {
+	Items []*Account
+	Total *int
+	Pagination
+}
+
 // Filter ...
 type Filter struct {
-	IDs []string
+	IDs       []string
+	Emails    []string
+	ParentIDs []string
+	// Subtree, when set, restricts the Accounts to this one and all its descendants
+	Subtree string
+	// Selector, when set, restricts the Accounts to the ones whose labels match
+	Selector  Selector
+	TenantIDs []string
+	// Custom, when set, restricts the Accounts to the ones whose custom fields
+	// have the given values; it requires a single tenant, whose schema indexes the fields
+	Custom map[string]interface{}
+	// Fields, when set, are the only fields of the Accounts read
+	Fields []string
 }
@@ -2,6 +2,7 @@ package account
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -24,7 +25,7 @@ func Test_MakeGetAccountsEndpoint(t *testing.T) {
 	req := GetAccountsRequest{Filter: f, Pagination: p}
 
 	fakeService := new(mockedService)
-	fakeService.On("GetAccounts", f, p).Return([]*Account{&(Account{})}, nil)
+	fakeService.On("GetAccountsPage", f, p).Return(&AccountsPage{Items: []*Account{&(Account{})}, Pagination: p}, nil)
 
 	endpoint := MakeGetAccountsEndpoint(fakeService)
 	a, err := endpoint(nil, req)
@@ -32,6 +33,32 @@ func Test_MakeGetAccountsEndpoint(t *testing.T) {
 	assert.NotNil(t, endpoint)
 	assert.Nil(t, err)
 	assert.NotNil(t, a)
+	assert.Equal(t, 1, a.(GetAccountsResponse).Count)
+	assert.Nil(t, a.(GetAccountsResponse).Total)
+}
+
+func Test_MakeExportAccountsEndpoint(t *testing.T) {
+	f := Filter{IDs: []string{"1", "2"}}
+	req := ExportAccountsRequest{Filter: f, Format: ExportFormatCSV}
+
+	fakeService := new(mockedService)
+	fakeService.On("ExportAccounts", f).Return([]*Account{&(Account{AccountID: "1"}), &(Account{AccountID: "2"})}, nil)
+
+	endpoint := MakeExportAccountsEndpoint(fakeService)
+	res, err := endpoint(nil, req)
+
+	assert.NotNil(t, endpoint)
+	assert.Nil(t, err)
+
+	var ids []string
+	err = res.(ExportAccountsResponse).Each(func(a *Account) error {
+		ids = append(ids, a.AccountID)
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, ExportFormatCSV, res.(ExportAccountsResponse).Format)
+	assert.Equal(t, []string{"1", "2"}, ids)
 }
 
 func Test_MakeUpdateAccountEndpoint(t *testing.T) {
@@ -65,3 +92,49 @@ func Test_MakeDeleteAccountEndpoint(t *testing.T) {
 	assert.NotNil(t, endpoint)
 	assert.Nil(t, err)
 }
+
+func Test_MakeGetAccountHistoryEndpoint(t *testing.T) {
+	p := Pagination{Size: 10}
+	fakeService := new(mockedService)
+	fakeService.On("GetAccountHistory", "1", p).Return([]*AuditEntry{{AccountID: "1"}}, nil)
+
+	endpoint := MakeGetAccountHistoryEndpoint(fakeService)
+	h, err := endpoint(nil, GetAccountHistoryRequest{ID: "1", Pagination: p})
+
+	assert.Nil(t, err)
+	assert.Len(t, h, 1)
+}
+
+func Test_MakeGetAccountEndpoint_Should_Use_As_Of(t *testing.T) {
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	fakeService := new(mockedService)
+	fakeService.On("GetAccountAsOf", "1", at).Return(&Account{AccountID: "1", Version: 2}, nil)
+
+	endpoint := MakeGetAccountEndpoint(fakeService)
+	a, err := endpoint(nil, GetAccountRequest{ID: "1", AsOf: at})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, a.(*Account).Version)
+}
+
+func Test_MakeGetAccountVersionEndpoint(t *testing.T) {
+	fakeService := new(mockedService)
+	fakeService.On("GetAccountVersion", "1", 2).Return(&Account{AccountID: "1", Version: 2}, nil)
+
+	endpoint := MakeGetAccountVersionEndpoint(fakeService)
+	a, err := endpoint(nil, GetAccountVersionRequest{ID: "1", Version: 2})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, a.(*Account).Version)
+}
+
+func Test_MakeRevertAccountEndpoint(t *testing.T) {
+	fakeService := new(mockedService)
+	fakeService.On("RevertAccount", "1", 2).Return(nil)
+
+	endpoint := MakeRevertAccountEndpoint(fakeService)
+	_, err := endpoint(nil, RevertAccountRequest{ID: "1", Version: 2})
+
+	assert.Nil(t, err)
+	fakeService.AssertExpectations(t)
+}
@@ -0,0 +1,122 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Event types
+const (
+	EventAccountCreated = "account.created"
+	EventAccountUpdated = "account.updated"
+	EventAccountDeleted = "account.deleted"
+)
+
+// ErrUnknownEvent is used when an EventEnvelope holds an unknown event type
+var ErrUnknownEvent = errors.New("unknown event type")
+
+// Event is a change that happened to an Account
+type Event interface {
+	Envelope() EventEnvelope
+}
+
+// EventEnvelope is the serialisable form shared by every Account event
+type EventEnvelope struct {
+	Type       string    `json:"type" bson:"type"`
+	AccountID  string    `json:"account_id" bson:"account_id"`
+	OccurredAt time.Time `json:"occurred_at" bson:"occurred_at"`
+	Before     *Account  `json:"before,omitempty" bson:"before,omitempty"`
+	After      *Account  `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// AccountCreated is published once an Account has been created
+type AccountCreated struct {
+	OccurredAt time.Time
+	After      Account
+}
+
+// Envelope returns the serialisable form of the event
+func (e AccountCreated) Envelope() EventEnvelope {
+	return EventEnvelope{
+		Type:       EventAccountCreated,
+		AccountID:  e.After.AccountID,
+		OccurredAt: e.OccurredAt,
+		After:      &e.After,
+	}
+}
+
+// AccountUpdated is published once an Account has been updated
+type AccountUpdated struct {
+	OccurredAt time.Time
+	Before     Account
+	After      Account
+}
+
+// Envelope returns the serialisable form of the event
+func (e AccountUpdated) Envelope() EventEnvelope {
+	return EventEnvelope{
+		Type:       EventAccountUpdated,
+		AccountID:  e.After.AccountID,
+		OccurredAt: e.OccurredAt,
+		Before:     &e.Before,
+		After:      &e.After,
+	}
+}
+
+// AccountDeleted is published once an Account has been deleted
+type AccountDeleted struct {
+	OccurredAt time.Time
+	Before     Account
+}
+
+// Envelope returns the serialisable form of the event
+func (e AccountDeleted) Envelope() EventEnvelope {
+	return EventEnvelope{
+		Type:       EventAccountDeleted,
+		AccountID:  e.Before.AccountID,
+		OccurredAt: e.OccurredAt,
+		Before:     &e.Before,
+	}
+}
+
+// Event returns the typed event held by the envelope
+func (e EventEnvelope) Event() (Event, error) {
+	var before, after Account
+	if e.Before != nil {
+		before = *e.Before
+	}
+	if e.After != nil {
+		after = *e.After
+	}
+
+	switch e.Type {
+	case EventAccountCreated:
+		return AccountCreated{OccurredAt: e.OccurredAt, After: after}, nil
+	case EventAccountUpdated:
+		return AccountUpdated{OccurredAt: e.OccurredAt, Before: before, After: after}, nil
+	case EventAccountDeleted:
+		return AccountDeleted{OccurredAt: e.OccurredAt, Before: before}, nil
+	}
+
+	return nil, ErrUnknownEvent
+}
+
+// Publisher is notified of every successful write made by the Service
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// PublisherFunc is an adapter to use an ordinary function as a Publisher
+type PublisherFunc func(ctx context.Context, e Event) error
+
+// Publish calls f(ctx, e)
+func (f PublisherFunc) Publish(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(ctx context.Context, e Event) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package account
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EventEnvelope_Event_Should_Return_The_Typed_Event(t *testing.T) {
+	at := time.Now()
+	var flagtests = []Event{
+		AccountCreated{OccurredAt: at, After: Account{AccountID: "1"}},
+		AccountUpdated{OccurredAt: at, Before: Account{AccountID: "1"}, After: Account{AccountID: "1"}},
+		AccountDeleted{OccurredAt: at, Before: Account{AccountID: "1"}},
+	}
+
+	for _, tt := range flagtests {
+		e, err := tt.Envelope().Event()
+
+		assert.Nil(t, err)
+		assert.Equal(t, tt, e)
+	}
+}
+
+func Test_EventEnvelope_Event_Should_Return_ErrUnknownEvent(t *testing.T) {
+	_, err := EventEnvelope{Type: "unknown"}.Event()
+
+	assert.Equal(t, ErrUnknownEvent, err)
+}
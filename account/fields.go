@@ -0,0 +1,122 @@
+package account
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrInvalidFields is used when a sparse fieldset names an unknown field
+var ErrInvalidFields = errors.New("invalid fields")
+
+// accountFieldNames are the json names of the fields of an Account, they are also their names in storage
+var accountFieldNames = func() map[string]bool {
+	names := map[string]bool{}
+
+	t := reflect.TypeOf(Account{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if len(name) > 0 && name != "-" {
+			names[name] = true
+		}
+	}
+
+	return names
+}()
+
+// ParseFields parses a sparse fieldset, such as "account_id,name,status"
+func ParseFields(s string) ([]string, error) {
+	var fields []string
+
+	seen := map[string]bool{}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if !accountFieldNames[field] {
+			return nil, ErrInvalidFields
+		}
+
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+
+	return fields, nil
+}
+
+// Projection is a response holding an Account, or a list of Accounts, of
+// which only the Fields are written as JSON
+type Projection struct {
+	Fields []string
+	Value  interface{}
+}
+
+// MarshalJSON writes the Value with only the Fields of its Accounts
+func (p Projection) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(p.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return json.Marshal(p.project(v))
+	case []interface{}:
+		for i, a := range v {
+			if m, ok := a.(map[string]interface{}); ok {
+				v[i] = p.project(m)
+			}
+		}
+		return json.Marshal(v)
+	}
+
+	return b, nil
+}
+
+// EncodeMsgpack writes the Value with only the Fields of its Accounts, see msgpackCodec
+func (p Projection) EncodeMsgpack(enc *msgpack.Encoder) error {
+	var b bytes.Buffer
+	if err := newMsgpackEncoder(&b).Encode(p.Value); err != nil {
+		return err
+	}
+
+	var v interface{}
+	if err := msgpack.Unmarshal(b.Bytes(), &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return enc.Encode(p.project(v))
+	case []interface{}:
+		for i, a := range v {
+			if m, ok := a.(map[string]interface{}); ok {
+				v[i] = p.project(m)
+			}
+		}
+	}
+
+	return enc.Encode(v)
+}
+
+func (p Projection) project(m map[string]interface{}) map[string]interface{} {
+	projected := map[string]interface{}{}
+	for _, field := range p.Fields {
+		if value, ok := m[field]; ok {
+			projected[field] = value
+		}
+	}
+
+	return projected
+}
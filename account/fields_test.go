@@ -0,0 +1,62 @@
+package account
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseFields(t *testing.T) {
+	var flagtests = []struct {
+		in  string
+		out []string
+		err error
+	}{
+		{"account_id,name,status", []string{"account_id", "name", "status"}, nil},
+		{"name, email,name", []string{"name", "email"}, nil},
+		{"custom,labels", []string{"custom", "labels"}, nil},
+		{"name,password", nil, ErrInvalidFields},
+		{"name,", nil, ErrInvalidFields},
+	}
+
+	for _, tt := range flagtests {
+		fields, err := ParseFields(tt.in)
+
+		assert.Equal(t, tt.err, err, tt.in)
+		assert.Equal(t, tt.out, fields, tt.in)
+	}
+}
+
+func Test_Projection_MarshalJSON(t *testing.T) {
+	a := &Account{AccountID: "1", Name: "Acme", Status: StatusActive, Version: 12345678901234567, Custom: map[string]interface{}{"plan": "pro"}}
+
+	b, err := json.Marshal(Projection{Fields: []string{"account_id", "name", "version", "email"}, Value: a})
+
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"account_id": "1", "name": "Acme", "version": 12345678901234567}`, string(b))
+
+	b, err = json.Marshal(Projection{Fields: []string{"status"}, Value: []*Account{a, {AccountID: "2"}}})
+
+	assert.Nil(t, err)
+	assert.JSONEq(t, `[{"status": "active"}, {}]`, string(b))
+}
+
+func Test_MakeGetAccountEndpoint_Should_Read_Only_The_Fields(t *testing.T) {
+	fakeService := new(mockedService)
+	fields := []string{"name", "merged_into", "version", "updated_at"}
+	fakeService.On("GetAccounts", Filter{IDs: []string{"1"}, Fields: fields}, Pagination{Size: 1}).
+		Return([]*Account{{AccountID: "1", Name: "Acme", Version: 4}}, nil)
+	fakeService.On("GetAccounts", Filter{IDs: []string{"2"}, Fields: fields}, Pagination{Size: 1}).
+		Return([]*Account{}, nil)
+
+	endpoint := MakeGetAccountEndpoint(fakeService)
+	a, err := endpoint(nil, GetAccountRequest{ID: "1", Fields: []string{"name"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, Projection{Fields: []string{"name"}, Value: &Account{AccountID: "1", Name: "Acme", Version: 4}}, a)
+
+	_, err = endpoint(nil, GetAccountRequest{ID: "2", Fields: []string{"name"}})
+
+	assert.Equal(t, ErrNotFound, err)
+}
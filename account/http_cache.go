@@ -0,0 +1,94 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+// Routes of the Account handler a Cache-Control policy can be set for
+const (
+	// RouteAccount is GET /accounts/{id}
+	RouteAccount = "account"
+	// RouteAccounts are the lists: GET /accounts/, /accounts/search, /accounts/{id}/children and /accounts/{id}/descendants
+	RouteAccounts = "accounts"
+	// RouteStats is GET /accounts/stats
+	RouteStats = "stats"
+	// RouteHistory are GET /accounts/{id}/history and /accounts/{id}/versions/{version}
+	RouteHistory = "history"
+)
+
+// HandlerOption configures the handler returned by MakeHTTPHandler
+type HandlerOption func(*handlerOptions)
+
+type handlerOptions struct {
+	policies map[string]string
+}
+
+// HandlerCacheControl sets the Cache-Control header of the successful responses
+// of a route, such as "private, no-cache". The routes have none by default.
+func HandlerCacheControl(route, policy string) HandlerOption {
+	return func(o *handlerOptions) { o.policies[route] = policy }
+}
+
+// cacheControl returns the ServerOption setting the Cache-Control policy of a route, if it has one
+func (o handlerOptions) cacheControl(route string) []kithttp.ServerOption {
+	policy, ok := o.policies[route]
+	if !ok || len(policy) == 0 {
+		return nil
+	}
+
+	return []kithttp.ServerOption{kithttp.ServerAfter(kithttp.SetResponseHeader("Cache-Control", policy))}
+}
+
+type conditionalContextKey int
+
+const (
+	contextKeyIfNoneMatch conditionalContextKey = iota
+	contextKeyIfModifiedSince
+)
+
+// populateConditionalContext puts the conditional headers of a GET in the context
+func populateConditionalContext(ctx context.Context, r *http.Request) context.Context {
+	ctx = context.WithValue(ctx, contextKeyIfNoneMatch, r.Header.Get("If-None-Match"))
+	return context.WithValue(ctx, contextKeyIfModifiedSince, r.Header.Get("If-Modified-Since"))
+}
+
+// accountETag is a weak validator, the representations of a version of an Account
+// differ by their media type and fields but are semantically the same
+func accountETag(a *Account) string {
+	return fmt.Sprintf(`W/"%d"`, a.Version)
+}
+
+// notModified sets the validators of an Account and writes a 304 when the
+// request is conditional on them and they still match. If-Modified-Since is
+// ignored when If-None-Match is set.
+func notModified(ctx context.Context, w http.ResponseWriter, a *Account) bool {
+	etag := accountETag(a)
+	w.Header().Set("ETag", etag)
+	if a.UpdatedAt != nil {
+		w.Header().Set("Last-Modified", a.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+
+	match := false
+	if ifNoneMatch, _ := ctx.Value(contextKeyIfNoneMatch).(string); len(ifNoneMatch) > 0 {
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			tag = strings.TrimSpace(tag)
+			// the comparison of If-None-Match is weak
+			match = match || tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/")
+		}
+	} else if ifModifiedSince, _ := ctx.Value(contextKeyIfModifiedSince).(string); len(ifModifiedSince) > 0 && a.UpdatedAt != nil {
+		since, err := http.ParseTime(ifModifiedSince)
+		match = err == nil && !a.UpdatedAt.Truncate(time.Second).After(since)
+	}
+
+	if match {
+		w.Header().Add("Vary", "Accept")
+		w.WriteHeader(http.StatusNotModified)
+	}
+	return match
+}
@@ -0,0 +1,101 @@
+package account
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EncodeGetAccountResponse_Should_Answer_Conditional_Requests(t *testing.T) {
+	updated := time.Date(2019, 3, 4, 5, 6, 7, 500, time.UTC)
+	a := &Account{AccountID: "1", Version: 3, UpdatedAt: &updated}
+
+	var flagtests = []struct {
+		ifNoneMatch     string
+		ifModifiedSince string
+		expected        int
+	}{
+		{"", "", http.StatusOK},
+		{`W/"3"`, "", http.StatusNotModified},
+		{`"2", "3"`, "", http.StatusNotModified},
+		{"*", "", http.StatusNotModified},
+		{`W/"2"`, "Mon, 04 Mar 2019 05:06:07 GMT", http.StatusOK},
+		{"", "Mon, 04 Mar 2019 05:06:07 GMT", http.StatusNotModified},
+		{"", "Mon, 04 Mar 2019 05:06:06 GMT", http.StatusOK},
+		{"", "yesterday", http.StatusOK},
+	}
+
+	for _, tt := range flagtests {
+		r := httptest.NewRequest("GET", "/accounts/1", nil)
+		r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		r.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+		ctx := populateConditionalContext(context.Background(), r)
+
+		w := httptest.NewRecorder()
+		err := encodeGetAccountResponse(ctx, w, a)
+
+		assert.Nil(t, err)
+		assert.Equal(t, tt.expected, w.Code, tt)
+		assert.Equal(t, `W/"3"`, w.Header().Get("ETag"))
+		assert.Equal(t, "Mon, 04 Mar 2019 05:06:07 GMT", w.Header().Get("Last-Modified"))
+		if tt.expected == http.StatusNotModified {
+			assert.Empty(t, w.Body.String())
+		}
+	}
+}
+
+func Test_EncodeGetAccountResponse_Should_Answer_Conditional_Requests_On_A_Projection(t *testing.T) {
+	updated := time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC)
+	p := Projection{Fields: []string{"name"}, Value: &Account{AccountID: "1", Name: "Acme", Version: 3, UpdatedAt: &updated}}
+
+	r := httptest.NewRequest("GET", "/accounts/1?fields=name", nil)
+	w := httptest.NewRecorder()
+	assert.Nil(t, encodeGetAccountResponse(populateConditionalContext(context.Background(), r), w, p))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `W/"3"`, w.Header().Get("ETag"))
+	assert.JSONEq(t, `{"name": "Acme"}`, w.Body.String())
+
+	r.Header.Set("If-None-Match", `W/"3"`)
+	w = httptest.NewRecorder()
+	assert.Nil(t, encodeGetAccountResponse(populateConditionalContext(context.Background(), r), w, p))
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func Test_MakeHTTPHandler_Should_Set_The_Cache_Control_Of_A_Route(t *testing.T) {
+	h := MakeHTTPHandler(log.NewNopLogger(), Endpoints{
+		GetByID: func(ctx context.Context, request interface{}) (interface{}, error) {
+			if request.(GetAccountRequest).ID == "2" {
+				return nil, ErrNotFound
+			}
+			return &Account{AccountID: "1"}, nil
+		},
+		Stats: func(ctx context.Context, request interface{}) (interface{}, error) {
+			return &Stats{}, nil
+		},
+	}, HandlerCacheControl(RouteAccount, "private, no-cache"))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/accounts/1", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/accounts/2", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/accounts/stats", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Cache-Control"))
+}
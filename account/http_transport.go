@@ -2,10 +2,16 @@ package account
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	kithttp "github.com/go-kit/kit/transport/http"
@@ -16,24 +22,51 @@ import (
 // ErrInvalidBody thrown when the body of a request can not be parsed
 var ErrInvalidBody = errors.New("invalid body")
 
+// ErrInvalidAsOf thrown when the as_of query parameter is not an RFC 3339 time
+var ErrInvalidAsOf = errors.New("invalid as_of, expected an RFC 3339 time")
+
+// ErrNotAcceptable thrown when none of the media types of the Accept header can be produced
+var ErrNotAcceptable = errors.New("not acceptable")
+
+// HeaderRequestID is the header identifying a request, it is recorded in the audit trail
+const HeaderRequestID = "X-Request-ID"
+
 // MakeHTTPHandler returns all http handler for the Account service
-func MakeHTTPHandler(logger log.Logger, endpoints Endpoints) http.Handler {
+func MakeHTTPHandler(logger log.Logger, endpoints Endpoints, opts ...HandlerOption) http.Handler {
+	o := handlerOptions{policies: map[string]string{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	options := []kithttp.ServerOption{
 		kithttp.ServerErrorLogger(logger),
 		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(PopulateAuditContext),
+	}
+	// routeOptions are the options of a GET route, with its Cache-Control policy
+	routeOptions := func(route string, extra ...kithttp.ServerOption) []kithttp.ServerOption {
+		routeOptions := append(append([]kithttp.ServerOption{}, options...), extra...)
+		return append(routeOptions, o.cacheControl(route)...)
 	}
 
 	getAccountHandler := kithttp.NewServer(
 		endpoints.GetByID,
 		decodeGetAccountRequest,
-		encodeResponse,
-		options...,
+		encodeGetAccountResponse,
+		routeOptions(RouteAccount, kithttp.ServerBefore(populateConditionalContext))...,
 	)
 
 	getAccountsHandler := kithttp.NewServer(
 		endpoints.GetList,
 		decodeGetAccountsRequest,
-		encodeResponse,
+		encodeGetAccountsResponse,
+		routeOptions(RouteAccounts, kithttp.ServerBefore(kithttp.PopulateRequestContext))...,
+	)
+
+	exportAccountsHandler := kithttp.NewServer(
+		endpoints.Export,
+		decodeExportAccountsRequest,
+		encodeExportAccountsResponse,
 		options...,
 	)
 
@@ -58,39 +91,335 @@ func MakeHTTPHandler(logger log.Logger, endpoints Endpoints) http.Handler {
 		options...,
 	)
 
+	getAccountHistoryHandler := kithttp.NewServer(
+		endpoints.History,
+		decodeGetAccountHistoryRequest,
+		encodeResponse,
+		routeOptions(RouteHistory)...,
+	)
+
+	getAccountVersionHandler := kithttp.NewServer(
+		endpoints.GetVersion,
+		decodeGetAccountVersionRequest,
+		encodeResponse,
+		routeOptions(RouteHistory)...,
+	)
+
+	revertAccountHandler := kithttp.NewServer(
+		endpoints.Revert,
+		decodeRevertAccountRequest,
+		encodeResponse,
+		options...,
+	)
+
+	getChildrenHandler := kithttp.NewServer(
+		endpoints.Children,
+		decodeGetChildrenRequest,
+		encodeResponse,
+		routeOptions(RouteAccounts)...,
+	)
+
+	getDescendantsHandler := kithttp.NewServer(
+		endpoints.Descendants,
+		decodeGetDescendantsRequest,
+		encodeResponse,
+		routeOptions(RouteAccounts)...,
+	)
+
+	mergeAccountsHandler := kithttp.NewServer(
+		endpoints.Merge,
+		decodeMergeAccountsRequest,
+		encodeResponse,
+		options...,
+	)
+
+	searchAccountsHandler := kithttp.NewServer(
+		endpoints.Search,
+		decodeSearchAccountsRequest,
+		encodeResponse,
+		routeOptions(RouteAccounts)...,
+	)
+
+	getAccountStatsHandler := kithttp.NewServer(
+		endpoints.Stats,
+		decodeGetAccountStatsRequest,
+		encodeResponse,
+		routeOptions(RouteStats)...,
+	)
+
+	// the export negotiates its own formats
+	n := func(h http.Handler) http.Handler { return negotiate(DefaultCodecs, h) }
+
 	r := mux.NewRouter().PathPrefix("/accounts/").Subrouter().StrictSlash(true)
 
-	r.Handle("/", getAccountsHandler).Methods("GET")
-	r.Handle("/{id}", getAccountHandler).Methods("GET")
-	r.Handle("/{id}", updateAccountHandler).Methods("PATCH")
-	r.Handle("/", createAccountHandler).Methods("POST")
-	r.Handle("/{id}", deleteAccountHandler).Methods("DELETE")
+	r.Handle("/", n(getAccountsHandler)).Methods("GET")
+	r.Handle("/export", exportAccountsHandler).Methods("GET")
+	r.Handle("/search", n(searchAccountsHandler)).Methods("GET")
+	r.Handle("/stats", n(getAccountStatsHandler)).Methods("GET")
+	r.Handle("/{id}", n(getAccountHandler)).Methods("GET")
+	r.Handle("/{id}", n(updateAccountHandler)).Methods("PATCH")
+	r.Handle("/", n(createAccountHandler)).Methods("POST")
+	r.Handle("/{id}", n(deleteAccountHandler)).Methods("DELETE")
+	r.Handle("/{id}/history", n(getAccountHistoryHandler)).Methods("GET")
+	r.Handle("/{id}/versions/{version:[0-9]+}", n(getAccountVersionHandler)).Methods("GET")
+	r.Handle("/{id}/revert", n(revertAccountHandler)).Methods("POST")
+	r.Handle("/{id}/children", n(getChildrenHandler)).Methods("GET")
+	r.Handle("/{id}/descendants", n(getDescendantsHandler)).Methods("GET")
+	r.Handle("/{id}/merge", n(mergeAccountsHandler)).Methods("POST")
 
 	return r
 }
 
+// decodeGetAccountsRequest reads the Filter and the Pagination, the total is
+// only counted with count=true
 func decodeGetAccountsRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
-	// TODO : parse pagination filter properly
-	p := Pagination{Size: DefaultPaginationSize, Page: 0}
+	p := decodePagination(r)
+	p.Count, _ = strconv.ParseBool(r.URL.Query().Get("count"))
 
-	f := Filter{
-		IDs: strings.Split(r.URL.Query().Get("account_id"), ","),
+	f, err := decodeFilter(r)
+	if err != nil {
+		return nil, err
+	}
+	if f.Fields, err = decodeFields(r); err != nil {
+		return nil, err
 	}
 
 	return GetAccountsRequest{Filter: f, Pagination: p}, nil
 }
 
+func decodeExportAccountsRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	format, err := exportFormat(r.Header.Get("Accept"))
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := decodeFilter(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return ExportAccountsRequest{Filter: f, Format: format}, nil
+}
+
+// decodeFilter reads the Filter shared by the list and export routes from the query string
+func decodeFilter(r *http.Request) (f Filter, err error) {
+	if ids := r.URL.Query().Get("account_id"); len(ids) > 0 {
+		f.IDs = strings.Split(ids, ",")
+	}
+	if emails := r.URL.Query().Get("email"); len(emails) > 0 {
+		f.Emails = strings.Split(emails, ",")
+	}
+	if parents := r.URL.Query().Get("parent_id"); len(parents) > 0 {
+		f.ParentIDs = strings.Split(parents, ",")
+	}
+	if tenants := r.URL.Query().Get("tenant_id"); len(tenants) > 0 {
+		f.TenantIDs = strings.Split(tenants, ",")
+	}
+	// custom.<field>=<value>, converted to the type of the field by the service
+	for key, values := range r.URL.Query() {
+		if strings.HasPrefix(key, "custom.") {
+			if f.Custom == nil {
+				f.Custom = map[string]interface{}{}
+			}
+			f.Custom[strings.TrimPrefix(key, "custom.")] = values[0]
+		}
+	}
+	f.Subtree = r.URL.Query().Get("subtree")
+	if selector := r.URL.Query().Get("selector"); len(selector) > 0 {
+		f.Selector, err = ParseSelector(selector)
+	}
+
+	return f, err
+}
+
+// exportTypes are the media types of the export formats, the first one is the default
+var exportTypes = []struct{ mediaType, format string }{
+	{"application/x-ndjson", ExportFormatNDJSON},
+	{"application/json", ExportFormatNDJSON},
+	{"text/csv", ExportFormatCSV},
+}
+
+// exportFormat picks the export format from the Accept header by decreasing
+// quality, as Codecs.Negotiate does, NDJSON being the default
+func exportFormat(accept string) (string, error) {
+	if len(strings.TrimSpace(accept)) == 0 {
+		return exportTypes[0].format, nil
+	}
+
+	for _, r := range mediaRanges(accept) {
+		for _, t := range exportTypes {
+			if matchesRange(r, t.mediaType) {
+				return t.format, nil
+			}
+		}
+	}
+
+	return "", ErrNotAcceptable
+}
+
 func decodeGetAccountRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
 	vars := mux.Vars(r)
+	req := GetAccountRequest{ID: vars["id"]}
 
-	return GetAccountRequest{ID: vars["id"]}, nil
+	if asOf := r.URL.Query().Get("as_of"); len(asOf) > 0 {
+		if req.AsOf, err = time.Parse(time.RFC3339, asOf); err != nil {
+			return nil, ErrInvalidAsOf
+		}
+	}
+	if req.Fields, err = decodeFields(r); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// decodeFields reads the sparse fieldset of the fields parameter, nil when all fields are asked for
+func decodeFields(r *http.Request) ([]string, error) {
+	if fields := r.URL.Query().Get("fields"); len(fields) > 0 {
+		return ParseFields(fields)
+	}
+
+	return nil, nil
+}
+
+func decodeGetAccountVersionRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	vars := mux.Vars(r)
+
+	version, err := strconv.Atoi(vars["version"])
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	return GetAccountVersionRequest{ID: vars["id"], Version: version}, nil
+}
+
+func decodeRevertAccountRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req RevertAccountRequest
+
+	if err := decodeBody(ctx, r, &req); err != nil {
+		return nil, err
+	}
+	if req.Version < 1 {
+		return nil, ErrInvalidBody
+	}
+
+	req.ID = mux.Vars(r)["id"]
+
+	return req, nil
+}
+
+func decodeGetChildrenRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	vars := mux.Vars(r)
+
+	return GetChildrenRequest{ID: vars["id"], Pagination: decodePagination(r)}, nil
+}
+
+// decodeGetDescendantsRequest reads the depth query parameter, all the levels
+// up to MaxDescendantsDepth are returned by default
+func decodeGetDescendantsRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	req := GetDescendantsRequest{ID: mux.Vars(r)["id"], Depth: MaxDescendantsDepth}
+
+	if depth := r.URL.Query().Get("depth"); len(depth) > 0 {
+		if req.Depth, err = strconv.Atoi(depth); err != nil {
+			return nil, ErrInvalidDepth
+		}
+	}
+
+	return req, nil
+}
+
+// decodeGetAccountStatsRequest reads the group_by parameter, status by default, and the Filter
+func decodeGetAccountStatsRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	f, err := decodeFilter(r)
+	if err != nil {
+		return nil, err
+	}
+
+	groupBy := r.URL.Query().Get("group_by")
+	if len(groupBy) == 0 {
+		groupBy = GroupByStatus
+	}
+	g, err := ParseStatsGroup(groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetAccountStatsRequest{Filter: f, GroupBy: g}, nil
+}
+
+func decodeSearchAccountsRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	req := SearchAccountsRequest{Query: r.URL.Query().Get("q"), Limit: DefaultSearchLimit}
+
+	if limit := r.URL.Query().Get("limit"); len(limit) > 0 {
+		if req.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, ErrInvalidQuery
+		}
+	}
+
+	return req, nil
+}
+
+func decodeMergeAccountsRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req MergeAccountsRequest
+
+	if err := decodeBody(ctx, r, &req); err != nil {
+		return nil, err
+	}
+	if len(req.SourceID) == 0 {
+		return nil, ErrInvalidBody
+	}
+
+	req.ID = mux.Vars(r)["id"]
+
+	return req, nil
+}
+
+func decodeGetAccountHistoryRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	vars := mux.Vars(r)
+
+	return GetAccountHistoryRequest{ID: vars["id"], Pagination: decodePagination(r)}, nil
+}
+
+// decodePagination reads the page and size query parameters, invalid values fall back
+// to the defaults and the size is capped to MaxPaginationSize
+func decodePagination(r *http.Request) Pagination {
+	p := Pagination{Size: DefaultPaginationSize, Page: 0}
+
+	if size, err := strconv.Atoi(r.URL.Query().Get("size")); err == nil && size > 0 {
+		p.Size = size
+	}
+	if p.Size > MaxPaginationSize {
+		p.Size = MaxPaginationSize
+	}
+	if page, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && page > 0 {
+		p.Page = page
+	}
+
+	return p
+}
+
+// PopulateAuditContext puts the request ID of the request in the context, one
+// is generated when the client did not send one. The actor is not taken from
+// the request: the authentication middleware puts its principal in the context.
+func PopulateAuditContext(ctx context.Context, r *http.Request) context.Context {
+	requestID := r.Header.Get(HeaderRequestID)
+	if len(requestID) == 0 {
+		b := make([]byte, 16)
+		// without randomness the entry is left without request ID, rather than one that may collide
+		if _, err := rand.Read(b); err != nil {
+			return ctx
+		}
+		requestID = hex.EncodeToString(b)
+	}
+
+	return ContextWithRequestID(ctx, requestID)
 }
 
 func decodeUpdateAccountRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
 	var req UpdateAccountRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, ErrInvalidBody
+	if err := decodeBody(ctx, r, &req); err != nil {
+		return nil, err
 	}
 
 	vars := mux.Vars(r)
@@ -109,9 +438,11 @@ func decodeUpdateAccountRequest(ctx context.Context, r *http.Request) (request i
 func decodeCreateAccountRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
 	var req CreateAccountRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, ErrInvalidBody
+	if err := decodeBody(ctx, r, &req); err != nil {
+		return nil, err
 	}
+	// the id of an Account created through the API is assigned by the repository
+	req.AccountID = ""
 
 	return req, nil
 }
@@ -122,9 +453,95 @@ func decodeDeleteAccountRequest(ctx context.Context, r *http.Request) (request i
 	return DeleteAccountRequest{ID: vars["id"]}, nil
 }
 
+// encodeResponse writes the response in the media type negotiated from the Accept header
 func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	return json.NewEncoder(w).Encode(response)
+	return encode(ctx, w, http.StatusOK, response)
+}
+
+// encodeGetAccountResponse redirects to the surviving Account when the one asked for was merged,
+// and answers the conditional requests on the others
+func encodeGetAccountResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	a, ok := response.(*Account)
+	if p, projected := response.(Projection); projected {
+		a, ok = p.Value.(*Account)
+	}
+
+	if ok && len(a.MergedInto) > 0 {
+		w.Header().Set("Location", fmt.Sprintf("/accounts/%v", a.MergedInto))
+		return encode(ctx, w, http.StatusMovedPermanently, response)
+	}
+
+	if ok && notModified(ctx, w, a) {
+		return nil
+	}
+
+	return encodeResponse(ctx, w, response)
+}
+
+// encodeGetAccountsResponse adds the links to the other pages to the envelope. The
+// last page is only known when the total was counted, otherwise a full page is
+// assumed to have a next one.
+func encodeGetAccountsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(GetAccountsResponse)
+
+	uri, _ := ctx.Value(kithttp.ContextKeyRequestURI).(string)
+	u, err := url.Parse(uri)
+	if err != nil || len(uri) == 0 {
+		u = &url.URL{Path: "/accounts/"}
+	}
+
+	link := func(page int) string {
+		q := u.Query()
+		q.Set("page", strconv.Itoa(page))
+		q.Set("size", strconv.Itoa(res.Size))
+		return (&url.URL{Path: u.Path, RawQuery: q.Encode()}).String()
+	}
+
+	res.Links = map[string]string{"self": link(res.Page), "first": link(0)}
+	if res.Page > 0 {
+		res.Links["prev"] = link(res.Page - 1)
+	}
+	if res.Total != nil {
+		last := 0
+		if *res.Total > 0 {
+			last = (*res.Total - 1) / res.Size
+		}
+		res.Links["last"] = link(last)
+		if res.Page < last {
+			res.Links["next"] = link(res.Page + 1)
+		}
+	} else if res.Count == res.Size {
+		res.Links["next"] = link(res.Page + 1)
+	}
+
+	return encodeResponse(ctx, w, res)
+}
+
+func encodeExportAccountsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	res := response.(ExportAccountsResponse)
+
+	if res.Format == ExportFormatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		if err := cw.Write(CSVHeader); err != nil {
+			return err
+		}
+
+		err := res.Each(func(a *Account) error {
+			return cw.Write(a.MarshalCSV())
+		})
+		cw.Flush()
+		if err != nil {
+			return err
+		}
+		return cw.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	return res.Each(func(a *Account) error {
+		return enc.Encode(a)
+	})
 }
 
 func encodeDeleteAccountResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
@@ -144,20 +561,70 @@ func encodeCreateAccountResponse(ctx context.Context, w http.ResponseWriter, res
 	return nil
 }
 
+// AccountIDFromPath returns the id of the account an /accounts/ path is about,
+// empty for the routes of the collection
+func AccountIDFromPath(path string) string {
+	rest := strings.TrimPrefix(path, "/accounts/")
+	if rest == path {
+		return ""
+	}
+
+	id := strings.SplitN(rest, "/", 2)[0]
+	switch id {
+	case "", "search", "stats", "export", "changes":
+		return ""
+	}
+	return id
+}
+
 // encode errors from business-logic
-func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
+	status := http.StatusInternalServerError
 
 	switch err {
-	default:
-		w.WriteHeader(http.StatusInternalServerError)
 	case ErrInconsistentID,
-		ErrInvalidBody:
-		w.WriteHeader(http.StatusBadRequest)
+		ErrInvalidBody,
+		ErrInvalidStatus,
+		ErrInvalidEmail,
+		ErrInvalidAsOf,
+		ErrInvalidDepth,
+		ErrHierarchyTooLarge,
+		ErrParentNotFound,
+		ErrMergeSelf,
+		ErrSourceNotFound,
+		ErrInvalidMergeRule,
+		ErrInvalidLabel,
+		ErrInvalidAnnotation,
+		ErrInvalidSelector,
+		ErrInvalidCustomFields,
+		ErrInvalidCustomFilter,
+		ErrInvalidQuery,
+		ErrInvalidGroupBy,
+		ErrInvalidFields:
+		status = http.StatusBadRequest
 	case ErrNotFound:
-		w.WriteHeader(http.StatusNotFound)
+		status = http.StatusNotFound
+	case ErrEmailMismatch,
+		ErrEmailTaken,
+		ErrCycle,
+		ErrParentClosed,
+		ErrMerged,
+		ErrConflict:
+		status = http.StatusConflict
+	case ErrOtherTenant,
+		ErrMergeForbidden:
+		status = http.StatusForbidden
+	case ErrNotAcceptable:
+		status = http.StatusNotAcceptable
+	case ErrSearchUnavailable:
+		status = http.StatusNotImplemented
+	case ErrBodyTooLarge:
+		status = http.StatusRequestEntityTooLarge
+	case ErrUnsupportedMediaType:
+		status = http.StatusUnsupportedMediaType
 	}
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+
+	encode(ctx, w, status, map[string]interface{}{
 		"error": err.Error(),
 	})
 }
@@ -3,14 +3,19 @@ package account
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,6 +35,25 @@ func Test_DecodeGetAccountRequest(t *testing.T) {
 	assert.Equal(t, expected, req)
 }
 
+func Test_DecodeGetAccountRequest_Should_Read_As_Of(t *testing.T) {
+	expected := GetAccountRequest{ID: "1", AsOf: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+	r, _ := http.NewRequest("GET", "/accounts/1?as_of=2020-01-02T03:04:05Z", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+
+	req, err := decodeGetAccountRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, req)
+}
+
+func Test_DecodeGetAccountRequest_Should_Return_ErrInvalidAsOf(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/accounts/1?as_of=yesterday", nil)
+
+	_, err := decodeGetAccountRequest(context.Background(), r)
+
+	assert.Equal(t, ErrInvalidAsOf, err)
+}
+
 func Test_DecodeGetAccountsRequest(t *testing.T) {
 	f := Filter{IDs: []string{"1", "2"}}
 	p := Pagination{Size: 100}
@@ -42,6 +66,101 @@ func Test_DecodeGetAccountsRequest(t *testing.T) {
 	assert.Equal(t, expected, req)
 }
 
+func Test_DecodeExportAccountsRequest(t *testing.T) {
+	var flagtests = []struct {
+		accept string
+		out    string
+	}{
+		{"", ExportFormatNDJSON},
+		{"application/x-ndjson", ExportFormatNDJSON},
+		{"text/csv", ExportFormatCSV},
+		{"text/html, text/csv;q=0.9", ExportFormatCSV},
+		{"*/*", ExportFormatNDJSON},
+		{"text/csv;q=0.1, application/x-ndjson", ExportFormatNDJSON},
+		{"application/*;q=0.5, text/*", ExportFormatCSV},
+		{"text/csv;q=0, */*", ExportFormatNDJSON},
+	}
+
+	for _, tt := range flagtests {
+		r, _ := http.NewRequest("GET", "/accounts/export?account_id=1,2", nil)
+		r.Header.Set("Accept", tt.accept)
+
+		req, err := decodeExportAccountsRequest(context.Background(), r)
+
+		assert.Nil(t, err)
+		assert.Equal(t, ExportAccountsRequest{Filter: Filter{IDs: []string{"1", "2"}}, Format: tt.out}, req)
+	}
+}
+
+func Test_DecodeExportAccountsRequest_Should_Return_ErrNotAcceptable_When_Format_Is_Unknown(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/accounts/export", nil)
+	r.Header.Set("Accept", "application/xml")
+
+	_, err := decodeExportAccountsRequest(context.Background(), r)
+
+	assert.Equal(t, ErrNotAcceptable, err)
+}
+
+func Test_DecodeGetAccountHistoryRequest(t *testing.T) {
+	expected := GetAccountHistoryRequest{ID: "1", Pagination: Pagination{Size: 20, Page: 3}}
+	r, _ := http.NewRequest("GET", "/accounts/1/history?size=20&page=3", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+
+	req, err := decodeGetAccountHistoryRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, req)
+}
+
+func Test_DecodeGetAccountVersionRequest(t *testing.T) {
+	expected := GetAccountVersionRequest{ID: "1", Version: 3}
+	r, _ := http.NewRequest("GET", "/accounts/1/versions/3", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "1", "version": "3"})
+
+	req, err := decodeGetAccountVersionRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, req)
+}
+
+func Test_DecodeRevertAccountRequest(t *testing.T) {
+	expected := RevertAccountRequest{ID: "1", Version: 2}
+	r, _ := http.NewRequest("POST", "/accounts/1/revert", bytes.NewBufferString(`{"version":2}`))
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+
+	req, err := decodeRevertAccountRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, req)
+}
+
+func Test_DecodeRevertAccountRequest_Should_Return_ErrInvalidBody_When_Version_Is_Missing(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/accounts/1/revert", bytes.NewBufferString("{}"))
+
+	_, err := decodeRevertAccountRequest(context.Background(), r)
+
+	assert.Equal(t, ErrInvalidBody, err)
+}
+
+func Test_PopulateAuditContext(t *testing.T) {
+	r, _ := http.NewRequest("PATCH", "/accounts/1", nil)
+	r.Header.Set("X-Actor", "mallory")
+	r.Header.Set(HeaderRequestID, "req")
+
+	ctx := PopulateAuditContext(ContextWithActor(context.Background(), "alice"), r)
+
+	assert.Equal(t, "alice", ActorFromContext(ctx))
+	assert.Equal(t, "req", RequestIDFromContext(ctx))
+}
+
+func Test_PopulateAuditContext_Should_Generate_A_Request_ID(t *testing.T) {
+	r, _ := http.NewRequest("PATCH", "/accounts/1", nil)
+
+	ctx := PopulateAuditContext(context.Background(), r)
+
+	assert.Len(t, RequestIDFromContext(ctx), 32)
+}
+
 func Test_DecodeUpdateAccountRequest(t *testing.T) {
 	expected := UpdateAccountRequest{}
 	r, _ := http.NewRequest("PUT", "/Accounts/1", bytes.NewBufferString("{}"))
@@ -125,6 +244,37 @@ func Test_EncodeResponse_Should_Return_JSON_ContentType(t *testing.T) {
 	assert.Equal(t, expected, w.Header().Get("Content-Type"))
 }
 
+func Test_EncodeExportAccountsResponse(t *testing.T) {
+	var flagtests = []struct {
+		format      string
+		contentType string
+		body        string
+	}{
+		{ExportFormatNDJSON, "application/x-ndjson", "{\"account_id\":\"1\"}\n{\"account_id\":\"2\"}\n"},
+		{ExportFormatCSV, "text/csv; charset=utf-8", "account_id,status,email,email_verified,version,updated_at,parent_id,merged_into,labels,annotations,tenant_id,custom,name,created_at\n1,,,false,,,,,,,,,,\n2,,,false,,,,,,,,,,\n"},
+	}
+
+	for _, tt := range flagtests {
+		response := ExportAccountsResponse{
+			Format: tt.format,
+			Each: func(fn func(*Account) error) error {
+				fn(&Account{AccountID: "1"})
+				return fn(&Account{AccountID: "2"})
+			},
+		}
+
+		w := httptest.NewRecorder()
+		err := encodeExportAccountsResponse(context.Background(), w, response)
+		assert.Nil(t, err)
+
+		body, err := ioutil.ReadAll(w.Body)
+
+		assert.Nil(t, err)
+		assert.Equal(t, tt.body, string(body))
+		assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+	}
+}
+
 func Test_EncodeCreateAccountResponse(t *testing.T) {
 	ID := "123"
 	response := ID
@@ -185,7 +335,17 @@ func Test_EncodeError_Should_Correctly_Map_Error(t *testing.T) {
 		{errors.New("not handled error"), http.StatusInternalServerError},
 		{ErrInvalidBody, http.StatusBadRequest},
 		{ErrInconsistentID, http.StatusBadRequest},
+		{ErrInvalidAsOf, http.StatusBadRequest},
+		{ErrInvalidEmail, http.StatusBadRequest},
+		{ErrEmailMismatch, http.StatusConflict},
+		{ErrInvalidDepth, http.StatusBadRequest},
+		{ErrParentNotFound, http.StatusBadRequest},
+		{ErrCycle, http.StatusConflict},
+		{ErrConflict, http.StatusConflict},
+		{ErrEmailTaken, http.StatusConflict},
+		{ErrParentClosed, http.StatusConflict},
 		{ErrNotFound, http.StatusNotFound},
+		{ErrNotAcceptable, http.StatusNotAcceptable},
 	}
 
 	for _, tt := range flagtests {
@@ -195,3 +355,221 @@ func Test_EncodeError_Should_Correctly_Map_Error(t *testing.T) {
 		assert.Equal(t, tt.out, w.Code)
 	}
 }
+
+func Test_DecodeGetDescendantsRequest(t *testing.T) {
+	var flagtests = []struct {
+		url string
+		out interface{}
+		err error
+	}{
+		{"/accounts/1/descendants", GetDescendantsRequest{ID: "1", Depth: MaxDescendantsDepth}, nil},
+		{"/accounts/1/descendants?depth=2", GetDescendantsRequest{ID: "1", Depth: 2}, nil},
+		{"/accounts/1/descendants?depth=all", nil, ErrInvalidDepth},
+	}
+
+	for _, tt := range flagtests {
+		r, _ := http.NewRequest("GET", tt.url, nil)
+		r = mux.SetURLVars(r, map[string]string{"id": "1"})
+
+		req, err := decodeGetDescendantsRequest(context.Background(), r)
+
+		assert.Equal(t, tt.err, err)
+		assert.Equal(t, tt.out, req)
+	}
+}
+
+func Test_DecodeFilter_Should_Read_The_Hierarchy_Parameters(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/accounts/?parent_id=1,2&subtree=3", nil)
+
+	f, err := decodeFilter(r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Filter{ParentIDs: []string{"1", "2"}, Subtree: "3"}, f)
+}
+
+func Test_DecodeFilter_Should_Read_The_Selector(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/accounts/?selector="+url.QueryEscape("tier=gold,region in (eu,us)"), nil)
+
+	f, err := decodeFilter(r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Selector{{"tier", OperatorEquals, []string{"gold"}}, {"region", OperatorIn, []string{"eu", "us"}}}, f.Selector)
+
+	r, _ = http.NewRequest("GET", "/accounts/?selector=tier%3D%3D%3D", nil)
+	_, err = decodeFilter(r)
+
+	assert.Equal(t, ErrInvalidSelector, err)
+}
+
+func Test_DecodeFilter_Should_Read_The_Custom_Fields(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/accounts/?tenant_id=t1&custom.plan=pro&custom.seats=3", nil)
+
+	f, err := decodeFilter(r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Filter{TenantIDs: []string{"t1"}, Custom: map[string]interface{}{"plan": "pro", "seats": "3"}}, f)
+}
+
+func Test_EncodeGetAccountResponse_Should_Redirect_A_Merged_Account(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := encodeGetAccountResponse(context.Background(), w, &Account{AccountID: "2", Status: StatusMerged, MergedInto: "1"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/accounts/1", w.Header().Get("Location"))
+}
+
+func Test_DecodeMergeAccountsRequest(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/accounts/1/merge", bytes.NewBufferString(`{"source_id":"2","rules":{"email":"source"}}`))
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+
+	req, err := decodeMergeAccountsRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, MergeAccountsRequest{ID: "1", SourceID: "2", Rules: MergeRules{"email": ResolveSource}}, req)
+
+	r, _ = http.NewRequest("POST", "/accounts/1/merge", bytes.NewBufferString(`{}`))
+	_, err = decodeMergeAccountsRequest(context.Background(), r)
+
+	assert.Equal(t, ErrInvalidBody, err)
+}
+
+func Test_DecodeSearchAccountsRequest(t *testing.T) {
+	var flagtests = []struct {
+		query string
+		out   interface{}
+		err   error
+	}{
+		{"?q=jane+doe", SearchAccountsRequest{Query: "jane doe", Limit: DefaultSearchLimit}, nil},
+		{"?q=jane&limit=5", SearchAccountsRequest{Query: "jane", Limit: 5}, nil},
+		{"?q=jane&limit=five", nil, ErrInvalidQuery},
+	}
+
+	for _, tt := range flagtests {
+		r, _ := http.NewRequest("GET", "/accounts/search"+tt.query, nil)
+
+		req, err := decodeSearchAccountsRequest(context.Background(), r)
+
+		assert.Equal(t, tt.err, err)
+		assert.Equal(t, tt.out, req)
+	}
+}
+
+func Test_DecodeGetAccountStatsRequest(t *testing.T) {
+	var flagtests = []struct {
+		query string
+		out   interface{}
+		err   error
+	}{
+		{"", GetAccountStatsRequest{GroupBy: StatsGroup{Field: GroupByStatus}}, nil},
+		{"?group_by=created:month&tenant_id=t1", GetAccountStatsRequest{Filter: Filter{TenantIDs: []string{"t1"}}, GroupBy: StatsGroup{Field: GroupByCreated, Key: IntervalMonth}}, nil},
+		{"?group_by=email", nil, ErrInvalidGroupBy},
+	}
+
+	for _, tt := range flagtests {
+		r, _ := http.NewRequest("GET", "/accounts/stats"+tt.query, nil)
+
+		req, err := decodeGetAccountStatsRequest(context.Background(), r)
+
+		assert.Equal(t, tt.err, err)
+		assert.Equal(t, tt.out, req)
+	}
+}
+
+func Test_DecodeGetAccountRequest_Should_Read_The_Fields(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/accounts/1?fields=account_id,name", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+
+	req, err := decodeGetAccountRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, GetAccountRequest{ID: "1", Fields: []string{"account_id", "name"}}, req)
+
+	r, _ = http.NewRequest("GET", "/accounts/?fields=name,secret", nil)
+	_, err = decodeGetAccountsRequest(context.Background(), r)
+
+	assert.Equal(t, ErrInvalidFields, err)
+}
+
+func Test_EncodeGetAccountResponse_Should_Redirect_A_Projected_Merged_Account(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := encodeGetAccountResponse(context.Background(), w, Projection{Fields: []string{"status"}, Value: &Account{AccountID: "2", Status: StatusMerged, MergedInto: "1"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/accounts/1", w.Header().Get("Location"))
+	assert.JSONEq(t, `{"status": "merged"}`, w.Body.String())
+}
+
+func Test_DecodeGetAccountsRequest_Should_Read_The_Pagination(t *testing.T) {
+	r := httptest.NewRequest("GET", "/accounts/?page=2&size=5000&count=true", nil)
+
+	req, err := decodeGetAccountsRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Pagination{Page: 2, Size: MaxPaginationSize, Count: true}, req.(GetAccountsRequest).Pagination)
+}
+
+func Test_EncodeGetAccountsResponse_Should_Link_The_Pages(t *testing.T) {
+	total := 25
+	var flagtests = []struct {
+		res      GetAccountsResponse
+		expected map[string]string
+	}{
+		{
+			GetAccountsResponse{Items: []*Account{}, Count: 10, Total: &total, Page: 1, Size: 10},
+			map[string]string{
+				"self":  "/accounts/?page=1&size=10&status=active",
+				"first": "/accounts/?page=0&size=10&status=active",
+				"prev":  "/accounts/?page=0&size=10&status=active",
+				"next":  "/accounts/?page=2&size=10&status=active",
+				"last":  "/accounts/?page=2&size=10&status=active",
+			},
+		},
+		{
+			GetAccountsResponse{Items: []*Account{}, Count: 10, Page: 0, Size: 10},
+			map[string]string{
+				"self":  "/accounts/?page=0&size=10&status=active",
+				"first": "/accounts/?page=0&size=10&status=active",
+				"next":  "/accounts/?page=1&size=10&status=active",
+			},
+		},
+		{
+			GetAccountsResponse{Items: []*Account{}, Count: 3, Page: 0, Size: 10},
+			map[string]string{
+				"self":  "/accounts/?page=0&size=10&status=active",
+				"first": "/accounts/?page=0&size=10&status=active",
+			},
+		},
+	}
+
+	ctx := context.WithValue(context.Background(), kithttp.ContextKeyRequestURI, "/accounts/?status=active&page=1")
+	for _, tt := range flagtests {
+		w := httptest.NewRecorder()
+		err := encodeGetAccountsResponse(ctx, w, tt.res)
+
+		var body GetAccountsResponse
+		json.NewDecoder(w.Body).Decode(&body)
+
+		assert.Nil(t, err)
+		assert.Equal(t, tt.expected, body.Links)
+	}
+}
+
+func Test_AccountIDFromPath(t *testing.T) {
+	var flagtests = []struct {
+		in  string
+		out string
+	}{
+		{"/accounts/", ""},
+		{"/accounts/search", ""},
+		{"/accounts/changes", ""},
+		{"/accounts/1", "1"},
+		{"/accounts/1/history", "1"},
+		{"/schemas/accounts", ""},
+	}
+
+	for _, tt := range flagtests {
+		assert.Equal(t, tt.out, AccountIDFromPath(tt.in))
+	}
+}
@@ -0,0 +1,169 @@
+package account
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidLabel is used when a label has an invalid key or value, or an Account too many labels
+var ErrInvalidLabel = errors.New("invalid Account label")
+
+// ErrInvalidAnnotation is used when an annotation has an invalid key, or the annotations are too large
+var ErrInvalidAnnotation = errors.New("invalid Account annotation")
+
+// ErrInvalidSelector is used when a label selector can not be parsed
+var ErrInvalidSelector = errors.New("invalid label selector")
+
+// Limits of the metadata of an Account
+const (
+	MaxLabels = 64
+	// MaxAnnotationsSize is the maximum total size, in bytes, of the keys and values of the annotations
+	MaxAnnotationsSize = 64 * 1024
+)
+
+// A key is a name, optionally prefixed by a namespace and a slash, such as
+// "team/owner". Dots are not allowed as keys are used as field names in storage.
+var (
+	keySegment = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_]{0,61}[A-Za-z0-9])?$`)
+	labelValue = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?)?$`)
+)
+
+func validKey(key string) bool {
+	parts := strings.Split(key, "/")
+	if len(parts) > 2 {
+		return false
+	}
+
+	for _, part := range parts {
+		if !keySegment.MatchString(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateMetadata checks the labels and annotations of an Account, empty ones are dropped
+func validateMetadata(a *Account) error {
+	if len(a.Labels) > MaxLabels {
+		return ErrInvalidLabel
+	}
+	for k, v := range a.Labels {
+		if !validKey(k) || !labelValue.MatchString(v) {
+			return ErrInvalidLabel
+		}
+	}
+
+	size := 0
+	for k, v := range a.Annotations {
+		if !validKey(k) {
+			return ErrInvalidAnnotation
+		}
+		size += len(k) + len(v)
+	}
+	if size > MaxAnnotationsSize {
+		return ErrInvalidAnnotation
+	}
+
+	if len(a.Labels) == 0 {
+		a.Labels = nil
+	}
+	if len(a.Annotations) == 0 {
+		a.Annotations = nil
+	}
+
+	return nil
+}
+
+// Operators of a label selector Requirement
+const (
+	OperatorEquals       = "="
+	OperatorNotEquals    = "!="
+	OperatorIn           = "in"
+	OperatorNotIn        = "notin"
+	OperatorExists       = "exists"
+	OperatorDoesNotExist = "!"
+)
+
+// Requirement is one of the conditions of a label selector
+type Requirement struct {
+	Key      string
+	Operator string
+	Values   []string
+}
+
+// Selector selects the Accounts whose labels meet all its requirements
+type Selector []Requirement
+
+var setRequirement = regexp.MustCompile(`^(\S+)\s+(in|notin)\s+\(([^()]*)\)$`)
+
+// ParseSelector parses a label selector such as "tier=gold,region in (eu,us)".
+// The requirements are separated by commas and can be: key, !key, key=value,
+// key==value, key!=value, key in (values) and key notin (values).
+func ParseSelector(s string) (Selector, error) {
+	var selector Selector
+
+	for _, part := range splitRequirements(s) {
+		r, err := parseRequirement(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		selector = append(selector, r)
+	}
+
+	return selector, nil
+}
+
+// splitRequirements splits a selector on the commas out of parentheses
+func splitRequirements(s string) []string {
+	var parts []string
+
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+func parseRequirement(s string) (r Requirement, err error) {
+	switch {
+	case setRequirement.MatchString(s):
+		m := setRequirement.FindStringSubmatch(s)
+		r = Requirement{Key: m[1], Operator: m[2]}
+		for _, v := range strings.Split(m[3], ",") {
+			r.Values = append(r.Values, strings.TrimSpace(v))
+		}
+	case strings.Contains(s, "!="):
+		kv := strings.SplitN(s, "!=", 2)
+		r = Requirement{Key: strings.TrimSpace(kv[0]), Operator: OperatorNotEquals, Values: []string{strings.TrimSpace(kv[1])}}
+	case strings.Contains(s, "="):
+		kv := strings.SplitN(strings.Replace(s, "==", "=", 1), "=", 2)
+		r = Requirement{Key: strings.TrimSpace(kv[0]), Operator: OperatorEquals, Values: []string{strings.TrimSpace(kv[1])}}
+	case strings.HasPrefix(s, "!"):
+		r = Requirement{Key: strings.TrimSpace(s[1:]), Operator: OperatorDoesNotExist}
+	default:
+		r = Requirement{Key: s, Operator: OperatorExists}
+	}
+
+	if !validKey(r.Key) {
+		return r, ErrInvalidSelector
+	}
+	for _, v := range r.Values {
+		if !labelValue.MatchString(v) {
+			return r, ErrInvalidSelector
+		}
+	}
+
+	return r, nil
+}
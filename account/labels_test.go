@@ -0,0 +1,67 @@
+package account
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseSelector(t *testing.T) {
+	var flagtests = []struct {
+		in  string
+		out Selector
+		err error
+	}{
+		{"tier=gold", Selector{{"tier", OperatorEquals, []string{"gold"}}}, nil},
+		{"tier==gold, team/owner != a", Selector{{"tier", OperatorEquals, []string{"gold"}}, {"team/owner", OperatorNotEquals, []string{"a"}}}, nil},
+		{"region in (eu, us),tier notin (free)", Selector{{"region", OperatorIn, []string{"eu", "us"}}, {"tier", OperatorNotIn, []string{"free"}}}, nil},
+		{"vip,!trial", Selector{{"vip", OperatorExists, nil}, {"trial", OperatorDoesNotExist, nil}}, nil},
+		{"tier=", Selector{{"tier", OperatorEquals, []string{""}}}, nil},
+		{"", nil, ErrInvalidSelector},
+		{"tier=gold,", nil, ErrInvalidSelector},
+		{"a.b=c", nil, ErrInvalidSelector},
+		{"region in (eu,(us))", nil, ErrInvalidSelector},
+		{"tier=g old", nil, ErrInvalidSelector},
+	}
+
+	for _, tt := range flagtests {
+		s, err := ParseSelector(tt.in)
+
+		assert.Equal(t, tt.err, err, tt.in)
+		assert.Equal(t, tt.out, s, tt.in)
+	}
+}
+
+func Test_ValidateMetadata(t *testing.T) {
+	tooManyLabels := map[string]string{}
+	for i := 0; i <= MaxLabels; i++ {
+		tooManyLabels["l"+strings.Repeat("x", i)] = ""
+	}
+
+	var flagtests = []struct {
+		in  Account
+		err error
+	}{
+		{Account{Labels: map[string]string{"tier": "gold", "example.com": "x"}}, ErrInvalidLabel},
+		{Account{Labels: map[string]string{"team/owner": "a-b.c_d", "vip": ""}}, nil},
+		{Account{Labels: map[string]string{"tier": "-gold"}}, ErrInvalidLabel},
+		{Account{Labels: map[string]string{"a/b/c": "x"}}, ErrInvalidLabel},
+		{Account{Labels: map[string]string{strings.Repeat("k", 64): "x"}}, ErrInvalidLabel},
+		{Account{Labels: tooManyLabels}, ErrInvalidLabel},
+		{Account{Annotations: map[string]string{"note": "Anything, even $ and ."}}, nil},
+		{Account{Annotations: map[string]string{"$note": "x"}}, ErrInvalidAnnotation},
+		{Account{Annotations: map[string]string{"note": strings.Repeat("x", MaxAnnotationsSize)}}, ErrInvalidAnnotation},
+	}
+
+	for _, tt := range flagtests {
+		assert.Equal(t, tt.err, validateMetadata(&tt.in))
+	}
+}
+
+func Test_ValidateMetadata_Should_Drop_Empty_Maps(t *testing.T) {
+	a := Account{Labels: map[string]string{}, Annotations: map[string]string{}}
+
+	assert.Nil(t, validateMetadata(&a))
+	assert.Equal(t, Account{}, a)
+}
@@ -0,0 +1,294 @@
+package account
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrMergeSelf is used when merging an Account into itself
+var ErrMergeSelf = errors.New("can not merge an Account into itself")
+
+// ErrSourceNotFound is used when the source Account of a merge does not exist
+var ErrSourceNotFound = errors.New("source Account not found")
+
+// ErrInvalidMergeRule is used when a merge rule names an unknown field or resolution
+var ErrInvalidMergeRule = errors.New("invalid merge rule")
+
+// ErrMerged is used when writing to an Account that was merged into another one
+var ErrMerged = errors.New("Account was merged")
+
+// ErrMergeForbidden is used when the principal of a merge does not manage both Accounts
+var ErrMergeForbidden = errors.New("not allowed to merge these Accounts")
+
+// Resolutions of a merge conflict, when both Accounts have a value for a field.
+// An empty value never wins over a set one.
+const (
+	// ResolveTarget keeps the value of the target, it is the default
+	ResolveTarget = "target"
+	// ResolveSource takes the value of the source
+	ResolveSource = "source"
+	// ResolveNewest takes the value of the Account updated last
+	ResolveNewest = "newest"
+)
+
+// MergeRules maps the fields of an Account, by their json name, to the resolution of their conflicts
+type MergeRules map[string]string
+
+// mergeFields lists the fields a merge rule can be given for
+var mergeFields = map[string]func(*Account) *string{
+	"name":      func(a *Account) *string { return &a.Name },
+	"email":     func(a *Account) *string { return &a.Email },
+	"status":    func(a *Account) *string { return &a.Status },
+	"parent_id": func(a *Account) *string { return &a.ParentID },
+}
+
+// mergeMaps lists the map fields a merge rule can be given for, they are
+// merged key by key and the rule resolves the keys both Accounts have
+var mergeMaps = map[string]func(*Account) *map[string]string{
+	"labels":      func(a *Account) *map[string]string { return &a.Labels },
+	"annotations": func(a *Account) *map[string]string { return &a.Annotations },
+}
+
+// MergeHandler moves what belongs to the source Account of a merge onto the
+// target. It is called once the merge is written, by the Publisher returned by
+// NewMergePublisher, and called again until it succeeds, so it must be idempotent.
+type MergeHandler interface {
+	MergeAccount(ctx context.Context, targetID, sourceID string) error
+}
+
+// MergeAuthorizer authorizes the merges, the principal of a merge has to manage
+// both its Accounts
+type MergeAuthorizer interface {
+	// AuthorizeMerge returns ErrMergeForbidden unless the principal of the
+	// context may merge the Account, or merge another one into it
+	AuthorizeMerge(ctx context.Context, accountID string) error
+}
+
+// ServiceMergeAuthorizer sets the MergeAuthorizer of the merges, every merge is allowed without one
+func ServiceMergeAuthorizer(a MergeAuthorizer) ServiceOption {
+	return func(s *service) { s.mergeAuthorizer = a }
+}
+
+type mergePublisher struct {
+	handlers []MergeHandler
+}
+
+// NewMergePublisher returns the Publisher calling the MergeHandlers with the
+// event tombstoning the source of a merge, it ignores every other event. It is
+// meant to be relayed the events of the merges written, until it succeeds.
+func NewMergePublisher(handlers ...MergeHandler) Publisher {
+	return mergePublisher{handlers: handlers}
+}
+
+// Publish calls every handler, the first error is returned
+func (p mergePublisher) Publish(ctx context.Context, e Event) (err error) {
+	targetID, sourceID, ok := MergeOf(e)
+	if !ok {
+		return nil
+	}
+
+	for _, h := range p.handlers {
+		if herr := h.MergeAccount(ctx, targetID, sourceID); herr != nil && err == nil {
+			err = herr
+		}
+	}
+
+	return
+}
+
+// MergeOf returns the target and source Accounts of the merge an event
+// tombstones the source of, ok is false for any other event
+func MergeOf(e Event) (targetID, sourceID string, ok bool) {
+	u, isUpdate := e.(AccountUpdated)
+	if !isUpdate || u.Before.Status == StatusMerged || u.After.Status != StatusMerged {
+		return "", "", false
+	}
+
+	return u.After.MergedInto, u.After.AccountID, true
+}
+
+// Validate returns ErrInvalidMergeRule when a rule names an unknown field or resolution
+func (rules MergeRules) Validate() error {
+	for field, resolution := range rules {
+		_, ok := mergeFields[field]
+		if _, isMap := mergeMaps[field]; !ok && !isMap {
+			return ErrInvalidMergeRule
+		}
+
+		switch resolution {
+		case ResolveTarget, ResolveSource, ResolveNewest:
+		default:
+			return ErrInvalidMergeRule
+		}
+	}
+
+	return nil
+}
+
+// resolve returns the target with the conflicts with the source resolved by the rules
+func (rules MergeRules) resolve(target, source Account) Account {
+	merged := target
+
+	for field, value := range mergeFields {
+		t, s := *value(&target), *value(&source)
+
+		fromSource := len(t) == 0
+		if len(s) > 0 {
+			switch rules[field] {
+			case ResolveSource:
+				fromSource = true
+			case ResolveNewest:
+				fromSource = fromSource || newer(source, target)
+			}
+		}
+
+		if fromSource && len(s) > 0 {
+			*value(&merged) = s
+		}
+	}
+
+	for field, value := range mergeMaps {
+		t, s := *value(&target), *value(&source)
+		if len(s) == 0 {
+			continue
+		}
+
+		fromSource := rules[field] == ResolveSource || (rules[field] == ResolveNewest && newer(source, target))
+		m := make(map[string]string, len(t)+len(s))
+		for k, v := range t {
+			m[k] = v
+		}
+		for k, v := range s {
+			if _, ok := t[k]; !ok || fromSource {
+				m[k] = v
+			}
+		}
+		*value(&merged) = m
+	}
+
+	// the verification belongs to the email it was made for
+	merged.EmailVerified = (merged.Email == target.Email && target.EmailVerified) ||
+		(merged.Email == source.Email && source.EmailVerified)
+
+	return merged
+}
+
+// newer tells whether a was updated after b
+func newer(a, b Account) bool {
+	return a.UpdatedAt != nil && (b.UpdatedAt == nil || a.UpdatedAt.After(*b.UpdatedAt))
+}
+
+// MergeAccounts merges the source Account into the target one: conflicting
+// fields are resolved by the rules, the children are moved to the target, and
+// the source is left as a tombstone pointing to the target, without its email.
+// The Accounts are written at once, the merge handlers then move the rest, see
+// NewMergePublisher.
+func (s service) MergeAccounts(ctx context.Context, targetID, sourceID string, rules MergeRules) (*Account, error) {
+	if targetID == sourceID {
+		return nil, ErrMergeSelf
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	if s.mergeAuthorizer != nil {
+		for _, id := range []string{targetID, sourceID} {
+			if err := s.mergeAuthorizer.AuthorizeMerge(ctx, id); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	target, err := s.getForWrite(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	source, err := s.getForWrite(ctx, sourceID)
+	if err == ErrNotFound {
+		return nil, ErrSourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if target.Status == StatusMerged || source.Status == StatusMerged {
+		return nil, ErrMerged
+	}
+	if err := s.checkTenant(ctx, target.TenantID, source.TenantID); err != nil {
+		return nil, err
+	}
+
+	merged := rules.resolve(*target, *source)
+	switch merged.ParentID {
+	case sourceID:
+		merged.ParentID = source.ParentID
+	case targetID:
+		merged.ParentID = target.ParentID
+	}
+
+	if err := validate(&merged); err != nil {
+		return nil, err
+	}
+	ancestors, err := s.checkParent(ctx, &merged)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := s.children(ctx, []string{sourceID})
+	if err != nil {
+		return nil, err
+	}
+
+	// the tombstone releases the email of the source, before the target can claim it
+	tombstone := *source
+	tombstone.Status = StatusMerged
+	tombstone.MergedInto = targetID
+	tombstone.ParentID = ""
+	tombstone.Email = ""
+	tombstone.EmailVerified = false
+	writes := []Write{{Before: *source, After: &tombstone}, {Before: *target, After: &merged}}
+
+	closed := map[string]*Account{}
+	if target.Status != StatusClosed && merged.Status == StatusClosed {
+		descendants, err := s.closeDescendants(ctx, targetID, sourceID)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range descendants {
+			closed[w.After.AccountID] = w.After
+		}
+		writes = append(writes, descendants...)
+	}
+
+	// the children of the source are moved to the target, but for an ancestor
+	// of the target which takes the parent of the source instead
+	isAncestor := map[string]bool{}
+	for _, a := range ancestors {
+		isAncestor[a.AccountID] = true
+	}
+	for _, child := range children {
+		if child.AccountID == targetID {
+			continue
+		}
+
+		parentID := targetID
+		if isAncestor[child.AccountID] {
+			parentID = source.ParentID
+		}
+
+		if c, ok := closed[child.AccountID]; ok {
+			c.ParentID = parentID
+			continue
+		}
+		c := *child
+		c.ParentID = parentID
+		writes = append(writes, Write{Before: *child, After: &c})
+	}
+
+	if len(writes) > MaxSubtreeSize {
+		return nil, ErrHierarchyTooLarge
+	}
+	if err := s.write(ctx, writes, ancestors); err != nil {
+		return nil, err
+	}
+
+	return s.GetAccount(ctx, targetID)
+}
@@ -0,0 +1,182 @@
+package account
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type recordingMergeHandler struct {
+	merges [][2]string
+	err    error
+}
+
+func (h *recordingMergeHandler) MergeAccount(ctx context.Context, targetID, sourceID string) error {
+	h.merges = append(h.merges, [2]string{targetID, sourceID})
+	return h.err
+}
+
+// accountAuthorizer only lets merge the Accounts it lists
+type accountAuthorizer map[string]bool
+
+func (a accountAuthorizer) AuthorizeMerge(ctx context.Context, accountID string) error {
+	if !a[accountID] {
+		return ErrMergeForbidden
+	}
+	return nil
+}
+
+func Test_MergeRules_Resolve(t *testing.T) {
+	older := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	target := Account{AccountID: "1", Status: StatusActive, Email: "t@example.com", EmailVerified: true, UpdatedAt: &older}
+	source := Account{AccountID: "2", Status: StatusClosed, Email: "s@example.com", ParentID: "3", UpdatedAt: &newer}
+
+	var flagtests = []struct {
+		rules  MergeRules
+		status string
+		email  string
+		parent string
+	}{
+		{nil, StatusActive, "t@example.com", "3"},
+		{MergeRules{"email": ResolveSource}, StatusActive, "s@example.com", "3"},
+		{MergeRules{"status": ResolveNewest, "parent_id": ResolveTarget}, StatusClosed, "t@example.com", "3"},
+	}
+
+	for _, tt := range flagtests {
+		merged := tt.rules.resolve(target, source)
+
+		assert.Equal(t, "1", merged.AccountID)
+		assert.Equal(t, tt.status, merged.Status)
+		assert.Equal(t, tt.email, merged.Email)
+		assert.Equal(t, tt.parent, merged.ParentID)
+		assert.Equal(t, tt.email == target.Email, merged.EmailVerified)
+	}
+}
+
+func Test_MergeRules_Resolve_Should_Merge_Labels_By_Key(t *testing.T) {
+	target := Account{AccountID: "1", Labels: map[string]string{"tier": "gold", "team": "a"}}
+	source := Account{AccountID: "2", Labels: map[string]string{"tier": "silver", "region": "eu"}}
+
+	merged := MergeRules{}.resolve(target, source)
+	assert.Equal(t, map[string]string{"tier": "gold", "team": "a", "region": "eu"}, merged.Labels)
+
+	merged = MergeRules{"labels": ResolveSource}.resolve(target, source)
+	assert.Equal(t, map[string]string{"tier": "silver", "team": "a", "region": "eu"}, merged.Labels)
+	assert.Equal(t, map[string]string{"tier": "gold", "team": "a"}, target.Labels)
+}
+
+func Test_MergeRules_Validate(t *testing.T) {
+	assert.Nil(t, MergeRules{"email": ResolveNewest, "annotations": ResolveSource}.Validate())
+	assert.Equal(t, ErrInvalidMergeRule, MergeRules{"account_id": ResolveSource}.Validate())
+	assert.Equal(t, ErrInvalidMergeRule, MergeRules{"email": "longest"}.Validate())
+}
+
+func Test_MergeAccounts_Should_Tombstone_The_Source(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "1").Return(&Account{AccountID: "1", Status: StatusActive, Version: 1}, nil)
+	fakeRepo.On("GetAccount", "2").Return(&Account{AccountID: "2", Status: StatusActive, Email: "s@example.com", Version: 4}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{"2"}}).Return([]*Account{{AccountID: "3", Status: StatusActive, ParentID: "2"}}, nil)
+	fakeRepo.On("WriteAccounts", mock.MatchedBy(func(writes []Write) bool {
+		if len(writes) != 3 {
+			return false
+		}
+		tombstone, target, child := writes[0].After, writes[1].After, writes[2].After
+		return tombstone.AccountID == "2" && tombstone.Status == StatusMerged && tombstone.MergedInto == "1" &&
+			tombstone.Email == "" && tombstone.Version == 5 &&
+			target.AccountID == "1" && target.Email == "s@example.com" && target.Version == 2 &&
+			child.AccountID == "3" && child.ParentID == "1"
+	}), []Account(nil)).Return(nil)
+
+	svc := NewService(fakeRepo, ServiceMergeAuthorizer(accountAuthorizer{"1": true, "2": true}))
+	_, err := svc.MergeAccounts(context.Background(), "1", "2", nil)
+
+	assert.Nil(t, err)
+	fakeRepo.AssertExpectations(t)
+}
+
+func Test_MergeAccounts_Should_Require_Both_Accounts_To_Be_Authorized(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+
+	for _, authorized := range []string{"1", "2"} {
+		svc := NewService(fakeRepo, ServiceMergeAuthorizer(accountAuthorizer{authorized: true}))
+		_, err := svc.MergeAccounts(context.Background(), "1", "2", nil)
+
+		assert.Equal(t, ErrMergeForbidden, err)
+	}
+	fakeRepo.AssertNotCalled(t, "WriteAccounts", mock.Anything, mock.Anything)
+}
+
+func Test_MergePublisher_Should_Call_The_Handlers_With_The_Merges_Only(t *testing.T) {
+	handler := &recordingMergeHandler{err: ErrConflict}
+	p := NewMergePublisher(handler)
+
+	source := Account{AccountID: "2", Status: StatusActive}
+	tombstone := Account{AccountID: "2", Status: StatusMerged, MergedInto: "1"}
+
+	assert.Nil(t, p.Publish(context.Background(), AccountUpdated{Before: source, After: source}))
+	assert.Nil(t, p.Publish(context.Background(), AccountDeleted{Before: tombstone}))
+	assert.Nil(t, p.Publish(context.Background(), AccountUpdated{Before: tombstone, After: tombstone}))
+	// the error has the merge relayed again
+	assert.Equal(t, ErrConflict, p.Publish(context.Background(), AccountUpdated{Before: source, After: tombstone}))
+
+	assert.Equal(t, [][2]string{{"1", "2"}}, handler.merges)
+}
+
+func Test_MergeAccounts_Should_Return_An_Error(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "1").Return(&Account{AccountID: "1", Status: StatusActive}, nil)
+	fakeRepo.On("GetAccount", "2").Return(&Account{AccountID: "2", Status: StatusMerged, MergedInto: "4"}, nil)
+	fakeRepo.On("GetAccount", "3").Return(nil, nil)
+
+	var flagtests = []struct {
+		target string
+		source string
+		rules  MergeRules
+		err    error
+	}{
+		{"1", "1", nil, ErrMergeSelf},
+		{"1", "3", nil, ErrSourceNotFound},
+		{"1", "2", nil, ErrMerged},
+		{"1", "2", MergeRules{"version": ResolveSource}, ErrInvalidMergeRule},
+	}
+
+	svc := NewService(fakeRepo)
+	for _, tt := range flagtests {
+		_, err := svc.MergeAccounts(context.Background(), tt.target, tt.source, tt.rules)
+
+		assert.Equal(t, tt.err, err)
+	}
+	fakeRepo.AssertNotCalled(t, "WriteAccounts", mock.Anything, mock.Anything)
+}
+
+func Test_MergeAccounts_Should_Close_The_Children_Moved_To_A_Closed_Target(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "1").Return(&Account{AccountID: "1", Status: StatusActive}, nil)
+	fakeRepo.On("GetAccount", "2").Return(&Account{AccountID: "2", Status: StatusClosed}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{"2"}}).Return([]*Account{{AccountID: "3", Status: StatusActive, ParentID: "2"}}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{"1", "2"}}).Return([]*Account{{AccountID: "3", Status: StatusActive, ParentID: "2"}}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{"3"}}).Return([]*Account{}, nil)
+	fakeRepo.On("WriteAccounts", mock.MatchedBy(func(writes []Write) bool {
+		return len(writes) == 3 && writes[1].After.Status == StatusClosed &&
+			writes[2].After.AccountID == "3" && writes[2].After.ParentID == "1" && writes[2].After.Status == StatusClosed
+	}), []Account(nil)).Return(nil)
+
+	svc := NewService(fakeRepo)
+	_, err := svc.MergeAccounts(context.Background(), "1", "2", MergeRules{"status": ResolveSource})
+
+	assert.Nil(t, err)
+	fakeRepo.AssertExpectations(t)
+}
+
+func Test_UpdateAccount_Should_Return_ErrMerged(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "2").Return(&Account{AccountID: "2", Status: StatusMerged, MergedInto: "1"}, nil)
+
+	svc := NewService(fakeRepo)
+
+	assert.Equal(t, ErrMerged, svc.UpdateAccount(context.Background(), Account{AccountID: "2"}))
+}
@@ -2,6 +2,7 @@ package account
 
 import (
 	"context"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -25,6 +26,24 @@ func (m *mockedService) GetAccounts(ctx context.Context, filter Filter, paginati
 	return args.Get(0).([]*Account), args.Error(1)
 }
 
+func (m *mockedService) GetAccountsPage(ctx context.Context, filter Filter, pagination Pagination) (*AccountsPage, error) {
+	args := m.Called(filter, pagination)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*AccountsPage), args.Error(1)
+}
+
+func (m *mockedService) ExportAccounts(ctx context.Context, filter Filter, fn func(*Account) error) error {
+	args := m.Called(filter)
+	for _, a := range args.Get(0).([]*Account) {
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
 func (m *mockedService) UpdateAccount(ctx context.Context, a Account) error {
 	args := m.Called(a)
 	return args.Error(0)
@@ -35,8 +54,114 @@ func (m *mockedService) CreateAccount(ctx context.Context, a Account) (string, e
 	return args.Get(0).(string), args.Error(1)
 }
 
+func (m *mockedService) ValidateAccount(ctx context.Context, a Account) error {
+	args := m.Called(a)
+	return args.Error(0)
+}
+
 func (m *mockedService) DeleteAccount(ctx context.Context, id string) error {
 	args := m.Called(id)
 
 	return args.Error(0)
 }
+
+func (m *mockedService) GetAccountHistory(ctx context.Context, id string, pagination Pagination) ([]*AuditEntry, error) {
+	args := m.Called(id, pagination)
+	return args.Get(0).([]*AuditEntry), args.Error(1)
+}
+
+func (m *mockedService) GetAccountVersion(ctx context.Context, id string, version int) (*Account, error) {
+	args := m.Called(id, version)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*Account), args.Error(1)
+}
+
+func (m *mockedService) GetAccountAsOf(ctx context.Context, id string, at time.Time) (*Account, error) {
+	args := m.Called(id, at)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*Account), args.Error(1)
+}
+
+func (m *mockedService) RevertAccount(ctx context.Context, id string, version int) error {
+	args := m.Called(id, version)
+	return args.Error(0)
+}
+
+func (m *mockedService) VerifyEmail(ctx context.Context, id string, email string) error {
+	args := m.Called(id, email)
+	return args.Error(0)
+}
+
+func (m *mockedService) GetChildren(ctx context.Context, id string, pagination Pagination) ([]*Account, error) {
+	args := m.Called(id, pagination)
+	return args.Get(0).([]*Account), args.Error(1)
+}
+
+func (m *mockedService) GetDescendants(ctx context.Context, id string, depth int) ([]*Account, error) {
+	args := m.Called(id, depth)
+	return args.Get(0).([]*Account), args.Error(1)
+}
+
+func (m *mockedService) MergeAccounts(ctx context.Context, targetID, sourceID string, rules MergeRules) (*Account, error) {
+	args := m.Called(targetID, sourceID, rules)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*Account), args.Error(1)
+}
+
+func (m *mockedService) SearchAccounts(ctx context.Context, query string, limit int) ([]*SearchResult, error) {
+	args := m.Called(query, limit)
+	return args.Get(0).([]*SearchResult), args.Error(1)
+}
+
+func (m *mockedService) GetAccountStats(ctx context.Context, filter Filter, group StatsGroup) (*Stats, error) {
+	args := m.Called(filter, group)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*Stats), args.Error(1)
+}
+
+type recordingPublisher struct {
+	events []Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, e Event) error {
+	p.events = append(p.events, e)
+	return nil
+}
+
+type recordingAuditLog struct {
+	entries []AuditEntry
+}
+
+func (l *recordingAuditLog) Append(ctx context.Context, e AuditEntry) error {
+	var prev *AuditEntry
+	if len(l.entries) > 0 {
+		prev = &l.entries[len(l.entries)-1]
+	}
+	e.Chain(prev)
+	l.entries = append(l.entries, e)
+	return nil
+}
+
+func (l *recordingAuditLog) History(ctx context.Context, accountID string, pagination Pagination) ([]*AuditEntry, error) {
+	entries := []*AuditEntry{}
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entries = append(entries, &l.entries[i])
+	}
+	return entries, nil
+}
@@ -1,6 +1,33 @@
 package account
 
+import (
+	"time"
+)
+
+// Account statuses
+const (
+	StatusActive = "active"
+	StatusClosed = "closed"
+	// StatusMerged is the status of the tombstone left by a merge, see MergedInto
+	StatusMerged = "merged"
+)
+
 // Account model
 type Account struct {
-	AccountID string `json:"account_id" bson:"account_id"`
+	AccountID     string     `json:"account_id" bson:"account_id"`
+	ParentID      string     `json:"parent_id,omitempty" bson:"parent_id,omitempty"`
+	Status        string     `json:"status,omitempty" bson:"status,omitempty"`
+	Name          string     `json:"name,omitempty" bson:"name,omitempty"`
+	Email         string     `json:"email,omitempty" bson:"email,omitempty"`
+	EmailVerified bool       `json:"email_verified,omitempty" bson:"email_verified,omitempty"`
+	Version       int        `json:"version,omitempty" bson:"version,omitempty"`
+	CreatedAt     *time.Time `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt     *time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	MergedInto    string     `json:"merged_into,omitempty" bson:"merged_into,omitempty"`
+	// Labels are used by selectors, Annotations are free-form
+	Labels      map[string]string `json:"labels,omitempty" bson:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty" bson:"annotations,omitempty"`
+	// Custom fields are defined, per tenant, by the schema of the tenant
+	TenantID string                 `json:"tenant_id,omitempty" bson:"tenant_id,omitempty"`
+	Custom   map[string]interface{} `json:"custom,omitempty" bson:"custom,omitempty"`
 }
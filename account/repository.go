@@ -2,13 +2,36 @@ package account
 
 import (
 	"context"
+	"time"
 )
 
+// Write is one of the writes applied at once by Repository.WriteAccounts
+type Write struct {
+	// Before is the Account as it was read
+	Before Account
+	// After is the next version of the Account, nil deletes it
+	After *Account
+}
+
 // Repository represents an user repository interface
 type Repository interface {
 	GetAccount(ctx context.Context, id string) (*Account, error)
-	GetAccounts(ctx context.Context, filter Filter, pagination Pagination) ([]*Account, error)
+	// GetAccounts returns a page of the matching Accounts and, when the pagination asks for it, their total
+	GetAccounts(ctx context.Context, filter Filter, pagination Pagination) (accounts []*Account, total int, err error)
+	ExportAccounts(ctx context.Context, filter Filter, fn func(*Account) error) error
+	// UpdateAccount writes the version of an Account following the stored one, ErrConflict is returned otherwise
 	UpdateAccount(ctx context.Context, u Account) error
+	// CreateAccount returns the id of the new Account, the one it was given if any. The
+	// creator of the context, if any, is made its owner member with it, see CreatorFromContext.
 	CreateAccount(ctx context.Context, u Account) (string, error)
 	DeleteAccount(ctx context.Context, id string) error
+	// WriteAccounts applies all the writes or none of them. ErrConflict is returned when one
+	// of the written Accounts, or of the unchanged ones, is no longer at the version it was read at.
+	WriteAccounts(ctx context.Context, writes []Write, unchanged []Account) error
+	// GetAccountVersion returns the snapshot of an Account taken when it reached the version
+	GetAccountVersion(ctx context.Context, id string, version int) (*Account, error)
+	// GetAccountAsOf returns the snapshot of an Account that was current at the given time
+	GetAccountAsOf(ctx context.Context, id string, at time.Time) (*Account, error)
+	// CountAccounts counts the Accounts matching the filter by group, see StatsGroup.KeyOf, sorted by key
+	CountAccounts(ctx context.Context, filter Filter, group StatsGroup) ([]StatsCount, error)
 }
@@ -2,6 +2,7 @@ package account
 
 import (
 	"context"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -18,9 +19,24 @@ func (m *mockedAccountRepository) GetAccount(ctx context.Context, id string) (*A
 	return args.Get(0).(*Account), args.Error(1)
 }
 
-func (m *mockedAccountRepository) GetAccounts(ctx context.Context, filter Filter, pagination Pagination) ([]*Account, error) {
+// GetAccounts returns the number of accounts as their total, unless a third value is set
+func (m *mockedAccountRepository) GetAccounts(ctx context.Context, filter Filter, pagination Pagination) ([]*Account, int, error) {
 	args := m.Called(filter, pagination)
-	return args.Get(0).([]*Account), args.Error(1)
+	accounts := args.Get(0).([]*Account)
+	if len(args) > 2 {
+		return accounts, args.Int(1), args.Error(2)
+	}
+	return accounts, len(accounts), args.Error(1)
+}
+
+func (m *mockedAccountRepository) ExportAccounts(ctx context.Context, filter Filter, fn func(*Account) error) error {
+	args := m.Called(filter)
+	for _, a := range args.Get(0).([]*Account) {
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
 }
 
 func (m *mockedAccountRepository) UpdateAccount(ctx context.Context, a Account) error {
@@ -37,3 +53,33 @@ func (m *mockedAccountRepository) DeleteAccount(ctx context.Context, id string)
 	args := m.Called(id)
 	return args.Error(0)
 }
+
+func (m *mockedAccountRepository) WriteAccounts(ctx context.Context, writes []Write, unchanged []Account) error {
+	args := m.Called(writes, unchanged)
+	return args.Error(0)
+}
+
+func (m *mockedAccountRepository) GetAccountVersion(ctx context.Context, id string, version int) (*Account, error) {
+	args := m.Called(id, version)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*Account), args.Error(1)
+}
+
+func (m *mockedAccountRepository) GetAccountAsOf(ctx context.Context, id string, at time.Time) (*Account, error) {
+	args := m.Called(id, at)
+
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*Account), args.Error(1)
+}
+
+func (m *mockedAccountRepository) CountAccounts(ctx context.Context, filter Filter, group StatsGroup) ([]StatsCount, error) {
+	args := m.Called(filter, group)
+	return args.Get(0).([]StatsCount), args.Error(1)
+}
@@ -0,0 +1,217 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidQuery is used when a search query has no term, or its limit is out of bounds
+var ErrInvalidQuery = errors.New("invalid search query")
+
+// ErrSearchUnavailable is used when searching without a Searcher
+var ErrSearchUnavailable = errors.New("search is not available")
+
+// Bounds of the number of results of a search
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// Searcher finds the candidates of a search: the Accounts with, for every term,
+// a word of their name or email starting with it. The Service ranks them and
+// keeps the best ones, a Searcher can return more than limit of them.
+type Searcher interface {
+	SearchAccounts(ctx context.Context, terms []string, limit int) ([]*Account, error)
+}
+
+// ServiceSearcher sets the Searcher of the Service, by default the Repository
+// is used when it implements Searcher
+func ServiceSearcher(searcher Searcher) ServiceOption {
+	return func(s *service) { s.searcher = searcher }
+}
+
+// SearchResult is an Account found by a search, with its score and its fields
+// the terms were found in, where they are wrapped in <em> tags and the rest is
+// HTML escaped.
+type SearchResult struct {
+	Account    *Account          `json:"account"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// searchFields are the fields searched, with the weight of their matches
+var searchFields = []struct {
+	name   string
+	weight float64
+	value  func(*Account) string
+}{
+	{"name", 2, func(a *Account) string { return a.Name }},
+	{"email", 1, func(a *Account) string { return a.Email }},
+}
+
+// SearchAccounts returns the Accounts matching the query, best first
+func (s service) SearchAccounts(ctx context.Context, query string, limit int) ([]*SearchResult, error) {
+	terms := SearchTerms(query)
+	if len(terms) == 0 || limit <= 0 || limit > MaxSearchLimit {
+		return nil, ErrInvalidQuery
+	}
+	if s.searcher == nil {
+		return nil, ErrSearchUnavailable
+	}
+
+	candidates, err := s.searcher.SearchAccounts(ctx, terms, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*SearchResult{}
+	for _, a := range candidates {
+		if a.Status == StatusMerged {
+			continue
+		}
+		if r := match(terms, a); r != nil {
+			results = append(results, r)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Account.AccountID < results[j].Account.AccountID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// word is a word of a text, at its byte offsets in the text
+type word struct {
+	text       string
+	start, end int
+}
+
+// words splits a text on everything but letters and digits, "john.doe@example.com"
+// is made of the words john, doe, example and com
+func words(s string) []word {
+	var ws []word
+
+	start := -1
+	for i, r := range s + " " {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			ws = append(ws, word{strings.ToLower(s[start:i]), start, i})
+			start = -1
+		}
+	}
+
+	return ws
+}
+
+// SearchTerms returns the lower case words of a text, without duplicates, as they are indexed and searched
+func SearchTerms(s string) []string {
+	var terms []string
+
+	seen := map[string]bool{}
+	for _, w := range words(s) {
+		if !seen[w.text] {
+			seen[w.text] = true
+			terms = append(terms, w.text)
+		}
+	}
+
+	return terms
+}
+
+// AccountSearchTerms returns the terms an Account can be found by
+func AccountSearchTerms(a *Account) []string {
+	var texts []string
+	for _, f := range searchFields {
+		texts = append(texts, f.value(a))
+	}
+
+	return SearchTerms(strings.Join(texts, " "))
+}
+
+// match scores an Account against the terms, nil when a term is in none of its
+// fields. A term matching a whole word counts twice as much as a prefix.
+func match(terms []string, a *Account) *SearchResult {
+	r := &SearchResult{Account: a, Highlights: map[string]string{}}
+	spans := make([][]word, len(searchFields))
+
+	for _, term := range terms {
+		best := 0.0
+		for i, f := range searchFields {
+			value := f.value(a)
+			for _, w := range words(value) {
+				if !strings.HasPrefix(w.text, term) {
+					continue
+				}
+
+				score, end := f.weight, w.end
+				if w.text == term {
+					score *= 2
+				} else if len(w.text) == w.end-w.start {
+					// the prefix has the same length in the original text
+					end = w.start + len(term)
+				}
+
+				spans[i] = append(spans[i], word{start: w.start, end: end})
+				if score > best {
+					best = score
+				}
+			}
+		}
+
+		if best == 0 {
+			return nil
+		}
+		r.Score += best
+	}
+
+	for i, f := range searchFields {
+		if len(spans[i]) > 0 {
+			r.Highlights[f.name] = highlight(f.value(a), spans[i])
+		}
+	}
+
+	return r
+}
+
+// highlight wraps the spans of the text in <em> tags, and escapes the rest
+func highlight(s string, spans []word) string {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	// a word matched by several terms gives overlapping spans
+	merged := spans[:1]
+	for _, span := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if span.start > last.end {
+			merged = append(merged, span)
+		} else if span.end > last.end {
+			last.end = span.end
+		}
+	}
+
+	var b strings.Builder
+	at := 0
+	for _, span := range merged {
+		b.WriteString(html.EscapeString(s[at:span.start]))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(s[span.start:span.end]))
+		b.WriteString("</em>")
+		at = span.end
+	}
+	b.WriteString(html.EscapeString(s[at:]))
+
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package account
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SearchTerms(t *testing.T) {
+	assert.Equal(t, []string{"john", "doe", "example", "com"}, SearchTerms("John.Doe@example.com john"))
+	assert.Empty(t, SearchTerms(" .@ "))
+}
+
+func Test_Match(t *testing.T) {
+	a := &Account{Name: "Jonathan <Doe>", Email: "jdoe@example.com"}
+
+	r := match([]string{"jon", "doe"}, a)
+
+	assert.Equal(t, 2.0+4.0, r.Score)
+	assert.Equal(t, map[string]string{"name": "<em>Jon</em>athan &lt;<em>Doe</em>&gt;"}, r.Highlights)
+
+	assert.Nil(t, match([]string{"jon", "smith"}, a))
+}
+
+// fakeSearcher returns its Accounts as the candidates of every search
+type fakeSearcher []*Account
+
+func (f fakeSearcher) SearchAccounts(ctx context.Context, terms []string, limit int) ([]*Account, error) {
+	return f, nil
+}
+
+func Test_SearchAccounts_Should_Rank_The_Results(t *testing.T) {
+	searcher := fakeSearcher{
+		{AccountID: "1", Email: "doe@example.com"},
+		{AccountID: "2", Name: "Doe"},
+		{AccountID: "3", Name: "Doeling"},
+		{AccountID: "4", Name: "Doe", Status: StatusMerged},
+		{AccountID: "5", Name: "Jane"},
+	}
+
+	svc := NewService(new(mockedAccountRepository), ServiceSearcher(searcher))
+	results, err := svc.SearchAccounts(context.Background(), "DOE", 2)
+
+	assert.Nil(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "2", results[0].Account.AccountID)
+		assert.Equal(t, "1", results[1].Account.AccountID)
+		assert.Equal(t, 2.0, results[1].Score)
+		assert.Equal(t, "<em>doe</em>@example.com", results[1].Highlights["email"])
+	}
+}
+
+func Test_SearchAccounts_Should_Return_An_Error(t *testing.T) {
+	svc := NewService(new(mockedAccountRepository))
+
+	_, err := svc.SearchAccounts(context.Background(), "doe", 10)
+	assert.Equal(t, ErrSearchUnavailable, err)
+
+	svc = NewService(new(mockedAccountRepository), ServiceSearcher(fakeSearcher{}))
+
+	_, err = svc.SearchAccounts(context.Background(), " - ", 10)
+	assert.Equal(t, ErrInvalidQuery, err)
+	_, err = svc.SearchAccounts(context.Background(), "doe", MaxSearchLimit+1)
+	assert.Equal(t, ErrInvalidQuery, err)
+}
@@ -3,6 +3,10 @@ package account
 import (
 	"context"
 	"errors"
+	"net/mail"
+	"time"
+
+	"github.com/go-kit/kit/log"
 )
 
 // ErrNotFound is used when an Account is not found
@@ -11,24 +15,125 @@ var ErrNotFound = errors.New("Account not found")
 // ErrInconsistentID ...
 var ErrInconsistentID = errors.New("inconsistent Accountid")
 
+// ErrInvalidStatus is used when an Account status is not one of the known statuses
+var ErrInvalidStatus = errors.New("invalid Account status")
+
+// ErrInvalidEmail is used when an Account email is not a valid address
+var ErrInvalidEmail = errors.New("invalid Account email")
+
+// ErrEmailTaken is used when an Account is given the email of another one
+var ErrEmailTaken = errors.New("email already used by another Account")
+
+// ErrEmailMismatch is used when verifying an email that is no longer the one of the Account
+var ErrEmailMismatch = errors.New("email does not match the Account")
+
+// ErrParentNotFound is used when the parent of an Account does not exist
+var ErrParentNotFound = errors.New("parent Account not found")
+
+// ErrCycle is used when the parent of an Account is the Account itself or one of its descendants
+var ErrCycle = errors.New("parent Account would create a cycle")
+
+// ErrParentClosed is used when an active Account is given a closed parent
+var ErrParentClosed = errors.New("parent Account is closed")
+
+// ErrOtherTenant is used when the account making a request writes an Account of another tenant than its own
+var ErrOtherTenant = errors.New("Account belongs to another tenant")
+
+// ErrConflict is used when an Account was changed by another write since it was read
+var ErrConflict = errors.New("Account changed concurrently, read it again")
+
+// ErrInvalidDepth is used when the depth of a descendants request is out of bounds
+var ErrInvalidDepth = errors.New("invalid depth")
+
+// ErrHierarchyTooLarge is used when the hierarchy walked by a request has more levels or Accounts than the maximum
+var ErrHierarchyTooLarge = errors.New("Account hierarchy too large")
+
+// MaxDescendantsDepth is the maximum number of levels returned by GetDescendants
+const MaxDescendantsDepth = 10
+
+// MaxHierarchyDepth is the maximum number of levels walked up or down the hierarchy of an Account
+const MaxHierarchyDepth = 32
+
+// MaxSubtreeSize is the maximum number of Accounts read, or written at once, from the hierarchy of an Account
+const MaxSubtreeSize = 1000
+
 // Service is the Order service interface
 type Service interface {
 	GetAccount(ctx context.Context, id string) (*Account, error)
 	GetAccounts(ctx context.Context, filter Filter, pagination Pagination) ([]*Account, error)
+	GetAccountsPage(ctx context.Context, filter Filter, pagination Pagination) (*AccountsPage, error)
+	ExportAccounts(ctx context.Context, filter Filter, fn func(*Account) error) error
 	UpdateAccount(ctx context.Context, Account Account) error
 	CreateAccount(ctx context.Context, Account Account) (string, error)
+	ValidateAccount(ctx context.Context, Account Account) error
 	DeleteAccount(ctx context.Context, id string) error
+	GetAccountHistory(ctx context.Context, id string, pagination Pagination) ([]*AuditEntry, error)
+	GetAccountVersion(ctx context.Context, id string, version int) (*Account, error)
+	GetAccountAsOf(ctx context.Context, id string, at time.Time) (*Account, error)
+	RevertAccount(ctx context.Context, id string, version int) error
+	VerifyEmail(ctx context.Context, id string, email string) error
+	GetChildren(ctx context.Context, id string, pagination Pagination) ([]*Account, error)
+	GetDescendants(ctx context.Context, id string, depth int) ([]*Account, error)
+	MergeAccounts(ctx context.Context, targetID, sourceID string, rules MergeRules) (*Account, error)
+	SearchAccounts(ctx context.Context, query string, limit int) ([]*SearchResult, error)
+	GetAccountStats(ctx context.Context, filter Filter, group StatsGroup) (*Stats, error)
 }
 
 type service struct {
-	repository Repository
+	repository      Repository
+	publisher       Publisher
+	auditLog        AuditLog
+	history         AuditHistory
+	mergeAuthorizer MergeAuthorizer
+	schemas         Schemas
+	searcher        Searcher
+	statsCache      *statsCache
+	logger          log.Logger
+}
+
+// ServiceOption sets an optional parameter of the Service
+type ServiceOption func(*service)
+
+// ServicePublisher sets the Publisher notified after each successful write
+func ServicePublisher(p Publisher) ServiceOption {
+	return func(s *service) { s.publisher = p }
+}
+
+// ServiceAuditLog sets the AuditLog recording every successful write
+func ServiceAuditLog(l AuditLog) ServiceOption {
+	return func(s *service) { s.auditLog, s.history = l, l }
+}
+
+// ServiceAuditHistory sets where the history of the Accounts is read from,
+// when the Repository records the AuditEntry of each write with the write
+func ServiceAuditHistory(h AuditHistory) ServiceOption {
+	return func(s *service) { s.history = h }
+}
+
+// ServiceLogger sets the Logger of the errors that do not fail a write, such as the publication ones
+func ServiceLogger(l log.Logger) ServiceOption {
+	return func(s *service) { s.logger = l }
 }
 
 // NewService return a new instance of order service
-func NewService(r Repository) Service {
-	return service{
+func NewService(r Repository, options ...ServiceOption) Service {
+	s := service{
 		repository: r,
+		publisher:  nopPublisher{},
+		auditLog:   nopAuditLog{},
+		history:    nopAuditLog{},
+		schemas:    nopSchemas{},
+		logger:     log.NewNopLogger(),
+	}
+	if searcher, ok := r.(Searcher); ok {
+		s.searcher = searcher
 	}
+
+	for _, option := range options {
+		option(&s)
+	}
+
+	return s
 }
 
 // GetAccount returns an Account regarding the id passed in parameter
@@ -42,27 +147,564 @@ func (s service) GetAccount(ctx context.Context, id string) (a *Account, err err
 	return
 }
 
-// GetAccounts returns a list of Accounts regarding the ids passed in parameter
-func (s service) GetAccounts(ctx context.Context, filter Filter, pagination Pagination) (accounts []*Account, err error) {
+// getForWrite returns an Account as it is stored, bypassing the cache of the
+// Repository if it is a FreshReader: the Accounts read for a write are
+// written over, or asserted unchanged, at the version read
+func (s service) getForWrite(ctx context.Context, id string) (a *Account, err error) {
+	if r, ok := s.repository.(FreshReader); ok {
+		a, err = r.GetAccountFresh(ctx, id)
+	} else {
+		a, err = s.repository.GetAccount(ctx, id)
+	}
 
-	accounts, err = s.repository.GetAccounts(ctx, filter, pagination)
+	if a == nil {
+		err = ErrNotFound
+	}
 
 	return
 }
 
+// GetAccounts returns a list of Accounts regarding the ids passed in parameter
+func (s service) GetAccounts(ctx context.Context, filter Filter, pagination Pagination) ([]*Account, error) {
+	pagination.Count = false
+
+	page, err := s.GetAccountsPage(ctx, filter, pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Items, nil
+}
+
+// GetAccountsPage returns a page of the Accounts matching the filter, and their total when the pagination asks for it
+func (s service) GetAccountsPage(ctx context.Context, filter Filter, pagination Pagination) (*AccountsPage, error) {
+	page := &AccountsPage{Items: []*Account{}, Pagination: pagination}
+	if pagination.Count {
+		page.Total = new(int)
+	}
+
+	filter, err := s.resolveCustom(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	filter, ok, err := s.resolveSubtree(ctx, filter)
+	if err != nil || !ok {
+		return page, err
+	}
+
+	accounts, total, err := s.repository.GetAccounts(ctx, filter, pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	if accounts != nil {
+		page.Items = accounts
+	}
+	if page.Total != nil {
+		*page.Total = total
+	}
+	return page, nil
+}
+
+// ExportAccounts calls fn for every Account matching the filter, one at a time
+func (s service) ExportAccounts(ctx context.Context, filter Filter, fn func(*Account) error) error {
+	filter, err := s.resolveCustom(ctx, filter)
+	if err != nil {
+		return err
+	}
+	filter, ok, err := s.resolveSubtree(ctx, filter)
+	if err != nil || !ok {
+		return err
+	}
+
+	return s.repository.ExportAccounts(ctx, filter, fn)
+}
+
 // UpdateAccount updates an existing Account
 func (s service) UpdateAccount(ctx context.Context, a Account) error {
+	if err := validate(&a); err != nil {
+		return err
+	}
+
+	before, err := s.getForWrite(ctx, a.AccountID)
+	if err != nil {
+		return err
+	}
+	if before.Status == StatusMerged {
+		return ErrMerged
+	}
+	if err := s.checkTenant(ctx, before.TenantID, a.TenantID); err != nil {
+		return err
+	}
+	if err := s.schemas.ValidateCustom(ctx, a.TenantID, a.Custom); err != nil {
+		return err
+	}
+
+	// only a merge tombstones an Account
+	a.MergedInto = ""
+
+	// the ancestors checked are written unchanged with the Account, so that
+	// none of them becomes a descendant or is closed in the meantime
+	var ancestors []Account
+	if a.ParentID != before.ParentID || a.Status != before.Status {
+		if ancestors, err = s.checkParent(ctx, &a); err != nil {
+			return err
+		}
+	}
+
+	// only VerifyEmail marks an email as verified, and changing it requires a new verification
+	a.EmailVerified = before.EmailVerified && before.Email == a.Email
+
+	writes := []Write{{Before: *before, After: &a}}
+	if before.Status != StatusClosed && a.Status == StatusClosed {
+		closed, err := s.closeDescendants(ctx, a.AccountID)
+		if err != nil {
+			return err
+		}
+		writes = append(writes, closed...)
+	}
+
+	return s.write(ctx, writes, ancestors)
+}
+
+// VerifyEmail marks the email of an Account as verified, if it still is the given one
+func (s service) VerifyEmail(ctx context.Context, id string, email string) error {
+	before, err := s.getForWrite(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if len(before.Email) == 0 || before.Email != email {
+		return ErrEmailMismatch
+	}
+	if before.EmailVerified {
+		return nil
+	}
+
+	a := *before
+	a.EmailVerified = true
+
+	return s.update(ctx, before, a)
+}
+
+// update writes a as the next version of before
+func (s service) update(ctx context.Context, before *Account, a Account) error {
+	return s.write(ctx, []Write{{Before: *before, After: &a}}, nil)
+}
+
+// write applies the writes at once, as long as the unchanged Accounts are still
+// at the version they were read at. A merged Account can not be written anymore.
+func (s service) write(ctx context.Context, writes []Write, unchanged []Account) error {
+	for _, w := range writes {
+		if a := w.After; a != nil {
+			if w.Before.Status == StatusMerged {
+				return ErrMerged
+			}
+
+			a.Version = w.Before.Version + 1
+			a.CreatedAt = w.Before.CreatedAt
+			a.UpdatedAt = now()
+		}
+	}
+
+	var err error
+	switch w := writes[0]; {
+	case len(writes) > 1 || len(unchanged) > 0:
+		err = s.repository.WriteAccounts(ctx, writes, unchanged)
+	case w.After != nil:
+		err = s.repository.UpdateAccount(ctx, *w.After)
+	default:
+		err = s.repository.DeleteAccount(ctx, w.Before.AccountID)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, w := range writes {
+		before := w.Before
+		if w.After == nil {
+			s.publish(ctx, AccountDeleted{OccurredAt: time.Now().UTC(), Before: before})
+			err = s.audit(ctx, OperationDelete, before.AccountID, &before, nil)
+		} else {
+			s.publish(ctx, AccountUpdated{OccurredAt: time.Now().UTC(), Before: before, After: *w.After})
+			err = s.audit(ctx, OperationUpdate, before.AccountID, &before, w.After)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 // CreateAccount creates an Account
 func (s service) CreateAccount(ctx context.Context, a Account) (id string, err error) {
+	if err = s.check(ctx, &a); err != nil {
+		return
+	}
+
+	a.EmailVerified = false
+	a.MergedInto = ""
+	a.Version = 1
+	a.UpdatedAt = now()
+	a.CreatedAt = a.UpdatedAt
+
 	id, err = s.repository.CreateAccount(ctx, a)
+	if err != nil {
+		return
+	}
+
+	a.AccountID = id
+	s.publish(ctx, AccountCreated{OccurredAt: time.Now().UTC(), After: a})
+	err = s.audit(ctx, OperationCreate, id, nil, &a)
 	return
 }
 
+// ValidateAccount checks an Account as CreateAccount does, without writing it
+func (s service) ValidateAccount(ctx context.Context, a Account) error {
+	return s.check(ctx, &a)
+}
+
+// check validates an Account written, its tenant, parent and custom fields
+func (s service) check(ctx context.Context, a *Account) error {
+	if err := validate(a); err != nil {
+		return err
+	}
+	if err := s.checkTenant(ctx, a.TenantID); err != nil {
+		return err
+	}
+	if _, err := s.checkParent(ctx, a); err != nil {
+		return err
+	}
+
+	return s.schemas.ValidateCustom(ctx, a.TenantID, a.Custom)
+}
+
 // DeleteAccount deletes an account
 func (s service) DeleteAccount(ctx context.Context, id string) (err error) {
-	err = s.repository.DeleteAccount(ctx, id)
+	before, err := s.getForWrite(ctx, id)
+	if err != nil {
+		return
+	}
+	if err = s.checkTenant(ctx, before.TenantID); err != nil {
+		return
+	}
+
+	// the children are attached to the parent of the deleted Account, or
+	// become roots, in the same write as the deletion
+	children, err := s.children(ctx, []string{id})
+	if err != nil {
+		return
+	}
+
+	writes := make([]Write, 0, len(children)+1)
+	for _, child := range children {
+		c := *child
+		c.ParentID = before.ParentID
+		writes = append(writes, Write{Before: *child, After: &c})
+	}
+
+	return s.write(ctx, append(writes, Write{Before: *before}), nil)
+}
+
+// GetAccountHistory returns the audit trail of an Account, newest first
+func (s service) GetAccountHistory(ctx context.Context, id string, pagination Pagination) ([]*AuditEntry, error) {
+	return s.history.History(ctx, id, pagination)
+}
+
+// validate checks the Account before it is written, and sets the default values
+func validate(a *Account) error {
+	switch a.Status {
+	case "":
+		a.Status = StatusActive
+	case StatusActive, StatusClosed:
+	default:
+		return ErrInvalidStatus
+	}
+
+	if len(a.Email) > 0 {
+		addr, err := mail.ParseAddress(a.Email)
+		if err != nil || addr.Address != a.Email {
+			return ErrInvalidEmail
+		}
+	}
+
+	if len(a.Custom) == 0 {
+		a.Custom = nil
+	}
+
+	return validateMetadata(a)
+}
+
+// GetAccountVersion returns an Account as it was at the given version
+func (s service) GetAccountVersion(ctx context.Context, id string, version int) (a *Account, err error) {
+	a, err = s.repository.GetAccountVersion(ctx, id, version)
+
+	if a == nil {
+		err = ErrNotFound
+	}
+
 	return
 }
+
+// GetAccountAsOf returns an Account as it was at the given time
+func (s service) GetAccountAsOf(ctx context.Context, id string, at time.Time) (a *Account, err error) {
+	a, err = s.repository.GetAccountAsOf(ctx, id, at)
+
+	if a == nil {
+		err = ErrNotFound
+	}
+
+	return
+}
+
+// RevertAccount restores the content an Account had at a previous version, as a new version
+func (s service) RevertAccount(ctx context.Context, id string, version int) error {
+	a, err := s.GetAccountVersion(ctx, id, version)
+	if err != nil {
+		return err
+	}
+
+	return s.UpdateAccount(ctx, *a)
+}
+
+// GetChildren returns the direct children of an Account
+func (s service) GetChildren(ctx context.Context, id string, pagination Pagination) ([]*Account, error) {
+	if _, err := s.GetAccount(ctx, id); err != nil {
+		return nil, err
+	}
+
+	pagination.Count = false
+	accounts, _, err := s.repository.GetAccounts(ctx, Filter{ParentIDs: []string{id}}, pagination)
+	return accounts, err
+}
+
+// GetDescendants returns the descendants of an Account down to depth levels,
+// level by level
+func (s service) GetDescendants(ctx context.Context, id string, depth int) ([]*Account, error) {
+	if depth < 1 || depth > MaxDescendantsDepth {
+		return nil, ErrInvalidDepth
+	}
+
+	if _, err := s.GetAccount(ctx, id); err != nil {
+		return nil, err
+	}
+
+	descendants := []*Account{}
+	seen := map[string]bool{id: true}
+	level := []string{id}
+	for i := 0; i < depth && len(level) > 0; i++ {
+		children, err := s.children(ctx, level)
+		if err != nil {
+			return nil, err
+		}
+
+		level = nil
+		for _, c := range children {
+			if !seen[c.AccountID] {
+				seen[c.AccountID] = true
+				level = append(level, c.AccountID)
+				descendants = append(descendants, c)
+			}
+		}
+	}
+
+	return descendants, nil
+}
+
+// children returns the direct children of the Accounts, ErrHierarchyTooLarge
+// when there are more than MaxSubtreeSize of them
+func (s service) children(ctx context.Context, ids []string) ([]*Account, error) {
+	children := []*Account{}
+	err := s.repository.ExportAccounts(ctx, Filter{ParentIDs: ids}, func(a *Account) error {
+		if len(children) == MaxSubtreeSize {
+			return ErrHierarchyTooLarge
+		}
+		children = append(children, a)
+		return nil
+	})
+
+	return children, err
+}
+
+// resolveSubtree replaces the Subtree of a filter with the ids of the Accounts
+// in the subtree, false is returned when no Account can match
+func (s service) resolveSubtree(ctx context.Context, filter Filter) (Filter, bool, error) {
+	if len(filter.Subtree) == 0 {
+		return filter, true, nil
+	}
+
+	ids := []string{filter.Subtree}
+	seen := map[string]bool{filter.Subtree: true}
+	for depth, level := 0, ids; len(level) > 0; depth++ {
+		if depth == MaxHierarchyDepth {
+			return filter, false, ErrHierarchyTooLarge
+		}
+
+		children, err := s.children(ctx, level)
+		if err != nil {
+			return filter, false, err
+		}
+
+		level = nil
+		for _, c := range children {
+			if !seen[c.AccountID] {
+				seen[c.AccountID] = true
+				level = append(level, c.AccountID)
+				ids = append(ids, c.AccountID)
+			}
+		}
+		if len(ids) > MaxSubtreeSize {
+			return filter, false, ErrHierarchyTooLarge
+		}
+	}
+
+	if len(filter.IDs) > 0 {
+		var both []string
+		for _, id := range filter.IDs {
+			if seen[id] {
+				both = append(both, id)
+			}
+		}
+		ids = both
+	}
+
+	filter.IDs = ids
+	filter.Subtree = ""
+	return filter, len(ids) > 0, nil
+}
+
+// checkTenant returns ErrOtherTenant unless the tenants are the one of the
+// account making the request, if any, see CreatorFromContext
+func (s service) checkTenant(ctx context.Context, tenantIDs ...string) error {
+	id := CreatorFromContext(ctx)
+	if len(id) == 0 {
+		return nil
+	}
+
+	caller, err := s.getForWrite(ctx, id)
+	if err == ErrNotFound {
+		return ErrOtherTenant
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, tenantID := range tenantIDs {
+		if tenantID != caller.TenantID {
+			return ErrOtherTenant
+		}
+	}
+	return nil
+}
+
+// checkParent checks the parent of an Account exists, is not closed while the
+// Account is active, and is not the Account itself or one of its descendants.
+// The ancestors read for the checks are returned, the parent first.
+func (s service) checkParent(ctx context.Context, a *Account) ([]Account, error) {
+	if len(a.ParentID) == 0 {
+		return nil, nil
+	}
+	if a.ParentID == a.AccountID {
+		return nil, ErrCycle
+	}
+
+	parent, err := s.getForWrite(ctx, a.ParentID)
+	if err == ErrNotFound {
+		return nil, ErrParentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Status == StatusActive && parent.Status == StatusClosed {
+		return nil, ErrParentClosed
+	}
+
+	ancestors := []Account{*parent}
+	// a new Account can not be the ancestor of its parent
+	if len(a.AccountID) == 0 {
+		return ancestors, nil
+	}
+
+	seen := map[string]bool{parent.AccountID: true}
+	for p := parent; len(p.ParentID) > 0 && !seen[p.ParentID]; {
+		if p.ParentID == a.AccountID {
+			return nil, ErrCycle
+		}
+		if len(ancestors) == MaxHierarchyDepth {
+			return nil, ErrHierarchyTooLarge
+		}
+		seen[p.ParentID] = true
+
+		p, err = s.getForWrite(ctx, p.ParentID)
+		if err == ErrNotFound {
+			return ancestors, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, *p)
+	}
+
+	return ancestors, nil
+}
+
+// closeDescendants returns the writes closing the active children of closed
+// Accounts, and theirs in turn
+func (s service) closeDescendants(ctx context.Context, ids ...string) ([]Write, error) {
+	var writes []Write
+	seen := map[string]bool{}
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for depth, level := 0, ids; len(level) > 0; depth++ {
+		if depth == MaxHierarchyDepth {
+			return nil, ErrHierarchyTooLarge
+		}
+
+		children, err := s.children(ctx, level)
+		if err != nil {
+			return nil, err
+		}
+
+		level = nil
+		for _, child := range children {
+			if child.Status != StatusActive || seen[child.AccountID] {
+				continue
+			}
+			seen[child.AccountID] = true
+			level = append(level, child.AccountID)
+
+			c := *child
+			c.Status = StatusClosed
+			writes = append(writes, Write{Before: *child, After: &c})
+		}
+		if len(writes) >= MaxSubtreeSize {
+			return nil, ErrHierarchyTooLarge
+		}
+	}
+
+	return writes, nil
+}
+
+// audit records who made a write, and what it changed
+func (s service) audit(ctx context.Context, operation string, id string, before, after *Account) error {
+	return s.auditLog.Append(ctx, NewAuditEntry(ctx, operation, id, before, after))
+}
+
+// publish notifies the publisher of a write that already succeeded, so a
+// publication error is logged, not reported as a failure of the write itself
+func (s service) publish(ctx context.Context, e Event) {
+	if err := s.publisher.Publish(ctx, e); err != nil {
+		env := e.Envelope()
+		s.logger.Log("event", env.Type, "account_id", env.AccountID, "err", err)
+	}
+}
+
+// now returns the update time of an Account, truncated to the millisecond
+// precision of the storage so that it reads back unchanged
+func now() *time.Time {
+	t := time.Now().UTC().Truncate(time.Millisecond)
+	return &t
+}
@@ -3,9 +3,13 @@ package account
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/go-kit/kit/log"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func Test_NewService_Should_Create_New_Service_Instance(t *testing.T) {
@@ -66,3 +70,434 @@ func Test_GetAccounts_Should_Return_OK_If_Params_Is_Valid(t *testing.T) {
 	assert.NotNil(t, u)
 	assert.Nil(t, err)
 }
+
+func Test_ExportAccounts_Should_Call_fn_For_Each_Account(t *testing.T) {
+	f := Filter{IDs: []string{"01234", "56789"}}
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("ExportAccounts", f).Return([]*Account{&Account{AccountID: "01234"}, &Account{AccountID: "56789"}}, nil)
+
+	svc := NewService(fakeRepo)
+	count := 0
+	err := svc.ExportAccounts(context.Background(), f, func(a *Account) error {
+		count++
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func Test_UpdateAccount_Should_Publish_AccountUpdated(t *testing.T) {
+	a := Account{AccountID: "12345", Status: StatusActive}
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", a.AccountID).Return(&Account{AccountID: "12345"}, nil)
+	fakeRepo.On("UpdateAccount", mock.MatchedBy(func(u Account) bool {
+		return u.AccountID == a.AccountID && u.Version == 1 && u.UpdatedAt != nil
+	})).Return(nil)
+	publisher := new(recordingPublisher)
+
+	svc := NewService(fakeRepo, ServicePublisher(publisher))
+	err := svc.UpdateAccount(context.Background(), a)
+
+	assert.Nil(t, err)
+	fakeRepo.AssertExpectations(t)
+	assert.Len(t, publisher.events, 1)
+	assert.Equal(t, EventAccountUpdated, publisher.events[0].Envelope().Type)
+}
+
+func Test_UpdateAccount_Should_Return_ErrNotFound_If_Account_Does_Not_Exist(t *testing.T) {
+	a := Account{AccountID: "12345"}
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", a.AccountID).Return(nil, nil)
+	publisher := new(recordingPublisher)
+
+	svc := NewService(fakeRepo, ServicePublisher(publisher))
+	err := svc.UpdateAccount(context.Background(), a)
+
+	assert.Equal(t, ErrNotFound, err)
+	assert.Empty(t, publisher.events)
+}
+
+func Test_CreateAccount_Should_Publish_AccountCreated_With_The_New_ID(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("CreateAccount", mock.MatchedBy(func(a Account) bool {
+		return a.Status == StatusActive && a.Version == 1
+	})).Return("12345", nil)
+	publisher := new(recordingPublisher)
+
+	svc := NewService(fakeRepo, ServicePublisher(publisher))
+	id, err := svc.CreateAccount(context.Background(), Account{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "12345", id)
+	assert.Len(t, publisher.events, 1)
+	assert.Equal(t, "12345", publisher.events[0].(AccountCreated).After.AccountID)
+}
+
+func Test_CreateAccount_Should_Log_A_Publication_Error(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("CreateAccount", mock.AnythingOfType("Account")).Return("12345", nil)
+	publisher := PublisherFunc(func(ctx context.Context, e Event) error { return errors.New("broker down") })
+	var logged []interface{}
+	logger := log.LoggerFunc(func(keyvals ...interface{}) error {
+		logged = keyvals
+		return nil
+	})
+
+	svc := NewService(fakeRepo, ServicePublisher(publisher), ServiceLogger(logger))
+	_, err := svc.CreateAccount(context.Background(), Account{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"event", EventAccountCreated, "account_id", "12345", "err", errors.New("broker down")}, logged)
+}
+
+func Test_CreateAccount_Should_Not_Publish_If_Repository_Return_Error(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("CreateAccount", mock.AnythingOfType("Account")).Return("", errors.New("error"))
+	publisher := new(recordingPublisher)
+
+	svc := NewService(fakeRepo, ServicePublisher(publisher))
+	_, err := svc.CreateAccount(context.Background(), Account{})
+
+	assert.NotNil(t, err)
+	assert.Empty(t, publisher.events)
+}
+
+func Test_DeleteAccount_Should_Publish_AccountDeleted(t *testing.T) {
+	AccountID := "12345"
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", AccountID).Return(&Account{AccountID: AccountID}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{AccountID}}).Return([]*Account{}, nil)
+	fakeRepo.On("DeleteAccount", AccountID).Return(nil)
+	publisher := new(recordingPublisher)
+
+	svc := NewService(fakeRepo, ServicePublisher(publisher))
+	err := svc.DeleteAccount(context.Background(), AccountID)
+
+	assert.Nil(t, err)
+	assert.Len(t, publisher.events, 1)
+	assert.Equal(t, AccountID, publisher.events[0].(AccountDeleted).Before.AccountID)
+}
+
+func Test_CreateAccount_Should_Return_ErrInvalidStatus(t *testing.T) {
+	svc := NewService(new(mockedAccountRepository))
+	_, err := svc.CreateAccount(context.Background(), Account{Status: "unknown"})
+
+	assert.Equal(t, ErrInvalidStatus, err)
+}
+
+func Test_ValidateAccount_Should_Check_The_Parent_Without_Writing(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "2").Return(nil, nil)
+
+	svc := NewService(fakeRepo)
+	err := svc.ValidateAccount(context.Background(), Account{AccountID: "1", ParentID: "2"})
+
+	assert.Equal(t, ErrParentNotFound, err)
+	fakeRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+}
+
+func Test_DeleteAccount_Should_Record_An_Audit_Entry(t *testing.T) {
+	AccountID := "12345"
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", AccountID).Return(&Account{AccountID: AccountID, Status: StatusActive}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{AccountID}}).Return([]*Account{}, nil)
+	fakeRepo.On("DeleteAccount", AccountID).Return(nil)
+	auditLog := new(recordingAuditLog)
+	ctx := ContextWithRequestID(ContextWithActor(context.Background(), "alice"), "req")
+
+	svc := NewService(fakeRepo, ServiceAuditLog(auditLog))
+	err := svc.DeleteAccount(ctx, AccountID)
+
+	assert.Nil(t, err)
+	assert.Len(t, auditLog.entries, 1)
+	assert.Equal(t, OperationDelete, auditLog.entries[0].Operation)
+	assert.Equal(t, "alice", auditLog.entries[0].Actor)
+	assert.Equal(t, "req", auditLog.entries[0].RequestID)
+	assert.Len(t, auditLog.entries[0].Changes, 2)
+}
+
+func Test_DeleteAccount_Should_Leave_The_Audit_Entry_To_The_Repository(t *testing.T) {
+	AccountID := "12345"
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", AccountID).Return(&Account{AccountID: AccountID, Status: StatusActive}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{AccountID}}).Return([]*Account{}, nil)
+	fakeRepo.On("DeleteAccount", AccountID).Return(nil)
+	auditLog := new(recordingAuditLog)
+
+	svc := NewService(fakeRepo, ServiceAuditHistory(auditLog))
+	err := svc.DeleteAccount(context.Background(), AccountID)
+
+	assert.Nil(t, err)
+	assert.Empty(t, auditLog.entries)
+}
+
+func Test_RevertAccount_Should_Update_With_The_Snapshot_As_A_New_Version(t *testing.T) {
+	AccountID := "12345"
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccountVersion", AccountID, 1).Return(&Account{AccountID: AccountID, Status: StatusActive, Version: 1}, nil)
+	fakeRepo.On("GetAccount", AccountID).Return(&Account{AccountID: AccountID, Status: StatusClosed, Version: 2}, nil)
+	fakeRepo.On("UpdateAccount", mock.MatchedBy(func(a Account) bool {
+		return a.Status == StatusActive && a.Version == 3
+	})).Return(nil)
+
+	svc := NewService(fakeRepo)
+	err := svc.RevertAccount(context.Background(), AccountID, 1)
+
+	assert.Nil(t, err)
+	fakeRepo.AssertExpectations(t)
+}
+
+func Test_RevertAccount_Should_Return_ErrNotFound_If_Version_Does_Not_Exist(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccountVersion", "12345", 7).Return(nil, nil)
+
+	svc := NewService(fakeRepo)
+	err := svc.RevertAccount(context.Background(), "12345", 7)
+
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func Test_GetAccountAsOf_Should_Return_ErrNotFound_If_No_Snapshot(t *testing.T) {
+	at := time.Now()
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccountAsOf", "12345", at).Return(nil, nil)
+
+	svc := NewService(fakeRepo)
+	a, err := svc.GetAccountAsOf(context.Background(), "12345", at)
+
+	assert.Nil(t, a)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func Test_CreateAccount_Should_Return_ErrInvalidEmail(t *testing.T) {
+	svc := NewService(new(mockedAccountRepository))
+	_, err := svc.CreateAccount(context.Background(), Account{Email: "not an email"})
+
+	assert.Equal(t, ErrInvalidEmail, err)
+}
+
+func Test_UpdateAccount_Should_Reset_EmailVerified_When_Email_Changes(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "12345").Return(&Account{AccountID: "12345", Email: "a@example.com", EmailVerified: true}, nil)
+	fakeRepo.On("UpdateAccount", mock.MatchedBy(func(a Account) bool {
+		return a.Email == "b@example.com" && !a.EmailVerified
+	})).Return(nil)
+
+	svc := NewService(fakeRepo)
+	err := svc.UpdateAccount(context.Background(), Account{AccountID: "12345", Email: "b@example.com", EmailVerified: true})
+
+	assert.Nil(t, err)
+	fakeRepo.AssertExpectations(t)
+}
+
+func Test_VerifyEmail_Should_Mark_The_Email_As_Verified(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "12345").Return(&Account{AccountID: "12345", Email: "a@example.com", Version: 1}, nil)
+	fakeRepo.On("UpdateAccount", mock.MatchedBy(func(a Account) bool {
+		return a.EmailVerified && a.Version == 2
+	})).Return(nil)
+
+	svc := NewService(fakeRepo)
+	err := svc.VerifyEmail(context.Background(), "12345", "a@example.com")
+
+	assert.Nil(t, err)
+	fakeRepo.AssertExpectations(t)
+}
+
+func Test_VerifyEmail_Should_Return_ErrEmailMismatch(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "12345").Return(&Account{AccountID: "12345", Email: "b@example.com"}, nil)
+
+	svc := NewService(fakeRepo)
+	err := svc.VerifyEmail(context.Background(), "12345", "a@example.com")
+
+	assert.Equal(t, ErrEmailMismatch, err)
+}
+
+func Test_CreateAccount_Should_Check_The_Parent(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "unknown").Return(nil, nil)
+	fakeRepo.On("GetAccount", "closed").Return(&Account{AccountID: "closed", Status: StatusClosed}, nil)
+
+	svc := NewService(fakeRepo)
+
+	_, err := svc.CreateAccount(context.Background(), Account{ParentID: "unknown"})
+	assert.Equal(t, ErrParentNotFound, err)
+
+	_, err = svc.CreateAccount(context.Background(), Account{ParentID: "closed"})
+	assert.Equal(t, ErrParentClosed, err)
+}
+
+func Test_UpdateAccount_Should_Return_ErrCycle(t *testing.T) {
+	// 1 <- 2 <- 3, moving 1 under 3 would make a cycle
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "1").Return(&Account{AccountID: "1", Status: StatusActive}, nil)
+	fakeRepo.On("GetAccount", "2").Return(&Account{AccountID: "2", Status: StatusActive, ParentID: "1"}, nil)
+	fakeRepo.On("GetAccount", "3").Return(&Account{AccountID: "3", Status: StatusActive, ParentID: "2"}, nil)
+
+	svc := NewService(fakeRepo)
+
+	assert.Equal(t, ErrCycle, svc.UpdateAccount(context.Background(), Account{AccountID: "1", ParentID: "3"}))
+	assert.Equal(t, ErrCycle, svc.UpdateAccount(context.Background(), Account{AccountID: "1", ParentID: "1"}))
+	fakeRepo.AssertNotCalled(t, "UpdateAccount", mock.Anything)
+}
+
+func Test_UpdateAccount_Should_Close_The_Children_Of_A_Closed_Account(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "1").Return(&Account{AccountID: "1", Status: StatusActive}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{"1"}}).Return([]*Account{{AccountID: "2", Status: StatusActive, ParentID: "1"}}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{"2"}}).Return([]*Account{}, nil)
+	fakeRepo.On("WriteAccounts", mock.MatchedBy(func(writes []Write) bool {
+		return len(writes) == 2 && writes[0].After.AccountID == "1" && writes[1].After.AccountID == "2" &&
+			writes[0].After.Status == StatusClosed && writes[1].After.Status == StatusClosed
+	}), []Account(nil)).Return(nil)
+
+	svc := NewService(fakeRepo)
+	err := svc.UpdateAccount(context.Background(), Account{AccountID: "1", Status: StatusClosed})
+
+	assert.Nil(t, err)
+	fakeRepo.AssertExpectations(t)
+}
+
+func Test_DeleteAccount_Should_Attach_The_Children_To_The_Parent(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "2").Return(&Account{AccountID: "2", Status: StatusActive, ParentID: "1"}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{"2"}}).Return([]*Account{{AccountID: "3", Status: StatusActive, ParentID: "2"}}, nil)
+	fakeRepo.On("WriteAccounts", mock.MatchedBy(func(writes []Write) bool {
+		return len(writes) == 2 && writes[0].After.AccountID == "3" && writes[0].After.ParentID == "1" &&
+			writes[1].Before.AccountID == "2" && writes[1].After == nil
+	}), []Account(nil)).Return(nil)
+
+	svc := NewService(fakeRepo)
+	err := svc.DeleteAccount(context.Background(), "2")
+
+	assert.Nil(t, err)
+	fakeRepo.AssertExpectations(t)
+}
+
+func Test_UpdateAccount_Should_Write_Over_The_Ancestors_Of_The_New_Parent(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "1").Return(&Account{AccountID: "1", Status: StatusActive, Version: 3}, nil)
+	fakeRepo.On("GetAccount", "2").Return(&Account{AccountID: "2", Status: StatusActive, ParentID: "1", Version: 5}, nil)
+	fakeRepo.On("GetAccount", "3").Return(&Account{AccountID: "3", Status: StatusActive, Version: 1}, nil)
+	fakeRepo.On("WriteAccounts", mock.MatchedBy(func(writes []Write) bool {
+		return len(writes) == 1 && writes[0].After.ParentID == "2"
+	}), []Account{{AccountID: "2", Status: StatusActive, ParentID: "1", Version: 5}, {AccountID: "1", Status: StatusActive, Version: 3}}).Return(ErrConflict)
+
+	svc := NewService(fakeRepo)
+	err := svc.UpdateAccount(context.Background(), Account{AccountID: "3", ParentID: "2"})
+
+	assert.Equal(t, ErrConflict, err)
+	fakeRepo.AssertExpectations(t)
+}
+
+func Test_DeleteAccount_Should_Return_ErrHierarchyTooLarge(t *testing.T) {
+	children := make([]*Account, MaxSubtreeSize+1)
+	for i := range children {
+		children[i] = &Account{AccountID: fmt.Sprint(i + 10), ParentID: "2"}
+	}
+
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "2").Return(&Account{AccountID: "2", Status: StatusActive}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{"2"}}).Return(children, nil)
+
+	svc := NewService(fakeRepo)
+	err := svc.DeleteAccount(context.Background(), "2")
+
+	assert.Equal(t, ErrHierarchyTooLarge, err)
+	fakeRepo.AssertNotCalled(t, "WriteAccounts", mock.Anything, mock.Anything)
+}
+
+func Test_GetAccounts_Should_Return_ErrHierarchyTooLarge_Below_The_Maximum_Depth(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	for i := 0; i < MaxHierarchyDepth; i++ {
+		fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{fmt.Sprint(i)}}).Return([]*Account{{AccountID: fmt.Sprint(i + 1)}}, nil)
+	}
+
+	svc := NewService(fakeRepo)
+	_, err := svc.GetAccounts(context.Background(), Filter{Subtree: "0"}, Pagination{Size: 10})
+
+	assert.Equal(t, ErrHierarchyTooLarge, err)
+}
+
+func Test_Writes_Should_Return_ErrOtherTenant(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "1").Return(&Account{AccountID: "1", Status: StatusActive, TenantID: "t1"}, nil)
+	fakeRepo.On("GetAccount", "2").Return(&Account{AccountID: "2", Status: StatusActive, TenantID: "t2"}, nil)
+	ctx := ContextWithCreator(context.Background(), "1")
+
+	svc := NewService(fakeRepo)
+
+	_, err := svc.CreateAccount(ctx, Account{Name: "new", TenantID: "t2"})
+	assert.Equal(t, ErrOtherTenant, err)
+	assert.Equal(t, ErrOtherTenant, svc.UpdateAccount(ctx, Account{AccountID: "1", TenantID: "t2"}))
+	assert.Equal(t, ErrOtherTenant, svc.UpdateAccount(ctx, Account{AccountID: "2", TenantID: "t1"}))
+	assert.Equal(t, ErrOtherTenant, svc.DeleteAccount(ctx, "2"))
+	_, err = svc.MergeAccounts(ctx, "1", "2", nil)
+	assert.Equal(t, ErrOtherTenant, err)
+	fakeRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+	fakeRepo.AssertNotCalled(t, "UpdateAccount", mock.Anything)
+	fakeRepo.AssertNotCalled(t, "DeleteAccount", mock.Anything)
+}
+
+func Test_GetDescendants_Should_Stop_At_Depth(t *testing.T) {
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccount", "1").Return(&Account{AccountID: "1"}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{"1"}}).Return([]*Account{{AccountID: "2"}, {AccountID: "3"}}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{"2", "3"}}).Return([]*Account{{AccountID: "4"}}, nil)
+
+	svc := NewService(fakeRepo)
+
+	descendants, err := svc.GetDescendants(context.Background(), "1", 2)
+	assert.Nil(t, err)
+	assert.Len(t, descendants, 3)
+
+	descendants, err = svc.GetDescendants(context.Background(), "1", 1)
+	assert.Nil(t, err)
+	assert.Len(t, descendants, 2)
+
+	_, err = svc.GetDescendants(context.Background(), "1", MaxDescendantsDepth+1)
+	assert.Equal(t, ErrInvalidDepth, err)
+}
+
+func Test_GetAccounts_Should_Resolve_The_Subtree(t *testing.T) {
+	p := Pagination{Size: 100}
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{"1"}}).Return([]*Account{{AccountID: "2"}}, nil)
+	fakeRepo.On("ExportAccounts", Filter{ParentIDs: []string{"2"}}).Return([]*Account{}, nil)
+	fakeRepo.On("GetAccounts", Filter{IDs: []string{"1", "2"}}, p).Return([]*Account{{AccountID: "1"}, {AccountID: "2"}}, nil)
+
+	svc := NewService(fakeRepo)
+
+	accounts, err := svc.GetAccounts(context.Background(), Filter{Subtree: "1"}, p)
+	assert.Nil(t, err)
+	assert.Len(t, accounts, 2)
+
+	// none of the ids is in the subtree
+	accounts, err = svc.GetAccounts(context.Background(), Filter{Subtree: "1", IDs: []string{"3"}}, p)
+	assert.Nil(t, err)
+	assert.Empty(t, accounts)
+}
+
+func Test_GetAccountsPage_Should_Count_The_Total_When_Asked(t *testing.T) {
+	f := Filter{Emails: []string{"john@doe.com"}}
+	p := Pagination{Size: 1, Count: true}
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("GetAccounts", f, p).Return([]*Account{&Account{AccountID: "1"}}, 42, nil)
+
+	svc := NewService(fakeRepo)
+	page, err := svc.GetAccountsPage(context.Background(), f, p)
+
+	assert.Nil(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, 42, *page.Total)
+
+	p.Count = false
+	fakeRepo.On("GetAccounts", f, p).Return([]*Account{&Account{AccountID: "1"}}, nil)
+
+	page, err = svc.GetAccountsPage(context.Background(), f, p)
+
+	assert.Nil(t, err)
+	assert.Nil(t, page.Total)
+}
@@ -0,0 +1,187 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidGroupBy is used when the statistics are grouped by an unknown field
+var ErrInvalidGroupBy = errors.New("invalid group by")
+
+// Fields the Accounts can be counted by
+const (
+	GroupByStatus  = "status"
+	GroupByTenant  = "tenant"
+	GroupByLabel   = "label"
+	GroupByCreated = "created"
+)
+
+// Intervals of the created date buckets, a week starts on Monday
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// StatsGroup is what the Accounts are counted by: a field and, for a label its
+// key, for the creation date the interval of its buckets
+type StatsGroup struct {
+	Field string
+	Key   string
+}
+
+// ParseStatsGroup parses "status", "tenant", "label:<key>" or "created:<day|week|month>"
+func ParseStatsGroup(s string) (StatsGroup, error) {
+	parts := strings.SplitN(s, ":", 2)
+	g := StatsGroup{Field: parts[0]}
+	if len(parts) == 2 {
+		g.Key = parts[1]
+	}
+
+	switch {
+	case (g.Field == GroupByStatus || g.Field == GroupByTenant) && len(parts) == 1:
+	case g.Field == GroupByLabel && validKey(g.Key):
+	case g.Field == GroupByCreated && (g.Key == IntervalDay || g.Key == IntervalWeek || g.Key == IntervalMonth):
+	default:
+		return g, ErrInvalidGroupBy
+	}
+
+	return g, nil
+}
+
+func (g StatsGroup) String() string {
+	if len(g.Key) == 0 {
+		return g.Field
+	}
+	return g.Field + ":" + g.Key
+}
+
+// KeyOf returns the group of an Account, empty when it has no value for the field.
+// Created dates are bucketed in UTC as 2006-01-02 for days and weeks, by their
+// Monday, and as 2006-01 for months.
+func (g StatsGroup) KeyOf(a *Account) string {
+	switch g.Field {
+	case GroupByStatus:
+		return a.Status
+	case GroupByTenant:
+		return a.TenantID
+	case GroupByLabel:
+		return a.Labels[g.Key]
+	case GroupByCreated:
+		if a.CreatedAt == nil {
+			return ""
+		}
+
+		t := a.CreatedAt.UTC()
+		switch g.Key {
+		case IntervalWeek:
+			return t.AddDate(0, 0, -(int(t.Weekday())+6)%7).Format("2006-01-02")
+		case IntervalMonth:
+			return t.Format("2006-01")
+		}
+		return t.Format("2006-01-02")
+	}
+
+	return ""
+}
+
+// StatsCount is the number of Accounts of a group
+type StatsCount struct {
+	Key   string `json:"key" bson:"_id"`
+	Count int    `json:"count" bson:"count"`
+}
+
+// Stats are the counts of the Accounts matching a filter, by group, sorted by key
+type Stats struct {
+	GroupBy     string       `json:"group_by"`
+	Total       int          `json:"total"`
+	Groups      []StatsCount `json:"groups"`
+	GeneratedAt time.Time    `json:"generated_at"`
+}
+
+// ServiceStatsCache caches the statistics for ttl, each filter and group on its own,
+// a ttl of 0 disables the cache
+func ServiceStatsCache(ttl time.Duration) ServiceOption {
+	return func(s *service) {
+		if ttl > 0 {
+			s.statsCache = &statsCache{ttl: ttl, entries: map[string]*Stats{}}
+		}
+	}
+}
+
+// GetAccountStats counts the Accounts matching the filter by group
+func (s service) GetAccountStats(ctx context.Context, filter Filter, group StatsGroup) (*Stats, error) {
+	key := fmt.Sprintf("%+v|%v", filter, group)
+	if stats := s.statsCache.get(key); stats != nil {
+		return stats, nil
+	}
+
+	filter, err := s.resolveCustom(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	filter, ok, err := s.resolveSubtree(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{GroupBy: group.String(), Groups: []StatsCount{}, GeneratedAt: time.Now().UTC()}
+	if ok {
+		counts, err := s.repository.CountAccounts(ctx, filter, group)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range counts {
+			stats.Total += c.Count
+			stats.Groups = append(stats.Groups, c)
+		}
+	}
+
+	s.statsCache.put(key, stats)
+	return stats, nil
+}
+
+type statsCache struct {
+	ttl     time.Duration
+	mtx     sync.Mutex
+	entries map[string]*Stats
+}
+
+// get returns the cached statistics, nil when there are none or they expired;
+// a nil cache caches nothing
+func (c *statsCache) get(key string) *Stats {
+	if c == nil {
+		return nil
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	stats, ok := c.entries[key]
+	if !ok || time.Since(stats.GeneratedAt) >= c.ttl {
+		return nil
+	}
+	return stats
+}
+
+// put caches the statistics, and drops the expired ones so that the cache does not grow forever
+func (c *statsCache) put(key string, stats *Stats) {
+	if c == nil {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for k, cached := range c.entries {
+		if time.Since(cached.GeneratedAt) >= c.ttl {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = stats
+}
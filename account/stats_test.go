@@ -0,0 +1,90 @@
+package account
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseStatsGroup(t *testing.T) {
+	var flagtests = []struct {
+		in  string
+		out StatsGroup
+		err error
+	}{
+		{"status", StatsGroup{Field: GroupByStatus}, nil},
+		{"tenant", StatsGroup{Field: GroupByTenant}, nil},
+		{"label:team/tier", StatsGroup{Field: GroupByLabel, Key: "team/tier"}, nil},
+		{"created:week", StatsGroup{Field: GroupByCreated, Key: IntervalWeek}, nil},
+		{"status:x", StatsGroup{Field: GroupByStatus, Key: "x"}, ErrInvalidGroupBy},
+		{"label:a.b", StatsGroup{Field: GroupByLabel, Key: "a.b"}, ErrInvalidGroupBy},
+		{"created:year", StatsGroup{Field: GroupByCreated, Key: "year"}, ErrInvalidGroupBy},
+		{"email", StatsGroup{Field: "email"}, ErrInvalidGroupBy},
+	}
+
+	for _, tt := range flagtests {
+		g, err := ParseStatsGroup(tt.in)
+
+		assert.Equal(t, tt.err, err, tt.in)
+		assert.Equal(t, tt.out, g, tt.in)
+		if err == nil {
+			assert.Equal(t, tt.in, g.String())
+		}
+	}
+}
+
+func Test_StatsGroup_KeyOf(t *testing.T) {
+	// a Sunday, late in the day in UTC
+	created := time.Date(2026, 10, 18, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60))
+	a := &Account{Status: StatusActive, TenantID: "t1", Labels: map[string]string{"tier": "gold"}, CreatedAt: &created}
+
+	assert.Equal(t, StatusActive, StatsGroup{Field: GroupByStatus}.KeyOf(a))
+	assert.Equal(t, "t1", StatsGroup{Field: GroupByTenant}.KeyOf(a))
+	assert.Equal(t, "gold", StatsGroup{Field: GroupByLabel, Key: "tier"}.KeyOf(a))
+	assert.Equal(t, "", StatsGroup{Field: GroupByLabel, Key: "region"}.KeyOf(a))
+	assert.Equal(t, "2026-10-19", StatsGroup{Field: GroupByCreated, Key: IntervalDay}.KeyOf(a))
+	assert.Equal(t, "2026-10-19", StatsGroup{Field: GroupByCreated, Key: IntervalWeek}.KeyOf(a))
+	assert.Equal(t, "2026-10", StatsGroup{Field: GroupByCreated, Key: IntervalMonth}.KeyOf(a))
+	assert.Equal(t, "", StatsGroup{Field: GroupByCreated, Key: IntervalDay}.KeyOf(&Account{}))
+
+	sunday := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2026-10-12", StatsGroup{Field: GroupByCreated, Key: IntervalWeek}.KeyOf(&Account{CreatedAt: &sunday}))
+}
+
+func Test_GetAccountStats_Should_Total_And_Cache_The_Counts(t *testing.T) {
+	f := Filter{TenantIDs: []string{"t1"}}
+	g := StatsGroup{Field: GroupByStatus}
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("CountAccounts", f, g).Return([]StatsCount{{StatusActive, 3}, {StatusClosed, 1}}, nil).Once()
+
+	svc := NewService(fakeRepo, ServiceStatsCache(time.Minute))
+	stats, err := svc.GetAccountStats(context.Background(), f, g)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "status", stats.GroupBy)
+	assert.Equal(t, 4, stats.Total)
+	assert.Equal(t, []StatsCount{{StatusActive, 3}, {StatusClosed, 1}}, stats.Groups)
+
+	cached, err := svc.GetAccountStats(context.Background(), f, g)
+
+	assert.Nil(t, err)
+	assert.Equal(t, stats, cached)
+	fakeRepo.AssertExpectations(t)
+}
+
+func Test_GetAccountStats_Should_Not_Cache_Without_TTL(t *testing.T) {
+	g := StatsGroup{Field: GroupByTenant}
+	fakeRepo := new(mockedAccountRepository)
+	fakeRepo.On("CountAccounts", Filter{}, g).Return([]StatsCount{}, nil).Twice()
+
+	svc := NewService(fakeRepo, ServiceStatsCache(0))
+	svc.GetAccountStats(context.Background(), Filter{}, g)
+	stats, err := svc.GetAccountStats(context.Background(), Filter{}, g)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, stats.Total)
+	assert.Equal(t, []StatsCount{}, stats.Groups)
+	fakeRepo.AssertExpectations(t)
+}
@@ -0,0 +1,68 @@
+package apikey
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Endpoints represent the API key service endpoints
+type Endpoints struct {
+	GetList endpoint.Endpoint
+	Create  endpoint.Endpoint
+	Rotate  endpoint.Endpoint
+	Revoke  endpoint.Endpoint
+}
+
+// MakeGetKeysEndpoint returns an endpoint used for listing the keys of an account
+func MakeGetKeysEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetKeysRequest)
+
+		return s.GetKeys(ctx, req.AccountID)
+	}
+}
+
+// MakeCreateKeyEndpoint returns an endpoint used for creating a key
+func MakeCreateKeyEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(CreateKeyRequest)
+
+		return s.CreateKey(ctx, req.AccountID, req.Key)
+	}
+}
+
+// MakeRotateKeyEndpoint returns an endpoint used for rotating the secret of a key
+func MakeRotateKeyEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(KeyRequest)
+
+		return s.RotateKey(ctx, req.AccountID, req.ID)
+	}
+}
+
+// MakeRevokeKeyEndpoint returns an endpoint used for revoking a key
+func MakeRevokeKeyEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(KeyRequest)
+
+		return nil, s.RevokeKey(ctx, req.AccountID, req.ID)
+	}
+}
+
+// GetKeysRequest represents the request parameters used for listing the keys of an account
+type GetKeysRequest struct {
+	AccountID string
+}
+
+// CreateKeyRequest represents the request parameters used for creating a key
+type CreateKeyRequest struct {
+	AccountID string
+	Key
+}
+
+// KeyRequest represents the request parameters used for rotating or revoking a key
+type KeyRequest struct {
+	AccountID string
+	ID        string
+}
@@ -0,0 +1,141 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+)
+
+// ErrInvalidBody thrown when the body of a request can not be parsed
+var ErrInvalidBody = errors.New("invalid body")
+
+// MakeHTTPHandler returns all http handler for the API key service
+func MakeHTTPHandler(logger log.Logger, endpoints Endpoints) http.Handler {
+	options := []kithttp.ServerOption{
+		kithttp.ServerErrorLogger(logger),
+		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(account.PopulateAuditContext),
+	}
+
+	getKeysHandler := kithttp.NewServer(
+		endpoints.GetList,
+		decodeGetKeysRequest,
+		encodeResponse,
+		options...,
+	)
+
+	createKeyHandler := kithttp.NewServer(
+		endpoints.Create,
+		decodeCreateKeyRequest,
+		encodeCreateKeyResponse,
+		options...,
+	)
+
+	rotateKeyHandler := kithttp.NewServer(
+		endpoints.Rotate,
+		decodeKeyRequest,
+		encodeSecretResponse,
+		options...,
+	)
+
+	revokeKeyHandler := kithttp.NewServer(
+		endpoints.Revoke,
+		decodeKeyRequest,
+		encodeNoContentResponse,
+		options...,
+	)
+
+	r := mux.NewRouter().PathPrefix("/accounts/{account_id}/api-keys").Subrouter()
+
+	r.Handle("", getKeysHandler).Methods("GET")
+	r.Handle("", createKeyHandler).Methods("POST")
+	r.Handle("/{id}/rotate", rotateKeyHandler).Methods("POST")
+	r.Handle("/{id}", revokeKeyHandler).Methods("DELETE")
+
+	return r
+}
+
+func decodeGetKeysRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	vars := mux.Vars(r)
+
+	return GetKeysRequest{AccountID: vars["account_id"]}, nil
+}
+
+func decodeCreateKeyRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req CreateKeyRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req.Key); err != nil {
+		return nil, ErrInvalidBody
+	}
+
+	req.AccountID = mux.Vars(r)["account_id"]
+
+	return req, nil
+}
+
+func decodeKeyRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	vars := mux.Vars(r)
+
+	return KeyRequest{AccountID: vars["account_id"], ID: vars["id"]}, nil
+}
+
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func encodeCreateKeyResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	k, ok := response.(*Key)
+	if !ok {
+		return errors.New("An error occured while creating API key")
+	}
+	w.Header().Set("Location", fmt.Sprintf("/accounts/%v/api-keys/%v", k.AccountID, k.ID))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	// the secret is only ever returned here and on rotation
+	return json.NewEncoder(w).Encode(k)
+}
+
+// encodeSecretResponse writes a key with its secret, which is only ever returned on creation and rotation
+func encodeSecretResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func encodeNoContentResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// encode errors from business-logic
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	switch err {
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	case ErrInvalidBody,
+		ErrInvalidScope,
+		ErrInvalidExpiry:
+		w.WriteHeader(http.StatusBadRequest)
+	case auth.ErrUnauthenticated:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
+	case ErrNotFound,
+		account.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,69 @@
+package apikey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+)
+
+func Test_DecodeCreateKeyRequest(t *testing.T) {
+	expected := CreateKeyRequest{AccountID: "1", Key: Key{Name: "ci", Scopes: []string{ScopeAccountsRead}}}
+	r, _ := http.NewRequest("POST", "/accounts/1/api-keys", bytes.NewBufferString(`{"name":"ci","scopes":["accounts:read"]}`))
+	r = mux.SetURLVars(r, map[string]string{"account_id": "1"})
+
+	req, err := decodeCreateKeyRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, req)
+}
+
+func Test_EncodeCreateKeyResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := encodeCreateKeyResponse(context.Background(), w, &Key{ID: "2", AccountID: "1", Secret: "secret"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/accounts/1/api-keys/2", w.Header().Get("Location"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), `"secret":"secret"`)
+}
+
+func Test_EncodeResponse_Should_Not_Return_Hash_Or_Secret(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := encodeResponse(context.Background(), w, []*Key{{ID: "2", Prefix: "ak_1", Hash: "hash"}})
+
+	assert.Nil(t, err)
+	assert.NotContains(t, w.Body.String(), "hash")
+	assert.NotContains(t, w.Body.String(), "secret")
+}
+
+func Test_EncodeError_Should_Correctly_Map_Error(t *testing.T) {
+	var flagtests = []struct {
+		in  error
+		out int
+	}{
+		{errors.New("not handled error"), http.StatusInternalServerError},
+		{ErrInvalidBody, http.StatusBadRequest},
+		{ErrInvalidScope, http.StatusBadRequest},
+		{ErrInvalidExpiry, http.StatusBadRequest},
+		{auth.ErrUnauthenticated, http.StatusUnauthorized},
+		{auth.ErrForbidden, http.StatusForbidden},
+		{ErrNotFound, http.StatusNotFound},
+		{account.ErrNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range flagtests {
+		w := httptest.NewRecorder()
+		encodeError(context.Background(), tt.in, w)
+
+		assert.Equal(t, tt.out, w.Code)
+	}
+}
@@ -0,0 +1,23 @@
+package apikey
+
+import (
+	"context"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+type mergeHandler struct {
+	repository Repository
+}
+
+// NewMergeHandler returns the account.MergeHandler moving the API keys of the
+// source account of a merge to the target one, they keep working and now
+// authenticate as the target
+func NewMergeHandler(r Repository) account.MergeHandler {
+	return mergeHandler{repository: r}
+}
+
+// MergeAccount ...
+func (h mergeHandler) MergeAccount(ctx context.Context, targetID, sourceID string) error {
+	return h.repository.MoveKeys(ctx, sourceID, targetID)
+}
@@ -0,0 +1,93 @@
+package apikey
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+)
+
+// HeaderAPIKey is the header carrying an API key, "Authorization: ApiKey <key>" is accepted too
+const HeaderAPIKey = "X-API-Key"
+
+// ErrMissingKey is used when a request requiring an API key has none
+var ErrMissingKey = errors.New("missing API key")
+
+// ErrForbidden is used when an API key does not have the scope a request requires
+var ErrForbidden = errors.New("API key scope does not allow this request")
+
+// ErrOtherAccount is used when an API key is used on another account than its own
+var ErrOtherAccount = errors.New("API key does not belong to this account")
+
+// NewMiddleware returns an http middleware authenticating every request with
+// its API key. Reads (GET and HEAD) require readScope and any other method
+// writeScope; the principal of the key is then put in the request context and
+// its key recorded as the actor of the changes, and its account as the creator. A key only reaches the routes
+// of its own account, see account.AccountIDFromPath.
+func NewMiddleware(s Service, readScope, writeScope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFromRequest(r)
+			if len(key) == 0 {
+				writeError(w, http.StatusUnauthorized, ErrMissingKey)
+				return
+			}
+
+			p, err := s.Authenticate(r.Context(), key)
+			if err == ErrInvalidKey {
+				writeError(w, http.StatusUnauthorized, err)
+				return
+			}
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+
+			scope := writeScope
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				scope = readScope
+			}
+			if !(Key{Scopes: p.Scopes}).HasScope(scope) {
+				writeError(w, http.StatusForbidden, ErrForbidden)
+				return
+			}
+			if id := account.AccountIDFromPath(r.URL.Path); len(id) > 0 && id != p.AccountID {
+				writeError(w, http.StatusForbidden, ErrOtherAccount)
+				return
+			}
+
+			ctx := account.ContextWithActor(auth.ContextWithPrincipal(r.Context(), p), p.Actor())
+			ctx = account.ContextWithCreator(ctx, p.AccountID)
+			r = r.WithContext(ctx)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func keyFromRequest(r *http.Request) string {
+	if key := r.Header.Get(HeaderAPIKey); len(key) > 0 {
+		return key
+	}
+
+	const scheme = "ApiKey "
+	if h := r.Header.Get("Authorization"); len(h) > len(scheme) && strings.EqualFold(h[:len(scheme)], scheme) {
+		return strings.TrimSpace(h[len(scheme):])
+	}
+
+	return ""
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "ApiKey")
+	}
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
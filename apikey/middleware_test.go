@@ -0,0 +1,61 @@
+package apikey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+)
+
+func Test_Middleware(t *testing.T) {
+	svc := NewService(fakeAccounts{}, newMembers(), new(fakeRepository))
+	k, _ := svc.CreateKey(ownerContext, "1", Key{Scopes: []string{ScopeAccountsRead}})
+
+	var principal *auth.Principal
+	var actor, creator string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = auth.PrincipalFromContext(r.Context())
+		actor = account.ActorFromContext(r.Context())
+		creator = account.CreatorFromContext(r.Context())
+	})
+	h := NewMiddleware(svc, ScopeAccountsRead, ScopeAccountsWrite)(next)
+
+	var flagtests = []struct {
+		method string
+		path   string
+		header string
+		value  string
+		code   int
+	}{
+		{"GET", "/accounts/1", "", "", http.StatusUnauthorized},
+		{"GET", "/accounts/1", HeaderAPIKey, "ak_unknown.secret", http.StatusUnauthorized},
+		{"PATCH", "/accounts/1", HeaderAPIKey, k.Secret, http.StatusForbidden},
+		{"GET", "/accounts/3/history", HeaderAPIKey, k.Secret, http.StatusForbidden},
+		{"GET", "/accounts/1", "Authorization", "ApiKey " + k.Secret, http.StatusOK},
+		{"GET", "/accounts/1", HeaderAPIKey, k.Secret, http.StatusOK},
+		{"GET", "/accounts/search", HeaderAPIKey, k.Secret, http.StatusOK},
+	}
+
+	for _, tt := range flagtests {
+		principal = nil
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(tt.method, tt.path, nil)
+		if len(tt.header) > 0 {
+			r.Header.Set(tt.header, tt.value)
+		}
+
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, tt.code, w.Code)
+		if tt.code == http.StatusOK {
+			assert.Equal(t, "1", principal.AccountID)
+			assert.Equal(t, "api-key:"+k.ID, actor)
+			assert.Equal(t, "1", creator)
+		} else {
+			assert.Nil(t, principal)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Scopes a key can be granted
+const (
+	ScopeAccountsRead  = "accounts:read"
+	ScopeAccountsWrite = "accounts:write"
+	// ScopeSchemasWrite allows changing the schemas of the custom fields of the tenants
+	ScopeSchemasWrite = "schemas:write"
+)
+
+// Scopes lists every known scope
+var Scopes = []string{ScopeAccountsRead, ScopeAccountsWrite, ScopeSchemasWrite}
+
+// Key is an API key of an account. The secret is only returned when the key
+// is created or rotated, only its hash is stored; the prefix identifies the
+// key and is safe to display.
+type Key struct {
+	ID        string     `json:"id" bson:"id"`
+	AccountID string     `json:"account_id" bson:"account_id"`
+	Name      string     `json:"name" bson:"name"`
+	Prefix    string     `json:"prefix" bson:"prefix"`
+	Hash      string     `json:"-" bson:"hash"`
+	Secret    string     `json:"secret,omitempty" bson:"-"`
+	Scopes    []string   `json:"scopes" bson:"scopes"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty" bson:"rotated_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// Active tells whether the key can authenticate at the given time
+func (k Key) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// HasScope tells whether the key was granted the scope
+func (k Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HashKey returns the hash under which a key is stored
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
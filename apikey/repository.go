@@ -0,0 +1,21 @@
+package apikey
+
+import (
+	"context"
+	"time"
+)
+
+// Repository is the storage of the API Keys
+type Repository interface {
+	CreateKey(ctx context.Context, k Key) error
+	// GetKey returns a key of an account, nil if there is none
+	GetKey(ctx context.Context, accountID, id string) (*Key, error)
+	GetKeys(ctx context.Context, accountID string) ([]*Key, error)
+	// GetKeyByPrefix returns the key with the given prefix, nil if there is none
+	GetKeyByPrefix(ctx context.Context, prefix string) (*Key, error)
+	// RotateKey replaces the prefix and hash of a key
+	RotateKey(ctx context.Context, accountID, id, prefix, hash string, at time.Time) error
+	RevokeKey(ctx context.Context, accountID, id string, at time.Time) error
+	// MoveKeys moves every key of an account to another one
+	MoveKeys(ctx context.Context, accountID, toAccountID string) error
+}
@@ -0,0 +1,108 @@
+package apikey
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// fakeRepository keeps keys in memory
+type fakeRepository struct {
+	mu   sync.Mutex
+	keys []Key
+}
+
+func (r *fakeRepository) CreateKey(ctx context.Context, k Key) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys = append(r.keys, k)
+	return nil
+}
+
+func (r *fakeRepository) GetKey(ctx context.Context, accountID, id string) (*Key, error) {
+	return r.find(func(k Key) bool { return k.AccountID == accountID && k.ID == id }), nil
+}
+
+func (r *fakeRepository) GetKeys(ctx context.Context, accountID string) ([]*Key, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := []*Key{}
+	for _, k := range r.keys {
+		if k.AccountID == accountID {
+			k := k
+			keys = append(keys, &k)
+		}
+	}
+	return keys, nil
+}
+
+func (r *fakeRepository) GetKeyByPrefix(ctx context.Context, prefix string) (*Key, error) {
+	return r.find(func(k Key) bool { return k.Prefix == prefix }), nil
+}
+
+func (r *fakeRepository) RotateKey(ctx context.Context, accountID, id, prefix, hash string, at time.Time) error {
+	return r.update(accountID, id, func(k *Key) {
+		k.Prefix = prefix
+		k.Hash = hash
+		k.RotatedAt = &at
+	})
+}
+
+func (r *fakeRepository) RevokeKey(ctx context.Context, accountID, id string, at time.Time) error {
+	return r.update(accountID, id, func(k *Key) {
+		k.RevokedAt = &at
+	})
+}
+
+func (r *fakeRepository) MoveKeys(ctx context.Context, accountID, toAccountID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.keys {
+		if r.keys[i].AccountID == accountID {
+			r.keys[i].AccountID = toAccountID
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepository) find(match func(Key) bool) *Key {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, k := range r.keys {
+		if match(k) {
+			return &k
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepository) update(accountID, id string, fn func(*Key)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.keys {
+		if r.keys[i].AccountID == accountID && r.keys[i].ID == id && r.keys[i].RevokedAt == nil {
+			fn(&r.keys[i])
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// fakeAccounts only implements the account.Service methods used by the API key service
+type fakeAccounts struct {
+	account.Service
+}
+
+func (fakeAccounts) GetAccount(ctx context.Context, id string) (*account.Account, error) {
+	if id != "1" {
+		return nil, account.ErrNotFound
+	}
+	return &account.Account{AccountID: id}, nil
+}
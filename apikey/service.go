@@ -0,0 +1,208 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+	"github.com/tkanos/go-rest-api-sample/membership"
+)
+
+// ErrNotFound is used when a Key is not found
+var ErrNotFound = errors.New("API key not found")
+
+// ErrInvalidScope is used when a Key has no scope, or an unknown one
+var ErrInvalidScope = errors.New("invalid API key scope")
+
+// ErrInvalidExpiry is used when a Key would already be expired
+var ErrInvalidExpiry = errors.New("API key expiry is in the past")
+
+// ErrInvalidKey is used when a key is unknown, revoked or expired
+var ErrInvalidKey = errors.New("invalid API key")
+
+// keyPrefix starts every key, so that leaked keys are easy to spot
+const keyPrefix = "ak_"
+
+// Service is the API key service interface
+type Service interface {
+	CreateKey(ctx context.Context, accountID string, k Key) (*Key, error)
+	GetKeys(ctx context.Context, accountID string) ([]*Key, error)
+	RotateKey(ctx context.Context, accountID, id string) (*Key, error)
+	RevokeKey(ctx context.Context, accountID, id string) error
+	Authenticate(ctx context.Context, key string) (*auth.Principal, error)
+}
+
+type service struct {
+	accounts   account.Service
+	members    membership.Service
+	repository Repository
+}
+
+// NewService return a new instance of the API key service
+func NewService(accounts account.Service, members membership.Service, r Repository) Service {
+	return service{
+		accounts:   accounts,
+		members:    members,
+		repository: r,
+	}
+}
+
+// CreateKey creates a Key for an account, its secret is only returned here. The keys of an
+// account are managed by its owners, see membership.Service.Authorize.
+func (s service) CreateKey(ctx context.Context, accountID string, k Key) (*Key, error) {
+	if err := s.members.Authorize(ctx, accountID, membership.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	if err := validateScopes(k.Scopes); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if k.ExpiresAt != nil && !now.Before(*k.ExpiresAt) {
+		return nil, ErrInvalidExpiry
+	}
+
+	if _, err := s.accounts.GetAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	prefix, key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+
+	k.ID = id
+	k.AccountID = accountID
+	k.Prefix = prefix
+	k.Hash = HashKey(key)
+	k.CreatedAt = now
+	k.RotatedAt = nil
+	k.RevokedAt = nil
+
+	if err := s.repository.CreateKey(ctx, k); err != nil {
+		return nil, err
+	}
+
+	k.Secret = key
+	return &k, nil
+}
+
+// GetKeys returns the Keys of an account, without their secrets
+func (s service) GetKeys(ctx context.Context, accountID string) ([]*Key, error) {
+	if err := s.members.Authorize(ctx, accountID, membership.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	return s.repository.GetKeys(ctx, accountID)
+}
+
+// RotateKey replaces the secret of a Key, the previous one stops working at once
+func (s service) RotateKey(ctx context.Context, accountID, id string) (*Key, error) {
+	if err := s.members.Authorize(ctx, accountID, membership.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	k, err := s.repository.GetKey(ctx, accountID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if k == nil || !k.Active(now) {
+		return nil, ErrNotFound
+	}
+
+	prefix, key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.RotateKey(ctx, accountID, id, prefix, HashKey(key), now); err != nil {
+		return nil, err
+	}
+
+	k.Prefix = prefix
+	k.Secret = key
+	k.RotatedAt = &now
+	return k, nil
+}
+
+// RevokeKey revokes a Key
+func (s service) RevokeKey(ctx context.Context, accountID, id string) error {
+	if err := s.members.Authorize(ctx, accountID, membership.RoleOwner); err != nil {
+		return err
+	}
+
+	return s.repository.RevokeKey(ctx, accountID, id, time.Now().UTC())
+}
+
+// Authenticate returns the account principal of an active key
+func (s service) Authenticate(ctx context.Context, key string) (*auth.Principal, error) {
+	i := strings.IndexByte(key, '.')
+	if i < 0 {
+		return nil, ErrInvalidKey
+	}
+
+	k, err := s.repository.GetKeyByPrefix(ctx, key[:i])
+	if err != nil {
+		return nil, err
+	}
+	if k == nil || subtle.ConstantTimeCompare([]byte(HashKey(key)), []byte(k.Hash)) != 1 || !k.Active(time.Now()) {
+		return nil, ErrInvalidKey
+	}
+
+	return &auth.Principal{AccountID: k.AccountID, KeyID: k.ID, Scopes: k.Scopes}, nil
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return ErrInvalidScope
+	}
+
+	for _, scope := range scopes {
+		known := false
+		for _, s := range Scopes {
+			known = known || s == scope
+		}
+		if !known {
+			return ErrInvalidScope
+		}
+	}
+
+	return nil
+}
+
+// newKey returns the public prefix of a new key, and the whole key
+func newKey() (prefix, key string, err error) {
+	p, err := randomHex(6)
+	if err != nil {
+		return
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return
+	}
+
+	prefix = keyPrefix + p
+	key = prefix + "." + secret
+	return
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
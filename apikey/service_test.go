@@ -0,0 +1,137 @@
+package apikey
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+	"github.com/tkanos/go-rest-api-sample/membership"
+)
+
+// newMembers returns a membership service without any member
+func newMembers() membership.Service {
+	return membership.NewService(fakeAccounts{}, membership.NewMemoryRepository())
+}
+
+// ownerContext is the context of a session of the account 1
+var ownerContext = auth.ContextWithPrincipal(context.Background(), &auth.Principal{AccountID: "1", SessionID: "s"})
+
+func Test_CreateKey_Should_Only_Store_The_Hash(t *testing.T) {
+	repo := new(fakeRepository)
+	svc := NewService(fakeAccounts{}, newMembers(), repo)
+
+	k, err := svc.CreateKey(ownerContext, "1", Key{Name: "ci", Scopes: []string{ScopeAccountsRead}})
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(k.Secret, k.Prefix+"."))
+	assert.Len(t, repo.keys, 1)
+	assert.Empty(t, repo.keys[0].Secret)
+	assert.Equal(t, HashKey(k.Secret), repo.keys[0].Hash)
+}
+
+func Test_CreateKey_Should_Validate_The_Key(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	var flagtests = []struct {
+		accountID string
+		key       Key
+		err       error
+	}{
+		{"1", Key{}, ErrInvalidScope},
+		{"1", Key{Scopes: []string{"accounts:delete"}}, ErrInvalidScope},
+		{"1", Key{Scopes: []string{ScopeAccountsRead}, ExpiresAt: &past}, ErrInvalidExpiry},
+		{"2", Key{Scopes: []string{ScopeAccountsRead}}, account.ErrNotFound},
+	}
+
+	for _, tt := range flagtests {
+		svc := NewService(fakeAccounts{}, newMembers(), new(fakeRepository))
+		ctx := auth.ContextWithPrincipal(context.Background(), &auth.Principal{Admin: true})
+		_, err := svc.CreateKey(ctx, tt.accountID, tt.key)
+
+		assert.Equal(t, tt.err, err)
+	}
+}
+
+func Test_CreateKey_Should_Require_The_Account_Or_The_Admin(t *testing.T) {
+	var flagtests = []struct {
+		principal *auth.Principal
+		err       error
+	}{
+		{nil, auth.ErrUnauthenticated},
+		{&auth.Principal{AccountID: "3", SessionID: "s"}, auth.ErrForbidden},
+		{&auth.Principal{AccountID: "1", KeyID: "k", Scopes: []string{ScopeAccountsWrite}}, auth.ErrForbidden},
+		{&auth.Principal{Admin: true}, nil},
+	}
+
+	for _, tt := range flagtests {
+		svc := NewService(fakeAccounts{}, newMembers(), new(fakeRepository))
+		ctx := context.Background()
+		if tt.principal != nil {
+			ctx = auth.ContextWithPrincipal(ctx, tt.principal)
+		}
+
+		_, err := svc.CreateKey(ctx, "1", Key{Scopes: []string{ScopeAccountsRead}})
+
+		assert.Equal(t, tt.err, err)
+	}
+}
+
+func Test_Authenticate_Should_Resolve_The_Account_Principal(t *testing.T) {
+	svc := NewService(fakeAccounts{}, newMembers(), new(fakeRepository))
+	k, _ := svc.CreateKey(ownerContext, "1", Key{Scopes: []string{ScopeAccountsRead}})
+
+	p, err := svc.Authenticate(context.Background(), k.Secret)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1", p.AccountID)
+	assert.Equal(t, k.ID, p.KeyID)
+	assert.Equal(t, []string{ScopeAccountsRead}, p.Scopes)
+
+	_, err = svc.Authenticate(context.Background(), k.Prefix+".wrong")
+	assert.Equal(t, ErrInvalidKey, err)
+}
+
+func Test_RotateKey_Should_Invalidate_The_Previous_Secret(t *testing.T) {
+	svc := NewService(fakeAccounts{}, newMembers(), new(fakeRepository))
+	k, _ := svc.CreateKey(ownerContext, "1", Key{Scopes: []string{ScopeAccountsRead}})
+
+	rotated, err := svc.RotateKey(ownerContext, "1", k.ID)
+	assert.Nil(t, err)
+	assert.NotEqual(t, k.Secret, rotated.Secret)
+
+	_, err = svc.Authenticate(context.Background(), k.Secret)
+	assert.Equal(t, ErrInvalidKey, err)
+
+	_, err = svc.Authenticate(context.Background(), rotated.Secret)
+	assert.Nil(t, err)
+}
+
+func Test_RevokeKey_Should_Invalidate_The_Key(t *testing.T) {
+	svc := NewService(fakeAccounts{}, newMembers(), new(fakeRepository))
+	k, _ := svc.CreateKey(ownerContext, "1", Key{Scopes: []string{ScopeAccountsRead}})
+
+	assert.Nil(t, svc.RevokeKey(ownerContext, "1", k.ID))
+
+	_, err := svc.Authenticate(context.Background(), k.Secret)
+	assert.Equal(t, ErrInvalidKey, err)
+
+	_, err = svc.RotateKey(ownerContext, "1", k.ID)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func Test_MergeHandler_Should_Move_The_Keys(t *testing.T) {
+	repo := new(fakeRepository)
+	svc := NewService(fakeAccounts{}, newMembers(), repo)
+	k, _ := svc.CreateKey(ownerContext, "1", Key{Scopes: []string{ScopeAccountsRead}})
+
+	assert.Nil(t, NewMergeHandler(repo).MergeAccount(context.Background(), "3", "1"))
+	// retrying a merge changes nothing
+	assert.Nil(t, NewMergeHandler(repo).MergeAccount(context.Background(), "3", "1"))
+
+	p, err := svc.Authenticate(context.Background(), k.Secret)
+	assert.Nil(t, err)
+	assert.Equal(t, "3", p.AccountID)
+}
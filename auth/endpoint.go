@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Endpoints represent the authentication service endpoints
+type Endpoints struct {
+	SetPassword endpoint.Endpoint
+	Login       endpoint.Endpoint
+	Refresh     endpoint.Endpoint
+	Logout      endpoint.Endpoint
+	// RequestReset and ConfirmReset are the two steps of a password reset
+	RequestReset endpoint.Endpoint
+	ConfirmReset endpoint.Endpoint
+}
+
+// MakeSetPasswordEndpoint returns an endpoint used for setting the password of an account
+func MakeSetPasswordEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SetPasswordRequest)
+
+		return nil, s.SetPassword(ctx, req.ID, req.CurrentPassword, req.Password)
+	}
+}
+
+// MakeLoginEndpoint returns an endpoint used for logging in
+func MakeLoginEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(LoginRequest)
+
+		return s.Login(ctx, req.Email, req.Password)
+	}
+}
+
+// MakeRefreshEndpoint returns an endpoint used for refreshing the tokens of a session
+func MakeRefreshEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RefreshRequest)
+
+		return s.Refresh(ctx, req.RefreshToken)
+	}
+}
+
+// MakeLogoutEndpoint returns an endpoint used for logging out
+func MakeLogoutEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RefreshRequest)
+
+		return nil, s.Logout(ctx, req.RefreshToken)
+	}
+}
+
+// MakeRequestPasswordResetEndpoint returns an endpoint used for asking for a password reset token
+func MakeRequestPasswordResetEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RequestPasswordResetRequest)
+
+		return nil, s.RequestPasswordReset(ctx, req.Email)
+	}
+}
+
+// MakeConfirmPasswordResetEndpoint returns an endpoint used for setting a password with a reset token
+func MakeConfirmPasswordResetEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ConfirmPasswordResetRequest)
+
+		return nil, s.ResetPassword(ctx, req.Token, req.Password)
+	}
+}
+
+// SetPasswordRequest represents the request parameters used for setting the password of an account
+type SetPasswordRequest struct {
+	ID              string `json:"-"`
+	CurrentPassword string `json:"current_password"`
+	Password        string `json:"password"`
+}
+
+// LoginRequest represents the request parameters used for logging in
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest represents the request parameters used for refreshing tokens and logging out
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RequestPasswordResetRequest represents the request parameters used for asking for a password reset
+type RequestPasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+// ConfirmPasswordResetRequest represents the request parameters used for completing a password reset
+type ConfirmPasswordResetRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
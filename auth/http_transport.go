@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// ErrInvalidBody thrown when the body of a request can not be parsed
+var ErrInvalidBody = errors.New("invalid body")
+
+// MakeHTTPHandler returns all http handler for the authentication service
+func MakeHTTPHandler(logger log.Logger, endpoints Endpoints) http.Handler {
+	options := []kithttp.ServerOption{
+		kithttp.ServerErrorLogger(logger),
+		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(account.PopulateAuditContext),
+	}
+
+	setPasswordHandler := kithttp.NewServer(
+		endpoints.SetPassword,
+		decodeSetPasswordRequest,
+		encodeNoContentResponse,
+		options...,
+	)
+
+	loginHandler := kithttp.NewServer(
+		endpoints.Login,
+		decodeLoginRequest,
+		encodeTokensResponse,
+		options...,
+	)
+
+	refreshHandler := kithttp.NewServer(
+		endpoints.Refresh,
+		decodeRefreshRequest,
+		encodeTokensResponse,
+		options...,
+	)
+
+	logoutHandler := kithttp.NewServer(
+		endpoints.Logout,
+		decodeRefreshRequest,
+		encodeNoContentResponse,
+		options...,
+	)
+
+	requestResetHandler := kithttp.NewServer(
+		endpoints.RequestReset,
+		decodeRequestPasswordResetRequest,
+		encodeAcceptedResponse,
+		options...,
+	)
+
+	confirmResetHandler := kithttp.NewServer(
+		endpoints.ConfirmReset,
+		decodeConfirmPasswordResetRequest,
+		encodeNoContentResponse,
+		options...,
+	)
+
+	r := mux.NewRouter()
+
+	r.Handle("/accounts/{id}/password", setPasswordHandler).Methods("PUT")
+	r.Handle("/auth/login", loginHandler).Methods("POST")
+	r.Handle("/auth/refresh", refreshHandler).Methods("POST")
+	r.Handle("/auth/logout", logoutHandler).Methods("POST")
+	r.Handle("/auth/password-reset", requestResetHandler).Methods("POST")
+	r.Handle("/auth/password-reset/confirm", confirmResetHandler).Methods("POST")
+
+	return r
+}
+
+func decodeSetPasswordRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req SetPasswordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, ErrInvalidBody
+	}
+
+	req.ID = mux.Vars(r)["id"]
+
+	return req, nil
+}
+
+func decodeLoginRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req LoginRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, ErrInvalidBody
+	}
+
+	return req, nil
+}
+
+func decodeRefreshRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req RefreshRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.RefreshToken) == 0 {
+		return nil, ErrInvalidBody
+	}
+
+	return req, nil
+}
+
+func decodeRequestPasswordResetRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req RequestPasswordResetRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Email) == 0 {
+		return nil, ErrInvalidBody
+	}
+
+	return req, nil
+}
+
+func decodeConfirmPasswordResetRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req ConfirmPasswordResetRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Token) == 0 {
+		return nil, ErrInvalidBody
+	}
+
+	return req, nil
+}
+
+func encodeTokensResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	// tokens must not be kept by intermediaries
+	w.Header().Set("Cache-Control", "no-store")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func encodeAcceptedResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+func encodeNoContentResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// encode errors from business-logic
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	switch err {
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	case ErrInvalidBody,
+		ErrWeakPassword,
+		ErrInvalidResetToken:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrInvalidCredentials,
+		ErrInvalidToken,
+		ErrUnauthenticated:
+		w.WriteHeader(http.StatusUnauthorized)
+	case ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
+	case account.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case ErrLocked:
+		w.WriteHeader(http.StatusLocked)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
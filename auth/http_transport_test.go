@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+func Test_DecodeSetPasswordRequest(t *testing.T) {
+	expected := SetPasswordRequest{ID: "1", CurrentPassword: "old", Password: "new"}
+	r, _ := http.NewRequest("PUT", "/accounts/1/password", bytes.NewBufferString(`{"current_password":"old","password":"new"}`))
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+
+	req, err := decodeSetPasswordRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, req)
+}
+
+func Test_DecodeRefreshRequest_Should_Return_ErrInvalidBody_When_Token_Is_Missing(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/auth/refresh", bytes.NewBufferString("{}"))
+
+	_, err := decodeRefreshRequest(context.Background(), r)
+
+	assert.Equal(t, ErrInvalidBody, err)
+}
+
+func Test_EncodeTokensResponse_Should_Not_Be_Cached(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := encodeTokensResponse(context.Background(), w, &Tokens{AccessToken: "a"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+}
+
+func Test_EncodeError_Should_Correctly_Map_Error(t *testing.T) {
+	var flagtests = []struct {
+		in  error
+		out int
+	}{
+		{errors.New("not handled error"), http.StatusInternalServerError},
+		{ErrInvalidBody, http.StatusBadRequest},
+		{ErrWeakPassword, http.StatusBadRequest},
+		{ErrInvalidResetToken, http.StatusBadRequest},
+		{ErrInvalidCredentials, http.StatusUnauthorized},
+		{ErrInvalidToken, http.StatusUnauthorized},
+		{ErrUnauthenticated, http.StatusUnauthorized},
+		{ErrForbidden, http.StatusForbidden},
+		{account.ErrNotFound, http.StatusNotFound},
+		{ErrLocked, http.StatusLocked},
+	}
+
+	for _, tt := range flagtests {
+		w := httptest.NewRecorder()
+		encodeError(context.Background(), tt.in, w)
+
+		assert.Equal(t, tt.out, w.Code)
+	}
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// ErrMissingToken is used when a request requiring an access token has none
+var ErrMissingToken = errors.New("missing access token")
+
+// ErrForbidden is used when the principal of a request is not allowed to make it
+var ErrForbidden = errors.New("not allowed to make this request")
+
+// NewMiddleware returns an http middleware authenticating every request with
+// the access token of its "Authorization: Bearer" header. The principal of the
+// token is put in the request context, and recorded as the actor and, unless
+// it is the admin, as the creator.
+func NewMiddleware(s Service) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if len(token) == 0 {
+				writeError(w, http.StatusUnauthorized, ErrMissingToken)
+				return
+			}
+
+			p, err := s.Authenticate(r.Context(), token)
+			if err == ErrInvalidToken {
+				writeError(w, http.StatusUnauthorized, err)
+				return
+			}
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+
+			ctx := account.ContextWithActor(ContextWithPrincipal(r.Context(), p), p.Actor())
+			if !p.Admin {
+				ctx = account.ContextWithCreator(ctx, p.AccountID)
+			}
+			r = r.WithContext(ctx)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAdmin returns an http middleware only letting the requests of the
+// admin principal through, it goes after NewMiddleware
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := PrincipalFromContext(r.Context()); p == nil || !p.Admin {
+			writeError(w, http.StatusForbidden, ErrForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAdminToWrite returns an http middleware only letting the GET and
+// HEAD requests of any principal through, and the others of the admin, it
+// goes after NewMiddleware
+func RequireAdminToWrite(next http.Handler) http.Handler {
+	admin := RequireAdmin(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		admin.ServeHTTP(w, r)
+	})
+}
+
+func bearerToken(r *http.Request) string {
+	const scheme = "Bearer "
+	if h := r.Header.Get("Authorization"); len(h) > len(scheme) && strings.EqualFold(h[:len(scheme)], scheme) {
+		return strings.TrimSpace(h[len(scheme):])
+	}
+
+	return ""
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// accountStore keeps the accounts written through the account handler in
+// memory, with the audit entries and the creators of the accounts created
+type accountStore struct {
+	account.Repository
+	mu       sync.Mutex
+	accounts map[string]account.Account
+	creators map[string]string
+	entries  []account.AuditEntry
+}
+
+func newAccountStore(accounts ...account.Account) *accountStore {
+	s := &accountStore{accounts: map[string]account.Account{}, creators: map[string]string{}}
+	for _, a := range accounts {
+		s.accounts[a.AccountID] = a
+	}
+	return s
+}
+
+func (s *accountStore) GetAccount(ctx context.Context, id string) (*account.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.accounts[id]
+	if !ok {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+func (s *accountStore) CreateAccount(ctx context.Context, a account.Account) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a.AccountID = "new"
+	s.accounts[a.AccountID] = a
+	s.creators[a.AccountID] = account.CreatorFromContext(ctx)
+	return a.AccountID, nil
+}
+
+func (s *accountStore) UpdateAccount(ctx context.Context, a account.Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accounts[a.AccountID] = a
+	return nil
+}
+
+func (s *accountStore) Append(ctx context.Context, e account.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+func (s *accountStore) History(ctx context.Context, accountID string, pagination account.Pagination) ([]*account.AuditEntry, error) {
+	return nil, nil
+}
+
+// newAccountHandler returns the account handler behind the middleware, as it is served
+func newAccountHandler(s Service, store *accountStore) http.Handler {
+	accounts := account.NewService(store, account.ServiceAuditLog(store))
+
+	return NewMiddleware(s)(account.MakeHTTPHandler(log.NewNopLogger(), account.Endpoints{
+		Update: account.MakeUpdateAccountEndpoint(accounts),
+		Create: account.MakeCreateAccountEndpoint(accounts),
+	}))
+}
+
+func Test_Middleware(t *testing.T) {
+	svc, _ := newTestService(t)
+	tokens, _ := svc.Login(context.Background(), "a@example.com", "password1")
+
+	var principal *Principal
+	var actor string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = PrincipalFromContext(r.Context())
+		actor = account.ActorFromContext(r.Context())
+	})
+	h := NewMiddleware(svc)(next)
+
+	var flagtests = []struct {
+		authorization string
+		code          int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer not-a-token", http.StatusUnauthorized},
+		{"Bearer " + tokens.RefreshToken, http.StatusUnauthorized},
+		{"bearer " + tokens.AccessToken, http.StatusOK},
+	}
+
+	for _, tt := range flagtests {
+		principal = nil
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/invitations/token/accept", nil)
+		if len(tt.authorization) > 0 {
+			r.Header.Set("Authorization", tt.authorization)
+		}
+
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, tt.code, w.Code)
+		if tt.code == http.StatusOK {
+			assert.Equal(t, "1", principal.AccountID)
+			assert.Equal(t, "account:1", actor)
+		} else {
+			assert.Nil(t, principal)
+			assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func Test_RequireAdmin(t *testing.T) {
+	svc, _ := newTestService(t)
+	tokens, _ := svc.Login(context.Background(), "a@example.com", "password1")
+
+	var actor, creator string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = account.ActorFromContext(r.Context())
+		creator = account.CreatorFromContext(r.Context())
+	})
+	h := NewMiddleware(svc)(RequireAdmin(next))
+
+	var flagtests = []struct {
+		authorization string
+		code          int
+	}{
+		{"Bearer " + tokens.AccessToken, http.StatusForbidden},
+		{"Bearer admin", http.StatusUnauthorized},
+		{"Bearer admin-key", http.StatusOK},
+	}
+
+	for _, tt := range flagtests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/webhooks/", nil)
+		r.Header.Set("Authorization", tt.authorization)
+
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, tt.code, w.Code, tt.authorization)
+	}
+	assert.Equal(t, "admin", actor)
+	// the accounts created by the admin have no owner
+	assert.Empty(t, creator)
+}
+
+func Test_RequireAdminToWrite(t *testing.T) {
+	svc, _ := newTestService(t)
+	tokens, _ := svc.Login(context.Background(), "a@example.com", "password1")
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := NewMiddleware(svc)(RequireAdminToWrite(next))
+
+	var flagtests = []struct {
+		method        string
+		authorization string
+		code          int
+	}{
+		{"GET", "Bearer " + tokens.AccessToken, http.StatusOK},
+		{"HEAD", "Bearer " + tokens.AccessToken, http.StatusOK},
+		{"PUT", "Bearer " + tokens.AccessToken, http.StatusForbidden},
+		{"DELETE", "Bearer " + tokens.AccessToken, http.StatusForbidden},
+		{"PUT", "", http.StatusUnauthorized},
+		{"PUT", "Bearer admin-key", http.StatusOK},
+		{"DELETE", "Bearer admin-key", http.StatusOK},
+	}
+
+	for _, tt := range flagtests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(tt.method, "/schemas/accounts/t1", nil)
+		if len(tt.authorization) > 0 {
+			r.Header.Set("Authorization", tt.authorization)
+		}
+
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, tt.code, w.Code, tt.method+" "+tt.authorization)
+	}
+}
+
+func Test_Middleware_Should_Audit_The_Changes_With_The_Principal_As_Actor(t *testing.T) {
+	svc, _ := newTestService(t)
+	tokens, _ := svc.Login(context.Background(), "a@example.com", "password1")
+	store := newAccountStore(account.Account{AccountID: "1", Status: account.StatusActive, Email: "a@example.com"})
+	h := newAccountHandler(svc, store)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("PATCH", "/accounts/1", strings.NewReader(`{"name":"Alice","email":"a@example.com"}`))
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, store.entries)
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("PATCH", "/accounts/1", strings.NewReader(`{"name":"Alice","email":"a@example.com"}`))
+	r.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	if assert.Len(t, store.entries, 1) {
+		assert.Equal(t, "account:1", store.entries[0].Actor)
+		assert.Equal(t, account.OperationUpdate, store.entries[0].Operation)
+	}
+}
+
+func Test_Middleware_Should_Make_The_Principal_The_Creator_Of_The_Accounts_It_Creates(t *testing.T) {
+	svc, _ := newTestService(t)
+	tokens, _ := svc.Login(context.Background(), "a@example.com", "password1")
+	store := newAccountStore(account.Account{AccountID: "1", Status: account.StatusActive, Email: "a@example.com"})
+	h := newAccountHandler(svc, store)
+
+	var flagtests = []struct {
+		token   string
+		creator string
+	}{
+		{tokens.AccessToken, "1"},
+		// the accounts created by the admin have no owner
+		{"admin-key", ""},
+	}
+
+	for _, tt := range flagtests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/accounts/", strings.NewReader(`{"name":"Acme"}`))
+		r.Header.Set("Authorization", "Bearer "+tt.token)
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		// the repository makes the creator the owner of the account, with the account
+		assert.Equal(t, tt.creator, store.creators["new"])
+	}
+}
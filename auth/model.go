@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Token types
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+)
+
+// Credential is the password of an account, stored apart from the account
+// itself so that it is never returned with it
+type Credential struct {
+	AccountID      string     `bson:"_id"`
+	PasswordHash   string     `bson:"password_hash"`
+	FailedAttempts int        `bson:"failed_attempts"`
+	LockedUntil    *time.Time `bson:"locked_until,omitempty"`
+	UpdatedAt      time.Time  `bson:"updated_at"`
+}
+
+// Session is a login, the access and refresh tokens it issues are only valid until it is revoked
+type Session struct {
+	ID        string     `json:"id" bson:"_id"`
+	AccountID string     `json:"account_id" bson:"account_id"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" bson:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// ResetToken is a password reset token, only its hash is stored
+type ResetToken struct {
+	Hash      string     `bson:"_id"`
+	AccountID string     `bson:"account_id"`
+	CreatedAt time.Time  `bson:"created_at"`
+	ExpiresAt time.Time  `bson:"expires_at"`
+	UsedAt    *time.Time `bson:"used_at,omitempty"`
+}
+
+// Tokens is the pair of tokens issued by a login or a refresh
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Claims are the claims of the signed tokens, the subject is the account ID
+type Claims struct {
+	jwt.StandardClaims
+	Type      string `json:"typ"`
+	SessionID string `json:"sid"`
+}
+
+// Principal is who an authenticated request is made by: a session, an API key
+// and its scopes, or the admin, who may act on every account
+type Principal struct {
+	AccountID string
+	SessionID string
+	KeyID     string
+	Scopes    []string
+	Admin     bool
+}
+
+// Actor returns how the changes made by the principal are recorded, see account.AuditEntry
+func (p *Principal) Actor() string {
+	switch {
+	case p.Admin:
+		return "admin"
+	case len(p.KeyID) > 0:
+		return "api-key:" + p.KeyID
+	}
+	return "account:" + p.AccountID
+}
+
+type contextKey int
+
+const contextKeyPrincipal contextKey = iota
+
+// ContextWithPrincipal returns a context holding the authenticated principal
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKeyPrincipal, p)
+}
+
+// PrincipalFromContext returns the principal held by the context, nil if the request is not authenticated
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(contextKeyPrincipal).(*Principal)
+	return p
+}
+
+// AuthorizeAccount returns nil when the principal of the context manages the
+// account: the admin, or a session of the account itself. API keys never do.
+func AuthorizeAccount(ctx context.Context, accountID string) error {
+	p := PrincipalFromContext(ctx)
+	switch {
+	case p == nil:
+		return ErrUnauthenticated
+	case p.Admin:
+		return nil
+	case len(p.SessionID) > 0 && p.AccountID == accountID:
+		return nil
+	}
+
+	return ErrForbidden
+}
+
+// HashToken returns the hash under which a reset token is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/verification"
+)
+
+// Notifier delivers password reset tokens to the account holders
+type Notifier interface {
+	NotifyPasswordReset(ctx context.Context, a account.Account, token string, expiresAt time.Time) error
+}
+
+type mailNotifier struct {
+	mailer verification.Mailer
+}
+
+// NewMailNotifier returns a Notifier emailing the reset tokens
+func NewMailNotifier(m verification.Mailer) Notifier {
+	return mailNotifier{
+		mailer: m,
+	}
+}
+
+func (n mailNotifier) NotifyPasswordReset(ctx context.Context, a account.Account, token string, expiresAt time.Time) error {
+	return n.mailer.Send(ctx, verification.Message{
+		To:      a.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("To choose a new password, send this token with it to POST /auth/password-reset/confirm before %s:\n\n%s\n\nIf you did not ask for a password reset, ignore this email.\n",
+			expiresAt.Format(time.RFC1123), token),
+	})
+}
+
+// FileNotifier appends every notification as a JSON line to a file, for local runs and tests
+type FileNotifier struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// fileNotification is a line written by the FileNotifier
+type fileNotification struct {
+	AccountID string    `json:"account_id"`
+	Email     string    `json:"email"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// OpenFileNotifier returns a FileNotifier appending to the file at path, which is created if needed
+func OpenFileNotifier(path string) (*FileNotifier, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileNotifier{file: f}, nil
+}
+
+// NotifyPasswordReset writes the reset token to the file
+func (n *FileNotifier) NotifyPasswordReset(ctx context.Context, a account.Account, token string, expiresAt time.Time) error {
+	b, err := json.Marshal(fileNotification{
+		AccountID: a.AccountID,
+		Email:     a.Email,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, err = n.file.Write(append(b, '\n'))
+	return err
+}
+
+// Close closes the underlying file
+func (n *FileNotifier) Close() error {
+	return n.file.Close()
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// Repository is the storage of the Credentials and Sessions
+type Repository interface {
+	// GetCredential returns the credential of an account, nil if it has none
+	GetCredential(ctx context.Context, accountID string) (*Credential, error)
+	// SetPassword creates or replaces the password hash of an account, and clears its failed attempts
+	SetPassword(ctx context.Context, accountID string, hash string) error
+	// RecordFailedLogin increments the failed attempts of an account and returns them
+	RecordFailedLogin(ctx context.Context, accountID string) (int, error)
+	// Lock prevents logging in to an account until the given time, and clears its failed attempts
+	Lock(ctx context.Context, accountID string, until time.Time) error
+	// ResetFailedLogins clears the failed attempts of an account
+	ResetFailedLogins(ctx context.Context, accountID string) error
+
+	CreateSession(ctx context.Context, s Session) error
+	// GetSession returns a session, nil if there is none
+	GetSession(ctx context.Context, id string) (*Session, error)
+	RevokeSession(ctx context.Context, id string, at time.Time) error
+	// RevokeSessions revokes every session of an account
+	RevokeSessions(ctx context.Context, accountID string, at time.Time) error
+
+	CreateResetToken(ctx context.Context, t ResetToken) error
+	// LastResetToken returns the newest reset token of an account, nil if there is none
+	LastResetToken(ctx context.Context, accountID string) (*ResetToken, error)
+	// UseResetToken marks a reset token as used and returns it, nil if it is
+	// unknown, expired or already used
+	UseResetToken(ctx context.Context, hash string, at time.Time) (*ResetToken, error)
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// fakeRepository keeps credentials and sessions in memory
+type fakeRepository struct {
+	mu          sync.Mutex
+	credentials map[string]Credential
+	sessions    map[string]Session
+	resets      map[string]ResetToken
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		credentials: map[string]Credential{},
+		sessions:    map[string]Session{},
+		resets:      map[string]ResetToken{},
+	}
+}
+
+func (r *fakeRepository) GetCredential(ctx context.Context, accountID string) (*Credential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.credentials[accountID]
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (r *fakeRepository) SetPassword(ctx context.Context, accountID string, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.credentials[accountID] = Credential{AccountID: accountID, PasswordHash: hash}
+	return nil
+}
+
+func (r *fakeRepository) RecordFailedLogin(ctx context.Context, accountID string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.credentials[accountID]
+	c.FailedAttempts++
+	r.credentials[accountID] = c
+	return c.FailedAttempts, nil
+}
+
+func (r *fakeRepository) Lock(ctx context.Context, accountID string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.credentials[accountID]
+	c.FailedAttempts = 0
+	c.LockedUntil = &until
+	r.credentials[accountID] = c
+	return nil
+}
+
+func (r *fakeRepository) ResetFailedLogins(ctx context.Context, accountID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.credentials[accountID]
+	c.FailedAttempts = 0
+	c.LockedUntil = nil
+	r.credentials[accountID] = c
+	return nil
+}
+
+func (r *fakeRepository) CreateSession(ctx context.Context, s Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[s.ID] = s
+	return nil
+}
+
+func (r *fakeRepository) GetSession(ctx context.Context, id string) (*Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+func (r *fakeRepository) RevokeSession(ctx context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[id]
+	if !ok || s.RevokedAt != nil {
+		return ErrInvalidToken
+	}
+	s.RevokedAt = &at
+	r.sessions[id] = s
+	return nil
+}
+
+func (r *fakeRepository) RevokeSessions(ctx context.Context, accountID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, s := range r.sessions {
+		if s.AccountID == accountID && s.RevokedAt == nil {
+			s.RevokedAt = &at
+			r.sessions[id] = s
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepository) CreateResetToken(ctx context.Context, t ResetToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.resets[t.Hash] = t
+	return nil
+}
+
+func (r *fakeRepository) LastResetToken(ctx context.Context, accountID string) (*ResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var last *ResetToken
+	for _, t := range r.resets {
+		if t.AccountID == accountID && (last == nil || t.CreatedAt.After(last.CreatedAt)) {
+			t := t
+			last = &t
+		}
+	}
+	return last, nil
+}
+
+func (r *fakeRepository) UseResetToken(ctx context.Context, hash string, at time.Time) (*ResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.resets[hash]
+	if !ok || t.UsedAt != nil || !at.Before(t.ExpiresAt) {
+		return nil, nil
+	}
+	t.UsedAt = &at
+	r.resets[hash] = t
+	return &t, nil
+}
+
+// fakeAccounts only implements the account.Service methods used by the authentication service
+type fakeAccounts struct {
+	account.Service
+	accounts []account.Account
+}
+
+func (s *fakeAccounts) GetAccount(ctx context.Context, id string) (*account.Account, error) {
+	for _, a := range s.accounts {
+		if a.AccountID == id {
+			return &a, nil
+		}
+	}
+	return nil, account.ErrNotFound
+}
+
+func (s *fakeAccounts) GetAccounts(ctx context.Context, filter account.Filter, pagination account.Pagination) ([]*account.Account, error) {
+	accounts := []*account.Account{}
+	for i, a := range s.accounts {
+		for _, email := range filter.Emails {
+			if a.Email == email {
+				accounts = append(accounts, &s.accounts[i])
+			}
+		}
+	}
+	return accounts, nil
+}
@@ -0,0 +1,429 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-kit/kit/log"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials is used when a login does not match any account password
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrLocked is used when logging in to an account locked after too many failed attempts
+var ErrLocked = errors.New("account locked, try again later")
+
+// ErrInvalidToken is used when a token is malformed, expired, of the wrong type or revoked
+var ErrInvalidToken = errors.New("invalid token")
+
+// ErrInvalidResetToken is used when a password reset token is unknown, expired or already used
+var ErrInvalidResetToken = errors.New("invalid password reset token")
+
+// ErrUnauthenticated is used when a request requiring a principal has none
+var ErrUnauthenticated = errors.New("authentication required")
+
+// ErrWeakPassword is used when a password is shorter than MinPasswordLength
+var ErrWeakPassword = errors.New("password too short")
+
+// MinPasswordLength is the minimum length of a password
+const MinPasswordLength = 8
+
+// resetQueueSize is the number of password resets waiting to be sent, past
+// which the requests are dropped
+const resetQueueSize = 100
+
+// dummyHash is compared against when there is no credential to check, so that
+// a login takes as long whether the account exists or not
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// Options configures the authentication service
+type Options struct {
+	SigningKey []byte
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// MaxAttempts is the number of failed logins after which an account is locked for LockoutTime
+	MaxAttempts int
+	LockoutTime time.Duration
+	// ResetTTL is the lifetime of a password reset token, and ResetInterval the
+	// minimum time between two resets sent to an account
+	ResetTTL      time.Duration
+	ResetInterval time.Duration
+	// AdminKey is the access token of the admin principal, there is none when it is empty
+	AdminKey string
+}
+
+// Service is the authentication service interface
+type Service interface {
+	SetPassword(ctx context.Context, accountID, current, password string) error
+	Login(ctx context.Context, email, password string) (*Tokens, error)
+	Refresh(ctx context.Context, refreshToken string) (*Tokens, error)
+	Logout(ctx context.Context, refreshToken string) error
+	Authenticate(ctx context.Context, accessToken string) (*Principal, error)
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	// SendPasswordResets sends the requested password resets until ctx is done
+	SendPasswordResets(ctx context.Context)
+}
+
+type service struct {
+	accounts   account.Service
+	repository Repository
+	notifier   Notifier
+	options    Options
+	logger     log.Logger
+	// resets are the emails of the password resets waiting to be sent
+	resets chan string
+}
+
+// NewService return a new instance of the authentication service
+func NewService(accounts account.Service, r Repository, n Notifier, o Options, logger log.Logger) Service {
+	return service{
+		accounts:   accounts,
+		repository: r,
+		notifier:   n,
+		options:    o,
+		logger:     logger,
+		resets:     make(chan string, resetQueueSize),
+	}
+}
+
+// SetPassword changes the password of the account of the session making the request, the current
+// one is required. An account without password sets its first one with a reset token, see
+// RequestPasswordReset. Changing a password revokes the sessions of the account.
+func (s service) SetPassword(ctx context.Context, accountID, current, password string) error {
+	p := PrincipalFromContext(ctx)
+	if p == nil {
+		return ErrUnauthenticated
+	}
+	if len(p.SessionID) == 0 || p.AccountID != accountID {
+		return ErrForbidden
+	}
+
+	if len(password) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+
+	if _, err := s.accounts.GetAccount(ctx, accountID); err != nil {
+		return err
+	}
+
+	c, err := s.repository.GetCredential(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	if c == nil || bcrypt.CompareHashAndPassword([]byte(c.PasswordHash), []byte(current)) != nil {
+		return ErrInvalidCredentials
+	}
+
+	return s.setPassword(ctx, accountID, password)
+}
+
+// RequestPasswordReset sends a reset token to the active account with the email, once it is
+// verified. Whether there is one is never revealed: the reset is queued and sent by
+// SendPasswordResets, so that the caller gets nil at once in every case, and its outcome is only
+// logged. A request made when the queue is full is dropped.
+func (s service) RequestPasswordReset(ctx context.Context, email string) error {
+	select {
+	case s.resets <- email:
+	default:
+		s.logger.Log("password_reset", "dropped", "err", "queue full")
+	}
+
+	return nil
+}
+
+// SendPasswordResets sends the queued password resets one at a time, until ctx is done
+func (s service) SendPasswordResets(ctx context.Context) {
+	for {
+		select {
+		case email := <-s.resets:
+			s.sendPasswordReset(ctx, email)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sendPasswordReset sends a reset token to the account with the email, unless
+// its email is not verified, as the one of an account given another's address
+// would be, or it was sent one less than the reset interval ago
+func (s service) sendPasswordReset(ctx context.Context, email string) {
+	a, err := s.accountByEmail(ctx, email)
+	if err != nil || a == nil {
+		s.logger.Log("password_reset", "no_account", "err", err)
+		return
+	}
+	if !a.EmailVerified {
+		s.logger.Log("account_id", a.AccountID, "password_reset", "unverified_email")
+		return
+	}
+
+	last, err := s.repository.LastResetToken(ctx, a.AccountID)
+	if err != nil {
+		s.logger.Log("account_id", a.AccountID, "password_reset_error", err)
+		return
+	}
+	if last != nil && time.Since(last.CreatedAt) < s.options.ResetInterval {
+		s.logger.Log("account_id", a.AccountID, "password_reset", "too_many_requests")
+		return
+	}
+
+	token, err := newID()
+	if err != nil {
+		s.logger.Log("account_id", a.AccountID, "password_reset_error", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	t := ResetToken{
+		Hash:      HashToken(token),
+		AccountID: a.AccountID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.options.ResetTTL),
+	}
+	if err := s.repository.CreateResetToken(ctx, t); err != nil {
+		s.logger.Log("account_id", a.AccountID, "password_reset_error", err)
+		return
+	}
+
+	if err := s.notifier.NotifyPasswordReset(ctx, *a, token, t.ExpiresAt); err != nil {
+		s.logger.Log("account_id", a.AccountID, "password_reset_error", err)
+	}
+}
+
+// ResetPassword sets a new password with a reset token, and revokes the sessions of the account
+func (s service) ResetPassword(ctx context.Context, token, password string) error {
+	if len(password) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+
+	t, err := s.repository.UseResetToken(ctx, HashToken(token), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return ErrInvalidResetToken
+	}
+
+	return s.setPassword(ctx, t.AccountID, password)
+}
+
+// setPassword hashes and stores a password, the sessions opened with the previous one are revoked
+func (s service) setPassword(ctx context.Context, accountID, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repository.SetPassword(ctx, accountID, string(hash)); err != nil {
+		return err
+	}
+
+	return s.repository.RevokeSessions(ctx, accountID, time.Now().UTC())
+}
+
+// Login checks the password of the active account with the given email and opens a session
+func (s service) Login(ctx context.Context, email, password string) (*Tokens, error) {
+	c, err := s.credentialByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+
+	now := time.Now().UTC()
+	if c.LockedUntil != nil && now.Before(*c.LockedUntil) {
+		return nil, ErrLocked
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(c.PasswordHash), []byte(password)) != nil {
+		attempts, err := s.repository.RecordFailedLogin(ctx, c.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		if attempts >= s.options.MaxAttempts {
+			if err := s.repository.Lock(ctx, c.AccountID, now.Add(s.options.LockoutTime)); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if c.FailedAttempts > 0 {
+		if err := s.repository.ResetFailedLogins(ctx, c.AccountID); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.openSession(ctx, c.AccountID, now)
+}
+
+// Refresh exchanges a refresh token for a new pair of tokens, the session of
+// the old ones is revoked so that a refresh token can only be used once
+func (s service) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	session, err := s.session(ctx, refreshToken, TokenRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if err := s.repository.RevokeSession(ctx, session.ID, now); err != nil {
+		return nil, err
+	}
+
+	return s.openSession(ctx, session.AccountID, now)
+}
+
+// Logout revokes the session of a refresh token, and so its access token too
+func (s service) Logout(ctx context.Context, refreshToken string) error {
+	session, err := s.session(ctx, refreshToken, TokenRefresh)
+	if err != nil {
+		return err
+	}
+
+	return s.repository.RevokeSession(ctx, session.ID, time.Now().UTC())
+}
+
+// Authenticate returns who an access token was issued to, or the admin principal for the admin key
+func (s service) Authenticate(ctx context.Context, accessToken string) (*Principal, error) {
+	if len(s.options.AdminKey) > 0 && subtle.ConstantTimeCompare([]byte(accessToken), []byte(s.options.AdminKey)) == 1 {
+		return &Principal{Admin: true}, nil
+	}
+
+	session, err := s.session(ctx, accessToken, TokenAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{AccountID: session.AccountID, SessionID: session.ID}, nil
+}
+
+// credentialByEmail returns the credential of the only active account with the email, nil if there is none
+func (s service) credentialByEmail(ctx context.Context, email string) (*Credential, error) {
+	a, err := s.accountByEmail(ctx, email)
+	if err != nil || a == nil {
+		return nil, err
+	}
+
+	return s.repository.GetCredential(ctx, a.AccountID)
+}
+
+// accountByEmail returns the only active account with the email, nil if there is none
+func (s service) accountByEmail(ctx context.Context, email string) (*account.Account, error) {
+	if len(email) == 0 {
+		return nil, nil
+	}
+
+	accounts, err := s.accounts.GetAccounts(ctx, account.Filter{Emails: []string{email}}, account.Pagination{Size: 2})
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) != 1 || accounts[0].Status != account.StatusActive {
+		return nil, nil
+	}
+
+	return accounts[0], nil
+}
+
+func (s service) openSession(ctx context.Context, accountID string, now time.Time) (*Tokens, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	session := Session{
+		ID:        id,
+		AccountID: accountID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.options.RefreshTTL),
+	}
+	if err := s.repository.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	access, err := s.sign(session, TokenAccess, now, now.Add(s.options.AccessTTL))
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := s.sign(session, TokenRefresh, now, session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.options.AccessTTL / time.Second),
+	}, nil
+}
+
+func (s service) sign(session Session, tokenType string, now, expiresAt time.Time) (string, error) {
+	jti, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	claims := Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			Subject:   session.AccountID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+		Type:      tokenType,
+		SessionID: session.ID,
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.options.SigningKey)
+}
+
+// session returns the live session of a token of the given type
+func (s service) session(ctx context.Context, token string, tokenType string) (*Session, error) {
+	claims := new(Claims)
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, ErrInvalidToken
+		}
+		return s.options.SigningKey, nil
+	})
+	if err != nil || claims.Type != tokenType {
+		return nil, ErrInvalidToken
+	}
+
+	session, err := s.repository.GetSession(ctx, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.RevokedAt != nil || session.AccountID != claims.Subject {
+		return nil, ErrInvalidToken
+	}
+
+	// the sessions of an account end with it, when it is deleted, merged or closed
+	a, err := s.accounts.GetAccount(ctx, session.AccountID)
+	if err == account.ErrNotFound || (err == nil && a.Status != account.StatusActive) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testOptions = Options{
+	SigningKey:    []byte("secret"),
+	AccessTTL:     time.Minute,
+	RefreshTTL:    time.Hour,
+	MaxAttempts:   3,
+	LockoutTime:   time.Minute,
+	ResetTTL:      time.Minute,
+	ResetInterval: time.Minute,
+	AdminKey:      "admin-key",
+}
+
+func newTestService(t *testing.T) (Service, *fakeRepository) {
+	return newTestServiceWithNotifier(t, nil)
+}
+
+func newTestServiceWithNotifier(t *testing.T, n Notifier) (Service, *fakeRepository) {
+	accounts := &fakeAccounts{accounts: []account.Account{
+		{AccountID: "1", Status: account.StatusActive, Email: "a@example.com", EmailVerified: true},
+		{AccountID: "2", Status: account.StatusClosed, Email: "b@example.com", EmailVerified: true},
+		{AccountID: "3", Status: account.StatusActive, Email: "c@example.com"},
+	}}
+	repo := newFakeRepository()
+	svc := NewService(accounts, repo, n, testOptions, log.NewNopLogger())
+
+	for id, password := range map[string]string{"1": "password1", "2": "password2"} {
+		hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		assert.Nil(t, repo.SetPassword(context.Background(), id, string(hash)))
+	}
+
+	return svc, repo
+}
+
+// sendResets sends the password resets requested so far
+func sendResets(svc Service) {
+	s := svc.(service)
+	for len(s.resets) > 0 {
+		s.sendPasswordReset(context.Background(), <-s.resets)
+	}
+}
+
+// sessionContext returns the context of a request made by a session of the account
+func sessionContext(accountID string) context.Context {
+	return ContextWithPrincipal(context.Background(), &Principal{AccountID: accountID, SessionID: "s"})
+}
+
+func Test_Login_Should_Issue_Tokens_Of_The_Account(t *testing.T) {
+	svc, _ := newTestService(t)
+
+	tokens, err := svc.Login(context.Background(), "a@example.com", "password1")
+	assert.Nil(t, err)
+	assert.Equal(t, 60, tokens.ExpiresIn)
+
+	p, err := svc.Authenticate(context.Background(), tokens.AccessToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", p.AccountID)
+
+	_, err = svc.Authenticate(context.Background(), tokens.RefreshToken)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func Test_Login_Should_Return_ErrInvalidCredentials(t *testing.T) {
+	svc, _ := newTestService(t)
+
+	var flagtests = []struct {
+		email    string
+		password string
+	}{
+		{"a@example.com", "wrong password"},
+		{"unknown@example.com", "password1"},
+		{"b@example.com", "password2"},
+	}
+
+	for _, tt := range flagtests {
+		_, err := svc.Login(context.Background(), tt.email, tt.password)
+
+		assert.Equal(t, ErrInvalidCredentials, err, tt.email)
+	}
+}
+
+func Test_Login_Should_Lock_After_Max_Attempts(t *testing.T) {
+	svc, repo := newTestService(t)
+
+	for i := 0; i < testOptions.MaxAttempts; i++ {
+		_, err := svc.Login(context.Background(), "a@example.com", "wrong password")
+		assert.Equal(t, ErrInvalidCredentials, err)
+	}
+
+	_, err := svc.Login(context.Background(), "a@example.com", "password1")
+	assert.Equal(t, ErrLocked, err)
+
+	repo.ResetFailedLogins(context.Background(), "1")
+	_, err = svc.Login(context.Background(), "a@example.com", "password1")
+	assert.Nil(t, err)
+}
+
+func Test_Refresh_Should_Rotate_The_Session(t *testing.T) {
+	svc, _ := newTestService(t)
+	tokens, _ := svc.Login(context.Background(), "a@example.com", "password1")
+
+	refreshed, err := svc.Refresh(context.Background(), tokens.RefreshToken)
+	assert.Nil(t, err)
+
+	_, err = svc.Refresh(context.Background(), tokens.RefreshToken)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	_, err = svc.Authenticate(context.Background(), tokens.AccessToken)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	_, err = svc.Authenticate(context.Background(), refreshed.AccessToken)
+	assert.Nil(t, err)
+}
+
+func Test_Logout_Should_Revoke_The_Access_Token(t *testing.T) {
+	svc, _ := newTestService(t)
+	tokens, _ := svc.Login(context.Background(), "a@example.com", "password1")
+
+	err := svc.Logout(context.Background(), tokens.RefreshToken)
+	assert.Nil(t, err)
+
+	_, err = svc.Authenticate(context.Background(), tokens.AccessToken)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func Test_Authenticate_Should_Reject_Tokens_Signed_With_Another_Key(t *testing.T) {
+	svc, repo := newTestService(t)
+	tokens, _ := svc.Login(context.Background(), "a@example.com", "password1")
+
+	other := NewService(&fakeAccounts{}, repo, nil, Options{SigningKey: []byte("other")}, log.NewNopLogger())
+	_, err := other.Authenticate(context.Background(), tokens.AccessToken)
+
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func Test_Authenticate_Should_Reject_The_Tokens_Of_A_Deleted_Or_Merged_Account(t *testing.T) {
+	svc, repo := newTestService(t)
+	tokens, _ := svc.Login(context.Background(), "a@example.com", "password1")
+
+	var flagtests = []*fakeAccounts{
+		{},
+		{accounts: []account.Account{{AccountID: "1", Status: account.StatusMerged, MergedInto: "3"}}},
+	}
+
+	for _, accounts := range flagtests {
+		after := NewService(accounts, repo, nil, testOptions, log.NewNopLogger())
+
+		_, err := after.Authenticate(context.Background(), tokens.AccessToken)
+		assert.Equal(t, ErrInvalidToken, err)
+
+		_, err = after.Refresh(context.Background(), tokens.RefreshToken)
+		assert.Equal(t, ErrInvalidToken, err)
+	}
+}
+
+func Test_SetPassword_Should_Require_The_Current_Password(t *testing.T) {
+	svc, repo := newTestService(t)
+
+	err := svc.SetPassword(sessionContext("1"), "1", "wrong password", "password3")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	err = svc.SetPassword(sessionContext("1"), "1", "password1", "short")
+	assert.Equal(t, ErrWeakPassword, err)
+
+	delete(repo.credentials, "1")
+	err = svc.SetPassword(sessionContext("1"), "1", "", "password3")
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func Test_SetPassword_Should_Require_A_Session_Of_The_Account(t *testing.T) {
+	svc, _ := newTestService(t)
+
+	err := svc.SetPassword(context.Background(), "1", "password1", "password3")
+	assert.Equal(t, ErrUnauthenticated, err)
+
+	err = svc.SetPassword(sessionContext("2"), "1", "password1", "password3")
+	assert.Equal(t, ErrForbidden, err)
+
+	ctx := ContextWithPrincipal(context.Background(), &Principal{AccountID: "1", KeyID: "k"})
+	err = svc.SetPassword(ctx, "1", "password1", "password3")
+	assert.Equal(t, ErrForbidden, err)
+}
+
+func Test_SetPassword_Should_Revoke_The_Sessions(t *testing.T) {
+	svc, _ := newTestService(t)
+	tokens, _ := svc.Login(context.Background(), "a@example.com", "password1")
+
+	err := svc.SetPassword(sessionContext("1"), "1", "password1", "password3")
+	assert.Nil(t, err)
+
+	_, err = svc.Authenticate(context.Background(), tokens.AccessToken)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func Test_PasswordReset_Should_Set_The_Password_And_Revoke_The_Sessions(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "auth")
+	defer os.RemoveAll(dir)
+	notifier, err := OpenFileNotifier(filepath.Join(dir, "notifications.ndjson"))
+	assert.Nil(t, err)
+	defer notifier.Close()
+
+	svc, _ := newTestServiceWithNotifier(t, notifier)
+	tokens, _ := svc.Login(context.Background(), "a@example.com", "password1")
+
+	err = svc.RequestPasswordReset(context.Background(), "a@example.com")
+	assert.Nil(t, err)
+	sendResets(svc)
+
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "notifications.ndjson"))
+	var n fileNotification
+	assert.Nil(t, json.Unmarshal(b, &n))
+	assert.Equal(t, "1", n.AccountID)
+
+	err = svc.ResetPassword(context.Background(), n.Token, "password3")
+	assert.Nil(t, err)
+
+	_, err = svc.Authenticate(context.Background(), tokens.AccessToken)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	_, err = svc.Login(context.Background(), "a@example.com", "password3")
+	assert.Nil(t, err)
+
+	err = svc.ResetPassword(context.Background(), n.Token, "password4")
+	assert.Equal(t, ErrInvalidResetToken, err)
+}
+
+func Test_RequestPasswordReset_Should_Not_Reveal_Unknown_Emails(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "auth")
+	defer os.RemoveAll(dir)
+	notifier, _ := OpenFileNotifier(filepath.Join(dir, "notifications.ndjson"))
+	defer notifier.Close()
+
+	svc, _ := newTestServiceWithNotifier(t, notifier)
+	err := svc.RequestPasswordReset(context.Background(), "unknown@example.com")
+	sendResets(svc)
+
+	assert.Nil(t, err)
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "notifications.ndjson"))
+	assert.Empty(t, b)
+}
+
+func Test_RequestPasswordReset_Should_Only_Send_To_Verified_Emails_Once_Per_Interval(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "auth")
+	defer os.RemoveAll(dir)
+	notifier, _ := OpenFileNotifier(filepath.Join(dir, "notifications.ndjson"))
+	defer notifier.Close()
+
+	svc, _ := newTestServiceWithNotifier(t, notifier)
+	for _, email := range []string{"c@example.com", "a@example.com", "a@example.com"} {
+		assert.Nil(t, svc.RequestPasswordReset(context.Background(), email))
+	}
+	sendResets(svc)
+
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "notifications.ndjson"))
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if assert.Len(t, lines, 1) {
+		var n fileNotification
+		assert.Nil(t, json.Unmarshal([]byte(lines[0]), &n))
+		assert.Equal(t, "1", n.AccountID)
+	}
+}
+
+func Test_RequestPasswordReset_Should_Drop_The_Requests_Past_The_Queue(t *testing.T) {
+	svc, _ := newTestService(t)
+	for i := 0; i < resetQueueSize+1; i++ {
+		assert.Nil(t, svc.RequestPasswordReset(context.Background(), "a@example.com"))
+	}
+
+	assert.Len(t, svc.(service).resets, resetQueueSize)
+}
+
+func Test_ResetPassword_Should_Return_ErrInvalidResetToken_When_Expired(t *testing.T) {
+	svc, repo := newTestService(t)
+	repo.CreateResetToken(context.Background(), ResetToken{Hash: HashToken("token"), AccountID: "1", ExpiresAt: time.Now().Add(-time.Second)})
+
+	err := svc.ResetPassword(context.Background(), "token", "password3")
+
+	assert.Equal(t, ErrInvalidResetToken, err)
+}
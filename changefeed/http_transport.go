@@ -0,0 +1,140 @@
+package changefeed
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// Filter restricts the changes sent to a client
+type Filter struct {
+	IDs      []string
+	Statuses []string
+}
+
+// Matches tells if the change concerns one of the filtered accounts or statuses,
+// the status of an account is checked both before and after the change
+func (f Filter) Matches(e account.EventEnvelope) bool {
+	if len(f.IDs) > 0 && !contains(f.IDs, e.AccountID) {
+		return false
+	}
+
+	if len(f.Statuses) > 0 {
+		before := e.Before != nil && contains(f.Statuses, e.Before.Status)
+		after := e.After != nil && contains(f.Statuses, e.After.Status)
+		return before || after
+	}
+
+	return true
+}
+
+// MakeHTTPHandler returns the Server-Sent Events handler streaming the changes of the log.
+// Clients resume after the last change they received with the Last-Event-ID header,
+// a comment is sent every heartbeat to keep the connection open. A client whose
+// last change is not retained anymore, or was sent before a restart, gets a 410
+// and has to read the accounts again.
+func MakeHTTPHandler(logger log.Logger, l *Log, heartbeat time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		filter := decodeFilter(r)
+		lastID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+
+		backlog, changes, cancel, err := l.Subscribe(lastID)
+		if err == ErrUnknownLastID {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		for _, c := range backlog {
+			if err := writeChange(w, filter, c); err != nil {
+				logger.Log("changefeed_error", err)
+				return
+			}
+		}
+		flusher.Flush()
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			var err error
+
+			select {
+			case <-r.Context().Done():
+				return
+			case c, ok := <-changes:
+				if !ok {
+					// too slow, the client will resume from its last event
+					return
+				}
+				err = writeChange(w, filter, c)
+			case <-ticker.C:
+				_, err = fmt.Fprint(w, ": heartbeat\n\n")
+			}
+
+			if err != nil {
+				logger.Log("changefeed_error", err)
+				return
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+func writeChange(w http.ResponseWriter, filter Filter, c Change) error {
+	if !filter.Matches(c.Event) {
+		return nil
+	}
+
+	data, err := json.Marshal(c.Event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", c.ID, c.Event.Type, data)
+	return err
+}
+
+func decodeFilter(r *http.Request) Filter {
+	var f Filter
+
+	if ids := r.URL.Query().Get("account_id"); len(ids) > 0 {
+		f.IDs = strings.Split(ids, ",")
+	}
+	if statuses := r.URL.Query().Get("status"); len(statuses) > 0 {
+		f.Statuses = strings.Split(statuses, ",")
+	}
+
+	return f
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
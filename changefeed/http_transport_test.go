@@ -0,0 +1,50 @@
+package changefeed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+func Test_Filter_Matches(t *testing.T) {
+	e := account.AccountUpdated{
+		Before: account.Account{AccountID: "1", Status: account.StatusActive},
+		After:  account.Account{AccountID: "1", Status: account.StatusClosed},
+	}.Envelope()
+
+	assert.True(t, Filter{}.Matches(e))
+	assert.True(t, Filter{IDs: []string{"1"}, Statuses: []string{account.StatusClosed}}.Matches(e))
+	assert.False(t, Filter{IDs: []string{"2"}}.Matches(e))
+	assert.False(t, Filter{Statuses: []string{"other"}}.Matches(e))
+}
+
+func Test_MakeHTTPHandler_Should_Stream_Backlog_After_Last_Event_ID(t *testing.T) {
+	l := newTestLog(10)
+	l.Publish(context.Background(), created("1"))
+	l.Publish(context.Background(), created("2"))
+	l.Publish(context.Background(), created("3"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, _ := http.NewRequest("GET", "/accounts/changes?account_id=2,3", nil)
+	r = r.WithContext(ctx)
+	r.Header.Set("Last-Event-ID", "2")
+	w := httptest.NewRecorder()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	MakeHTTPHandler(log.NewNopLogger(), l, time.Hour).ServeHTTP(w, r)
+
+	body := w.Body.String()
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, 1, strings.Count(body, "id: "))
+	assert.Contains(t, body, "id: 3\nevent: account.created\ndata: ")
+}
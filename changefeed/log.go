@@ -0,0 +1,127 @@
+package changefeed
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// subscriberBuffer is the number of changes a subscriber may lag behind before
+// it is dropped, a dropped client reconnects and catches up from the log
+const subscriberBuffer = 64
+
+// ErrInvalidSize is used when a Log is created without room for any change
+var ErrInvalidSize = errors.New("invalid changes log size")
+
+// ErrUnknownLastID is used when resuming after a change the Log does not hold
+// anymore, or never did: the changes that followed it are lost
+var ErrUnknownLastID = errors.New("unknown last event ID")
+
+// Change is an account event numbered in the order it was received
+type Change struct {
+	ID    uint64                `json:"id"`
+	Event account.EventEnvelope `json:"event"`
+}
+
+// Log is an account.Publisher keeping the last changes in a bounded buffer,
+// and broadcasting new ones to its subscribers. The changes are numbered from
+// the start time of the process, so that the IDs of an earlier one are told
+// apart after a restart.
+type Log struct {
+	mu      sync.Mutex
+	changes []Change
+	size    int
+	// firstID precedes the first change of the process
+	firstID     uint64
+	lastID      uint64
+	subscribers map[chan Change]bool
+}
+
+// NewLog returns a Log keeping at most size changes
+func NewLog(size int) (*Log, error) {
+	if size < 1 {
+		return nil, ErrInvalidSize
+	}
+
+	start := uint64(time.Now().UnixNano())
+	return &Log{
+		changes:     make([]Change, 0, size),
+		size:        size,
+		firstID:     start,
+		lastID:      start,
+		subscribers: map[chan Change]bool{},
+	}, nil
+}
+
+// Publish appends the event to the log
+func (l *Log) Publish(ctx context.Context, e account.Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lastID++
+	c := Change{ID: l.lastID, Event: e.Envelope()}
+
+	if len(l.changes) == l.size {
+		copy(l.changes, l.changes[1:])
+		l.changes = l.changes[:l.size-1]
+	}
+	l.changes = append(l.changes, c)
+
+	for ch := range l.subscribers {
+		select {
+		case ch <- c:
+		default:
+			delete(l.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return nil
+}
+
+// Subscribe returns the retained changes following lastID, all of them for 0,
+// and a channel receiving the next ones. The channel is closed when the
+// subscriber is too slow, cancel must be called once the subscriber is done.
+// ErrUnknownLastID is returned when some of the changes following lastID are
+// not retained.
+func (l *Log) Subscribe(lastID uint64) (backlog []Change, changes <-chan Change, cancel func(), err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lastID > 0 && !l.resumable(lastID) {
+		return nil, nil, nil, ErrUnknownLastID
+	}
+
+	for _, c := range l.changes {
+		if c.ID > lastID {
+			backlog = append(backlog, c)
+		}
+	}
+
+	ch := make(chan Change, subscriberBuffer)
+	l.subscribers[ch] = true
+
+	cancel = func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.subscribers[ch] {
+			delete(l.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return backlog, ch, cancel, nil
+}
+
+// resumable tells whether every change following lastID is retained
+func (l *Log) resumable(lastID uint64) bool {
+	if lastID < l.firstID || lastID > l.lastID {
+		return false
+	}
+
+	return len(l.changes) == 0 || lastID+1 >= l.changes[0].ID
+}
@@ -0,0 +1,89 @@
+package changefeed
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// newTestLog returns a Log numbering its changes from 1
+func newTestLog(size int) *Log {
+	l, _ := NewLog(size)
+	l.firstID, l.lastID = 0, 0
+	return l
+}
+
+func created(id string) account.Event {
+	return account.AccountCreated{After: account.Account{AccountID: id}}
+}
+
+func Test_Log_Should_Keep_The_Last_Changes(t *testing.T) {
+	l := newTestLog(2)
+	l.Publish(context.Background(), created("1"))
+	l.Publish(context.Background(), created("2"))
+	l.Publish(context.Background(), created("3"))
+
+	backlog, _, cancel, err := l.Subscribe(0)
+	defer cancel()
+
+	assert.Nil(t, err)
+	assert.Len(t, backlog, 2)
+	assert.Equal(t, uint64(2), backlog[0].ID)
+	assert.Equal(t, "3", backlog[1].Event.AccountID)
+}
+
+func Test_Log_Should_Resume_After_Last_ID(t *testing.T) {
+	l := newTestLog(10)
+	l.Publish(context.Background(), created("1"))
+	l.Publish(context.Background(), created("2"))
+
+	backlog, changes, cancel, err := l.Subscribe(1)
+	defer cancel()
+	assert.Nil(t, err)
+	l.Publish(context.Background(), created("3"))
+
+	assert.Len(t, backlog, 1)
+	assert.Equal(t, "2", backlog[0].Event.AccountID)
+	assert.Equal(t, "3", (<-changes).Event.AccountID)
+}
+
+func Test_Log_Should_Drop_Slow_Subscribers(t *testing.T) {
+	l := newTestLog(10)
+	_, changes, cancel, _ := l.Subscribe(0)
+	defer cancel()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		l.Publish(context.Background(), created("1"))
+	}
+
+	count := 0
+	for range changes {
+		count++
+	}
+	assert.Equal(t, subscriberBuffer, count)
+}
+
+func Test_Log_Should_Return_ErrUnknownLastID(t *testing.T) {
+	l := newTestLog(2)
+	l.Publish(context.Background(), created("1"))
+	l.Publish(context.Background(), created("2"))
+	l.Publish(context.Background(), created("3"))
+	l.Publish(context.Background(), created("4"))
+
+	for _, lastID := range []uint64{1, 5} {
+		_, _, _, err := l.Subscribe(lastID)
+		assert.Equal(t, ErrUnknownLastID, err, lastID)
+	}
+
+	restarted, _ := NewLog(2)
+	_, _, _, err := restarted.Subscribe(3)
+	assert.Equal(t, ErrUnknownLastID, err)
+}
+
+func Test_NewLog_Should_Return_ErrInvalidSize(t *testing.T) {
+	_, err := NewLog(0)
+
+	assert.Equal(t, ErrInvalidSize, err)
+}
@@ -15,6 +15,37 @@ var (
 type Config struct {
 	Port                  int    `mapstructure:"APP_PORT"`
 	MongoConnectionString string `mapstructure:"MONGO_CONNECTION_STRING"`
+	EventsFile            string `mapstructure:"EVENTS_FILE"`
+	WebhookMaxAttempts    int    `mapstructure:"WEBHOOK_MAX_ATTEMPTS"`
+	OutboxMaxAttempts     int    `mapstructure:"OUTBOX_MAX_ATTEMPTS"`
+	ChangesLogSize        int    `mapstructure:"CHANGES_LOG_SIZE"`
+	ChangesHeartbeat      int    `mapstructure:"CHANGES_HEARTBEAT_SECONDS"`
+	VersionRetention      int    `mapstructure:"ACCOUNT_VERSION_RETENTION"`
+	Mailer                string `mapstructure:"MAILER"`
+	MailDir               string `mapstructure:"MAIL_DIR"`
+	MailFrom              string `mapstructure:"MAIL_FROM"`
+	SMTPURL               string `mapstructure:"SMTP_URL"`
+	EmailResendInterval   int    `mapstructure:"EMAIL_RESEND_SECONDS"`
+	AuthSigningKey        string `mapstructure:"AUTH_SIGNING_KEY"`
+	AuthAccessTTL         int    `mapstructure:"AUTH_ACCESS_TTL_SECONDS"`
+	AuthRefreshTTL        int    `mapstructure:"AUTH_REFRESH_TTL_SECONDS"`
+	LoginMaxAttempts      int    `mapstructure:"LOGIN_MAX_ATTEMPTS"`
+	LoginLockout          int    `mapstructure:"LOGIN_LOCKOUT_SECONDS"`
+	PasswordResetTTL      int    `mapstructure:"PASSWORD_RESET_TTL_SECONDS"`
+	PasswordResetInterval int    `mapstructure:"PASSWORD_RESET_INTERVAL_SECONDS"`
+	Notifier              string `mapstructure:"NOTIFIER"`
+	NotifierFile          string `mapstructure:"NOTIFIER_FILE"`
+	AuthMode              string `mapstructure:"AUTH_MODE"`
+	AdminKey              string `mapstructure:"ADMIN_API_KEY"`
+	InvitationTTL         int    `mapstructure:"INVITATION_TTL_SECONDS"`
+	InvitationSweep       int    `mapstructure:"INVITATION_SWEEP_SECONDS"`
+	StatsCacheTTL         int    `mapstructure:"STATS_CACHE_SECONDS"`
+	CacheControlAccount   string `mapstructure:"CACHE_CONTROL_ACCOUNT"`
+	CacheControlAccounts  string `mapstructure:"CACHE_CONTROL_ACCOUNTS"`
+	CacheControlStats     string `mapstructure:"CACHE_CONTROL_STATS"`
+	CacheControlHistory   string `mapstructure:"CACHE_CONTROL_HISTORY"`
+	AccountCacheSize      int    `mapstructure:"ACCOUNT_CACHE_SIZE"`
+	AccountCacheTTL       int    `mapstructure:"ACCOUNT_CACHE_TTL_SECONDS"`
 }
 
 // GetConfig return the Application configuration
@@ -23,6 +54,35 @@ func GetConfig() *Config {
 		appConfig = &Config{}
 		viper.SetDefault("APP_PORT", 8001)
 		viper.SetDefault("MONGO_CONNECTION_STRING", "localhost")
+		viper.SetDefault("EVENTS_FILE", "events.ndjson")
+		viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 8)
+		viper.SetDefault("OUTBOX_MAX_ATTEMPTS", 20)
+		viper.SetDefault("CHANGES_LOG_SIZE", 1000)
+		viper.SetDefault("CHANGES_HEARTBEAT_SECONDS", 15)
+		viper.SetDefault("ACCOUNT_VERSION_RETENTION", 50)
+		viper.SetDefault("MAILER", "log")
+		viper.SetDefault("MAIL_DIR", "mail")
+		viper.SetDefault("MAIL_FROM", "no-reply@localhost")
+		viper.SetDefault("SMTP_URL", "smtp://localhost:25")
+		viper.SetDefault("EMAIL_RESEND_SECONDS", 60)
+		viper.SetDefault("AUTH_ACCESS_TTL_SECONDS", 900)
+		viper.SetDefault("AUTH_REFRESH_TTL_SECONDS", 2592000)
+		viper.SetDefault("LOGIN_MAX_ATTEMPTS", 5)
+		viper.SetDefault("LOGIN_LOCKOUT_SECONDS", 900)
+		viper.SetDefault("PASSWORD_RESET_TTL_SECONDS", 3600)
+		viper.SetDefault("PASSWORD_RESET_INTERVAL_SECONDS", 300)
+		viper.SetDefault("NOTIFIER", "mail")
+		viper.SetDefault("NOTIFIER_FILE", "notifications.ndjson")
+		viper.SetDefault("AUTH_MODE", "session")
+		viper.SetDefault("INVITATION_TTL_SECONDS", 604800)
+		viper.SetDefault("INVITATION_SWEEP_SECONDS", 60)
+		viper.SetDefault("STATS_CACHE_SECONDS", 30)
+		viper.SetDefault("CACHE_CONTROL_ACCOUNT", "private, no-cache")
+		viper.SetDefault("CACHE_CONTROL_ACCOUNTS", "private, no-cache")
+		viper.SetDefault("CACHE_CONTROL_STATS", "private, max-age=30")
+		viper.SetDefault("CACHE_CONTROL_HISTORY", "private, no-cache")
+		viper.SetDefault("ACCOUNT_CACHE_SIZE", 10000)
+		viper.SetDefault("ACCOUNT_CACHE_TTL_SECONDS", 30)
 
 		if os.Getenv("ENVIRONMENT") == "DEV" {
 			viper.SetConfigName("config")
@@ -0,0 +1,42 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// Bus is an in-process account.Publisher dispatching every event to its subscribers
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []account.Publisher
+}
+
+// NewBus returns a new Bus without subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a Publisher that will receive every event published on the bus
+func (b *Bus) Subscribe(p account.Publisher) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers = append(b.subscribers, p)
+}
+
+// Publish hands the event to every subscriber, in subscription order. All the
+// subscribers are called even if one fails, the first error is returned.
+func (b *Bus) Publish(ctx context.Context, e account.Event) (err error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, s := range b.subscribers {
+		if serr := s.Publish(ctx, e); serr != nil && err == nil {
+			err = serr
+		}
+	}
+
+	return
+}
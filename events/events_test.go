@@ -0,0 +1,50 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+func Test_Bus_Should_Dispatch_To_All_Subscribers(t *testing.T) {
+	expected := errors.New("error")
+	var received []string
+	bus := NewBus()
+	bus.Subscribe(account.PublisherFunc(func(ctx context.Context, e account.Event) error {
+		received = append(received, "first")
+		return expected
+	}))
+	bus.Subscribe(account.PublisherFunc(func(ctx context.Context, e account.Event) error {
+		received = append(received, "second")
+		return nil
+	}))
+
+	err := bus.Publish(context.Background(), account.AccountCreated{})
+
+	assert.Equal(t, expected, err)
+	assert.Equal(t, []string{"first", "second"}, received)
+}
+
+func Test_NDJSONSink_Should_Write_One_Envelope_Per_Line(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewNDJSONSink(&buf)
+	at := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sink.Publish(context.Background(), account.AccountCreated{OccurredAt: at, After: account.Account{AccountID: "1"}})
+	sink.Publish(context.Background(), account.AccountDeleted{OccurredAt: at, Before: account.Account{AccountID: "1"}})
+
+	dec := json.NewDecoder(&buf)
+	var first, second account.EventEnvelope
+	assert.Nil(t, dec.Decode(&first))
+	assert.Nil(t, dec.Decode(&second))
+	assert.Equal(t, account.EventAccountCreated, first.Type)
+	assert.Equal(t, "1", first.After.AccountID)
+	assert.Equal(t, account.EventAccountDeleted, second.Type)
+	assert.Nil(t, second.After)
+}
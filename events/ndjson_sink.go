@@ -0,0 +1,53 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// NDJSONSink is an account.Publisher writing every event as a line of JSON
+type NDJSONSink struct {
+	mu      sync.Mutex
+	w       io.Writer
+	encoder *json.Encoder
+}
+
+// NewNDJSONSink returns a sink writing the events to w
+func NewNDJSONSink(w io.Writer) *NDJSONSink {
+	return &NDJSONSink{
+		w:       w,
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// OpenFileSink returns a sink appending the events to the file at path,
+// the file is created if it does not exist
+func OpenFileSink(path string) (*NDJSONSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewNDJSONSink(f), nil
+}
+
+// Publish writes the event envelope as one line
+func (s *NDJSONSink) Publish(ctx context.Context, e account.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.encoder.Encode(e.Envelope())
+}
+
+// Close closes the underlying writer if it is closable
+func (s *NDJSONSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
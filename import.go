@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/importer"
+	"github.com/tkanos/go-rest-api-sample/schema"
+	"gopkg.in/mgo.v2"
+)
+
+// runImport implements the import subcommand:
+//
+//	app import [-format ndjson|csv] [-dry-run] [-concurrency n] [-checkpoint file] accounts.ndjson
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "file format, ndjson or csv (default: guessed from the file extension)")
+	dryRun := flags.Bool("dry-run", false, "report what would change without writing anything")
+	concurrency := flags.Int("concurrency", 4, "number of rows written in parallel")
+	checkpoint := flags.String("checkpoint", "", "file used to resume an interrupted import")
+
+	if err := flags.Parse(args); err != nil {
+		return importError
+	}
+	if flags.NArg() != 1 {
+		errorLogger.Log("import_error", "expected exactly one file to import")
+		return importError
+	}
+
+	path := flags.Arg(0)
+	if len(*format) == 0 {
+		*format = importer.FormatNDJSON
+		if filepath.Ext(path) == ".csv" {
+			*format = importer.FormatCSV
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		errorLogger.Log("import_error", err)
+		return importError
+	}
+	defer f.Close()
+
+	session, err := mgo.Dial(appConfig.MongoConnectionString)
+	if err != nil {
+		errorLogger.Log("mongo_session_error", err)
+		return dbError
+	}
+	defer session.Close()
+
+	// an interrupted import keeps its checkpoint so it can be resumed
+	ctx, cancel := context.WithCancel(account.ContextWithActor(context.Background(), "import"))
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+		<-c
+		cancel()
+	}()
+
+	i := importer.New(getAccountService(session, schema.NewRegistry(getSchemaRepository(session))), importer.Options{
+		Format:      *format,
+		DryRun:      *dryRun,
+		Concurrency: *concurrency,
+		Checkpoint:  *checkpoint,
+	}, infoLogger)
+
+	report, err := i.Run(ctx, f)
+	infoLogger.Log("file", path, "dry_run", *dryRun, "created", report.Created, "updated", report.Updated, "skipped", report.Skipped, "resumed", report.Resumed, "failed", report.Failed, "errors", report.Errors)
+	if err != nil {
+		errorLogger.Log("import_error", err)
+		return importError
+	}
+
+	return 0
+}
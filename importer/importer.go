@@ -0,0 +1,385 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// Import formats
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// checkpointInterval is the number of rows processed between two checkpoint writes
+const checkpointInterval = 100
+
+// ErrUnknownFormat is used when the import format is neither NDJSON nor CSV
+var ErrUnknownFormat = errors.New("unknown import format")
+
+// rowErrors are the errors of the service rejecting a row. Any other error,
+// such as one of the repository, stops the run before the row, so that it is
+// imported again when the run is resumed.
+var rowErrors = []error{
+	account.ErrInvalidStatus,
+	account.ErrInvalidEmail,
+	account.ErrEmailTaken,
+	account.ErrParentNotFound,
+	account.ErrCycle,
+	account.ErrParentClosed,
+	account.ErrHierarchyTooLarge,
+	account.ErrInvalidLabel,
+	account.ErrInvalidAnnotation,
+	account.ErrInvalidCustomFields,
+	account.ErrMerged,
+	account.ErrOtherTenant,
+}
+
+// Options configures an import run
+type Options struct {
+	Format      string
+	DryRun      bool
+	Concurrency int
+	// Checkpoint is the path of the file recording the rows already imported,
+	// an empty path disables checkpointing
+	Checkpoint string
+}
+
+// Report summarises an import run. Skipped are the rows already matching
+// their account, Resumed the ones a previous run imported, per the checkpoint,
+// and Failed the ones that could not be decoded or were rejected by the service.
+type Report struct {
+	Created int        `json:"created"`
+	Updated int        `json:"updated"`
+	Skipped int        `json:"skipped"`
+	Resumed int        `json:"resumed"`
+	Failed  int        `json:"failed"`
+	Errors  []RowError `json:"errors,omitempty"`
+}
+
+// RowError describes why a row could not be imported
+type RowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// Importer writes accounts read from a file through an account.Service, so
+// they get the same validation as the ones coming from the API
+type Importer struct {
+	service account.Service
+	options Options
+	logger  log.Logger
+}
+
+// Row actions
+const (
+	actionCreate = "create"
+	actionUpdate = "update"
+	actionSkip   = "skip"
+)
+
+type row struct {
+	number  int
+	account account.Account
+	err     error
+}
+
+type result struct {
+	row    row
+	action string
+	err    error
+}
+
+// rejected reports whether the row of a result failed to be decoded or was
+// rejected by the service, rather than failed to be written
+func (res result) rejected() bool {
+	if res.row.err != nil {
+		return true
+	}
+	for _, err := range rowErrors {
+		if res.err == err {
+			return true
+		}
+	}
+
+	return false
+}
+
+// New returns a new Importer
+func New(s account.Service, o Options, logger log.Logger) *Importer {
+	if o.Concurrency < 1 {
+		o.Concurrency = 1
+	}
+
+	return &Importer{
+		service: s,
+		options: o,
+		logger:  logger,
+	}
+}
+
+// Run imports every row of r, resuming after the checkpoint if there is one
+func (i *Importer) Run(ctx context.Context, r io.Reader) (report Report, err error) {
+	done, err := i.readCheckpoint()
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rows := make(chan row)
+	results := make(chan result)
+
+	var readErr error
+	go func() {
+		defer close(rows)
+		readErr = readRows(ctx, r, i.options.Format, done, rows)
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < i.options.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rw := range rows {
+				results <- i.process(ctx, rw)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// the checkpoint only moves past rows whose predecessors are all done, so
+	// that a resumed run never skips a row that was still in flight
+	watermark := done
+	pending := map[int]bool{}
+	report.Resumed = done
+	var checkpointErr, runErr error
+	for res := range results {
+		if res.err != nil && ctx.Err() != nil {
+			// interrupted rows are left for the next run
+			continue
+		}
+		if res.err != nil && !res.rejected() {
+			// the checkpoint stays before the row, which the next run imports again
+			runErr = res.err
+			cancel()
+			continue
+		}
+
+		switch {
+		case res.err != nil:
+			report.Failed++
+			report.Errors = append(report.Errors, RowError{Row: res.row.number, Error: res.err.Error()})
+		case res.action == actionCreate:
+			report.Created++
+		case res.action == actionUpdate:
+			report.Updated++
+		default:
+			report.Skipped++
+		}
+
+		pending[res.row.number] = true
+		for pending[watermark+1] {
+			delete(pending, watermark+1)
+			watermark++
+		}
+
+		if checkpointErr == nil && watermark-done >= checkpointInterval {
+			if checkpointErr = i.writeCheckpoint(watermark); checkpointErr != nil {
+				cancel()
+			}
+			done = watermark
+		}
+	}
+
+	if checkpointErr != nil {
+		err = checkpointErr
+		return
+	}
+
+	if err = i.writeCheckpoint(watermark); err != nil {
+		return
+	}
+
+	if runErr != nil {
+		err = runErr
+		return
+	}
+
+	if readErr != nil {
+		err = readErr
+		return
+	}
+
+	err = ctx.Err()
+	return
+}
+
+// process writes one row, or only validates and logs what would be written in dry-run mode
+func (i *Importer) process(ctx context.Context, rw row) result {
+	if rw.err != nil {
+		return result{row: rw, err: rw.err}
+	}
+
+	action, err := i.plan(ctx, rw.account)
+	if err != nil {
+		return result{row: rw, err: err}
+	}
+
+	// a dry run validates the rows as the service does when writing them
+	if i.options.DryRun {
+		if action == actionSkip {
+			return result{row: rw, action: action}
+		}
+		if err = i.service.ValidateAccount(ctx, rw.account); err != nil {
+			return result{row: rw, err: err}
+		}
+		i.logger.Log("row", rw.number, "account_id", rw.account.AccountID, "dry_run", action)
+		return result{row: rw, action: action}
+	}
+
+	switch action {
+	case actionCreate:
+		_, err = i.service.CreateAccount(ctx, rw.account)
+		if err == account.ErrConflict {
+			// another row, or another run, created the account since it was
+			// planned: the row is written over it instead
+			if action, err = i.plan(ctx, rw.account); err == nil && action == actionUpdate {
+				err = i.service.UpdateAccount(ctx, rw.account)
+			}
+		}
+	case actionUpdate:
+		err = i.service.UpdateAccount(ctx, rw.account)
+	}
+
+	return result{row: rw, action: action, err: err}
+}
+
+// plan decides whether the account has to be created, updated or left untouched.
+// An account is created with the account_id of its row, so that importing
+// the same file again updates it instead of creating a duplicate.
+func (i *Importer) plan(ctx context.Context, a account.Account) (string, error) {
+	if len(a.AccountID) == 0 {
+		return actionCreate, nil
+	}
+
+	existing, err := i.service.GetAccount(ctx, a.AccountID)
+	if err == account.ErrNotFound {
+		return actionCreate, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// the verification, version, creation and update times and merge are set by the service, not by the file
+	a.EmailVerified, a.Version, a.UpdatedAt = existing.EmailVerified, existing.Version, existing.UpdatedAt
+	a.CreatedAt, a.MergedInto = existing.CreatedAt, existing.MergedInto
+	if reflect.DeepEqual(*existing, a) {
+		return actionSkip, nil
+	}
+
+	return actionUpdate, nil
+}
+
+func (i *Importer) readCheckpoint() (int, error) {
+	if len(i.options.Checkpoint) == 0 {
+		return 0, nil
+	}
+
+	b, err := ioutil.ReadFile(i.options.Checkpoint)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+func (i *Importer) writeCheckpoint(rows int) error {
+	if len(i.options.Checkpoint) == 0 || i.options.DryRun {
+		return nil
+	}
+
+	return ioutil.WriteFile(i.options.Checkpoint, []byte(strconv.Itoa(rows)+"\n"), 0644)
+}
+
+// readRows sends every row after the first skip ones to the rows channel,
+// rows that can not be decoded are sent with their error
+func readRows(ctx context.Context, r io.Reader, format string, skip int, rows chan<- row) error {
+	var next func() (a account.Account, rowErr error, err error)
+
+	switch format {
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		next = func() (a account.Account, rowErr error, err error) {
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if len(line) > 0 {
+					rowErr = json.Unmarshal([]byte(line), &a)
+					return
+				}
+			}
+			if err = scanner.Err(); err == nil {
+				err = io.EOF
+			}
+			return
+		}
+	case FormatCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		header, err := cr.Read()
+		if err != nil {
+			return err
+		}
+		next = func() (a account.Account, rowErr error, err error) {
+			record, err := cr.Read()
+			if err != nil {
+				return
+			}
+			rowErr = a.UnmarshalCSV(header, record)
+			return
+		}
+	default:
+		return ErrUnknownFormat
+	}
+
+	for n := 1; ; n++ {
+		a, rowErr, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if n <= skip {
+			continue
+		}
+
+		select {
+		case rows <- row{number: n, account: a, err: rowErr}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
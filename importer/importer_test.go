@@ -0,0 +1,224 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// fakeService keeps accounts in memory, only the methods used by the importer are implemented.
+// The first stale reads find no account, as reads made before a concurrent create.
+type fakeService struct {
+	account.Service
+	mu       sync.Mutex
+	accounts map[string]account.Account
+	created  int
+	stale    int
+	// errs are returned by the writes of the accounts with their id
+	errs map[string]error
+}
+
+func newFakeService(accounts ...account.Account) *fakeService {
+	s := &fakeService{accounts: map[string]account.Account{}}
+	for _, a := range accounts {
+		s.accounts[a.AccountID] = a
+	}
+	return s
+}
+
+func (s *fakeService) GetAccount(ctx context.Context, id string) (*account.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.accounts[id]
+	if s.stale > 0 {
+		s.stale--
+		ok = false
+	}
+	if !ok {
+		return nil, account.ErrNotFound
+	}
+	return &a, nil
+}
+
+func (s *fakeService) CreateAccount(ctx context.Context, a account.Account) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.errs[a.AccountID]; err != nil {
+		return "", err
+	}
+	if _, ok := s.accounts[a.AccountID]; ok {
+		return "", account.ErrConflict
+	}
+	s.created++
+	s.accounts[a.AccountID] = a
+	return a.AccountID, nil
+}
+
+func (s *fakeService) ValidateAccount(ctx context.Context, a account.Account) error {
+	if a.Status != "" && a.Status != account.StatusActive && a.Status != account.StatusClosed {
+		return account.ErrInvalidStatus
+	}
+	return nil
+}
+
+func (s *fakeService) UpdateAccount(ctx context.Context, a account.Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accounts[a.AccountID] = a
+	return nil
+}
+
+func Test_Run_Should_Report_Created_Skipped_And_Failed_Rows(t *testing.T) {
+	svc := newFakeService(account.Account{AccountID: "1"})
+	input := "{\"account_id\":\"1\"}\n{\"account_id\":\"2\"}\n\n{invalid}\n{}\n"
+
+	i := New(svc, Options{Format: FormatNDJSON, Concurrency: 2}, log.NewNopLogger())
+	report, err := i.Run(context.Background(), strings.NewReader(input))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 3, report.Errors[0].Row)
+	assert.Equal(t, 2, svc.created)
+}
+
+func Test_Run_Should_Create_An_Account_Once_When_Rows_Share_Its_ID(t *testing.T) {
+	svc := newFakeService()
+	svc.stale = 2
+	input := "{\"account_id\":\"1\",\"name\":\"John\"}\n{\"account_id\":\"1\",\"name\":\"John\"}\n"
+
+	i := New(svc, Options{Format: FormatNDJSON, Concurrency: 2}, log.NewNopLogger())
+	report, err := i.Run(context.Background(), strings.NewReader(input))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 0, report.Failed)
+	assert.Equal(t, 1, svc.created)
+}
+
+func Test_Run_Should_Update_The_Accounts_Of_A_Previous_Run(t *testing.T) {
+	svc := newFakeService()
+	input := "account_id,name\n1,John\n"
+
+	i := New(svc, Options{Format: FormatCSV}, log.NewNopLogger())
+	_, err := i.Run(context.Background(), strings.NewReader(input))
+	assert.Nil(t, err)
+
+	report, err := i.Run(context.Background(), strings.NewReader(input))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 1, svc.created)
+}
+
+func Test_Run_Should_Not_Write_In_DryRun(t *testing.T) {
+	svc := newFakeService()
+	input := "account_id\n1\n2\n"
+
+	i := New(svc, Options{Format: FormatCSV, DryRun: true}, log.NewNopLogger())
+	report, err := i.Run(context.Background(), strings.NewReader(input))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 0, svc.created)
+}
+
+func Test_Run_Should_Report_The_Invalid_Rows_In_DryRun(t *testing.T) {
+	svc := newFakeService()
+	input := "account_id,status\n1,active\n2,unknown\n"
+
+	i := New(svc, Options{Format: FormatCSV, DryRun: true}, log.NewNopLogger())
+	report, err := i.Run(context.Background(), strings.NewReader(input))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 2, report.Errors[0].Row)
+}
+
+func Test_Run_Should_Go_On_After_A_Rejected_Row(t *testing.T) {
+	svc := newFakeService()
+	svc.errs = map[string]error{"2": account.ErrInvalidEmail}
+	input := "account_id\n1\n2\n3\n"
+
+	i := New(svc, Options{Format: FormatCSV}, log.NewNopLogger())
+	report, err := i.Run(context.Background(), strings.NewReader(input))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 1, report.Failed)
+}
+
+func Test_Run_Should_Stop_Before_A_Row_Failing_To_Be_Written(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "importer")
+	defer os.RemoveAll(dir)
+	checkpoint := filepath.Join(dir, "checkpoint")
+
+	svc := newFakeService()
+	unavailable := errors.New("no reachable servers")
+	svc.errs = map[string]error{"2": unavailable}
+	input := "account_id\n1\n2\n3\n"
+
+	i := New(svc, Options{Format: FormatCSV, Checkpoint: checkpoint}, log.NewNopLogger())
+	report, err := i.Run(context.Background(), strings.NewReader(input))
+
+	assert.Equal(t, unavailable, err)
+	assert.Equal(t, 0, report.Failed)
+	assert.NotContains(t, svc.accounts, "2")
+
+	b, _ := ioutil.ReadFile(checkpoint)
+	assert.Equal(t, "1\n", string(b))
+
+	// the resumed run imports the row again
+	delete(svc.errs, "2")
+	report, err = i.Run(context.Background(), strings.NewReader(input))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Resumed)
+	assert.Contains(t, svc.accounts, "2")
+	assert.Contains(t, svc.accounts, "3")
+}
+
+func Test_Run_Should_Resume_From_Checkpoint(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "importer")
+	defer os.RemoveAll(dir)
+	checkpoint := filepath.Join(dir, "checkpoint")
+	ioutil.WriteFile(checkpoint, []byte("2\n"), 0644)
+
+	svc := newFakeService()
+	input := "account_id\n1\n2\n3\n"
+
+	i := New(svc, Options{Format: FormatCSV, Checkpoint: checkpoint}, log.NewNopLogger())
+	report, err := i.Run(context.Background(), strings.NewReader(input))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 0, report.Skipped)
+	assert.Equal(t, 2, report.Resumed)
+	assert.Contains(t, svc.accounts, "3")
+
+	b, _ := ioutil.ReadFile(checkpoint)
+	assert.Equal(t, "3\n", string(b))
+}
+
+func Test_Run_Should_Return_ErrUnknownFormat(t *testing.T) {
+	i := New(newFakeService(), Options{Format: "xml"}, log.NewNopLogger())
+	_, err := i.Run(context.Background(), strings.NewReader(""))
+
+	assert.Equal(t, ErrUnknownFormat, err)
+}
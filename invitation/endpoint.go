@@ -0,0 +1,73 @@
+package invitation
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Endpoints represent the invitation service endpoints
+type Endpoints struct {
+	GetList endpoint.Endpoint
+	Create  endpoint.Endpoint
+	Revoke  endpoint.Endpoint
+	Accept  endpoint.Endpoint
+}
+
+// MakeGetInvitationsEndpoint returns an endpoint used for listing the pending invitations of an account
+func MakeGetInvitationsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetInvitationsRequest)
+
+		return s.GetInvitations(ctx, req.AccountID)
+	}
+}
+
+// MakeCreateInvitationEndpoint returns an endpoint used for creating an invitation
+func MakeCreateInvitationEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(CreateInvitationRequest)
+
+		return s.CreateInvitation(ctx, req.AccountID, req.Invitation)
+	}
+}
+
+// MakeRevokeInvitationEndpoint returns an endpoint used for revoking an invitation
+func MakeRevokeInvitationEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RevokeInvitationRequest)
+
+		return nil, s.RevokeInvitation(ctx, req.AccountID, req.ID)
+	}
+}
+
+// MakeAcceptInvitationEndpoint returns an endpoint used for accepting an invitation
+func MakeAcceptInvitationEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(AcceptInvitationRequest)
+
+		return s.AcceptInvitation(ctx, req.Token)
+	}
+}
+
+// GetInvitationsRequest represents the request parameters used for listing the invitations of an account
+type GetInvitationsRequest struct {
+	AccountID string
+}
+
+// CreateInvitationRequest represents the request parameters used for creating an invitation
+type CreateInvitationRequest struct {
+	AccountID string
+	Invitation
+}
+
+// RevokeInvitationRequest represents the request parameters used for revoking an invitation
+type RevokeInvitationRequest struct {
+	AccountID string
+	ID        string
+}
+
+// AcceptInvitationRequest represents the request parameters used for accepting an invitation
+type AcceptInvitationRequest struct {
+	Token string
+}
@@ -0,0 +1,145 @@
+package invitation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+	"github.com/tkanos/go-rest-api-sample/membership"
+)
+
+// ErrInvalidBody thrown when the body of a request can not be parsed
+var ErrInvalidBody = errors.New("invalid body")
+
+// MakeHTTPHandler returns all http handler for the invitation service.
+// Accepting an invitation requires the request to carry its principal, see auth.NewMiddleware.
+func MakeHTTPHandler(logger log.Logger, endpoints Endpoints) http.Handler {
+	options := []kithttp.ServerOption{
+		kithttp.ServerErrorLogger(logger),
+		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(account.PopulateAuditContext),
+	}
+
+	getInvitationsHandler := kithttp.NewServer(
+		endpoints.GetList,
+		decodeGetInvitationsRequest,
+		encodeResponse,
+		options...,
+	)
+
+	createInvitationHandler := kithttp.NewServer(
+		endpoints.Create,
+		decodeCreateInvitationRequest,
+		encodeCreateInvitationResponse,
+		options...,
+	)
+
+	revokeInvitationHandler := kithttp.NewServer(
+		endpoints.Revoke,
+		decodeRevokeInvitationRequest,
+		encodeNoContentResponse,
+		options...,
+	)
+
+	acceptInvitationHandler := kithttp.NewServer(
+		endpoints.Accept,
+		decodeAcceptInvitationRequest,
+		encodeResponse,
+		options...,
+	)
+
+	r := mux.NewRouter()
+
+	r.Handle("/accounts/{account_id}/invitations", getInvitationsHandler).Methods("GET")
+	r.Handle("/accounts/{account_id}/invitations", createInvitationHandler).Methods("POST")
+	r.Handle("/accounts/{account_id}/invitations/{id}", revokeInvitationHandler).Methods("DELETE")
+	r.Handle("/invitations/{token}/accept", acceptInvitationHandler).Methods("POST")
+
+	return r
+}
+
+func decodeGetInvitationsRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	return GetInvitationsRequest{AccountID: mux.Vars(r)["account_id"]}, nil
+}
+
+func decodeCreateInvitationRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req CreateInvitationRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req.Invitation); err != nil {
+		return nil, ErrInvalidBody
+	}
+
+	req.AccountID = mux.Vars(r)["account_id"]
+
+	return req, nil
+}
+
+func decodeRevokeInvitationRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	vars := mux.Vars(r)
+
+	return RevokeInvitationRequest{AccountID: vars["account_id"], ID: vars["id"]}, nil
+}
+
+func decodeAcceptInvitationRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	return AcceptInvitationRequest{Token: mux.Vars(r)["token"]}, nil
+}
+
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func encodeCreateInvitationResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	inv, ok := response.(*Invitation)
+	if !ok {
+		return errors.New("An error occured while creating invitation")
+	}
+	w.Header().Set("Location", fmt.Sprintf("/accounts/%v/invitations/%v", inv.AccountID, inv.ID))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(inv)
+}
+
+func encodeNoContentResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// encode errors from business-logic
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	switch err {
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	case ErrInvalidBody,
+		membership.ErrInvalidRole,
+		membership.ErrInvalidEmail:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrUnauthenticated,
+		auth.ErrUnauthenticated:
+		w.WriteHeader(http.StatusUnauthorized)
+	case ErrEmailMismatch,
+		ErrEmailNotVerified,
+		auth.ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
+	case ErrNotFound,
+		ErrInvalidToken,
+		account.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case ErrNotPending,
+		membership.ErrAlreadyMember:
+		w.WriteHeader(http.StatusConflict)
+	case ErrExpired:
+		w.WriteHeader(http.StatusGone)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,62 @@
+package invitation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+	"github.com/tkanos/go-rest-api-sample/membership"
+)
+
+func Test_DecodeAcceptInvitationRequest(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/invitations/token/accept", nil)
+	r = mux.SetURLVars(r, map[string]string{"token": "token"})
+
+	req, err := decodeAcceptInvitationRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, AcceptInvitationRequest{Token: "token"}, req)
+}
+
+func Test_EncodeCreateInvitationResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := encodeCreateInvitationResponse(context.Background(), w, &Invitation{ID: "2", AccountID: "1"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/accounts/1/invitations/2", w.Header().Get("Location"))
+}
+
+func Test_EncodeError_Should_Correctly_Map_Error(t *testing.T) {
+	var flagtests = []struct {
+		in  error
+		out int
+	}{
+		{errors.New("not handled error"), http.StatusInternalServerError},
+		{ErrInvalidBody, http.StatusBadRequest},
+		{membership.ErrInvalidRole, http.StatusBadRequest},
+		{ErrUnauthenticated, http.StatusUnauthorized},
+		{auth.ErrUnauthenticated, http.StatusUnauthorized},
+		{ErrEmailMismatch, http.StatusForbidden},
+		{ErrEmailNotVerified, http.StatusForbidden},
+		{auth.ErrForbidden, http.StatusForbidden},
+		{ErrInvalidToken, http.StatusNotFound},
+		{account.ErrNotFound, http.StatusNotFound},
+		{ErrNotPending, http.StatusConflict},
+		{membership.ErrAlreadyMember, http.StatusConflict},
+		{ErrExpired, http.StatusGone},
+	}
+
+	for _, tt := range flagtests {
+		w := httptest.NewRecorder()
+		encodeError(context.Background(), tt.in, w)
+
+		assert.Equal(t, tt.out, w.Code)
+	}
+}
@@ -0,0 +1,38 @@
+package invitation
+
+import (
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Status of an invitation
+const (
+	StatusPending  = "pending"
+	StatusAccepted = "accepted"
+	StatusRevoked  = "revoked"
+	StatusExpired  = "expired"
+)
+
+// tokenAudience is the audience of the invitation tokens, so that no other
+// token signed with the same key is accepted as one
+const tokenAudience = "invitation"
+
+// Invitation invites an email to become a member of an account with a role.
+// Its token is signed, carries the invitation id and expiry, and is only
+// emailed to the invited address.
+type Invitation struct {
+	ID        string     `json:"id" bson:"_id"`
+	AccountID string     `json:"account_id" bson:"account_id"`
+	Email     string     `json:"email" bson:"email"`
+	Role      string     `json:"role" bson:"role"`
+	Status    string     `json:"status" bson:"status"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" bson:"expires_at"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty" bson:"closed_at,omitempty"`
+}
+
+// claims are the claims of an invitation token, its id is the invitation id
+type claims struct {
+	jwt.StandardClaims
+}
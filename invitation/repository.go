@@ -0,0 +1,19 @@
+package invitation
+
+import (
+	"context"
+	"time"
+)
+
+// Repository is the storage of the invitations
+type Repository interface {
+	CreateInvitation(ctx context.Context, inv Invitation) error
+	// GetInvitation returns an invitation, nil if there is none
+	GetInvitation(ctx context.Context, id string) (*Invitation, error)
+	GetInvitations(ctx context.Context, accountID string, status string) ([]*Invitation, error)
+	// Transition changes the status of an invitation, only if it still is from;
+	// it returns false when it is not
+	Transition(ctx context.Context, id string, from, to string, at time.Time) (bool, error)
+	// ExpireInvitations marks the pending invitations expired at now as expired, and returns their number
+	ExpireInvitations(ctx context.Context, now time.Time) (int, error)
+}
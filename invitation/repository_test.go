@@ -0,0 +1,128 @@
+package invitation
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/verification"
+)
+
+// fakeRepository keeps invitations in memory
+type fakeRepository struct {
+	mu          sync.Mutex
+	invitations []Invitation
+}
+
+func (r *fakeRepository) CreateInvitation(ctx context.Context, inv Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.invitations = append(r.invitations, inv)
+	return nil
+}
+
+func (r *fakeRepository) GetInvitation(ctx context.Context, id string) (*Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, inv := range r.invitations {
+		if inv.ID == id {
+			return &inv, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRepository) GetInvitations(ctx context.Context, accountID string, status string) ([]*Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitations := []*Invitation{}
+	for _, inv := range r.invitations {
+		if inv.AccountID == accountID && inv.Status == status {
+			inv := inv
+			invitations = append(invitations, &inv)
+		}
+	}
+	return invitations, nil
+}
+
+func (r *fakeRepository) Transition(ctx context.Context, id string, from, to string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.invitations {
+		if r.invitations[i].ID == id && r.invitations[i].Status == from {
+			r.invitations[i].Status = to
+			r.invitations[i].ClosedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeRepository) ExpireInvitations(ctx context.Context, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for i := range r.invitations {
+		if r.invitations[i].Status == StatusPending && !now.Before(r.invitations[i].ExpiresAt) {
+			r.invitations[i].Status = StatusExpired
+			r.invitations[i].ClosedAt = &now
+			n++
+		}
+	}
+	return n, nil
+}
+
+// fakeAccounts only implements the account.Service methods used by the invitation and membership services
+type fakeAccounts struct {
+	account.Service
+}
+
+// the account 1 is the one invitations are made to, 7 and 8 are the accounts accepting them,
+// and 9 an account given the email of 7 without verifying it
+func (fakeAccounts) GetAccount(ctx context.Context, id string) (*account.Account, error) {
+	emails := map[string]string{"1": "owner@example.com", "7": "a@example.com", "8": "b@example.com", "9": "a@example.com"}
+	if _, ok := emails[id]; !ok {
+		return nil, account.ErrNotFound
+	}
+	return &account.Account{AccountID: id, Email: emails[id], EmailVerified: id != "9"}, nil
+}
+
+// fakeMailer keeps the messages sent, or fails to send them
+type fakeMailer struct {
+	mu       sync.Mutex
+	messages []verification.Message
+	err      error
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg verification.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+var sentToken = regexp.MustCompile(`/invitations/([^/]+)/accept`)
+
+// token returns the invitation token of the last message sent to the email
+func (m *fakeMailer) token(email string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == email {
+			return sentToken.FindStringSubmatch(m.messages[i].Body)[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,288 @@
+package invitation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-kit/kit/log"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+	"github.com/tkanos/go-rest-api-sample/membership"
+	"github.com/tkanos/go-rest-api-sample/verification"
+)
+
+// ErrNotFound is used when an Invitation is not found
+var ErrNotFound = errors.New("Invitation not found")
+
+// ErrInvalidToken is used when an invitation token is malformed or not signed by us
+var ErrInvalidToken = errors.New("invalid invitation token")
+
+// ErrExpired is used when accepting an expired Invitation
+var ErrExpired = errors.New("invitation expired")
+
+// ErrNotPending is used when accepting or revoking an Invitation already accepted or revoked
+var ErrNotPending = errors.New("invitation no longer pending")
+
+// ErrUnauthenticated is used when accepting an Invitation without being authenticated
+var ErrUnauthenticated = errors.New("authentication required")
+
+// ErrEmailMismatch is used when accepting an Invitation sent to another email than the caller's
+var ErrEmailMismatch = errors.New("invitation sent to another email")
+
+// ErrEmailNotVerified is used when accepting an Invitation with an email the caller has not verified
+var ErrEmailNotVerified = errors.New("email not verified, verify it to accept the invitation")
+
+// Options configures the invitation service
+type Options struct {
+	SigningKey []byte
+	// TTL is the lifetime of an invitation
+	TTL time.Duration
+}
+
+// Service is the invitation service interface
+type Service interface {
+	CreateInvitation(ctx context.Context, accountID string, inv Invitation) (*Invitation, error)
+	GetInvitations(ctx context.Context, accountID string) ([]*Invitation, error)
+	RevokeInvitation(ctx context.Context, accountID, id string) error
+	AcceptInvitation(ctx context.Context, token string) (*membership.Member, error)
+	ExpireInvitations(ctx context.Context) (int, error)
+}
+
+type service struct {
+	accounts   account.Service
+	members    membership.Service
+	repository Repository
+	mailer     verification.Mailer
+	options    Options
+	logger     log.Logger
+}
+
+// NewService return a new instance of the invitation service
+func NewService(accounts account.Service, members membership.Service, r Repository, m verification.Mailer, o Options, logger log.Logger) Service {
+	return service{
+		accounts:   accounts,
+		members:    members,
+		repository: r,
+		mailer:     m,
+		options:    o,
+		logger:     logger,
+	}
+}
+
+// CreateInvitation invites an email to join an account, as a member by default. The owners of
+// the account invite, see membership.Service.Authorize. The token of the invitation is only
+// emailed to the invited address; the invitation is revoked when it can not be sent.
+func (s service) CreateInvitation(ctx context.Context, accountID string, inv Invitation) (*Invitation, error) {
+	if err := s.members.Authorize(ctx, accountID, membership.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	if len(inv.Role) == 0 {
+		inv.Role = membership.RoleMember
+	}
+	if err := membership.ValidateRole(inv.Role); err != nil {
+		return nil, err
+	}
+	if err := membership.ValidateEmail(inv.Email); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.accounts.GetAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	inv.ID = id
+	inv.AccountID = accountID
+	inv.Status = StatusPending
+	inv.CreatedAt = now
+	inv.ExpiresAt = now.Add(s.options.TTL)
+	inv.ClosedAt = nil
+
+	token, err := s.sign(inv)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.CreateInvitation(ctx, inv); err != nil {
+		return nil, err
+	}
+
+	if err := s.send(ctx, inv, token); err != nil {
+		if _, rerr := s.repository.Transition(ctx, inv.ID, StatusPending, StatusRevoked, time.Now().UTC()); rerr != nil {
+			s.logger.Log("invitation_id", inv.ID, "invitation_revoke_error", rerr)
+		}
+		return nil, err
+	}
+
+	return &inv, nil
+}
+
+// send emails the token of an Invitation to the invited address
+func (s service) send(ctx context.Context, inv Invitation, token string) error {
+	return s.mailer.Send(ctx, verification.Message{
+		To:      inv.Email,
+		Subject: "You are invited to join an account",
+		Body: fmt.Sprintf("You are invited to join the account %s as %s. To accept, sign in with this email and send POST /invitations/%s/accept before %s.\n",
+			inv.AccountID, inv.Role, token, inv.ExpiresAt.Format(time.RFC1123)),
+	})
+}
+
+// GetInvitations returns the pending Invitations of an account to its owners
+func (s service) GetInvitations(ctx context.Context, accountID string) ([]*Invitation, error) {
+	if err := s.members.Authorize(ctx, accountID, membership.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	return s.repository.GetInvitations(ctx, accountID, StatusPending)
+}
+
+// RevokeInvitation revokes a pending Invitation, its token stops working at once. The owners
+// of the account revoke.
+func (s service) RevokeInvitation(ctx context.Context, accountID, id string) error {
+	if err := s.members.Authorize(ctx, accountID, membership.RoleOwner); err != nil {
+		return err
+	}
+
+	inv, err := s.repository.GetInvitation(ctx, id)
+	if err != nil {
+		return err
+	}
+	if inv == nil || inv.AccountID != accountID {
+		return ErrNotFound
+	}
+
+	ok, err := s.repository.Transition(ctx, id, StatusPending, StatusRevoked, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotPending
+	}
+
+	return nil
+}
+
+// AcceptInvitation makes the authenticated caller a member of the account it
+// was invited to, the verified email of the caller has to be the invited one. An
+// Invitation can only be accepted once.
+func (s service) AcceptInvitation(ctx context.Context, token string) (*membership.Member, error) {
+	p := auth.PrincipalFromContext(ctx)
+	if p == nil {
+		return nil, ErrUnauthenticated
+	}
+
+	inv, err := s.invitation(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case inv.Status == StatusExpired, inv.Status == StatusPending && !now.Before(inv.ExpiresAt):
+		return nil, ErrExpired
+	case inv.Status != StatusPending:
+		return nil, ErrNotPending
+	}
+
+	caller, err := s.accounts.GetAccount(ctx, p.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(caller.Email, inv.Email) {
+		return nil, ErrEmailMismatch
+	}
+	// any account can be given the invited email, only its verification proves it is the caller's
+	if !caller.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	// accepted first so that two concurrent calls can not both succeed
+	ok, err := s.repository.Transition(ctx, inv.ID, StatusPending, StatusAccepted, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotPending
+	}
+
+	m, err := s.members.AddMember(ctx, inv.AccountID, membership.Member{
+		UserID: p.AccountID,
+		Email:  inv.Email,
+		Role:   inv.Role,
+	})
+	if err != nil {
+		// back to pending, the invitation was not used
+		if _, rerr := s.repository.Transition(ctx, inv.ID, StatusAccepted, StatusPending, now); rerr != nil {
+			s.logger.Log("invitation_id", inv.ID, "invitation_rollback_error", rerr)
+		}
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// ExpireInvitations marks the pending Invitations past their expiry as expired
+func (s service) ExpireInvitations(ctx context.Context) (int, error) {
+	return s.repository.ExpireInvitations(ctx, time.Now().UTC())
+}
+
+func (s service) sign(inv Invitation) (string, error) {
+	c := claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        inv.ID,
+			Subject:   inv.AccountID,
+			Audience:  tokenAudience,
+			IssuedAt:  inv.CreatedAt.Unix(),
+			ExpiresAt: inv.ExpiresAt.Unix(),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(s.options.SigningKey)
+}
+
+// invitation returns the Invitation of a token
+func (s service) invitation(ctx context.Context, token string) (*Invitation, error) {
+	c := new(claims)
+	_, err := jwt.ParseWithClaims(token, c, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, ErrInvalidToken
+		}
+		return s.options.SigningKey, nil
+	})
+	if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors == jwt.ValidationErrorExpired {
+		return nil, ErrExpired
+	}
+	if err != nil || c.Audience != tokenAudience {
+		return nil, ErrInvalidToken
+	}
+
+	inv, err := s.repository.GetInvitation(ctx, c.Id)
+	if err != nil {
+		return nil, err
+	}
+	if inv == nil || inv.AccountID != c.Subject {
+		return nil, ErrInvalidToken
+	}
+
+	return inv, nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,175 @@
+package invitation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+	"github.com/tkanos/go-rest-api-sample/membership"
+)
+
+var testOptions = Options{
+	SigningKey: []byte("secret"),
+	TTL:        time.Hour,
+}
+
+func newTestService(o Options) (Service, membership.Service, *fakeRepository, *fakeMailer) {
+	repo := new(fakeRepository)
+	mailer := new(fakeMailer)
+	members := membership.NewService(fakeAccounts{}, membership.NewMemoryRepository())
+
+	return NewService(fakeAccounts{}, members, repo, mailer, o, log.NewNopLogger()), members, repo, mailer
+}
+
+// caller returns a context authenticated as the account id
+func caller(id string) context.Context {
+	return auth.ContextWithPrincipal(context.Background(), &auth.Principal{AccountID: id, SessionID: "s"})
+}
+
+// owner is the context of the account invitations are made to
+var owner = caller("1")
+
+func Test_CreateInvitation_Should_Email_The_Token_Of_A_Pending_Invitation(t *testing.T) {
+	svc, _, repo, mailer := newTestService(testOptions)
+
+	inv, err := svc.CreateInvitation(owner, "1", Invitation{Email: "a@example.com"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, StatusPending, inv.Status)
+	assert.Equal(t, membership.RoleMember, inv.Role)
+	assert.Equal(t, inv.CreatedAt.Add(time.Hour), inv.ExpiresAt)
+	assert.Len(t, repo.invitations, 1)
+	assert.NotEmpty(t, mailer.token("a@example.com"))
+}
+
+func Test_CreateInvitation_Should_Revoke_The_Invitation_It_Can_Not_Send(t *testing.T) {
+	svc, _, repo, mailer := newTestService(testOptions)
+	mailer.err = errors.New("smtp down")
+
+	_, err := svc.CreateInvitation(owner, "1", Invitation{Email: "a@example.com"})
+
+	assert.Equal(t, mailer.err, err)
+	assert.Equal(t, StatusRevoked, repo.invitations[0].Status)
+}
+
+func Test_CreateInvitation_Should_Validate_The_Invitation(t *testing.T) {
+	var flagtests = []struct {
+		ctx        context.Context
+		accountID  string
+		invitation Invitation
+		err        error
+	}{
+		{owner, "1", Invitation{Email: "a@example.com", Role: "guest"}, membership.ErrInvalidRole},
+		{owner, "1", Invitation{Email: "not an email"}, membership.ErrInvalidEmail},
+		{caller("2"), "2", Invitation{Email: "a@example.com"}, account.ErrNotFound},
+		{context.Background(), "1", Invitation{Email: "a@example.com"}, auth.ErrUnauthenticated},
+		{caller("7"), "1", Invitation{Email: "a@example.com"}, auth.ErrForbidden},
+	}
+
+	for _, tt := range flagtests {
+		svc, _, _, _ := newTestService(testOptions)
+		_, err := svc.CreateInvitation(tt.ctx, tt.accountID, tt.invitation)
+
+		assert.Equal(t, tt.err, err)
+	}
+}
+
+func Test_AcceptInvitation_Should_Attach_The_Caller_Once(t *testing.T) {
+	svc, members, _, mailer := newTestService(testOptions)
+	svc.CreateInvitation(owner, "1", Invitation{Email: "a@example.com", Role: membership.RoleAdmin})
+	token := mailer.token("a@example.com")
+
+	_, err := svc.AcceptInvitation(context.Background(), token)
+	assert.Equal(t, ErrUnauthenticated, err)
+
+	m, err := svc.AcceptInvitation(caller("7"), token)
+	assert.Nil(t, err)
+	assert.Equal(t, "7", m.UserID)
+	assert.Equal(t, membership.RoleAdmin, m.Role)
+	assert.Equal(t, membership.StatusActive, m.Status)
+
+	_, err = svc.AcceptInvitation(caller("8"), token)
+	assert.Equal(t, ErrNotPending, err)
+
+	list, _ := members.GetMembers(owner, "1")
+	assert.Len(t, list, 1)
+
+	pending, _ := svc.GetInvitations(owner, "1")
+	assert.Empty(t, pending)
+}
+
+func Test_AcceptInvitation_Should_Require_The_Invited_Email(t *testing.T) {
+	svc, _, repo, mailer := newTestService(testOptions)
+	svc.CreateInvitation(owner, "1", Invitation{Email: "a@example.com"})
+
+	_, err := svc.AcceptInvitation(caller("8"), mailer.token("a@example.com"))
+
+	assert.Equal(t, ErrEmailMismatch, err)
+	assert.Equal(t, StatusPending, repo.invitations[0].Status)
+}
+
+func Test_AcceptInvitation_Should_Require_A_Verified_Email(t *testing.T) {
+	svc, members, repo, mailer := newTestService(testOptions)
+	svc.CreateInvitation(owner, "1", Invitation{Email: "a@example.com"})
+
+	_, err := svc.AcceptInvitation(caller("9"), mailer.token("a@example.com"))
+
+	assert.Equal(t, ErrEmailNotVerified, err)
+	assert.Equal(t, StatusPending, repo.invitations[0].Status)
+	list, _ := members.GetMembers(owner, "1")
+	assert.Empty(t, list)
+}
+
+func Test_AcceptInvitation_Should_Stay_Pending_When_Already_A_Member(t *testing.T) {
+	svc, members, repo, mailer := newTestService(testOptions)
+	members.AddMember(context.Background(), "1", membership.Member{Email: "a@example.com"})
+	svc.CreateInvitation(owner, "1", Invitation{Email: "a@example.com"})
+
+	_, err := svc.AcceptInvitation(caller("7"), mailer.token("a@example.com"))
+
+	assert.Equal(t, membership.ErrAlreadyMember, err)
+	assert.Equal(t, StatusPending, repo.invitations[0].Status)
+}
+
+func Test_AcceptInvitation_Should_Reject_Invalid_Tokens(t *testing.T) {
+	svc, _, _, _ := newTestService(testOptions)
+	other, _, _, mailer := newTestService(Options{SigningKey: []byte("other"), TTL: time.Hour})
+	other.CreateInvitation(owner, "1", Invitation{Email: "a@example.com"})
+
+	_, err := svc.AcceptInvitation(caller("7"), mailer.token("a@example.com"))
+	assert.Equal(t, ErrInvalidToken, err)
+
+	_, err = svc.AcceptInvitation(caller("7"), "not-a-token")
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func Test_RevokeInvitation_Should_Invalidate_The_Token(t *testing.T) {
+	svc, _, _, mailer := newTestService(testOptions)
+	inv, _ := svc.CreateInvitation(owner, "1", Invitation{Email: "a@example.com"})
+
+	assert.Equal(t, auth.ErrForbidden, svc.RevokeInvitation(caller("7"), "1", inv.ID))
+	assert.Equal(t, ErrNotFound, svc.RevokeInvitation(caller("2"), "2", inv.ID))
+	assert.Nil(t, svc.RevokeInvitation(owner, "1", inv.ID))
+	assert.Equal(t, ErrNotPending, svc.RevokeInvitation(owner, "1", inv.ID))
+
+	_, err := svc.AcceptInvitation(caller("7"), mailer.token("a@example.com"))
+	assert.Equal(t, ErrNotPending, err)
+}
+
+func Test_Expired_Invitations_Can_Not_Be_Accepted(t *testing.T) {
+	svc, _, repo, mailer := newTestService(Options{SigningKey: []byte("secret"), TTL: -time.Minute})
+	svc.CreateInvitation(owner, "1", Invitation{Email: "a@example.com"})
+
+	_, err := svc.AcceptInvitation(caller("7"), mailer.token("a@example.com"))
+	assert.Equal(t, ErrExpired, err)
+
+	n, err := svc.ExpireInvitations(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, StatusExpired, repo.invitations[0].Status)
+}
@@ -0,0 +1,61 @@
+package invitation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// ErrInvalidInterval is used when a Sweeper is not given a positive interval
+var ErrInvalidInterval = errors.New("the sweep interval must be positive")
+
+// Sweeper periodically expires the pending invitations past their expiry, so
+// that they are no longer listed
+type Sweeper struct {
+	service  Service
+	interval time.Duration
+	logger   log.Logger
+}
+
+// NewSweeper returns a new Sweeper running every interval
+func NewSweeper(s Service, interval time.Duration, logger log.Logger) (*Sweeper, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+
+	return &Sweeper{
+		service:  s,
+		interval: interval,
+		logger:   logger,
+	}, nil
+}
+
+// Run sweeps the invitations until the context is done
+func (s *Sweeper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.sweep(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Sweeper) sweep(ctx context.Context) {
+	n, err := s.service.ExpireInvitations(ctx)
+	if err != nil {
+		s.logger.Log("invitation_sweeper_error", err)
+		return
+	}
+
+	if n > 0 {
+		s.logger.Log("invitations_expired", n)
+	}
+}
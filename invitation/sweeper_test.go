@@ -0,0 +1,34 @@
+package invitation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sweeper_Should_Expire_Stale_Invitations(t *testing.T) {
+	svc, _, repo, _ := newTestService(testOptions)
+	svc.CreateInvitation(owner, "1", Invitation{Email: "a@example.com"})
+	stale, _ := svc.CreateInvitation(owner, "1", Invitation{Email: "b@example.com"})
+	repo.invitations[1].ExpiresAt = time.Now().Add(-time.Second)
+
+	sweeper, err := NewSweeper(svc, time.Minute, log.NewNopLogger())
+	assert.Nil(t, err)
+	sweeper.sweep(context.Background())
+
+	pending, _ := svc.GetInvitations(owner, "1")
+	assert.Len(t, pending, 1)
+	assert.NotEqual(t, stale.ID, pending[0].ID)
+	assert.Equal(t, StatusExpired, repo.invitations[1].Status)
+}
+
+func Test_NewSweeper_Should_Require_A_Positive_Interval(t *testing.T) {
+	svc, _, _, _ := newTestService(testOptions)
+
+	_, err := NewSweeper(svc, 0, log.NewNopLogger())
+
+	assert.Equal(t, ErrInvalidInterval, err)
+}
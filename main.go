@@ -1,17 +1,33 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"net/smtp"
+	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/expvar"
+	gmux "github.com/gorilla/mux"
 	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/apikey"
+	"github.com/tkanos/go-rest-api-sample/auth"
+	"github.com/tkanos/go-rest-api-sample/changefeed"
 	"github.com/tkanos/go-rest-api-sample/config"
+	"github.com/tkanos/go-rest-api-sample/events"
+	"github.com/tkanos/go-rest-api-sample/invitation"
+	"github.com/tkanos/go-rest-api-sample/membership"
 	"github.com/tkanos/go-rest-api-sample/mongoDb"
+	"github.com/tkanos/go-rest-api-sample/outbox"
+	"github.com/tkanos/go-rest-api-sample/schema"
+	"github.com/tkanos/go-rest-api-sample/verification"
+	"github.com/tkanos/go-rest-api-sample/webhook"
 	"gopkg.in/mgo.v2"
 )
 
@@ -23,7 +39,11 @@ var (
 
 // exit error codes
 var (
-	dbError = -2
+	dbError     = -2
+	importError = -3
+	eventsError = -4
+	mailError   = -5
+	configError = -6
 )
 
 func init() {
@@ -32,6 +52,16 @@ func init() {
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
+
+	// the sessions signed with a known key could be forged
+	if len(appConfig.AuthSigningKey) == 0 {
+		errorLogger.Log("config_error", "AUTH_SIGNING_KEY is not set")
+		os.Exit(configError)
+	}
+
 	//Db Connection
 	session, err := mgo.Dial(appConfig.MongoConnectionString)
 	if err != nil {
@@ -40,8 +70,68 @@ func main() {
 	}
 	defer session.Close()
 
+	// Repositories of what a merge moves from an account to another
+	memberRepository := getMemberRepository(session)
+	apiKeyRepository := getAPIKeyRepository(session)
+
+	schemaRepository := getSchemaRepository(session)
+
 	// Endpoints
-	accountEndpoints := getAccountEndpoints(session)
+	accountService := getAccountService(session, schema.NewRegistry(schemaRepository),
+		account.ServiceMergeAuthorizer(membership.NewMergeAuthorizer(memberRepository)),
+	)
+	accountEndpoints := getAccountEndpoints(accountService)
+
+	verificationService := getVerificationService(session, accountService)
+	verificationEndpoints := getVerificationEndpoints(verificationService)
+
+	authService := getAuthService(session, accountService)
+	authEndpoints := getAuthEndpoints(authService)
+	go authService.SendPasswordResets(context.Background())
+
+	schemaService := schema.NewService(accountService, schemaRepository)
+	schemaEndpoints := getSchemaEndpoints(schemaService)
+
+	memberService := membership.NewService(accountService, memberRepository)
+	memberEndpoints := getMemberEndpoints(memberService)
+
+	apiKeyService := apikey.NewService(accountService, memberService, apiKeyRepository)
+	apiKeyEndpoints := getAPIKeyEndpoints(apiKeyService)
+
+	invitationService := getInvitationService(session, accountService, memberService)
+	invitationEndpoints := getInvitationEndpoints(invitationService)
+	sweeper, err := invitation.NewSweeper(invitationService, time.Duration(appConfig.InvitationSweep)*time.Second, errorLogger)
+	if err != nil {
+		errorLogger.Log("invitation_sweeper_error", err)
+		os.Exit(configError)
+	}
+	go sweeper.Run(context.Background())
+
+	webhookRepository := getWebhookRepository(session)
+	webhookEndpoints := getWebhookEndpoints(webhookRepository)
+
+	// Events written to the outbox by the account repository are relayed to the bus
+	bus := getEventBus()
+	bus.Subscribe(webhook.NewDispatcher(webhookRepository, errorLogger))
+	bus.Subscribe(verification.NewSender(verificationService, errorLogger))
+
+	changes, err := changefeed.NewLog(appConfig.ChangesLogSize)
+	if err != nil {
+		errorLogger.Log("changes_log_error", err)
+		os.Exit(configError)
+	}
+	bus.Subscribe(changes)
+	go runOutboxRelay(session, bus)
+
+	// the merge handlers move what belongs to the source of a merge once it is written
+	go runMergeRelay(session, account.NewMergePublisher(
+		membership.NewMergeHandler(memberRepository),
+		apikey.NewMergeHandler(apiKeyRepository),
+	))
+
+	// Webhook deliveries
+	webhookClient := &http.Client{Timeout: 10 * time.Second}
+	go webhook.NewWorker(webhookRepository, webhookClient, appConfig.WebhookMaxAttempts, errorLogger).Run(context.Background())
 
 	// Errors channel
 	errc := make(chan error)
@@ -57,12 +147,52 @@ func main() {
 	go func() {
 		httpAddr := ":" + strconv.Itoa(appConfig.Port)
 		mux := http.NewServeMux()
+		// the management of an account always requires a session, or the admin key
+		authenticated := auth.NewMiddleware(authService)
 
-		mux.Handle("/accounts/", account.MakeHTTPHandler(errorLogger, accountEndpoints))
+		var accountHandler http.Handler = account.MakeHTTPHandler(errorLogger, accountEndpoints,
+			account.HandlerCacheControl(account.RouteAccount, appConfig.CacheControlAccount),
+			account.HandlerCacheControl(account.RouteAccounts, appConfig.CacheControlAccounts),
+			account.HandlerCacheControl(account.RouteStats, appConfig.CacheControlStats),
+			account.HandlerCacheControl(account.RouteHistory, appConfig.CacheControlHistory),
+		)
+		var schemaHandler http.Handler = schema.MakeHTTPHandler(errorLogger, schemaEndpoints)
+		// the accounts and schemas are managed with an API key in the api_key
+		// mode, and otherwise with a session or the admin key, so that every
+		// change is made, and audited, by a known principal. Only the admin
+		// registers the schemas then.
+		if appConfig.AuthMode == "api_key" {
+			accountHandler = apikey.NewMiddleware(apiKeyService, apikey.ScopeAccountsRead, apikey.ScopeAccountsWrite)(accountHandler)
+			schemaHandler = apikey.NewMiddleware(apiKeyService, apikey.ScopeAccountsRead, apikey.ScopeSchemasWrite)(schemaHandler)
+		} else {
+			accountHandler = authenticated(accountHandler)
+			schemaHandler = authenticated(auth.RequireAdminToWrite(schemaHandler))
+		}
+
+		mux.Handle("/accounts/", accountHandler)
+		// the feed streams the changes of every account, only the admin reads it
+		mux.Handle("/accounts/changes", authenticated(auth.RequireAdmin(changefeed.MakeHTTPHandler(errorLogger, changes, time.Duration(appConfig.ChangesHeartbeat)*time.Second))))
+		// the subscriptions receive the events of every account, only the admin manages them
+		mux.Handle("/webhooks/", authenticated(auth.RequireAdmin(webhook.MakeHTTPHandler(errorLogger, webhookEndpoints))))
+		mux.Handle("/schemas/accounts", schemaHandler)
+		mux.Handle("/schemas/accounts/", schemaHandler)
 
 		mux.HandleFunc("/healthz", healthzHandler)
 
-		http.Handle("/", mux)
+		// account subresources served by other packages are matched first
+		router := gmux.NewRouter()
+		router.PathPrefix("/accounts/{id}/verify-email").Handler(verification.MakeHTTPHandler(errorLogger, verificationEndpoints))
+		authHandler := auth.MakeHTTPHandler(errorLogger, authEndpoints)
+		router.Handle("/accounts/{id}/password", authenticated(authHandler))
+		router.PathPrefix("/auth/").Handler(authHandler)
+		router.PathPrefix("/accounts/{id}/api-keys").Handler(authenticated(apikey.MakeHTTPHandler(errorLogger, apiKeyEndpoints)))
+		router.PathPrefix("/accounts/{id}/members").Handler(authenticated(membership.MakeHTTPHandler(errorLogger, memberEndpoints)))
+		invitationHandler := invitation.MakeHTTPHandler(errorLogger, invitationEndpoints)
+		router.PathPrefix("/accounts/{id}/invitations").Handler(authenticated(invitationHandler))
+		router.PathPrefix("/invitations/").Handler(authenticated(invitationHandler))
+		router.PathPrefix("/").Handler(mux)
+
+		http.Handle("/", router)
 		infoLogger.Log("service", "go-rest-api-sample", "transport", "http", "address", httpAddr, "msg", "listening")
 		errc <- http.ListenAndServe(httpAddr, nil)
 	}()
@@ -70,32 +200,322 @@ func main() {
 	infoLogger.Log("exit", <-errc)
 }
 
-func getAccountEndpoints(mongoSession *mgo.Session) account.Endpoints {
+func getAccountService(mongoSession *mgo.Session, schemas account.Schemas, extra ...account.ServiceOption) account.Service {
 
-	accountRepository, err := mongoDb.NewAccountRepository(mongoSession)
+	accountRepository, err := mongoDb.NewAccountRepository(mongoSession, appConfig.VersionRetention, errorLogger)
 	if err != nil {
 		errorLogger.Log("mongo_account_session_error", err)
 		os.Exit(dbError)
 	}
-	accountService := account.NewService(accountRepository)
+	// a size of 0 disables the cache, its counters are exposed on /debug/vars
+	if appConfig.AccountCacheSize > 0 {
+		accountRepository = account.NewCachingRepository(accountRepository,
+			appConfig.AccountCacheSize,
+			time.Duration(appConfig.AccountCacheTTL)*time.Second,
+			expvar.NewCounter("account_cache_hits"),
+			expvar.NewCounter("account_cache_misses"),
+		)
+	}
+
+	auditLog, err := mongoDb.NewAuditLog(mongoSession)
+	if err != nil {
+		errorLogger.Log("mongo_audit_session_error", err)
+		os.Exit(dbError)
+	}
+
+	// no publisher nor audit log here: the repository writes every event to
+	// the outbox, and every audit entry, with the change
+	options := []account.ServiceOption{
+		account.ServiceAuditHistory(auditLog),
+		account.ServiceLogger(errorLogger),
+		account.ServiceSchemas(schemas),
+		account.ServiceStatsCache(time.Duration(appConfig.StatsCacheTTL) * time.Second),
+	}
+
+	return account.NewService(accountRepository, append(options, extra...)...)
+}
+
+func runOutboxRelay(mongoSession *mgo.Session, publisher account.Publisher) {
+	store, err := mongoDb.NewOutboxStore(mongoSession)
+	if err != nil {
+		errorLogger.Log("mongo_outbox_session_error", err)
+		os.Exit(dbError)
+	}
+
+	// exposed on /debug/vars
+	lag := expvar.NewGauge("outbox_relay_lag_seconds")
+
+	outbox.NewRelay(store, publisher, appConfig.OutboxMaxAttempts, errorLogger, lag).Run(context.Background())
+}
+
+func runMergeRelay(mongoSession *mgo.Session, publisher account.Publisher) {
+	store, err := mongoDb.NewMergeOutboxStore(mongoSession)
+	if err != nil {
+		errorLogger.Log("mongo_merge_outbox_session_error", err)
+		os.Exit(dbError)
+	}
+
+	// exposed on /debug/vars
+	lag := expvar.NewGauge("merge_relay_lag_seconds")
+
+	outbox.NewRelay(store, publisher, appConfig.OutboxMaxAttempts, errorLogger, lag).Run(context.Background())
+}
+
+// getEventBus returns the in-process bus receiving every account event,
+// each event is also appended to the configured events file
+func getEventBus() *events.Bus {
+	bus := events.NewBus()
+
+	sink, err := events.OpenFileSink(appConfig.EventsFile)
+	if err != nil {
+		errorLogger.Log("events_file_error", err)
+		os.Exit(eventsError)
+	}
+	bus.Subscribe(sink)
+
+	return bus
+}
+
+func getAccountEndpoints(accountService account.Service) account.Endpoints {
 
 	getByIDEndpoint := account.MakeGetAccountEndpoint(accountService)
 
 	getListEndpoint := account.MakeGetAccountsEndpoint(accountService)
 
+	exportEndpoint := account.MakeExportAccountsEndpoint(accountService)
+
 	updateEndpoint := account.MakeUpdateAccountEndpoint(accountService)
 
 	createEndpoint := account.MakeCreateAccountEndpoint(accountService)
 
 	deleteEndpoint := account.MakeDeleteAccountEndpoint(accountService)
 
+	historyEndpoint := account.MakeGetAccountHistoryEndpoint(accountService)
+
+	getVersionEndpoint := account.MakeGetAccountVersionEndpoint(accountService)
+
+	revertEndpoint := account.MakeRevertAccountEndpoint(accountService)
+
+	childrenEndpoint := account.MakeGetChildrenEndpoint(accountService)
+
+	descendantsEndpoint := account.MakeGetDescendantsEndpoint(accountService)
+
+	mergeEndpoint := account.MakeMergeAccountsEndpoint(accountService)
+
+	searchEndpoint := account.MakeSearchAccountsEndpoint(accountService)
+
+	statsEndpoint := account.MakeGetAccountStatsEndpoint(accountService)
+
 	return account.Endpoints{
-		GetByID: getByIDEndpoint,
-		GetList: getListEndpoint,
-		Update:  updateEndpoint,
-		Create:  createEndpoint,
-		Delete:  deleteEndpoint,
+		GetByID:    getByIDEndpoint,
+		GetList:    getListEndpoint,
+		Export:     exportEndpoint,
+		Update:     updateEndpoint,
+		Create:     createEndpoint,
+		Delete:     deleteEndpoint,
+		History:    historyEndpoint,
+		GetVersion: getVersionEndpoint,
+		Revert:     revertEndpoint,
+
+		Children:    childrenEndpoint,
+		Descendants: descendantsEndpoint,
+		Merge:       mergeEndpoint,
+		Search:      searchEndpoint,
+		Stats:       statsEndpoint,
+	}
+}
+
+func getWebhookRepository(mongoSession *mgo.Session) webhook.Repository {
+	webhookRepository, err := mongoDb.NewWebhookRepository(mongoSession)
+	if err != nil {
+		errorLogger.Log("mongo_webhook_session_error", err)
+		os.Exit(dbError)
+	}
+
+	return webhookRepository
+}
+
+func getWebhookEndpoints(webhookRepository webhook.Repository) webhook.Endpoints {
+	webhookService := webhook.NewService(webhookRepository)
+
+	return webhook.Endpoints{
+		GetByID:       webhook.MakeGetSubscriptionEndpoint(webhookService),
+		GetList:       webhook.MakeGetSubscriptionsEndpoint(webhookService),
+		Update:        webhook.MakeUpdateSubscriptionEndpoint(webhookService),
+		Create:        webhook.MakeCreateSubscriptionEndpoint(webhookService),
+		Delete:        webhook.MakeDeleteSubscriptionEndpoint(webhookService),
+		GetDeliveries: webhook.MakeGetDeliveriesEndpoint(webhookService),
+	}
+}
+
+func getVerificationService(mongoSession *mgo.Session, accountService account.Service) verification.Service {
+	verificationRepository, err := mongoDb.NewVerificationRepository(mongoSession)
+	if err != nil {
+		errorLogger.Log("mongo_verification_session_error", err)
+		os.Exit(dbError)
 	}
+
+	resendInterval := time.Duration(appConfig.EmailResendInterval) * time.Second
+
+	return verification.NewService(accountService, verificationRepository, getMailer(), resendInterval)
+}
+
+func getVerificationEndpoints(verificationService verification.Service) verification.Endpoints {
+	return verification.Endpoints{
+		Verify: verification.MakeVerifyEmailEndpoint(verificationService),
+		Resend: verification.MakeResendEmailEndpoint(verificationService),
+	}
+}
+
+func getAuthService(mongoSession *mgo.Session, accountService account.Service) auth.Service {
+	authRepository, err := mongoDb.NewAuthRepository(mongoSession)
+	if err != nil {
+		errorLogger.Log("mongo_auth_session_error", err)
+		os.Exit(dbError)
+	}
+
+	return auth.NewService(accountService, authRepository, getNotifier(), auth.Options{
+		SigningKey:    []byte(appConfig.AuthSigningKey),
+		AccessTTL:     time.Duration(appConfig.AuthAccessTTL) * time.Second,
+		RefreshTTL:    time.Duration(appConfig.AuthRefreshTTL) * time.Second,
+		MaxAttempts:   appConfig.LoginMaxAttempts,
+		LockoutTime:   time.Duration(appConfig.LoginLockout) * time.Second,
+		ResetTTL:      time.Duration(appConfig.PasswordResetTTL) * time.Second,
+		ResetInterval: time.Duration(appConfig.PasswordResetInterval) * time.Second,
+		AdminKey:      appConfig.AdminKey,
+	}, errorLogger)
+}
+
+func getAPIKeyRepository(mongoSession *mgo.Session) apikey.Repository {
+	apiKeyRepository, err := mongoDb.NewAPIKeyRepository(mongoSession)
+	if err != nil {
+		errorLogger.Log("mongo_apikey_session_error", err)
+		os.Exit(dbError)
+	}
+
+	return apiKeyRepository
+}
+
+func getAPIKeyEndpoints(apiKeyService apikey.Service) apikey.Endpoints {
+	return apikey.Endpoints{
+		GetList: apikey.MakeGetKeysEndpoint(apiKeyService),
+		Create:  apikey.MakeCreateKeyEndpoint(apiKeyService),
+		Rotate:  apikey.MakeRotateKeyEndpoint(apiKeyService),
+		Revoke:  apikey.MakeRevokeKeyEndpoint(apiKeyService),
+	}
+}
+
+func getSchemaRepository(mongoSession *mgo.Session) schema.Repository {
+	schemaRepository, err := mongoDb.NewSchemaRepository(mongoSession)
+	if err != nil {
+		errorLogger.Log("mongo_schema_session_error", err)
+		os.Exit(dbError)
+	}
+
+	return schemaRepository
+}
+
+func getSchemaEndpoints(schemaService schema.Service) schema.Endpoints {
+	return schema.Endpoints{
+		Get:     schema.MakeGetSchemaEndpoint(schemaService),
+		GetList: schema.MakeGetSchemasEndpoint(schemaService),
+		Put:     schema.MakePutSchemaEndpoint(schemaService),
+		Delete:  schema.MakeDeleteSchemaEndpoint(schemaService),
+	}
+}
+
+func getMemberRepository(mongoSession *mgo.Session) membership.MemberRepository {
+	memberRepository, err := mongoDb.NewMemberRepository(mongoSession)
+	if err != nil {
+		errorLogger.Log("mongo_member_session_error", err)
+		os.Exit(dbError)
+	}
+
+	return memberRepository
+}
+
+func getMemberEndpoints(memberService membership.Service) membership.Endpoints {
+	return membership.Endpoints{
+		Get:        membership.MakeGetMemberEndpoint(memberService),
+		GetList:    membership.MakeGetMembersEndpoint(memberService),
+		Invite:     membership.MakeInviteMemberEndpoint(memberService),
+		ChangeRole: membership.MakeChangeRoleEndpoint(memberService),
+		Remove:     membership.MakeRemoveMemberEndpoint(memberService),
+	}
+}
+
+func getInvitationService(mongoSession *mgo.Session, accountService account.Service, memberService membership.Service) invitation.Service {
+	invitationRepository, err := mongoDb.NewInvitationRepository(mongoSession)
+	if err != nil {
+		errorLogger.Log("mongo_invitation_session_error", err)
+		os.Exit(dbError)
+	}
+
+	return invitation.NewService(accountService, memberService, invitationRepository, getMailer(), invitation.Options{
+		SigningKey: []byte(appConfig.AuthSigningKey),
+		TTL:        time.Duration(appConfig.InvitationTTL) * time.Second,
+	}, errorLogger)
+}
+
+func getInvitationEndpoints(invitationService invitation.Service) invitation.Endpoints {
+	return invitation.Endpoints{
+		GetList: invitation.MakeGetInvitationsEndpoint(invitationService),
+		Create:  invitation.MakeCreateInvitationEndpoint(invitationService),
+		Revoke:  invitation.MakeRevokeInvitationEndpoint(invitationService),
+		Accept:  invitation.MakeAcceptInvitationEndpoint(invitationService),
+	}
+}
+
+// getNotifier returns the configured password reset Notifier: file or, by default, mail
+func getNotifier() auth.Notifier {
+	if appConfig.Notifier == "file" {
+		n, err := auth.OpenFileNotifier(appConfig.NotifierFile)
+		if err != nil {
+			errorLogger.Log("notifier_file_error", err)
+			os.Exit(mailError)
+		}
+		return n
+	}
+
+	return auth.NewMailNotifier(getMailer())
+}
+
+func getAuthEndpoints(authService auth.Service) auth.Endpoints {
+	return auth.Endpoints{
+		SetPassword: auth.MakeSetPasswordEndpoint(authService),
+		Login:       auth.MakeLoginEndpoint(authService),
+		Refresh:     auth.MakeRefreshEndpoint(authService),
+		Logout:      auth.MakeLogoutEndpoint(authService),
+
+		RequestReset: auth.MakeRequestPasswordResetEndpoint(authService),
+		ConfirmReset: auth.MakeConfirmPasswordResetEndpoint(authService),
+	}
+}
+
+// getMailer returns the configured Mailer: smtp, file or, by default, log
+func getMailer() verification.Mailer {
+	switch appConfig.Mailer {
+	case "smtp":
+		// SMTP_URL is smtp://[user:password@]host:port
+		u, err := url.Parse(appConfig.SMTPURL)
+		if err != nil {
+			errorLogger.Log("smtp_url_error", err)
+			os.Exit(mailError)
+		}
+
+		var auth smtp.Auth
+		if password, ok := u.User.Password(); ok {
+			auth = smtp.PlainAuth("", u.User.Username(), password, u.Hostname())
+		}
+		return verification.NewSMTPMailer(u.Host, appConfig.MailFrom, auth)
+	case "file":
+		if err := os.MkdirAll(appConfig.MailDir, 0700); err != nil {
+			errorLogger.Log("mail_dir_error", err)
+		}
+		return verification.NewFileMailer(appConfig.MailDir, appConfig.MailFrom)
+	}
+
+	return verification.NewLogMailer(infoLogger)
 }
 
 func healthzHandler(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,85 @@
+package membership
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Endpoints represent the membership service endpoints
+type Endpoints struct {
+	Get        endpoint.Endpoint
+	GetList    endpoint.Endpoint
+	Invite     endpoint.Endpoint
+	ChangeRole endpoint.Endpoint
+	Remove     endpoint.Endpoint
+}
+
+// MakeGetMemberEndpoint returns an endpoint used for getting a member of an account
+func MakeGetMemberEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(MemberRequest)
+
+		return s.GetMember(ctx, req.AccountID, req.ID)
+	}
+}
+
+// MakeGetMembersEndpoint returns an endpoint used for listing the members of an account
+func MakeGetMembersEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetMembersRequest)
+
+		return s.GetMembers(ctx, req.AccountID)
+	}
+}
+
+// MakeInviteMemberEndpoint returns an endpoint used for inviting a member
+func MakeInviteMemberEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(InviteMemberRequest)
+
+		return s.InviteMember(ctx, req.AccountID, req.Member)
+	}
+}
+
+// MakeChangeRoleEndpoint returns an endpoint used for changing the role of a member
+func MakeChangeRoleEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ChangeRoleRequest)
+
+		return nil, s.ChangeRole(ctx, req.AccountID, req.ID, req.Role)
+	}
+}
+
+// MakeRemoveMemberEndpoint returns an endpoint used for removing a member
+func MakeRemoveMemberEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(MemberRequest)
+
+		return nil, s.RemoveMember(ctx, req.AccountID, req.ID)
+	}
+}
+
+// GetMembersRequest represents the request parameters used for listing the members of an account
+type GetMembersRequest struct {
+	AccountID string
+}
+
+// InviteMemberRequest represents the request parameters used for inviting a member
+type InviteMemberRequest struct {
+	AccountID string
+	Member
+}
+
+// MemberRequest represents the request parameters used for getting or removing a member
+type MemberRequest struct {
+	AccountID string
+	ID        string
+}
+
+// ChangeRoleRequest represents the request parameters used for changing the role of a member
+type ChangeRoleRequest struct {
+	AccountID string
+	ID        string
+	Role      string `json:"role"`
+}
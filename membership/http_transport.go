@@ -0,0 +1,155 @@
+package membership
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+)
+
+// ErrInvalidBody thrown when the body of a request can not be parsed
+var ErrInvalidBody = errors.New("invalid body")
+
+// MakeHTTPHandler returns all http handler for the membership service
+func MakeHTTPHandler(logger log.Logger, endpoints Endpoints) http.Handler {
+	options := []kithttp.ServerOption{
+		kithttp.ServerErrorLogger(logger),
+		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(account.PopulateAuditContext),
+	}
+
+	getMemberHandler := kithttp.NewServer(
+		endpoints.Get,
+		decodeMemberRequest,
+		encodeResponse,
+		options...,
+	)
+
+	getMembersHandler := kithttp.NewServer(
+		endpoints.GetList,
+		decodeGetMembersRequest,
+		encodeResponse,
+		options...,
+	)
+
+	inviteMemberHandler := kithttp.NewServer(
+		endpoints.Invite,
+		decodeInviteMemberRequest,
+		encodeInviteMemberResponse,
+		options...,
+	)
+
+	changeRoleHandler := kithttp.NewServer(
+		endpoints.ChangeRole,
+		decodeChangeRoleRequest,
+		encodeNoContentResponse,
+		options...,
+	)
+
+	removeMemberHandler := kithttp.NewServer(
+		endpoints.Remove,
+		decodeMemberRequest,
+		encodeNoContentResponse,
+		options...,
+	)
+
+	r := mux.NewRouter().PathPrefix("/accounts/{account_id}/members").Subrouter()
+
+	r.Handle("", getMembersHandler).Methods("GET")
+	r.Handle("", inviteMemberHandler).Methods("POST")
+	r.Handle("/{id}", getMemberHandler).Methods("GET")
+	r.Handle("/{id}", changeRoleHandler).Methods("PATCH")
+	r.Handle("/{id}", removeMemberHandler).Methods("DELETE")
+
+	return r
+}
+
+func decodeGetMembersRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	return GetMembersRequest{AccountID: mux.Vars(r)["account_id"]}, nil
+}
+
+func decodeInviteMemberRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req InviteMemberRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req.Member); err != nil {
+		return nil, ErrInvalidBody
+	}
+
+	req.AccountID = mux.Vars(r)["account_id"]
+
+	return req, nil
+}
+
+func decodeMemberRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	vars := mux.Vars(r)
+
+	return MemberRequest{AccountID: vars["account_id"], ID: vars["id"]}, nil
+}
+
+func decodeChangeRoleRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req ChangeRoleRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, ErrInvalidBody
+	}
+
+	vars := mux.Vars(r)
+	req.AccountID = vars["account_id"]
+	req.ID = vars["id"]
+
+	return req, nil
+}
+
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func encodeInviteMemberResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	m, ok := response.(*Member)
+	if !ok {
+		return errors.New("An error occured while inviting member")
+	}
+	w.Header().Set("Location", fmt.Sprintf("/accounts/%v/members/%v", m.AccountID, m.ID))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(m)
+}
+
+func encodeNoContentResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// encode errors from business-logic
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	switch err {
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	case ErrInvalidBody,
+		ErrInvalidRole,
+		ErrInvalidEmail:
+		w.WriteHeader(http.StatusBadRequest)
+	case auth.ErrUnauthenticated:
+		w.WriteHeader(http.StatusUnauthorized)
+	case auth.ErrForbidden:
+		w.WriteHeader(http.StatusForbidden)
+	case ErrNotFound,
+		account.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case ErrAlreadyMember,
+		ErrLastOwner:
+		w.WriteHeader(http.StatusConflict)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
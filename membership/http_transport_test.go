@@ -0,0 +1,76 @@
+package membership
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+)
+
+func Test_DecodeInviteMemberRequest(t *testing.T) {
+	expected := InviteMemberRequest{AccountID: "1", Member: Member{Email: "a@example.com", Role: RoleAdmin}}
+	r, _ := http.NewRequest("POST", "/accounts/1/members", bytes.NewBufferString(`{"email":"a@example.com","role":"admin"}`))
+	r = mux.SetURLVars(r, map[string]string{"account_id": "1"})
+
+	req, err := decodeInviteMemberRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, req)
+}
+
+func Test_DecodeChangeRoleRequest(t *testing.T) {
+	expected := ChangeRoleRequest{AccountID: "1", ID: "2", Role: RoleOwner}
+	r, _ := http.NewRequest("PATCH", "/accounts/1/members/2", bytes.NewBufferString(`{"role":"owner"}`))
+	r = mux.SetURLVars(r, map[string]string{"account_id": "1", "id": "2"})
+
+	req, err := decodeChangeRoleRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, req)
+
+	r, _ = http.NewRequest("PATCH", "/accounts/1/members/2", bytes.NewBufferString(`{`))
+	_, err = decodeChangeRoleRequest(context.Background(), r)
+
+	assert.Equal(t, ErrInvalidBody, err)
+}
+
+func Test_EncodeInviteMemberResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := encodeInviteMemberResponse(context.Background(), w, &Member{ID: "2", AccountID: "1"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/accounts/1/members/2", w.Header().Get("Location"))
+}
+
+func Test_EncodeError_Should_Correctly_Map_Error(t *testing.T) {
+	var flagtests = []struct {
+		in  error
+		out int
+	}{
+		{errors.New("not handled error"), http.StatusInternalServerError},
+		{ErrInvalidBody, http.StatusBadRequest},
+		{ErrInvalidRole, http.StatusBadRequest},
+		{ErrInvalidEmail, http.StatusBadRequest},
+		{ErrNotFound, http.StatusNotFound},
+		{account.ErrNotFound, http.StatusNotFound},
+		{ErrAlreadyMember, http.StatusConflict},
+		{ErrLastOwner, http.StatusConflict},
+		{auth.ErrUnauthenticated, http.StatusUnauthorized},
+		{auth.ErrForbidden, http.StatusForbidden},
+	}
+
+	for _, tt := range flagtests {
+		w := httptest.NewRecorder()
+		encodeError(context.Background(), tt.in, w)
+
+		assert.Equal(t, tt.out, w.Code)
+	}
+}
@@ -0,0 +1,164 @@
+package membership
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+type memoryRepository struct {
+	mu      sync.Mutex
+	lastID  int
+	members []Member
+}
+
+// NewMemoryRepository returns a MemberRepository keeping the members in memory,
+// they are lost when the process stops
+func NewMemoryRepository() MemberRepository {
+	return &memoryRepository{}
+}
+
+// AddMember ...
+func (r *memoryRepository) AddMember(ctx context.Context, m Member) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, other := range r.members {
+		if other.AccountID == m.AccountID && other.Email == m.Email {
+			return "", ErrAlreadyMember
+		}
+	}
+
+	r.lastID++
+	m.ID = strconv.Itoa(r.lastID)
+	r.members = append(r.members, m)
+
+	return m.ID, nil
+}
+
+// GetMember ...
+func (r *memoryRepository) GetMember(ctx context.Context, accountID, id string) (*Member, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if i := r.index(accountID, id); i >= 0 {
+		m := r.members[i]
+		return &m, nil
+	}
+	return nil, nil
+}
+
+// GetMembers ...
+func (r *memoryRepository) GetMembers(ctx context.Context, accountID string) ([]*Member, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	members := []*Member{}
+	for _, m := range r.members {
+		if m.AccountID == accountID {
+			m := m
+			members = append(members, &m)
+		}
+	}
+	return members, nil
+}
+
+// UpdateMember ...
+func (r *memoryRepository) UpdateMember(ctx context.Context, m Member) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(m.AccountID, m.ID)
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	r.members[i] = m
+	return nil
+}
+
+// RemoveMember ...
+func (r *memoryRepository) RemoveMember(ctx context.Context, accountID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(accountID, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	r.members = append(r.members[:i], r.members[i+1:]...)
+	return nil
+}
+
+// UpdateOwner ...
+func (r *memoryRepository) UpdateOwner(ctx context.Context, m Member) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(m.AccountID, m.ID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if !r.hasOtherOwner(m.AccountID, m.ID) {
+		return ErrLastOwner
+	}
+
+	r.members[i] = m
+	return nil
+}
+
+// RemoveOwner ...
+func (r *memoryRepository) RemoveOwner(ctx context.Context, accountID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(accountID, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if !r.hasOtherOwner(accountID, id) {
+		return ErrLastOwner
+	}
+
+	r.members = append(r.members[:i], r.members[i+1:]...)
+	return nil
+}
+
+// MoveMember ...
+func (r *memoryRepository) MoveMember(ctx context.Context, accountID, id, toAccountID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(accountID, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	for _, other := range r.members {
+		if other.AccountID == toAccountID && other.Email == r.members[i].Email {
+			return ErrAlreadyMember
+		}
+	}
+
+	r.members[i].AccountID = toAccountID
+	return nil
+}
+
+func (r *memoryRepository) hasOtherOwner(accountID, id string) bool {
+	for _, m := range r.members {
+		if m.AccountID == accountID && m.ID != id && m.IsOwner() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryRepository) index(accountID, id string) int {
+	for i, m := range r.members {
+		if m.AccountID == accountID && m.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,55 @@
+package membership
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// fakeAccounts only implements the account.Service methods used by the membership service
+type fakeAccounts struct {
+	account.Service
+}
+
+func (fakeAccounts) GetAccount(ctx context.Context, id string) (*account.Account, error) {
+	if id != "1" {
+		return nil, account.ErrNotFound
+	}
+	return &account.Account{AccountID: id}, nil
+}
+
+func Test_MemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+
+	id, err := r.AddMember(ctx, Member{AccountID: "1", Email: "a@example.com", Role: RoleOwner})
+	assert.Nil(t, err)
+
+	_, err = r.AddMember(ctx, Member{AccountID: "1", Email: "a@example.com"})
+	assert.Equal(t, ErrAlreadyMember, err)
+
+	_, err = r.AddMember(ctx, Member{AccountID: "2", Email: "a@example.com"})
+	assert.Nil(t, err)
+
+	m, err := r.GetMember(ctx, "1", id)
+	assert.Nil(t, err)
+	assert.Equal(t, "a@example.com", m.Email)
+
+	m.Role = RoleAdmin
+	assert.Nil(t, r.UpdateMember(ctx, *m))
+
+	members, err := r.GetMembers(ctx, "1")
+	assert.Nil(t, err)
+	assert.Len(t, members, 1)
+	assert.Equal(t, RoleAdmin, members[0].Role)
+
+	assert.Nil(t, r.RemoveMember(ctx, "1", id))
+	assert.Equal(t, ErrNotFound, r.RemoveMember(ctx, "1", id))
+	assert.Equal(t, ErrNotFound, r.UpdateMember(ctx, *m))
+
+	m, err = r.GetMember(ctx, "1", id)
+	assert.Nil(t, err)
+	assert.Nil(t, m)
+}
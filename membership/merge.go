@@ -0,0 +1,103 @@
+package membership
+
+import (
+	"context"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+)
+
+type mergeHandler struct {
+	repository MemberRepository
+}
+
+// NewMergeHandler returns the account.MergeHandler moving the members of the
+// source account of a merge to the target one. A person member of both keeps
+// the membership of the target, with the highest of its two roles.
+func NewMergeHandler(r MemberRepository) account.MergeHandler {
+	return mergeHandler{repository: r}
+}
+
+// MergeAccount ...
+func (h mergeHandler) MergeAccount(ctx context.Context, targetID, sourceID string) error {
+	sources, err := h.repository.GetMembers(ctx, sourceID)
+	if err != nil {
+		return err
+	}
+	targets, err := h.repository.GetMembers(ctx, targetID)
+	if err != nil {
+		return err
+	}
+
+	byEmail := map[string]*Member{}
+	for _, m := range targets {
+		byEmail[m.Email] = m
+	}
+
+	for _, m := range sources {
+		existing, ok := byEmail[m.Email]
+		if !ok {
+			if err := h.repository.MoveMember(ctx, sourceID, m.ID, targetID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		changed := false
+		if rank(m.Role) > rank(existing.Role) {
+			existing.Role = m.Role
+			changed = true
+		}
+		if m.Status == StatusActive && existing.Status != StatusActive {
+			existing.Status = m.Status
+			existing.JoinedAt = m.JoinedAt
+			changed = true
+		}
+		if len(existing.UserID) == 0 && len(m.UserID) > 0 {
+			existing.UserID = m.UserID
+			changed = true
+		}
+
+		if changed {
+			if err := h.repository.UpdateMember(ctx, *existing); err != nil {
+				return err
+			}
+		}
+
+		if err := h.repository.RemoveMember(ctx, sourceID, m.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rank orders the roles, from the least to the most privileged
+func rank(role string) int {
+	for i, r := range []string{RoleMember, RoleAdmin, RoleOwner} {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+type mergeAuthorizer struct {
+	service service
+}
+
+// NewMergeAuthorizer returns the account.MergeAuthorizer letting the admins
+// and owners of an account merge it, see Service.Authorize
+func NewMergeAuthorizer(r MemberRepository) account.MergeAuthorizer {
+	return mergeAuthorizer{service: service{repository: r}}
+}
+
+// AuthorizeMerge ...
+func (a mergeAuthorizer) AuthorizeMerge(ctx context.Context, accountID string) error {
+	err := a.service.Authorize(ctx, accountID, RoleAdmin)
+	if err == auth.ErrForbidden || err == auth.ErrUnauthenticated {
+		return account.ErrMergeForbidden
+	}
+
+	return err
+}
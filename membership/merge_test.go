@@ -0,0 +1,68 @@
+package membership
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+)
+
+func Test_MergeHandler_Should_Move_The_Members(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+	r.AddMember(ctx, Member{AccountID: "1", Email: "both@example.com", Role: RoleMember, Status: StatusActive})
+	r.AddMember(ctx, Member{AccountID: "2", Email: "both@example.com", Role: RoleOwner, Status: StatusActive})
+	r.AddMember(ctx, Member{AccountID: "2", Email: "source@example.com", Role: RoleAdmin, Status: StatusInvited})
+
+	err := NewMergeHandler(r).MergeAccount(ctx, "1", "2")
+	assert.Nil(t, err)
+
+	source, _ := r.GetMembers(ctx, "2")
+	assert.Empty(t, source)
+
+	target, _ := r.GetMembers(ctx, "1")
+	assert.Len(t, target, 2)
+	for _, m := range target {
+		switch m.Email {
+		case "both@example.com":
+			assert.Equal(t, RoleOwner, m.Role)
+		case "source@example.com":
+			assert.Equal(t, RoleAdmin, m.Role)
+			assert.Equal(t, StatusInvited, m.Status)
+		}
+	}
+
+	// retrying a merge changes nothing
+	assert.Nil(t, NewMergeHandler(r).MergeAccount(ctx, "1", "2"))
+}
+
+func Test_MergeAuthorizer_Should_Only_Let_The_Admins_And_Owners_Merge(t *testing.T) {
+	ctx := context.Background()
+	r := NewMemoryRepository()
+	r.AddMember(ctx, Member{AccountID: "1", UserID: "7", Email: "admin@example.com", Role: RoleAdmin, Status: StatusActive})
+	r.AddMember(ctx, Member{AccountID: "1", UserID: "8", Email: "member@example.com", Role: RoleMember, Status: StatusActive})
+
+	var flagtests = []struct {
+		principal *auth.Principal
+		err       error
+	}{
+		{&auth.Principal{AccountID: "7", SessionID: "s"}, nil},
+		{&auth.Principal{AccountID: "1", SessionID: "s"}, nil},
+		{&auth.Principal{Admin: true}, nil},
+		{&auth.Principal{AccountID: "8", SessionID: "s"}, account.ErrMergeForbidden},
+		// an API key never merges, even one of the account
+		{&auth.Principal{AccountID: "1", KeyID: "k"}, account.ErrMergeForbidden},
+		{nil, account.ErrMergeForbidden},
+	}
+
+	for _, tt := range flagtests {
+		ctx := ctx
+		if tt.principal != nil {
+			ctx = auth.ContextWithPrincipal(ctx, tt.principal)
+		}
+
+		assert.Equal(t, tt.err, NewMergeAuthorizer(r).AuthorizeMerge(ctx, "1"))
+	}
+}
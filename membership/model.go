@@ -0,0 +1,48 @@
+package membership
+
+import "time"
+
+// Roles a member can have in an account
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Roles lists every known role, from the highest to the lowest
+var Roles = []string{RoleOwner, RoleAdmin, RoleMember}
+
+// roleRank returns how high a role is, 0 for an unknown one
+func roleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return len(Roles) - i
+		}
+	}
+	return 0
+}
+
+// Status of a member
+const (
+	StatusInvited = "invited"
+	StatusActive  = "active"
+)
+
+// Member is a person belonging to an account, with a role in it. A member is
+// invited first, and only counts once the invitation is accepted. UserID is the
+// account of the person, when known.
+type Member struct {
+	ID        string     `json:"id" bson:"id"`
+	AccountID string     `json:"account_id" bson:"account_id"`
+	UserID    string     `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	Email     string     `json:"email" bson:"email"`
+	Role      string     `json:"role" bson:"role"`
+	Status    string     `json:"status" bson:"status"`
+	InvitedAt time.Time  `json:"invited_at" bson:"invited_at"`
+	JoinedAt  *time.Time `json:"joined_at,omitempty" bson:"joined_at,omitempty"`
+}
+
+// IsOwner tells whether the member is an active owner of the account
+func (m Member) IsOwner() bool {
+	return m.Status == StatusActive && m.Role == RoleOwner
+}
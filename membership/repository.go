@@ -0,0 +1,26 @@
+package membership
+
+import (
+	"context"
+)
+
+// MemberRepository is the storage of the account members
+type MemberRepository interface {
+	// AddMember stores a new member and returns its id, ErrAlreadyMember if the email already is a member of the account
+	AddMember(ctx context.Context, m Member) (string, error)
+	// GetMember returns a member of an account, nil if there is none
+	GetMember(ctx context.Context, accountID, id string) (*Member, error)
+	GetMembers(ctx context.Context, accountID string) ([]*Member, error)
+	// UpdateMember replaces a member, ErrNotFound if there is none
+	UpdateMember(ctx context.Context, m Member) error
+	// RemoveMember removes a member, ErrNotFound if there is none
+	RemoveMember(ctx context.Context, accountID, id string) error
+	// UpdateOwner replaces an active owner, ErrLastOwner unless the account still has another
+	// active owner when it is written; ErrNotFound if there is none
+	UpdateOwner(ctx context.Context, m Member) error
+	// RemoveOwner removes an active owner, ErrLastOwner unless the account still has another
+	// active owner when it is removed; ErrNotFound if there is none
+	RemoveOwner(ctx context.Context, accountID, id string) error
+	// MoveMember moves a member to another account, keeping its id; ErrNotFound if there is none
+	MoveMember(ctx context.Context, accountID, id, toAccountID string) error
+}
@@ -0,0 +1,268 @@
+package membership
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"time"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+)
+
+// ErrNotFound is used when a Member is not found
+var ErrNotFound = errors.New("Member not found")
+
+// ErrInvalidRole is used when a Member role is not one of the known roles
+var ErrInvalidRole = errors.New("invalid Member role")
+
+// ErrInvalidEmail is used when a Member email is not a valid address
+var ErrInvalidEmail = errors.New("invalid Member email")
+
+// ErrAlreadyMember is used when inviting an email that already is a member of the account
+var ErrAlreadyMember = errors.New("email already is a member of the account")
+
+// ErrLastOwner is used when a change would leave an account without any owner
+var ErrLastOwner = errors.New("an account must keep at least one owner")
+
+// Service is the membership service interface
+type Service interface {
+	GetMember(ctx context.Context, accountID, id string) (*Member, error)
+	GetMembers(ctx context.Context, accountID string) ([]*Member, error)
+	InviteMember(ctx context.Context, accountID string, m Member) (*Member, error)
+	AddMember(ctx context.Context, accountID string, m Member) (*Member, error)
+	ChangeRole(ctx context.Context, accountID, id, role string) error
+	RemoveMember(ctx context.Context, accountID, id string) error
+	Authorize(ctx context.Context, accountID, role string) error
+}
+
+type service struct {
+	accounts   account.Service
+	repository MemberRepository
+}
+
+// NewService return a new instance of the membership service
+func NewService(accounts account.Service, r MemberRepository) Service {
+	return service{
+		accounts:   accounts,
+		repository: r,
+	}
+}
+
+// GetMember returns a Member of an account to its members
+func (s service) GetMember(ctx context.Context, accountID, id string) (*Member, error) {
+	if err := s.Authorize(ctx, accountID, RoleMember); err != nil {
+		return nil, err
+	}
+
+	return s.getMember(ctx, accountID, id)
+}
+
+func (s service) getMember(ctx context.Context, accountID, id string) (m *Member, err error) {
+	m, err = s.repository.GetMember(ctx, accountID, id)
+
+	if m == nil && err == nil {
+		err = ErrNotFound
+	}
+
+	return
+}
+
+// GetMembers returns the Members of an account to its members, invited ones included
+func (s service) GetMembers(ctx context.Context, accountID string) ([]*Member, error) {
+	if err := s.Authorize(ctx, accountID, RoleMember); err != nil {
+		return nil, err
+	}
+
+	return s.repository.GetMembers(ctx, accountID)
+}
+
+// InviteMember invites an email to join an account with a role, as a member by default. Its
+// admins invite, only its owners invite owners. The Member joins with an invitation token,
+// see AddMember.
+func (s service) InviteMember(ctx context.Context, accountID string, m Member) (*Member, error) {
+	if err := s.Authorize(ctx, accountID, managerRole(m.Role)); err != nil {
+		return nil, err
+	}
+
+	m.Status = StatusInvited
+	m.InvitedAt = time.Now().UTC().Truncate(time.Millisecond)
+	m.JoinedAt = nil
+
+	return s.add(ctx, accountID, m)
+}
+
+// AddMember adds an already active Member to an account, as a member by default. A Member
+// invited with the email joins with the role given here. It is not authorized: it is called
+// once the invitation token of the Member is accepted, see invitation.Service.
+func (s service) AddMember(ctx context.Context, accountID string, m Member) (*Member, error) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	m.Status = StatusActive
+	m.InvitedAt = now
+	m.JoinedAt = &now
+
+	added, err := s.add(ctx, accountID, m)
+	if err != ErrAlreadyMember {
+		return added, err
+	}
+
+	members, err := s.repository.GetMembers(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	for _, invited := range members {
+		if invited.Email != m.Email || invited.Status != StatusInvited {
+			continue
+		}
+
+		m.ID = invited.ID
+		m.AccountID = accountID
+		m.InvitedAt = invited.InvitedAt
+		if len(m.Role) == 0 {
+			m.Role = invited.Role
+		}
+		if err := s.repository.UpdateMember(ctx, m); err != nil {
+			return nil, err
+		}
+		return &m, nil
+	}
+
+	return nil, ErrAlreadyMember
+}
+
+// add validates and stores a new Member of an account
+func (s service) add(ctx context.Context, accountID string, m Member) (*Member, error) {
+	if len(m.Role) == 0 {
+		m.Role = RoleMember
+	}
+	if err := ValidateRole(m.Role); err != nil {
+		return nil, err
+	}
+	if err := ValidateEmail(m.Email); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.accounts.GetAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+
+	m.AccountID = accountID
+	id, err := s.repository.AddMember(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	m.ID = id
+
+	return &m, nil
+}
+
+// ChangeRole changes the role of a Member, the last owner of an account can not be demoted.
+// Its admins change the roles, only its owners change the ones of owners or to owner.
+func (s service) ChangeRole(ctx context.Context, accountID, id, role string) error {
+	if err := ValidateRole(role); err != nil {
+		return err
+	}
+	if err := s.Authorize(ctx, accountID, RoleAdmin); err != nil {
+		return err
+	}
+
+	m, err := s.getMember(ctx, accountID, id)
+	if err != nil {
+		return err
+	}
+
+	if m.Role == role {
+		return nil
+	}
+	if err := s.Authorize(ctx, accountID, managerRole(m.Role, role)); err != nil {
+		return err
+	}
+
+	owner := m.IsOwner()
+	m.Role = role
+	if owner {
+		return s.repository.UpdateOwner(ctx, *m)
+	}
+	return s.repository.UpdateMember(ctx, *m)
+}
+
+// RemoveMember removes a Member from an account, the last owner of an account can not be removed.
+// Its admins remove the members, only its owners remove owners.
+func (s service) RemoveMember(ctx context.Context, accountID, id string) error {
+	if err := s.Authorize(ctx, accountID, RoleAdmin); err != nil {
+		return err
+	}
+
+	m, err := s.getMember(ctx, accountID, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Authorize(ctx, accountID, managerRole(m.Role)); err != nil {
+		return err
+	}
+
+	if m.IsOwner() {
+		return s.repository.RemoveOwner(ctx, accountID, id)
+	}
+	return s.repository.RemoveMember(ctx, accountID, id)
+}
+
+// Authorize returns nil when the principal of the context manages the account
+// with at least the role: the account itself and the admin do, see
+// auth.AuthorizeAccount, and so does a session of an active Member of the
+// account with the role or a higher one.
+func (s service) Authorize(ctx context.Context, accountID, role string) error {
+	err := auth.AuthorizeAccount(ctx, accountID)
+	if err != auth.ErrForbidden {
+		return err
+	}
+
+	p := auth.PrincipalFromContext(ctx)
+	if len(p.SessionID) == 0 {
+		return err
+	}
+
+	members, err := s.repository.GetMembers(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if m.UserID == p.AccountID && m.Status == StatusActive && roleRank(m.Role) >= roleRank(role) {
+			return nil
+		}
+	}
+
+	return auth.ErrForbidden
+}
+
+// managerRole returns the role managing the Members with the roles: owners
+// manage the owners, and admins everyone else
+func managerRole(roles ...string) string {
+	for _, r := range roles {
+		if r == RoleOwner {
+			return RoleOwner
+		}
+	}
+	return RoleAdmin
+}
+
+// ValidateRole returns ErrInvalidRole unless role is one of the known roles
+func ValidateRole(role string) error {
+	for _, r := range Roles {
+		if r == role {
+			return nil
+		}
+	}
+	return ErrInvalidRole
+}
+
+// ValidateEmail returns ErrInvalidEmail unless email is a valid address
+func ValidateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return ErrInvalidEmail
+	}
+	return nil
+}
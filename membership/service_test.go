@@ -0,0 +1,153 @@
+package membership
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/auth"
+)
+
+// adminContext is the context of a request of the admin
+var adminContext = auth.ContextWithPrincipal(context.Background(), &auth.Principal{Admin: true})
+
+// sessionContext returns the context of a session of an account
+func sessionContext(accountID string) context.Context {
+	return auth.ContextWithPrincipal(context.Background(), &auth.Principal{AccountID: accountID, SessionID: "s"})
+}
+
+// withOwner returns a service on an account "1" having an active owner, and the owner id
+func withOwner(t *testing.T) (Service, string) {
+	svc := NewService(fakeAccounts{}, NewMemoryRepository())
+
+	m, err := svc.AddMember(context.Background(), "1", Member{Email: "owner@example.com", Role: RoleOwner})
+	assert.Nil(t, err)
+
+	return svc, m.ID
+}
+
+func Test_InviteMember_Should_Add_An_Invited_Member(t *testing.T) {
+	svc := NewService(fakeAccounts{}, NewMemoryRepository())
+
+	m, err := svc.InviteMember(adminContext, "1", Member{Email: "a@example.com"})
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, m.ID)
+	assert.Equal(t, RoleMember, m.Role)
+	assert.Equal(t, StatusInvited, m.Status)
+	assert.Nil(t, m.JoinedAt)
+}
+
+func Test_InviteMember_Should_Validate_The_Member(t *testing.T) {
+	var flagtests = []struct {
+		accountID string
+		member    Member
+		err       error
+	}{
+		{"1", Member{Email: "a@example.com", Role: "guest"}, ErrInvalidRole},
+		{"1", Member{Email: "not an email"}, ErrInvalidEmail},
+		{"2", Member{Email: "a@example.com"}, account.ErrNotFound},
+	}
+
+	for _, tt := range flagtests {
+		svc := NewService(fakeAccounts{}, NewMemoryRepository())
+		_, err := svc.InviteMember(adminContext, tt.accountID, tt.member)
+
+		assert.Equal(t, tt.err, err)
+	}
+}
+
+func Test_AddMember_Should_Activate_An_Invited_Member_Once(t *testing.T) {
+	svc, _ := withOwner(t)
+	invited, _ := svc.InviteMember(adminContext, "1", Member{Email: "a@example.com", Role: RoleAdmin})
+
+	m, err := svc.AddMember(context.Background(), "1", Member{UserID: "7", Email: "a@example.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, invited.ID, m.ID)
+	assert.Equal(t, RoleAdmin, m.Role)
+
+	m, err = svc.GetMember(adminContext, "1", invited.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusActive, m.Status)
+	assert.Equal(t, "7", m.UserID)
+	assert.NotNil(t, m.JoinedAt)
+
+	_, err = svc.AddMember(context.Background(), "1", Member{UserID: "7", Email: "a@example.com"})
+	assert.Equal(t, ErrAlreadyMember, err)
+}
+
+func Test_The_Last_Owner_Can_Not_Be_Demoted_Or_Removed(t *testing.T) {
+	svc, id := withOwner(t)
+
+	// an invited owner does not count until it accepts
+	invited, _ := svc.InviteMember(adminContext, "1", Member{Email: "b@example.com", Role: RoleOwner})
+
+	assert.Equal(t, ErrLastOwner, svc.ChangeRole(adminContext, "1", id, RoleAdmin))
+	assert.Equal(t, ErrLastOwner, svc.RemoveMember(adminContext, "1", id))
+
+	_, err := svc.AddMember(context.Background(), "1", Member{Email: "b@example.com", Role: RoleOwner})
+	assert.Nil(t, err)
+
+	assert.Nil(t, svc.ChangeRole(adminContext, "1", id, RoleAdmin))
+	assert.Equal(t, ErrLastOwner, svc.RemoveMember(adminContext, "1", invited.ID))
+	assert.Nil(t, svc.RemoveMember(adminContext, "1", id))
+}
+
+func Test_ChangeRole_Should_Return_ErrInvalidRole(t *testing.T) {
+	svc, id := withOwner(t)
+
+	assert.Equal(t, ErrInvalidRole, svc.ChangeRole(adminContext, "1", id, "guest"))
+}
+
+func Test_AddMember_Should_Add_An_Active_Member(t *testing.T) {
+	svc := NewService(fakeAccounts{}, NewMemoryRepository())
+
+	m, err := svc.AddMember(context.Background(), "1", Member{UserID: "2", Email: "a@example.com", Role: RoleAdmin})
+
+	assert.Nil(t, err)
+	assert.Equal(t, StatusActive, m.Status)
+	assert.Equal(t, "2", m.UserID)
+	assert.NotNil(t, m.JoinedAt)
+}
+
+func Test_Authorize_Should_Rank_The_Roles_Of_The_Members(t *testing.T) {
+	svc := NewService(fakeAccounts{}, NewMemoryRepository())
+	svc.AddMember(context.Background(), "1", Member{UserID: "10", Email: "owner@example.com", Role: RoleOwner})
+	svc.AddMember(context.Background(), "1", Member{UserID: "11", Email: "admin@example.com", Role: RoleAdmin})
+	svc.InviteMember(adminContext, "1", Member{UserID: "12", Email: "invited@example.com", Role: RoleOwner})
+
+	var flagtests = []struct {
+		ctx  context.Context
+		role string
+		err  error
+	}{
+		{context.Background(), RoleMember, auth.ErrUnauthenticated},
+		{adminContext, RoleOwner, nil},
+		{sessionContext("1"), RoleOwner, nil},
+		{sessionContext("10"), RoleOwner, nil},
+		{sessionContext("11"), RoleAdmin, nil},
+		{sessionContext("11"), RoleOwner, auth.ErrForbidden},
+		{sessionContext("12"), RoleMember, auth.ErrForbidden},
+		{auth.ContextWithPrincipal(context.Background(), &auth.Principal{AccountID: "10", KeyID: "k"}), RoleMember, auth.ErrForbidden},
+	}
+
+	for _, tt := range flagtests {
+		assert.Equal(t, tt.err, svc.Authorize(tt.ctx, "1", tt.role))
+	}
+}
+
+func Test_Only_Owners_Should_Manage_Owners(t *testing.T) {
+	svc, id := withOwner(t)
+	svc.AddMember(context.Background(), "1", Member{UserID: "11", Email: "admin@example.com", Role: RoleAdmin})
+	m, _ := svc.InviteMember(adminContext, "1", Member{Email: "a@example.com"})
+
+	assert.Equal(t, auth.ErrForbidden, svc.ChangeRole(sessionContext("11"), "1", id, RoleMember))
+	assert.Equal(t, auth.ErrForbidden, svc.RemoveMember(sessionContext("11"), "1", id))
+	assert.Equal(t, auth.ErrForbidden, svc.ChangeRole(sessionContext("11"), "1", m.ID, RoleOwner))
+	_, err := svc.InviteMember(sessionContext("11"), "1", Member{Email: "b@example.com", Role: RoleOwner})
+	assert.Equal(t, auth.ErrForbidden, err)
+
+	assert.Nil(t, svc.ChangeRole(sessionContext("11"), "1", m.ID, RoleAdmin))
+	assert.Nil(t, svc.RemoveMember(sessionContext("11"), "1", m.ID))
+}
@@ -2,26 +2,96 @@ package mongoDb
 
 import (
 	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/go-kit/kit/log"
 	mgo "gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
+	"gopkg.in/mgo.v2/txn"
 
 	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/membership"
 )
 
-const emailExpirationHours = 24
-
 type accountRepository struct {
-	session *mgo.Session
+	session   *mgo.Session
+	retention int
+	logger    log.Logger
+}
+
+// accountDocument is an account as stored, with the mongo id needed by transactions
+type accountDocument struct {
+	ID              bson.ObjectId `bson:"_id"`
+	account.Account `bson:",inline"`
+}
+
+// accountFields is what is written for an account: the account, its labels
+// flattened as "key=value" pairs and keys, so that selectors are served by
+// multikey indexes whatever the label keys are, and its search terms
+type accountFields struct {
+	account.Account `bson:",inline"`
+	LabelPairs      []string `bson:"label_pairs,omitempty"`
+	LabelKeys       []string `bson:"label_keys,omitempty"`
+	SearchTerms     []string `bson:"search_terms,omitempty"`
+}
+
+func newAccountFields(a account.Account) accountFields {
+	f := accountFields{Account: a, SearchTerms: account.AccountSearchTerms(&a)}
+	for k, v := range a.Labels {
+		f.LabelPairs = append(f.LabelPairs, labelPair(k, v))
+		f.LabelKeys = append(f.LabelKeys, k)
+	}
+	sort.Strings(f.LabelPairs)
+	sort.Strings(f.LabelKeys)
+
+	return f
+}
+
+func labelPair(key, value string) string {
+	return key + "=" + value
+}
+
+// accountEmailDocument claims an email for an account: keyed by the email, and
+// written in the transaction of the account, it keeps the emails unique
+type accountEmailDocument struct {
+	Email     string `bson:"_id"`
+	AccountID string `bson:"account_id"`
+}
+
+// accountIDDocument claims an account id: keyed by the id, and inserted in
+// the transaction creating the account, it keeps an id from being created
+// twice. It is kept once the account is deleted, its versions still use the id.
+type accountIDDocument struct {
+	AccountID string `bson:"_id"`
+}
+
+// accountVersionDocument is the snapshot of an account at one of its versions,
+// a deleted account gets a last snapshot flagged as deleted
+type accountVersionDocument struct {
+	ID        bson.ObjectId   `bson:"_id"`
+	AccountID string          `bson:"account_id"`
+	Version   int             `bson:"version"`
+	At        time.Time       `bson:"at"`
+	Deleted   bool            `bson:"deleted,omitempty"`
+	Account   account.Account `bson:"account"`
 }
 
-// NewAccountRepository creates a new instance of a legacy account repository
-func NewAccountRepository(s *mgo.Session) (account.Repository, error) {
+// NewAccountRepository creates a new instance of a legacy account repository.
+// Every write is run in a transaction that also inserts the matching event in
+// the outbox, see NewOutboxStore, its audit entry, see NewAuditLog, and a
+// snapshot of the account. Only the last
+// retention snapshots of an account are kept, 0 keeps them all.
+func NewAccountRepository(s *mgo.Session, retention int, logger log.Logger) (account.Repository, error) {
 
 	err := ensureIndex(s)
 
 	return accountRepository{
-		session: s,
+		session:   s,
+		retention: retention,
+		logger:    logger,
 	}, err
 }
 
@@ -38,7 +108,159 @@ func ensureIndex(s *mgo.Session) error {
 		Background: true,
 		Sparse:     true,
 	}
-	return c.EnsureIndex(index)
+	if err := c.EnsureIndex(index); err != nil {
+		return err
+	}
+
+	if err := c.EnsureIndex(mgo.Index{
+		Key:        []string{"email"},
+		Background: true,
+		Sparse:     true,
+	}); err != nil {
+		return err
+	}
+
+	if err := migrate(session, "merged_emails", releaseMergedEmails); err != nil {
+		return err
+	}
+	if err := migrate(session, "account_emails", backfillAccountEmails); err != nil {
+		return err
+	}
+	if err := migrate(session, "search_terms", backfillSearchTerms); err != nil {
+		return err
+	}
+	if err := migrate(session, "account_ids", backfillAccountIDs); err != nil {
+		return err
+	}
+
+	if err := c.EnsureIndex(mgo.Index{
+		Key:        []string{"parent_id"},
+		Background: true,
+		Sparse:     true,
+	}); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"label_pairs", "label_keys", "tenant_id", "search_terms"} {
+		if err := c.EnsureIndex(mgo.Index{
+			Key:        []string{key},
+			Background: true,
+			Sparse:     true,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// whole words are found with the text index, their prefixes with search_terms
+	if err := c.EnsureIndex(mgo.Index{
+		Key:        []string{"$text:name", "$text:email"},
+		Weights:    map[string]int{"name": 2, "email": 1},
+		Name:       "search",
+		Background: true,
+	}); err != nil {
+		return err
+	}
+
+	versions := session.DB("store").C("account_versions")
+
+	if err := versions.EnsureIndex(mgo.Index{
+		Key:        []string{"account_id", "version"},
+		Unique:     true,
+		Background: true,
+	}); err != nil {
+		return err
+	}
+
+	return versions.EnsureIndex(mgo.Index{
+		Key:        []string{"account_id", "at"},
+		Background: true,
+	})
+}
+
+// backfillAccountEmails claims the email of the accounts written before the
+// emails were unique, the oldest account using an email keeps it
+func backfillAccountEmails(db *mgo.Database) error {
+	iter := db.C("accounts").Find(bson.M{"email": bson.M{"$gt": ""}}).Select(bson.M{"account_id": 1, "email": 1}).Sort("_id").Iter()
+
+	var a account.Account
+	for iter.Next(&a) {
+		err := db.C("account_emails").Insert(accountEmailDocument{Email: a.Email, AccountID: a.AccountID})
+		if err != nil && !mgo.IsDup(err) {
+			iter.Close()
+			return err
+		}
+	}
+
+	return iter.Close()
+}
+
+// backfillAccountIDs claims the id of the accounts created before their ids were claimed
+func backfillAccountIDs(db *mgo.Database) error {
+	iter := db.C("accounts").Find(nil).Select(bson.M{"account_id": 1}).Iter()
+
+	var a account.Account
+	for iter.Next(&a) {
+		err := db.C("account_ids").Insert(accountIDDocument{AccountID: a.AccountID})
+		if err != nil && !mgo.IsDup(err) {
+			iter.Close()
+			return err
+		}
+	}
+
+	return iter.Close()
+}
+
+// backfillSearchTerms sets the search terms of the accounts written before
+// they were searched by prefix
+func backfillSearchTerms(db *mgo.Database) error {
+	query := bson.M{"search_terms": bson.M{"$exists": false}, "$or": []bson.M{{"name": bson.M{"$gt": ""}}, {"email": bson.M{"$gt": ""}}}}
+	iter := db.C("accounts").Find(query).Select(bson.M{"account_id": 1, "name": 1, "email": 1}).Iter()
+
+	// the account is reset between documents, a field one of them lacks is not decoded
+	for a := (account.Account{}); iter.Next(&a); a = (account.Account{}) {
+		terms := account.AccountSearchTerms(&a)
+		if len(terms) == 0 {
+			continue
+		}
+
+		err := db.C("accounts").Update(bson.M{"account_id": a.AccountID}, bson.M{"$set": bson.M{"search_terms": terms}})
+		if err != nil && err != mgo.ErrNotFound {
+			iter.Close()
+			return err
+		}
+	}
+
+	return iter.Close()
+}
+
+// releaseMergedEmails removes the email of the accounts merged before their
+// tombstones dropped it, with their claim on it
+func releaseMergedEmails(db *mgo.Database) error {
+	iter := db.C("accounts").Find(bson.M{"status": account.StatusMerged, "email": bson.M{"$gt": ""}}).Iter()
+
+	// the account is reset between documents, a field one of them lacks is not decoded
+	for a := (account.Account{}); iter.Next(&a); a = (account.Account{}) {
+		err := db.C("account_emails").Remove(bson.M{"_id": a.Email, "account_id": a.AccountID})
+		if err != nil && err != mgo.ErrNotFound {
+			iter.Close()
+			return err
+		}
+
+		a.Email, a.EmailVerified = "", false
+		update := bson.M{"$unset": bson.M{"email": "", "email_verified": ""}}
+		if terms := account.AccountSearchTerms(&a); len(terms) > 0 {
+			update["$set"] = bson.M{"search_terms": terms}
+		} else {
+			update["$unset"].(bson.M)["search_terms"] = ""
+		}
+
+		if err := db.C("accounts").Update(bson.M{"account_id": a.AccountID}, update); err != nil && err != mgo.ErrNotFound {
+			iter.Close()
+			return err
+		}
+	}
+
+	return iter.Close()
 }
 
 // Getaccount ...
@@ -52,38 +274,324 @@ func (r accountRepository) GetAccount(ctx context.Context, id string) (a *accoun
 	return
 }
 
-// Getaccounts ...
-func (r accountRepository) GetAccounts(ctx context.Context, filter account.Filter, pagination account.Pagination) (accounts []*account.Account, err error) {
+// Getaccounts returns a page of the accounts in insertion order, the total is counted
+// with the same query only when it is asked for
+func (r accountRepository) GetAccounts(ctx context.Context, filter account.Filter, pagination account.Pagination) (accounts []*account.Account, total int, err error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("accounts")
+	query := filterQuery(filter)
+
+	err = c.Find(query).Select(projection(filter.Fields)).Sort("_id").Skip(pagination.Page * pagination.Size).Limit(pagination.Size).All(&accounts)
+	if err != nil || !pagination.Count {
+		return
+	}
+
+	total, err = c.Find(query).Count()
+
+	return
+}
+
+// Exportaccounts iterates a cursor over the matching accounts so only one is held in memory at a time
+func (r accountRepository) ExportAccounts(ctx context.Context, filter account.Filter, fn func(*account.Account) error) error {
 	session := r.session.Copy()
 	defer session.Close()
 
 	c := session.DB("store").C("accounts")
 
+	iter := c.Find(filterQuery(filter)).Select(projection(filter.Fields)).Iter()
+
+	for {
+		a := new(account.Account)
+		if !iter.Next(a) {
+			break
+		}
+
+		if err := fn(a); err != nil {
+			iter.Close()
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+
+	return iter.Close()
+}
+
+func filterQuery(filter account.Filter) bson.M {
 	m := bson.M{}
 
 	if len(filter.IDs) > 0 {
-		m = bson.M{"account_id": bson.M{"$in": filter.IDs}}
+		m["account_id"] = bson.M{"$in": filter.IDs}
+	}
+	if len(filter.Emails) > 0 {
+		m["email"] = bson.M{"$in": filter.Emails}
+	}
+	if len(filter.ParentIDs) > 0 {
+		m["parent_id"] = bson.M{"$in": filter.ParentIDs}
+	}
+	if len(filter.TenantIDs) > 0 {
+		m["tenant_id"] = bson.M{"$in": filter.TenantIDs}
+	}
+	// the names of the custom fields are checked against the schema of the tenant by the service
+	for field, value := range filter.Custom {
+		m["custom."+field] = value
+	}
+	if len(filter.Selector) > 0 {
+		m["$and"] = selectorQuery(filter.Selector)
+	}
+
+	return m
+}
+
+// projection returns the fields to read, all of them when none is given.
+// The fields of an account are named the same in json and in storage.
+func projection(fields []string) bson.M {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	m := bson.M{"account_id": 1}
+	for _, field := range fields {
+		m[field] = 1
+	}
+
+	return m
+}
+
+// selectorQuery returns one condition per requirement of the selector, on the
+// flattened labels of the accounts
+func selectorQuery(selector account.Selector) []bson.M {
+	var and []bson.M
+
+	for _, r := range selector {
+		var pairs []string
+		for _, v := range r.Values {
+			pairs = append(pairs, labelPair(r.Key, v))
+		}
+
+		switch r.Operator {
+		case account.OperatorEquals, account.OperatorIn:
+			and = append(and, bson.M{"label_pairs": bson.M{"$in": pairs}})
+		case account.OperatorNotEquals, account.OperatorNotIn:
+			and = append(and, bson.M{"label_pairs": bson.M{"$nin": pairs}})
+		case account.OperatorExists:
+			and = append(and, bson.M{"label_keys": r.Key})
+		case account.OperatorDoesNotExist:
+			and = append(and, bson.M{"label_keys": bson.M{"$ne": r.Key}})
+		}
+	}
+
+	return and
+}
+
+// prefixCandidates is how many times the limit of a search the accounts found
+// by prefix are, they can not be ranked by the store
+const prefixCandidates = 10
+
+// SearchAccounts returns the best limit accounts of the text index, ranked by
+// their text score, and up to prefixCandidates times limit accounts with a
+// search term starting with each term, oldest first. The text index matches
+// stemmed whole words only, the service ranks the candidates of both queries
+// the same way.
+func (r accountRepository) SearchAccounts(ctx context.Context, terms []string, limit int) ([]*account.Account, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("accounts")
+
+	var words []*account.Account
+	err := c.Find(bson.M{"$text": bson.M{"$search": strings.Join(terms, " ")}}).
+		Select(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		Sort("$textScore:score").
+		Limit(limit).
+		All(&words)
+	if err != nil {
+		return nil, err
+	}
+
+	var prefixes []bson.M
+	for _, term := range terms {
+		prefixes = append(prefixes, bson.M{"search_terms": bson.M{"$regex": "^" + regexp.QuoteMeta(term)}})
+	}
+
+	var candidates []*account.Account
+	if err := c.Find(bson.M{"$and": prefixes}).Sort("_id").Limit(limit * prefixCandidates).All(&candidates); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, a := range candidates {
+		seen[a.AccountID] = true
+	}
+	for _, a := range words {
+		if !seen[a.AccountID] {
+			candidates = append(candidates, a)
+		}
 	}
 
-	err = c.Find(m).Skip(pagination.Page).Limit(pagination.Size).All(&accounts)
+	return candidates, nil
+}
+
+// CountAccounts groups the matching accounts in an aggregation pipeline. The
+// accounts created before created_at was recorded are bucketed by the creation
+// time of their document id.
+func (r accountRepository) CountAccounts(ctx context.Context, filter account.Filter, group account.StatsGroup) (counts []account.StatsCount, err error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("accounts")
 
+	pipeline := []bson.M{
+		{"$match": filterQuery(filter)},
+		{"$group": bson.M{"_id": groupKey(group), "count": bson.M{"$sum": 1}}},
+		{"$sort": bson.M{"_id": 1}},
+	}
+
+	err = c.Pipe(pipeline).All(&counts)
 	return
 }
 
+// groupKey returns the expression of the group of an account, see account.StatsGroup.KeyOf
+func groupKey(group account.StatsGroup) interface{} {
+	field := map[string]string{
+		account.GroupByStatus: "$status",
+		account.GroupByTenant: "$tenant_id",
+		account.GroupByLabel:  "$labels." + group.Key,
+	}
+
+	if group.Field != account.GroupByCreated {
+		return bson.M{"$ifNull": []interface{}{field[group.Field], ""}}
+	}
+
+	created := bson.M{"$ifNull": []interface{}{"$created_at", bson.M{"$toDate": "$_id"}}}
+	switch group.Key {
+	case account.IntervalWeek:
+		created = bson.M{"$dateFromParts": bson.M{
+			"isoWeekYear":  bson.M{"$isoWeekYear": created},
+			"isoWeek":      bson.M{"$isoWeek": created},
+			"isoDayOfWeek": 1,
+		}}
+	case account.IntervalMonth:
+		return bson.M{"$dateToString": bson.M{"format": "%Y-%m", "date": created}}
+	}
+
+	return bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": created}}
+}
+
 // Updateaccount ...
 func (r accountRepository) UpdateAccount(ctx context.Context, a account.Account) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	before, err := findAccountDocument(session, a.AccountID)
+	if err != nil {
+		return err
+	}
+
+	ops, e, err := updateOps(ctx, session, before, a)
+	if err != nil {
+		return err
+	}
+
+	if err = runWithOutbox(session, []account.Event{e}, ops...); err != nil {
+		return err
+	}
+
+	r.pruneVersions(session, a.AccountID, a.Version)
 	return nil
 }
 
+// updateOps returns the transaction operations writing a over the stored
+// account, the account is only written over the version it was read at
+func updateOps(ctx context.Context, session *mgo.Session, before accountDocument, a account.Account) ([]txn.Op, account.Event, error) {
+	e := account.AccountUpdated{OccurredAt: time.Now().UTC(), Before: before.Account, After: a}
+	audit, err := auditOp(session, account.NewAuditEntry(ctx, account.OperationUpdate, a.AccountID, &before.Account, &a))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	update := bson.M{"$set": newAccountFields(a)}
+	// empty fields are omitted from $set, so clearing them needs an $unset
+	unset := bson.M{}
+	if len(a.ParentID) == 0 {
+		unset["parent_id"] = ""
+	}
+	if len(a.Labels) == 0 {
+		unset["labels"], unset["label_pairs"], unset["label_keys"] = "", "", ""
+	}
+	if len(a.Annotations) == 0 {
+		unset["annotations"] = ""
+	}
+	if len(a.Name) == 0 && len(a.Email) == 0 {
+		unset["search_terms"] = ""
+	}
+	if len(a.TenantID) == 0 {
+		unset["tenant_id"] = ""
+	}
+	if len(a.Custom) == 0 {
+		unset["custom"] = ""
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	emails, err := emailOps(session, a.AccountID, before.Email, a.Email)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return append([]txn.Op{{
+		C:      "accounts",
+		Id:     before.ID,
+		Assert: versionAssert(a.Version - 1),
+		Update: update,
+	}, versionOp(a.Version, false, a, e), audit}, emails...), e, nil
+}
+
 // Createaccount ...
 func (r accountRepository) CreateAccount(ctx context.Context, a account.Account) (string, error) {
 	session := r.session.Copy()
 	defer session.Close()
 
-	a.AccountID = bson.NewObjectId().Hex()
-	c := session.DB("store").C("accounts")
+	// an imported account keeps the id of its source, its claim keeps a
+	// second import from creating it again
+	id := bson.NewObjectId()
+	if len(a.AccountID) == 0 {
+		a.AccountID = id.Hex()
+	}
+
+	e := account.AccountCreated{OccurredAt: time.Now().UTC(), After: a}
+	audit, err := auditOp(session, account.NewAuditEntry(ctx, account.OperationCreate, a.AccountID, nil, &a))
+	if err != nil {
+		return "", err
+	}
+
+	emails, err := emailOps(session, a.AccountID, "", a.Email)
+	if err != nil {
+		return "", err
+	}
+
+	owner, err := ownerOps(session, account.CreatorFromContext(ctx), a.AccountID)
+	if err != nil {
+		return "", err
+	}
 
-	err := c.Insert(a)
+	err = runWithOutbox(session, []account.Event{e}, append(append([]txn.Op{{
+		C:      "accounts",
+		Id:     id,
+		Assert: txn.DocMissing,
+		Insert: newAccountFields(a),
+	}, {
+		C:      "account_ids",
+		Id:     a.AccountID,
+		Assert: txn.DocMissing,
+		Insert: accountIDDocument{AccountID: a.AccountID},
+	}, versionOp(a.Version, false, a, e), audit}, emails...), owner...)...)
 
 	return a.AccountID, err
 }
@@ -93,7 +601,321 @@ func (r accountRepository) DeleteAccount(ctx context.Context, id string) error {
 	session := r.session.Copy()
 	defer session.Close()
 
+	before, err := findAccountDocument(session, id)
+	if err != nil {
+		return err
+	}
+
+	ops, e, err := deleteOps(ctx, session, before, before.Version)
+	if err != nil {
+		return err
+	}
+
+	if err = runWithOutbox(session, []account.Event{e}, ops...); err != nil {
+		return err
+	}
+
+	r.pruneVersions(session, id, before.Version+1)
+	return nil
+}
+
+// deleteOps returns the transaction operations removing the stored account
+// when it still is at the given version
+func deleteOps(ctx context.Context, session *mgo.Session, before accountDocument, version int) ([]txn.Op, account.Event, error) {
+	e := account.AccountDeleted{OccurredAt: time.Now().UTC(), Before: before.Account}
+	audit, err := auditOp(session, account.NewAuditEntry(ctx, account.OperationDelete, before.AccountID, &before.Account, nil))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	emails, err := emailOps(session, before.AccountID, before.Email, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return append([]txn.Op{{
+		C:      "accounts",
+		Id:     before.ID,
+		Assert: versionAssert(version),
+		Remove: true,
+	}, versionOp(version+1, true, before.Account, e), audit}, emails...), e, nil
+}
+
+// WriteAccounts applies the writes in a single transaction, which also asserts
+// the version of the unchanged accounts that are not written
+func (r accountRepository) WriteAccounts(ctx context.Context, writes []account.Write, unchanged []account.Account) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	var ops []txn.Op
+	var events []account.Event
+	written := map[string]bool{}
+	for _, w := range writes {
+		before, err := findAccountDocument(session, w.Before.AccountID)
+		if err != nil {
+			return err
+		}
+
+		var write []txn.Op
+		var e account.Event
+		if w.After == nil {
+			write, e, err = deleteOps(ctx, session, before, w.Before.Version)
+		} else {
+			write, e, err = updateOps(ctx, session, before, *w.After)
+		}
+		if err != nil {
+			return err
+		}
+
+		ops = append(ops, write...)
+		events = append(events, e)
+		written[w.Before.AccountID] = true
+	}
+
+	for _, a := range unchanged {
+		if written[a.AccountID] {
+			continue
+		}
+
+		doc, err := findAccountDocument(session, a.AccountID)
+		if err == account.ErrNotFound {
+			return account.ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+		ops = append(ops, txn.Op{C: "accounts", Id: doc.ID, Assert: versionAssert(a.Version)})
+	}
+
+	if err := runWithOutbox(session, events, ops...); err != nil {
+		return err
+	}
+
+	for _, w := range writes {
+		r.pruneVersions(session, w.Before.AccountID, w.Before.Version+1)
+	}
+	return nil
+}
+
+// GetAccountVersion ...
+func (r accountRepository) GetAccountVersion(ctx context.Context, id string, version int) (*account.Account, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	return findVersion(session, bson.M{"account_id": id, "version": version})
+}
+
+// GetAccountAsOf ...
+func (r accountRepository) GetAccountAsOf(ctx context.Context, id string, at time.Time) (*account.Account, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	return findVersion(session, bson.M{"account_id": id, "at": bson.M{"$lte": at}})
+}
+
+// findVersion returns the newest snapshot matching the query, nil when there is none or the account was deleted
+func findVersion(session *mgo.Session, query bson.M) (*account.Account, error) {
+	c := session.DB("store").C("account_versions")
+
+	var doc accountVersionDocument
+	err := c.Find(query).Sort("-version").One(&doc)
+	if err == mgo.ErrNotFound || (err == nil && doc.Deleted) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &doc.Account, nil
+}
+
+// versionOp returns the transaction operation inserting the snapshot of a at the given version
+func versionOp(version int, deleted bool, a account.Account, e account.Event) txn.Op {
+	doc := accountVersionDocument{
+		ID:        bson.NewObjectId(),
+		AccountID: a.AccountID,
+		Version:   version,
+		At:        e.Envelope().OccurredAt,
+		Deleted:   deleted,
+		Account:   a,
+	}
+
+	return txn.Op{
+		C:      "account_versions",
+		Id:     doc.ID,
+		Assert: txn.DocMissing,
+		Insert: doc,
+	}
+}
+
+// ownerOps returns the transaction operations making the creator of an account
+// its active owner member, none without a creator still stored
+func ownerOps(session *mgo.Session, creator, accountID string) ([]txn.Op, error) {
+	if len(creator) == 0 {
+		return nil, nil
+	}
+
+	var c account.Account
+	err := session.DB("store").C("accounts").Find(bson.M{"account_id": creator}).Select(bson.M{"email": 1}).One(&c)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	return []txn.Op{{
+		C:      "members",
+		Id:     bson.NewObjectId(),
+		Assert: txn.DocMissing,
+		Insert: membership.Member{
+			ID:        bson.NewObjectId().Hex(),
+			AccountID: accountID,
+			UserID:    creator,
+			Email:     c.Email,
+			Role:      membership.RoleOwner,
+			Status:    membership.StatusActive,
+			InvitedAt: now,
+			JoinedAt:  &now,
+		},
+	}}, nil
+}
+
+// emailOps returns the transaction operations moving the claim of an account
+// from its email before to the one after, the claim of another account on the
+// email after makes the transaction abort. An email claimed by another account
+// before the emails were unique is left to it.
+func emailOps(session *mgo.Session, id string, before, after string) ([]txn.Op, error) {
+	if before == after {
+		return nil, nil
+	}
+
+	var ops []txn.Op
+	if len(before) > 0 {
+		var claim accountEmailDocument
+		err := session.DB("store").C("account_emails").FindId(before).One(&claim)
+		if err != nil && err != mgo.ErrNotFound {
+			return nil, err
+		}
+		if err == nil && claim.AccountID == id {
+			ops = append(ops, txn.Op{
+				C:      "account_emails",
+				Id:     before,
+				Assert: bson.M{"account_id": id},
+				Remove: true,
+			})
+		}
+	}
+	if len(after) > 0 {
+		ops = append(ops, txn.Op{
+			C:      "account_emails",
+			Id:     after,
+			Assert: txn.DocMissing,
+			Insert: accountEmailDocument{Email: after, AccountID: id},
+		})
+	}
+
+	return ops, nil
+}
+
+// versionAssert is the assertion of a transaction writing over a version of
+// an account, the accounts written before they were versioned have none
+func versionAssert(version int) bson.M {
+	if version == 0 {
+		return bson.M{"version": bson.M{"$in": []interface{}{0, nil}}}
+	}
+	return bson.M{"version": version}
+}
+
+// pruneVersions removes the snapshots of an account falling out of the retention.
+// The write they belong to is already committed, so a failure is only logged:
+// the removal is done again on the next write of the account.
+func (r accountRepository) pruneVersions(session *mgo.Session, id string, version int) {
+	if r.retention <= 0 || version <= r.retention {
+		return
+	}
+
+	c := session.DB("store").C("account_versions")
+	if _, err := c.RemoveAll(bson.M{"account_id": id, "version": bson.M{"$lte": version - r.retention}}); err != nil {
+		r.logger.Log("account_id", id, "prune_versions_error", err)
+	}
+}
+
+func findAccountDocument(session *mgo.Session, id string) (doc accountDocument, err error) {
 	c := session.DB("store").C("accounts")
 
-	return c.Remove(bson.M{"account_id": id})
+	err = c.Find(bson.M{"account_id": id}).One(&doc)
+	if err == mgo.ErrNotFound {
+		err = account.ErrNotFound
+	}
+
+	return
+}
+
+// runWithOutbox applies ops and inserts the outbox records of the events in the
+// same transaction, the first op is the one writing the first account. When the
+// transaction aborts, the id of a created account or an email was claimed by
+// another account, or the first account was removed or an account written by
+// someone else in the meantime.
+func runWithOutbox(session *mgo.Session, events []account.Event, ops ...txn.Op) error {
+	now := time.Now().UTC()
+	for _, e := range events {
+		// the event of a merge is relayed to the merge handlers too
+		collections := []string{"outbox"}
+		if _, _, ok := account.MergeOf(e); ok {
+			collections = append(collections, "merge_outbox")
+		}
+
+		for _, c := range collections {
+			record := outboxDocument{
+				ID:          bson.NewObjectId(),
+				Event:       e.Envelope(),
+				CreatedAt:   now,
+				NextAttempt: now,
+			}
+			ops = append(ops, txn.Op{
+				C:      c,
+				Id:     record.ID,
+				Assert: txn.DocMissing,
+				Insert: record,
+			})
+		}
+	}
+
+	runner := txn.NewRunner(session.DB("store").C("txns"))
+	err := runner.Run(ops, "", nil)
+
+	if err != txn.ErrAborted {
+		return err
+	}
+
+	// the claims are inserted in the order of the ops, the one of the account id first
+	for _, op := range ops {
+		if op.Insert == nil || (op.C != "account_ids" && op.C != "account_emails") {
+			continue
+		}
+		n, err := session.DB("store").C(op.C).FindId(op.Id).Count()
+		if err != nil {
+			return err
+		}
+		if n > 0 && op.C == "account_ids" {
+			return account.ErrConflict
+		}
+		if n > 0 {
+			return account.ErrEmailTaken
+		}
+	}
+
+	if ops[0].Insert != nil {
+		return err
+	}
+	n, err := session.DB("store").C("accounts").FindId(ops[0].Id).Count()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return account.ErrConflict
+	}
+	return account.ErrNotFound
 }
@@ -0,0 +1,142 @@
+package mongoDb
+
+import (
+	"context"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/tkanos/go-rest-api-sample/apikey"
+)
+
+type apiKeyRepository struct {
+	session *mgo.Session
+}
+
+// NewAPIKeyRepository creates a new instance of the API keys repository
+func NewAPIKeyRepository(s *mgo.Session) (apikey.Repository, error) {
+	session := s.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("api_keys")
+
+	err := c.EnsureIndex(mgo.Index{
+		Key:        []string{"prefix"},
+		Unique:     true,
+		Background: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.EnsureIndex(mgo.Index{
+		Key:        []string{"account_id", "id"},
+		Unique:     true,
+		Background: true,
+	})
+
+	return apiKeyRepository{
+		session: s,
+	}, err
+}
+
+// CreateKey ...
+func (r apiKeyRepository) CreateKey(ctx context.Context, k apikey.Key) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("api_keys")
+
+	return c.Insert(k)
+}
+
+// GetKey ...
+func (r apiKeyRepository) GetKey(ctx context.Context, accountID, id string) (*apikey.Key, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	return findKey(session, bson.M{"account_id": accountID, "id": id})
+}
+
+// GetKeys ...
+func (r apiKeyRepository) GetKeys(ctx context.Context, accountID string) (keys []*apikey.Key, err error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("api_keys")
+
+	keys = []*apikey.Key{}
+	err = c.Find(bson.M{"account_id": accountID}).Sort("created_at").All(&keys)
+
+	return
+}
+
+// GetKeyByPrefix ...
+func (r apiKeyRepository) GetKeyByPrefix(ctx context.Context, prefix string) (*apikey.Key, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	return findKey(session, bson.M{"prefix": prefix})
+}
+
+// RotateKey ...
+func (r apiKeyRepository) RotateKey(ctx context.Context, accountID, id, prefix, hash string, at time.Time) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("api_keys")
+
+	err := c.Update(bson.M{"account_id": accountID, "id": id, "revoked_at": nil}, bson.M{"$set": bson.M{
+		"prefix":     prefix,
+		"hash":       hash,
+		"rotated_at": at,
+	}})
+	if err == mgo.ErrNotFound {
+		return apikey.ErrNotFound
+	}
+
+	return err
+}
+
+// RevokeKey ...
+func (r apiKeyRepository) RevokeKey(ctx context.Context, accountID, id string, at time.Time) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("api_keys")
+
+	err := c.Update(bson.M{"account_id": accountID, "id": id, "revoked_at": nil}, bson.M{"$set": bson.M{"revoked_at": at}})
+	if err == mgo.ErrNotFound {
+		return apikey.ErrNotFound
+	}
+
+	return err
+}
+
+// MoveKeys ...
+func (r apiKeyRepository) MoveKeys(ctx context.Context, accountID, toAccountID string) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("api_keys")
+
+	_, err := c.UpdateAll(bson.M{"account_id": accountID}, bson.M{"$set": bson.M{"account_id": toAccountID}})
+
+	return err
+}
+
+func findKey(session *mgo.Session, query bson.M) (*apikey.Key, error) {
+	c := session.DB("store").C("api_keys")
+
+	var k apikey.Key
+	err := c.Find(query).One(&k)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
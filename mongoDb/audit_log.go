@@ -0,0 +1,94 @@
+package mongoDb
+
+import (
+	"context"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"gopkg.in/mgo.v2/txn"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// appendRetries is the number of times an append is retried when another
+// entry took its place in the chain
+const appendRetries = 5
+
+type auditLog struct {
+	session *mgo.Session
+}
+
+// NewAuditLog creates a new instance of the account audit trail. The collection
+// is only ever appended to, the unique index on the sequence keeps the chain linear.
+func NewAuditLog(s *mgo.Session) (account.AuditLog, error) {
+	session := s.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("account_audit")
+
+	err := c.EnsureIndex(mgo.Index{
+		Key:        []string{"account_id", "sequence"},
+		Unique:     true,
+		Background: true,
+	})
+
+	return auditLog{
+		session: s,
+	}, err
+}
+
+// Append ...
+func (l auditLog) Append(ctx context.Context, e account.AuditEntry) (err error) {
+	session := l.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("account_audit")
+
+	for i := 0; i < appendRetries; i++ {
+		var prev *account.AuditEntry
+		err = c.Find(bson.M{"account_id": e.AccountID}).Sort("-sequence").One(&prev)
+		if err != nil && err != mgo.ErrNotFound {
+			return
+		}
+
+		e.Chain(prev)
+		if err = c.Insert(e); !mgo.IsDup(err) {
+			return
+		}
+	}
+
+	return
+}
+
+// auditOp returns the transaction operation appending the entry to the audit
+// trail of its account, chained after the last entry. The writes are asserted
+// on the version of the account, so of two concurrent writes chaining after
+// the same entry only one commits.
+func auditOp(session *mgo.Session, e account.AuditEntry) (txn.Op, error) {
+	c := session.DB("store").C("account_audit")
+
+	var prev *account.AuditEntry
+	if err := c.Find(bson.M{"account_id": e.AccountID}).Sort("-sequence").One(&prev); err != nil && err != mgo.ErrNotFound {
+		return txn.Op{}, err
+	}
+	e.Chain(prev)
+
+	return txn.Op{
+		C:      "account_audit",
+		Id:     bson.NewObjectId(),
+		Assert: txn.DocMissing,
+		Insert: e,
+	}, nil
+}
+
+// History ...
+func (l auditLog) History(ctx context.Context, accountID string, pagination account.Pagination) (entries []*account.AuditEntry, err error) {
+	session := l.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("account_audit")
+
+	err = c.Find(bson.M{"account_id": accountID}).Sort("-sequence").Skip(pagination.Page * pagination.Size).Limit(pagination.Size).All(&entries)
+
+	return
+}
@@ -0,0 +1,243 @@
+package mongoDb
+
+import (
+	"context"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/tkanos/go-rest-api-sample/auth"
+)
+
+type authRepository struct {
+	session *mgo.Session
+}
+
+// NewAuthRepository creates a new instance of the credentials, sessions and password
+// reset tokens repository, sessions and tokens are removed by mongo once expired
+func NewAuthRepository(s *mgo.Session) (auth.Repository, error) {
+	session := s.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("sessions")
+
+	err := c.EnsureIndex(mgo.Index{
+		Key:         []string{"expires_at"},
+		ExpireAfter: time.Second,
+		Background:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.EnsureIndex(mgo.Index{
+		Key:        []string{"account_id"},
+		Background: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resets := session.DB("store").C("password_resets")
+
+	err = resets.EnsureIndex(mgo.Index{
+		Key:         []string{"expires_at"},
+		ExpireAfter: time.Second,
+		Background:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = resets.EnsureIndex(mgo.Index{
+		Key:        []string{"account_id", "created_at"},
+		Background: true,
+	})
+
+	return authRepository{
+		session: s,
+	}, err
+}
+
+// GetCredential ...
+func (r authRepository) GetCredential(ctx context.Context, accountID string) (*auth.Credential, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("credentials")
+
+	var cred auth.Credential
+	err := c.FindId(accountID).One(&cred)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &cred, nil
+}
+
+// SetPassword ...
+func (r authRepository) SetPassword(ctx context.Context, accountID string, hash string) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("credentials")
+
+	_, err := c.UpsertId(accountID, bson.M{
+		"$set": bson.M{
+			"password_hash":   hash,
+			"failed_attempts": 0,
+			"updated_at":      time.Now().UTC(),
+		},
+		"$unset": bson.M{"locked_until": ""},
+	})
+
+	return err
+}
+
+// RecordFailedLogin increments the counter atomically, so that concurrent attempts are all counted
+func (r authRepository) RecordFailedLogin(ctx context.Context, accountID string) (int, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("credentials")
+
+	var cred auth.Credential
+	_, err := c.FindId(accountID).Apply(mgo.Change{
+		Update:    bson.M{"$inc": bson.M{"failed_attempts": 1}},
+		ReturnNew: true,
+	}, &cred)
+
+	return cred.FailedAttempts, err
+}
+
+// Lock ...
+func (r authRepository) Lock(ctx context.Context, accountID string, until time.Time) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("credentials")
+
+	return c.UpdateId(accountID, bson.M{"$set": bson.M{"failed_attempts": 0, "locked_until": until}})
+}
+
+// ResetFailedLogins ...
+func (r authRepository) ResetFailedLogins(ctx context.Context, accountID string) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("credentials")
+
+	return c.UpdateId(accountID, bson.M{"$set": bson.M{"failed_attempts": 0}, "$unset": bson.M{"locked_until": ""}})
+}
+
+// CreateSession ...
+func (r authRepository) CreateSession(ctx context.Context, s auth.Session) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("sessions")
+
+	return c.Insert(s)
+}
+
+// GetSession ...
+func (r authRepository) GetSession(ctx context.Context, id string) (*auth.Session, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("sessions")
+
+	var s auth.Session
+	err := c.FindId(id).One(&s)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// RevokeSession ...
+func (r authRepository) RevokeSession(ctx context.Context, id string, at time.Time) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("sessions")
+
+	err := c.Update(bson.M{"_id": id, "revoked_at": nil}, bson.M{"$set": bson.M{"revoked_at": at}})
+	if err == mgo.ErrNotFound {
+		// already revoked, or expired
+		return auth.ErrInvalidToken
+	}
+
+	return err
+}
+
+// RevokeSessions ...
+func (r authRepository) RevokeSessions(ctx context.Context, accountID string, at time.Time) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("sessions")
+
+	_, err := c.UpdateAll(bson.M{"account_id": accountID, "revoked_at": nil}, bson.M{"$set": bson.M{"revoked_at": at}})
+
+	return err
+}
+
+// CreateResetToken ...
+func (r authRepository) CreateResetToken(ctx context.Context, t auth.ResetToken) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("password_resets")
+
+	return c.Insert(t)
+}
+
+// LastResetToken ...
+func (r authRepository) LastResetToken(ctx context.Context, accountID string) (*auth.ResetToken, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("password_resets")
+
+	var t auth.ResetToken
+	err := c.Find(bson.M{"account_id": accountID}).Sort("-created_at").One(&t)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// UseResetToken only matches an unused and unexpired token, so that it can not be used twice
+func (r authRepository) UseResetToken(ctx context.Context, hash string, at time.Time) (*auth.ResetToken, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("password_resets")
+
+	var t auth.ResetToken
+	_, err := c.Find(bson.M{"_id": hash, "used_at": nil, "expires_at": bson.M{"$gt": at}}).Apply(mgo.Change{
+		Update:    bson.M{"$set": bson.M{"used_at": at}},
+		ReturnNew: true,
+	}, &t)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
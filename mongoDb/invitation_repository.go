@@ -0,0 +1,120 @@
+package mongoDb
+
+import (
+	"context"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/tkanos/go-rest-api-sample/invitation"
+)
+
+type invitationRepository struct {
+	session *mgo.Session
+}
+
+// NewInvitationRepository creates a new instance of the invitations repository
+func NewInvitationRepository(s *mgo.Session) (invitation.Repository, error) {
+	session := s.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("invitations")
+
+	err := c.EnsureIndex(mgo.Index{
+		Key:        []string{"account_id", "status"},
+		Background: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// used by the sweeper
+	err = c.EnsureIndex(mgo.Index{
+		Key:        []string{"status", "expires_at"},
+		Background: true,
+	})
+
+	return invitationRepository{
+		session: s,
+	}, err
+}
+
+// CreateInvitation ...
+func (r invitationRepository) CreateInvitation(ctx context.Context, inv invitation.Invitation) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("invitations")
+
+	return c.Insert(inv)
+}
+
+// GetInvitation ...
+func (r invitationRepository) GetInvitation(ctx context.Context, id string) (*invitation.Invitation, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("invitations")
+
+	var inv invitation.Invitation
+	err := c.FindId(id).One(&inv)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &inv, nil
+}
+
+// GetInvitations ...
+func (r invitationRepository) GetInvitations(ctx context.Context, accountID string, status string) (invitations []*invitation.Invitation, err error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("invitations")
+
+	invitations = []*invitation.Invitation{}
+	err = c.Find(bson.M{"account_id": accountID, "status": status}).Sort("created_at").All(&invitations)
+
+	return
+}
+
+// Transition ...
+func (r invitationRepository) Transition(ctx context.Context, id string, from, to string, at time.Time) (bool, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("invitations")
+
+	// the status is matched in the same update, so only one concurrent transition succeeds
+	err := c.Update(bson.M{"_id": id, "status": from}, bson.M{"$set": bson.M{"status": to, "closed_at": at}})
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ExpireInvitations ...
+func (r invitationRepository) ExpireInvitations(ctx context.Context, now time.Time) (int, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("invitations")
+
+	info, err := c.UpdateAll(
+		bson.M{"status": invitation.StatusPending, "expires_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"status": invitation.StatusExpired, "closed_at": now}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Updated, nil
+}
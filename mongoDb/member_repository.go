@@ -0,0 +1,208 @@
+package mongoDb
+
+import (
+	"context"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"gopkg.in/mgo.v2/txn"
+
+	"github.com/tkanos/go-rest-api-sample/membership"
+)
+
+// ownerWriteAttempts bounds the retries of an owner write aborted by a concurrent one
+const ownerWriteAttempts = 5
+
+type memberRepository struct {
+	session *mgo.Session
+}
+
+// NewMemberRepository creates a new instance of the account members repository
+func NewMemberRepository(s *mgo.Session) (membership.MemberRepository, error) {
+	session := s.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("members")
+
+	err := c.EnsureIndex(mgo.Index{
+		Key:        []string{"account_id", "email"},
+		Unique:     true,
+		Background: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.EnsureIndex(mgo.Index{
+		Key:        []string{"account_id", "id"},
+		Unique:     true,
+		Background: true,
+	})
+
+	return memberRepository{
+		session: s,
+	}, err
+}
+
+// AddMember ...
+func (r memberRepository) AddMember(ctx context.Context, m membership.Member) (string, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	m.ID = bson.NewObjectId().Hex()
+	c := session.DB("store").C("members")
+
+	err := c.Insert(m)
+	if mgo.IsDup(err) {
+		return "", membership.ErrAlreadyMember
+	}
+
+	return m.ID, err
+}
+
+// GetMember ...
+func (r memberRepository) GetMember(ctx context.Context, accountID, id string) (*membership.Member, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("members")
+
+	var m membership.Member
+	err := c.Find(bson.M{"account_id": accountID, "id": id}).One(&m)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// GetMembers ...
+func (r memberRepository) GetMembers(ctx context.Context, accountID string) (members []*membership.Member, err error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("members")
+
+	members = []*membership.Member{}
+	err = c.Find(bson.M{"account_id": accountID}).Sort("invited_at").All(&members)
+
+	return
+}
+
+// UpdateMember ...
+func (r memberRepository) UpdateMember(ctx context.Context, m membership.Member) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("members")
+
+	// the owners are written in transactions, whose fields are kept
+	err := c.Update(bson.M{"account_id": m.AccountID, "id": m.ID}, bson.M{"$set": m})
+	if err == mgo.ErrNotFound {
+		return membership.ErrNotFound
+	}
+
+	return err
+}
+
+// UpdateOwner ...
+func (r memberRepository) UpdateOwner(ctx context.Context, m membership.Member) error {
+	return r.writeOwner(m.AccountID, m.ID, func(id bson.ObjectId) txn.Op {
+		return txn.Op{C: "members", Id: id, Assert: txn.DocExists, Update: bson.M{"$set": m}}
+	})
+}
+
+// RemoveOwner ...
+func (r memberRepository) RemoveOwner(ctx context.Context, accountID, id string) error {
+	return r.writeOwner(accountID, id, func(id bson.ObjectId) txn.Op {
+		return txn.Op{C: "members", Id: id, Assert: txn.DocExists, Remove: true}
+	})
+}
+
+// writeOwner runs the write of an owner in a transaction asserting another
+// active owner of the account, so that two owners demoting each other do not
+// leave the account without any
+func (r memberRepository) writeOwner(accountID, id string, op func(id bson.ObjectId) txn.Op) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("members")
+	runner := txn.NewRunner(session.DB("store").C("txns"))
+	owner := bson.M{"role": membership.RoleOwner, "status": membership.StatusActive}
+
+	var err error
+	for attempt := 0; attempt < ownerWriteAttempts; attempt++ {
+		var doc struct {
+			ID bson.ObjectId `bson:"_id"`
+		}
+		err = c.Find(bson.M{"account_id": accountID, "id": id}).Select(bson.M{"_id": 1}).One(&doc)
+		if err == mgo.ErrNotFound {
+			return membership.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var other struct {
+			ID bson.ObjectId `bson:"_id"`
+		}
+		err = c.Find(bson.M{
+			"account_id": accountID,
+			"id":         bson.M{"$ne": id},
+			"role":       membership.RoleOwner,
+			"status":     membership.StatusActive,
+		}).Select(bson.M{"_id": 1}).One(&other)
+		if err == mgo.ErrNotFound {
+			return membership.ErrLastOwner
+		}
+		if err != nil {
+			return err
+		}
+
+		err = runner.Run([]txn.Op{
+			{C: "members", Id: other.ID, Assert: owner},
+			op(doc.ID),
+		}, "", nil)
+		if err != txn.ErrAborted {
+			return err
+		}
+	}
+
+	return err
+}
+
+// MoveMember ...
+func (r memberRepository) MoveMember(ctx context.Context, accountID, id, toAccountID string) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("members")
+
+	err := c.Update(bson.M{"account_id": accountID, "id": id}, bson.M{"$set": bson.M{"account_id": toAccountID}})
+	if err == mgo.ErrNotFound {
+		return membership.ErrNotFound
+	}
+	if mgo.IsDup(err) {
+		return membership.ErrAlreadyMember
+	}
+
+	return err
+}
+
+// RemoveMember ...
+func (r memberRepository) RemoveMember(ctx context.Context, accountID, id string) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("members")
+
+	err := c.Remove(bson.M{"account_id": accountID, "id": id})
+	if err == mgo.ErrNotFound {
+		return membership.ErrNotFound
+	}
+
+	return err
+}
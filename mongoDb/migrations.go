@@ -0,0 +1,30 @@
+package mongoDb
+
+import (
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// migrate runs a one-off migration of the store, the migrations already run
+// are recorded in the migrations collection. A migration interrupted before
+// being recorded runs again, so it has to be idempotent.
+func migrate(session *mgo.Session, name string, fn func(db *mgo.Database) error) error {
+	c := session.DB("store").C("migrations")
+
+	n, err := c.FindId(name).Count()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	if err := fn(session.DB("store")); err != nil {
+		return err
+	}
+
+	// another process may have run it at the same time
+	if err := c.Insert(bson.M{"_id": name, "at": time.Now().UTC()}); err != nil && !mgo.IsDup(err) {
+		return err
+	}
+	return nil
+}
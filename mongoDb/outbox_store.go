@@ -0,0 +1,170 @@
+package mongoDb
+
+import (
+	"context"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"gopkg.in/mgo.v2/txn"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/outbox"
+)
+
+// deliveredRetention is how long delivered records are kept before mongo removes them
+const deliveredRetention = 7 * 24 * time.Hour
+
+type outboxStore struct {
+	session *mgo.Session
+	// collection holds the records, and collection+"_dead" the dead letters
+	collection string
+}
+
+// outboxDocument is an outbox.Record as stored
+type outboxDocument struct {
+	ID          bson.ObjectId         `bson:"_id"`
+	Event       account.EventEnvelope `bson:"event"`
+	CreatedAt   time.Time             `bson:"created_at"`
+	Attempts    int                   `bson:"attempts"`
+	NextAttempt time.Time             `bson:"next_attempt"`
+	LastError   string                `bson:"last_error,omitempty"`
+	DeliveredAt *time.Time            `bson:"delivered_at,omitempty"`
+}
+
+// outboxDeadDocument is a record set aside by DeadLetter
+type outboxDeadDocument struct {
+	outboxDocument `bson:",inline"`
+	DeadAt         time.Time `bson:"dead_at"`
+}
+
+// NewOutboxStore creates a new instance of the store holding the events
+// written by the account repository. The transactions interrupted by a crash
+// are completed first, so that their records are not left behind.
+func NewOutboxStore(s *mgo.Session) (outbox.Store, error) {
+	return newOutboxStore(s, "outbox")
+}
+
+// NewMergeOutboxStore creates a new instance of the store holding the events
+// of the merges written by the account repository, see account.MergeOf, for
+// the merge handlers. They are relayed apart from the other events, so that
+// retrying a merge handler does not notify every subscriber again.
+func NewMergeOutboxStore(s *mgo.Session) (outbox.Store, error) {
+	return newOutboxStore(s, "merge_outbox")
+}
+
+func newOutboxStore(s *mgo.Session, collection string) (outbox.Store, error) {
+	session := s.Copy()
+	defer session.Close()
+
+	store := outboxStore{
+		session:    s,
+		collection: collection,
+	}
+
+	if err := txn.NewRunner(session.DB("store").C("txns")).ResumeAll(); err != nil {
+		return store, err
+	}
+
+	c := session.DB("store").C(collection)
+
+	if err := c.EnsureIndex(mgo.Index{
+		Key:         []string{"delivered_at"},
+		ExpireAfter: deliveredRetention,
+		Background:  true,
+	}); err != nil {
+		return store, err
+	}
+
+	err := c.EnsureIndex(mgo.Index{
+		Key:        []string{"next_attempt", "event.account_id"},
+		Background: true,
+	})
+
+	return store, err
+}
+
+// Pending returns the due records of the accounts that have none waiting for
+// a retry. A waiting record is always the oldest one of its account: the
+// relay does not try the following ones until it is delivered.
+func (s outboxStore) Pending(ctx context.Context, now time.Time, limit int) ([]outbox.Record, error) {
+	session := s.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C(s.collection)
+
+	var waiting []string
+	err := c.Find(bson.M{"delivered_at": nil, "next_attempt": bson.M{"$gt": now}}).Distinct("event.account_id", &waiting)
+	if err != nil {
+		return nil, err
+	}
+
+	query := bson.M{"delivered_at": nil, "next_attempt": bson.M{"$lte": now}}
+	if len(waiting) > 0 {
+		query["event.account_id"] = bson.M{"$nin": waiting}
+	}
+
+	var docs []outboxDocument
+	if err := c.Find(query).Sort("_id").Limit(limit).All(&docs); err != nil {
+		return nil, err
+	}
+
+	records := make([]outbox.Record, 0, len(docs))
+	for _, d := range docs {
+		records = append(records, outbox.Record{
+			ID:          d.ID.Hex(),
+			Event:       d.Event,
+			CreatedAt:   d.CreatedAt,
+			Attempts:    d.Attempts,
+			NextAttempt: d.NextAttempt,
+			LastError:   d.LastError,
+		})
+	}
+
+	return records, nil
+}
+
+// MarkDelivered ...
+func (s outboxStore) MarkDelivered(ctx context.Context, id string) error {
+	session := s.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C(s.collection)
+
+	return c.UpdateId(bson.ObjectIdHex(id), bson.M{"$set": bson.M{"delivered_at": time.Now().UTC()}})
+}
+
+// MarkFailed ...
+func (s outboxStore) MarkFailed(ctx context.Context, id string, attempts int, next time.Time, reason string) error {
+	session := s.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C(s.collection)
+
+	return c.UpdateId(bson.ObjectIdHex(id), bson.M{"$set": bson.M{
+		"attempts":     attempts,
+		"next_attempt": next,
+		"last_error":   reason,
+	}})
+}
+
+// DeadLetter moves a record to the dead letters collection. The copy is written
+// first, so a failure in between leaves the record pending, to be moved again.
+func (s outboxStore) DeadLetter(ctx context.Context, id string, attempts int, reason string) error {
+	session := s.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C(s.collection)
+
+	var doc outboxDeadDocument
+	if err := c.FindId(bson.ObjectIdHex(id)).One(&doc.outboxDocument); err != nil {
+		return err
+	}
+	doc.Attempts, doc.LastError, doc.DeadAt = attempts, reason, time.Now().UTC()
+
+	if err := session.DB("store").C(s.collection + "_dead").Insert(doc); err != nil && !mgo.IsDup(err) {
+		return err
+	}
+
+	return c.RemoveId(doc.ID)
+}
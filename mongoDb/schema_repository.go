@@ -0,0 +1,112 @@
+package mongoDb
+
+import (
+	"context"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/tkanos/go-rest-api-sample/schema"
+)
+
+type schemaRepository struct {
+	session *mgo.Session
+}
+
+// NewSchemaRepository creates a new instance of the tenant schemas repository
+func NewSchemaRepository(s *mgo.Session) (schema.Repository, error) {
+	session := s.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("schemas")
+
+	err := c.EnsureIndex(mgo.Index{
+		Key:        []string{"tenant_id"},
+		Unique:     true,
+		Background: true,
+	})
+
+	return schemaRepository{
+		session: s,
+	}, err
+}
+
+// GetSchema ...
+func (r schemaRepository) GetSchema(ctx context.Context, tenantID string) (*schema.Schema, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("schemas")
+
+	var s schema.Schema
+	err := c.Find(bson.M{"tenant_id": tenantID}).One(&s)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetSchemas ...
+func (r schemaRepository) GetSchemas(ctx context.Context) (schemas []*schema.Schema, err error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("schemas")
+
+	schemas = []*schema.Schema{}
+	err = c.Find(nil).Sort("tenant_id").All(&schemas)
+
+	return
+}
+
+// SaveSchema also indexes the indexed fields of the schema in the accounts
+// collection, by tenant; the indexes of fields no longer indexed are kept
+func (r schemaRepository) SaveSchema(ctx context.Context, s schema.Schema) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	accounts := session.DB("store").C("accounts")
+	for _, field := range s.IndexedFields {
+		if err := accounts.EnsureIndex(mgo.Index{
+			Key:        []string{"tenant_id", "custom." + field},
+			Background: true,
+			Sparse:     true,
+		}); err != nil {
+			return err
+		}
+	}
+
+	c := session.DB("store").C("schemas")
+
+	// the unique index on tenant_id keeps a second creation from succeeding
+	var err error
+	if s.Version == 1 {
+		err = c.Insert(s)
+	} else {
+		err = c.Update(bson.M{"tenant_id": s.TenantID, "version": s.Version - 1}, s)
+	}
+	if mgo.IsDup(err) || err == mgo.ErrNotFound {
+		return schema.ErrConflict
+	}
+
+	return err
+}
+
+// DeleteSchema ...
+func (r schemaRepository) DeleteSchema(ctx context.Context, tenantID string) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("schemas")
+
+	err := c.Remove(bson.M{"tenant_id": tenantID})
+	if err == mgo.ErrNotFound {
+		return schema.ErrNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,110 @@
+package mongoDb
+
+import (
+	"context"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/tkanos/go-rest-api-sample/verification"
+)
+
+// emailExpirationHours is the lifetime of an email verification token
+const emailExpirationHours = 24
+
+type verificationRepository struct {
+	session *mgo.Session
+}
+
+// NewVerificationRepository creates a new instance of the email verification tokens repository,
+// tokens are removed by mongo once expired
+func NewVerificationRepository(s *mgo.Session) (verification.Repository, error) {
+	session := s.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("email_verifications")
+
+	err := c.EnsureIndex(mgo.Index{
+		Key:         []string{"created_at"},
+		ExpireAfter: emailExpirationHours * time.Hour,
+		Background:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.EnsureIndex(mgo.Index{
+		Key:        []string{"account_id", "-created_at"},
+		Background: true,
+	})
+
+	return verificationRepository{
+		session: s,
+	}, err
+}
+
+// CreateToken ...
+func (r verificationRepository) CreateToken(ctx context.Context, t *verification.Token) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("email_verifications")
+
+	t.ExpiresAt = t.CreatedAt.Add(emailExpirationHours * time.Hour)
+
+	return c.Insert(t)
+}
+
+// GetToken ...
+func (r verificationRepository) GetToken(ctx context.Context, hash string) (*verification.Token, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("email_verifications")
+
+	var t verification.Token
+	err := c.FindId(hash).One(&t)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// UseToken only matches an unused token, so that two concurrent uses can not both succeed
+func (r verificationRepository) UseToken(ctx context.Context, hash string, at time.Time) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("email_verifications")
+
+	err := c.Update(bson.M{"_id": hash, "used_at": nil}, bson.M{"$set": bson.M{"used_at": at}})
+	if err == mgo.ErrNotFound {
+		return verification.ErrInvalidToken
+	}
+
+	return err
+}
+
+// LastToken ...
+func (r verificationRepository) LastToken(ctx context.Context, accountID string) (*verification.Token, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("email_verifications")
+
+	var t verification.Token
+	err := c.Find(bson.M{"account_id": accountID}).Sort("-created_at").One(&t)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
@@ -0,0 +1,194 @@
+package mongoDb
+
+import (
+	"context"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+	"github.com/tkanos/go-rest-api-sample/webhook"
+)
+
+type webhookRepository struct {
+	session *mgo.Session
+}
+
+// NewWebhookRepository creates a new instance of the webhook subscriptions and deliveries repository
+func NewWebhookRepository(s *mgo.Session) (webhook.Repository, error) {
+	session := s.Copy()
+	defer session.Close()
+
+	db := session.DB("store")
+
+	err := db.C("webhooks").EnsureIndex(mgo.Index{
+		Key:        []string{"id"},
+		Unique:     true,
+		Background: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.C("webhook_deliveries").EnsureIndex(mgo.Index{
+		Key:        []string{"status", "next_attempt"},
+		Background: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.C("webhook_deliveries").EnsureIndex(mgo.Index{
+		Key:        []string{"subscription_id", "-created_at"},
+		Background: true,
+	})
+
+	return webhookRepository{
+		session: s,
+	}, err
+}
+
+// GetSubscription ...
+func (r webhookRepository) GetSubscription(ctx context.Context, id string) (*webhook.Subscription, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("webhooks")
+
+	var sub webhook.Subscription
+	err := c.Find(bson.M{"id": id}).One(&sub)
+	if err == mgo.ErrNotFound {
+		return nil, webhook.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+// GetSubscriptions ...
+func (r webhookRepository) GetSubscriptions(ctx context.Context, pagination account.Pagination) (subs []*webhook.Subscription, err error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("webhooks")
+
+	err = c.Find(nil).Sort("created_at").Skip(pagination.Page * pagination.Size).Limit(pagination.Size).All(&subs)
+
+	return
+}
+
+// FindSubscriptions ...
+func (r webhookRepository) FindSubscriptions(ctx context.Context, e account.EventEnvelope) (subs []*webhook.Subscription, err error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("webhooks")
+
+	err = c.Find(bson.M{"$and": []bson.M{
+		{"$or": []bson.M{{"events": bson.M{"$exists": false}}, {"events": e.Type}}},
+		{"$or": []bson.M{{"account_ids": bson.M{"$exists": false}}, {"account_ids": e.AccountID}}},
+	}}).All(&subs)
+
+	return
+}
+
+// CreateSubscription ...
+func (r webhookRepository) CreateSubscription(ctx context.Context, s webhook.Subscription) (string, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	s.ID = bson.NewObjectId().Hex()
+	c := session.DB("store").C("webhooks")
+
+	err := c.Insert(s)
+
+	return s.ID, err
+}
+
+// UpdateSubscription ...
+func (r webhookRepository) UpdateSubscription(ctx context.Context, s webhook.Subscription) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("webhooks")
+
+	err := c.Update(bson.M{"id": s.ID}, s)
+	if err == mgo.ErrNotFound {
+		return webhook.ErrNotFound
+	}
+
+	return err
+}
+
+// DeleteSubscription ...
+func (r webhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("webhooks")
+
+	err := c.Remove(bson.M{"id": id})
+	if err == mgo.ErrNotFound {
+		return webhook.ErrNotFound
+	}
+
+	return err
+}
+
+// CreateDelivery ...
+func (r webhookRepository) CreateDelivery(ctx context.Context, d webhook.Delivery) (string, error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	d.ID = bson.NewObjectId().Hex()
+	c := session.DB("store").C("webhook_deliveries")
+
+	err := c.Insert(d)
+
+	return d.ID, err
+}
+
+// UpdateDelivery ...
+func (r webhookRepository) UpdateDelivery(ctx context.Context, d webhook.Delivery) error {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("webhook_deliveries")
+
+	return c.Update(bson.M{"id": d.ID}, d)
+}
+
+// DueDeliveries ...
+func (r webhookRepository) DueDeliveries(ctx context.Context, now time.Time, limit int) (deliveries []*webhook.Delivery, err error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("webhook_deliveries")
+
+	err = c.Find(bson.M{
+		"status":       webhook.DeliveryPending,
+		"next_attempt": bson.M{"$lte": now},
+	}).Sort("next_attempt").Limit(limit).All(&deliveries)
+
+	return
+}
+
+// GetDeliveries ...
+func (r webhookRepository) GetDeliveries(ctx context.Context, subscriptionID string, status string, pagination account.Pagination) (deliveries []*webhook.Delivery, err error) {
+	session := r.session.Copy()
+	defer session.Close()
+
+	c := session.DB("store").C("webhook_deliveries")
+
+	m := bson.M{"subscription_id": subscriptionID}
+	if len(status) > 0 {
+		m["status"] = status
+	}
+
+	err = c.Find(m).Sort("-created_at").Skip(pagination.Page * pagination.Size).Limit(pagination.Size).All(&deliveries)
+
+	return
+}
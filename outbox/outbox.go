@@ -0,0 +1,31 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// Record is an account event written together with the change that caused it,
+// waiting to be delivered
+type Record struct {
+	ID          string                `json:"id" bson:"_id"`
+	Event       account.EventEnvelope `json:"event" bson:"event"`
+	CreatedAt   time.Time             `json:"created_at" bson:"created_at"`
+	Attempts    int                   `json:"attempts" bson:"attempts"`
+	NextAttempt time.Time             `json:"next_attempt" bson:"next_attempt"`
+	LastError   string                `json:"last_error,omitempty" bson:"last_error,omitempty"`
+}
+
+// Store represents the outbox storage interface
+type Store interface {
+	// Pending returns the oldest undelivered records due at now, in the order
+	// they were written. The records of an account waiting for a retry are
+	// not due, nor are the ones following it.
+	Pending(ctx context.Context, now time.Time, limit int) ([]Record, error)
+	MarkDelivered(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id string, attempts int, next time.Time, reason string) error
+	// DeadLetter sets a record that will never be delivered aside, out of the pending ones
+	DeadLetter(ctx context.Context, id string, attempts int, reason string) error
+}
@@ -0,0 +1,127 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+const (
+	pollInterval = time.Second
+	batchSize    = 100
+	minBackoff   = time.Second
+	maxBackoff   = 5 * time.Minute
+)
+
+// Relay delivers the pending records of a Store to a Publisher. Records of the
+// same account are delivered in the order they were written: while one of them
+// waits for a retry, the following ones wait too. A record failing maxAttempts
+// times, or holding an unknown event, is moved to the dead letters so that it
+// does not hold back its account forever.
+type Relay struct {
+	store       Store
+	publisher   account.Publisher
+	maxAttempts int
+	logger      log.Logger
+	lag         metrics.Gauge
+}
+
+// NewRelay returns a new Relay, lag is set to the age in seconds of the oldest due
+// undelivered record each time the store is polled
+func NewRelay(s Store, p account.Publisher, maxAttempts int, logger log.Logger, lag metrics.Gauge) *Relay {
+	return &Relay{
+		store:       s,
+		publisher:   p,
+		maxAttempts: maxAttempts,
+		logger:      logger,
+		lag:         lag,
+	}
+}
+
+// Run polls the store until the context is done
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.deliver(ctx, time.Now()); err != nil {
+			r.logger.Log("outbox_relay_error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliver publishes one batch of pending records
+func (r *Relay) deliver(ctx context.Context, now time.Time) error {
+	records, err := r.store.Pending(ctx, now, batchSize)
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		r.lag.Set(0)
+		return nil
+	}
+	r.lag.Set(now.Sub(records[0].CreatedAt).Seconds())
+
+	blocked := map[string]bool{}
+	for _, record := range records {
+		accountID := record.Event.AccountID
+		if blocked[accountID] {
+			continue
+		}
+
+		if err := r.publish(ctx, record); err != nil {
+			blocked[accountID] = true
+
+			attempts := record.Attempts + 1
+			r.logger.Log("outbox_record", record.ID, "account_id", accountID, "attempts", attempts, "publish_error", err)
+			if err == account.ErrUnknownEvent || attempts >= r.maxAttempts {
+				if err := r.store.DeadLetter(ctx, record.ID, attempts, err.Error()); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := r.store.MarkFailed(ctx, record.ID, attempts, now.Add(backoff(attempts)), err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := r.store.MarkDelivered(ctx, record.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Relay) publish(ctx context.Context, record Record) error {
+	e, err := record.Event.Event()
+	if err != nil {
+		return err
+	}
+
+	return r.publisher.Publish(ctx, e)
+}
+
+// backoff doubles the delay after each failed attempt, up to maxBackoff
+func backoff(attempts int) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,135 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/generic"
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+type fakeStore struct {
+	records   []Record
+	delivered []string
+	failed    map[string]time.Time
+	dead      []string
+}
+
+// Pending leaves out the accounts waiting for a retry, as the Store does
+func (s *fakeStore) Pending(ctx context.Context, now time.Time, limit int) ([]Record, error) {
+	var due []Record
+	waiting := map[string]bool{}
+	for _, r := range s.records {
+		if r.NextAttempt.After(now) {
+			waiting[r.Event.AccountID] = true
+		}
+	}
+	for _, r := range s.records {
+		if !waiting[r.Event.AccountID] {
+			due = append(due, r)
+		}
+	}
+	return due, nil
+}
+
+func (s *fakeStore) MarkDelivered(ctx context.Context, id string) error {
+	s.delivered = append(s.delivered, id)
+	return nil
+}
+
+func (s *fakeStore) MarkFailed(ctx context.Context, id string, attempts int, next time.Time, reason string) error {
+	s.failed[id] = next
+	return nil
+}
+
+func (s *fakeStore) DeadLetter(ctx context.Context, id string, attempts int, reason string) error {
+	s.dead = append(s.dead, id)
+	return nil
+}
+
+func record(id, accountID string, created time.Time) Record {
+	return Record{
+		ID:        id,
+		Event:     account.AccountCreated{OccurredAt: created, After: account.Account{AccountID: accountID}}.Envelope(),
+		CreatedAt: created,
+	}
+}
+
+func Test_Relay_Should_Keep_Order_Per_Account(t *testing.T) {
+	now := time.Now()
+	store := &fakeStore{
+		records: []Record{
+			record("1", "a", now.Add(-time.Minute)),
+			record("2", "b", now),
+			record("3", "a", now),
+		},
+		failed: map[string]time.Time{},
+	}
+	publisher := account.PublisherFunc(func(ctx context.Context, e account.Event) error {
+		if e.Envelope().AccountID == "a" {
+			return errors.New("error")
+		}
+		return nil
+	})
+	lag := generic.NewGauge("lag")
+
+	relay := NewRelay(store, publisher, 3, log.NewNopLogger(), lag)
+	err := relay.deliver(context.Background(), now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2"}, store.delivered)
+	assert.Equal(t, now.Add(minBackoff), store.failed["1"])
+	assert.NotContains(t, store.failed, "3")
+	assert.Equal(t, float64(60), lag.Value())
+}
+
+func Test_Relay_Should_Wait_For_Next_Attempt(t *testing.T) {
+	now := time.Now()
+	retried := record("1", "a", now)
+	retried.NextAttempt = now.Add(time.Second)
+	store := &fakeStore{
+		records: []Record{retried, record("2", "a", now)},
+		failed:  map[string]time.Time{},
+	}
+	publisher := account.PublisherFunc(func(ctx context.Context, e account.Event) error {
+		return nil
+	})
+
+	relay := NewRelay(store, publisher, 3, log.NewNopLogger(), generic.NewGauge("lag"))
+	err := relay.deliver(context.Background(), now)
+
+	assert.Nil(t, err)
+	assert.Empty(t, store.delivered)
+}
+
+func Test_Relay_Should_Set_Aside_The_Records_It_Can_Not_Deliver(t *testing.T) {
+	now := time.Now()
+	exhausted := record("1", "a", now)
+	exhausted.Attempts = 2
+	unknown := record("2", "b", now)
+	unknown.Event.Type = "AccountRenamed"
+	store := &fakeStore{
+		records: []Record{exhausted, unknown, record("3", "c", now)},
+		failed:  map[string]time.Time{},
+	}
+	publisher := account.PublisherFunc(func(ctx context.Context, e account.Event) error {
+		return errors.New("error")
+	})
+
+	relay := NewRelay(store, publisher, 3, log.NewNopLogger(), generic.NewGauge("lag"))
+	err := relay.deliver(context.Background(), now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, store.dead)
+	assert.Contains(t, store.failed, "3")
+}
+
+func Test_Backoff(t *testing.T) {
+	assert.Equal(t, minBackoff, backoff(1))
+	assert.Equal(t, 4*minBackoff, backoff(3))
+	assert.Equal(t, maxBackoff, backoff(100))
+}
@@ -0,0 +1,291 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+// ErrInvalidSchema is used when a schema can not be parsed, or uses what is not supported
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// ValidationError tells which value does not match a schema, and why
+type ValidationError struct {
+	Path   string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Reason)
+}
+
+// Definition is a compiled JSON Schema. The supported keywords are type, enum,
+// properties, required, additionalProperties (as a boolean), items, minLength,
+// maxLength, pattern, minimum, maximum, minItems and maxItems; the other ones,
+// such as title or description, are ignored. The top level must be an object
+// and its properties can be declared indexed with the "x-indexed" extension.
+type Definition struct {
+	Type                 types                  `json:"type"`
+	Enum                 []interface{}          `json:"enum"`
+	Properties           map[string]*Definition `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *Definition            `json:"items"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Pattern              string                 `json:"pattern"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+	Indexed              bool                   `json:"x-indexed"`
+
+	pattern *regexp.Regexp
+}
+
+// types is the type keyword, a single type or a list of types
+type types []string
+
+func (t *types) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*t = types{one}
+		return nil
+	}
+
+	var many []string
+	err := json.Unmarshal(b, &many)
+	*t = many
+	return err
+}
+
+var knownTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+// fieldName is the syntax of the properties, used as field names in storage
+var fieldName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// Compile parses a JSON Schema
+func Compile(b []byte) (*Definition, error) {
+	var d Definition
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, ErrInvalidSchema
+	}
+
+	if len(d.Type) != 1 || d.Type[0] != "object" {
+		return nil, ErrInvalidSchema
+	}
+	if err := d.compile(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range d.Properties {
+		if p.Indexed && (len(p.Type) != 1 || !scalar(p.Type[0])) {
+			return nil, ErrInvalidSchema
+		}
+	}
+
+	return &d, nil
+}
+
+func (d *Definition) compile() error {
+	for _, t := range d.Type {
+		if !knownTypes[t] {
+			return ErrInvalidSchema
+		}
+	}
+
+	if len(d.Pattern) > 0 {
+		p, err := regexp.Compile(d.Pattern)
+		if err != nil {
+			return ErrInvalidSchema
+		}
+		d.pattern = p
+	}
+
+	for name, p := range d.Properties {
+		if p == nil || !fieldName.MatchString(name) {
+			return ErrInvalidSchema
+		}
+		if err := p.compile(); err != nil {
+			return err
+		}
+	}
+
+	if d.Items != nil {
+		return d.Items.compile()
+	}
+	return nil
+}
+
+func scalar(t string) bool {
+	return t == "string" || t == "number" || t == "integer" || t == "boolean"
+}
+
+// IndexedFields returns the top level properties declared indexed, sorted
+func (d *Definition) IndexedFields() []string {
+	fields := []string{}
+	for name, p := range d.Properties {
+		if p.Indexed {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+
+	return fields
+}
+
+// Validate checks the custom fields of an Account, it returns a ValidationError when they do not match
+func (d *Definition) Validate(custom map[string]interface{}) error {
+	// values are compared in their JSON form, whatever they were decoded from
+	var v interface{}
+	b, err := json.Marshal(custom)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if v == nil {
+		v = map[string]interface{}{}
+	}
+
+	return d.validate("custom", v)
+}
+
+func (d *Definition) validate(path string, v interface{}) error {
+	if len(d.Type) > 0 && !d.hasType(v) {
+		return &ValidationError{path, fmt.Sprintf("must be of type %v", []string(d.Type))}
+	}
+
+	if len(d.Enum) > 0 {
+		found := false
+		for _, e := range d.Enum {
+			found = found || reflect.DeepEqual(e, v)
+		}
+		if !found {
+			return &ValidationError{path, "must be one of the enum values"}
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return d.validateObject(path, v)
+	case []interface{}:
+		return d.validateArray(path, v)
+	case string:
+		return d.validateString(path, v)
+	case float64:
+		return d.validateNumber(path, v)
+	}
+	return nil
+}
+
+func (d *Definition) hasType(v interface{}) bool {
+	for _, t := range d.Type {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case nil:
+			if t == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *Definition) validateObject(path string, v map[string]interface{}) error {
+	for _, name := range d.Required {
+		if _, ok := v[name]; !ok {
+			return &ValidationError{path + "." + name, "is required"}
+		}
+	}
+
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	// the first error is always the same one
+	sort.Strings(names)
+
+	for _, name := range names {
+		p, ok := d.Properties[name]
+		if !ok {
+			if d.AdditionalProperties != nil && !*d.AdditionalProperties {
+				return &ValidationError{path + "." + name, "is not allowed"}
+			}
+			continue
+		}
+		if err := p.validate(path+"."+name, v[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Definition) validateArray(path string, v []interface{}) error {
+	if d.MinItems != nil && len(v) < *d.MinItems {
+		return &ValidationError{path, fmt.Sprintf("must have at least %d items", *d.MinItems)}
+	}
+	if d.MaxItems != nil && len(v) > *d.MaxItems {
+		return &ValidationError{path, fmt.Sprintf("must have at most %d items", *d.MaxItems)}
+	}
+
+	if d.Items != nil {
+		for i, item := range v {
+			if err := d.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Definition) validateString(path string, v string) error {
+	n := utf8.RuneCountInString(v)
+	if d.MinLength != nil && n < *d.MinLength {
+		return &ValidationError{path, fmt.Sprintf("must be at least %d characters long", *d.MinLength)}
+	}
+	if d.MaxLength != nil && n > *d.MaxLength {
+		return &ValidationError{path, fmt.Sprintf("must be at most %d characters long", *d.MaxLength)}
+	}
+	if d.pattern != nil && !d.pattern.MatchString(v) {
+		return &ValidationError{path, "must match " + d.Pattern}
+	}
+	return nil
+}
+
+func (d *Definition) validateNumber(path string, v float64) error {
+	if d.Minimum != nil && v < *d.Minimum {
+		return &ValidationError{path, fmt.Sprintf("must be at least %v", *d.Minimum)}
+	}
+	if d.Maximum != nil && v > *d.Maximum {
+		return &ValidationError{path, fmt.Sprintf("must be at most %v", *d.Maximum)}
+	}
+	return nil
+}
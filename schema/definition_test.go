@@ -0,0 +1,83 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const planSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"required": ["plan"],
+	"additionalProperties": false,
+	"properties": {
+		"plan": {"type": "string", "enum": ["free", "pro"], "x-indexed": true},
+		"seats": {"type": "integer", "minimum": 1, "x-indexed": true},
+		"code": {"type": "string", "pattern": "^[A-Z]{3}$", "maxLength": 3},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string", "minLength": 1}},
+		"billing": {"type": "object", "properties": {"vat": {"type": ["string", "null"]}}}
+	}
+}`
+
+func Test_Compile(t *testing.T) {
+	var flagtests = []struct {
+		in  string
+		err error
+	}{
+		{planSchema, nil},
+		{`{"type": "array"}`, ErrInvalidSchema},
+		{`{"type": "object", "properties": {"a": {"type": "date"}}}`, ErrInvalidSchema},
+		{`{"type": "object", "properties": {"a.b": {"type": "string"}}}`, ErrInvalidSchema},
+		{`{"type": "object", "properties": {"a": {"type": "string", "pattern": "("}}}`, ErrInvalidSchema},
+		{`{"type": "object", "properties": {"a": {"type": "object", "x-indexed": true}}}`, ErrInvalidSchema},
+		{`{"type": "object", "additionalProperties": {"type": "string"}}`, ErrInvalidSchema},
+		{`not json`, ErrInvalidSchema},
+	}
+
+	for _, tt := range flagtests {
+		_, err := Compile([]byte(tt.in))
+
+		assert.Equal(t, tt.err, err, tt.in)
+	}
+}
+
+func Test_Definition_IndexedFields(t *testing.T) {
+	d, _ := Compile([]byte(planSchema))
+
+	assert.Equal(t, []string{"plan", "seats"}, d.IndexedFields())
+}
+
+func Test_Definition_Validate(t *testing.T) {
+	d, err := Compile([]byte(planSchema))
+	assert.Nil(t, err)
+
+	var flagtests = []struct {
+		in   map[string]interface{}
+		path string
+	}{
+		{map[string]interface{}{"plan": "pro", "seats": 3, "code": "ABC", "tags": []string{"a"}, "billing": map[string]interface{}{"vat": nil}}, ""},
+		{map[string]interface{}{"plan": "pro", "seats": int64(2)}, ""},
+		{nil, "custom.plan"},
+		{map[string]interface{}{"plan": "gold"}, "custom.plan"},
+		{map[string]interface{}{"plan": "pro", "seats": 1.5}, "custom.seats"},
+		{map[string]interface{}{"plan": "pro", "seats": 0}, "custom.seats"},
+		{map[string]interface{}{"plan": "pro", "code": "abc"}, "custom.code"},
+		{map[string]interface{}{"plan": "pro", "tags": []string{"a", ""}}, "custom.tags[1]"},
+		{map[string]interface{}{"plan": "pro", "tags": []string{"a", "b", "c"}}, "custom.tags"},
+		{map[string]interface{}{"plan": "pro", "billing": map[string]interface{}{"vat": 1}}, "custom.billing.vat"},
+		{map[string]interface{}{"plan": "pro", "other": 1}, "custom.other"},
+	}
+
+	for _, tt := range flagtests {
+		err := d.Validate(tt.in)
+
+		if tt.path == "" {
+			assert.Nil(t, err, "%v", tt.in)
+			continue
+		}
+		if assert.IsType(t, &ValidationError{}, err, "%v", tt.in) {
+			assert.Equal(t, tt.path, err.(*ValidationError).Path)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Endpoints represent the schema service endpoints
+type Endpoints struct {
+	Get     endpoint.Endpoint
+	GetList endpoint.Endpoint
+	Put     endpoint.Endpoint
+	Delete  endpoint.Endpoint
+}
+
+// MakeGetSchemaEndpoint returns an endpoint used for getting the schema of a tenant
+func MakeGetSchemaEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SchemaRequest)
+
+		return s.GetSchema(ctx, req.TenantID)
+	}
+}
+
+// MakeGetSchemasEndpoint returns an endpoint used for listing the schemas of the tenants
+func MakeGetSchemasEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return s.GetSchemas(ctx)
+	}
+}
+
+// MakePutSchemaEndpoint returns an endpoint used for creating or replacing the schema of a tenant
+func MakePutSchemaEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(PutSchemaRequest)
+
+		return s.PutSchema(ctx, req.TenantID, req.Definition, req.Force)
+	}
+}
+
+// MakeDeleteSchemaEndpoint returns an endpoint used for removing the schema of a tenant
+func MakeDeleteSchemaEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(SchemaRequest)
+
+		return nil, s.DeleteSchema(ctx, req.TenantID, req.Force)
+	}
+}
+
+// SchemaRequest represents the request parameters used for getting or removing the schema of a tenant
+type SchemaRequest struct {
+	TenantID string
+	Force    bool
+}
+
+// PutSchemaRequest represents the request parameters used for creating or replacing the schema of a tenant
+type PutSchemaRequest struct {
+	TenantID   string
+	Definition json.RawMessage
+	Force      bool
+}
@@ -0,0 +1,142 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/go-kit/kit/log"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// ErrInvalidBody thrown when the body of a request can not be parsed
+var ErrInvalidBody = errors.New("invalid body")
+
+// ErrInvalidForce is used when the force parameter is not a boolean
+var ErrInvalidForce = errors.New("invalid force parameter")
+
+// MakeHTTPHandler returns all http handler for the schema service
+func MakeHTTPHandler(logger log.Logger, endpoints Endpoints) http.Handler {
+	options := []kithttp.ServerOption{
+		kithttp.ServerErrorLogger(logger),
+		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(account.PopulateAuditContext),
+	}
+
+	getSchemaHandler := kithttp.NewServer(
+		endpoints.Get,
+		decodeSchemaRequest,
+		encodeResponse,
+		options...,
+	)
+
+	getSchemasHandler := kithttp.NewServer(
+		endpoints.GetList,
+		decodeGetSchemasRequest,
+		encodeResponse,
+		options...,
+	)
+
+	putSchemaHandler := kithttp.NewServer(
+		endpoints.Put,
+		decodePutSchemaRequest,
+		encodeResponse,
+		options...,
+	)
+
+	deleteSchemaHandler := kithttp.NewServer(
+		endpoints.Delete,
+		decodeSchemaRequest,
+		encodeNoContentResponse,
+		options...,
+	)
+
+	r := mux.NewRouter().PathPrefix("/schemas/accounts").Subrouter()
+
+	r.Handle("", getSchemasHandler).Methods("GET")
+	r.Handle("/{tenant_id}", getSchemaHandler).Methods("GET")
+	r.Handle("/{tenant_id}", putSchemaHandler).Methods("PUT")
+	r.Handle("/{tenant_id}", deleteSchemaHandler).Methods("DELETE")
+
+	return r
+}
+
+func decodeGetSchemasRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	return nil, nil
+}
+
+func decodeSchemaRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	force, err := decodeForce(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return SchemaRequest{TenantID: mux.Vars(r)["tenant_id"], Force: force}, nil
+}
+
+// decodePutSchemaRequest reads the JSON Schema as the whole body, it is compiled by the service
+func decodePutSchemaRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	force, err := decodeForce(r)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil || !json.Valid(b) {
+		return nil, ErrInvalidBody
+	}
+
+	return PutSchemaRequest{TenantID: mux.Vars(r)["tenant_id"], Definition: b, Force: force}, nil
+}
+
+// decodeForce reads the force parameter, which allows a change invalidating existing Accounts
+func decodeForce(r *http.Request) (bool, error) {
+	force := r.URL.Query().Get("force")
+	if len(force) == 0 {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(force)
+	if err != nil {
+		return false, ErrInvalidForce
+	}
+	return b, nil
+}
+
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func encodeNoContentResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// encode errors from business-logic
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	switch err {
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	case ErrInvalidBody,
+		ErrInvalidForce,
+		ErrInvalidSchema:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case ErrOtherTenant:
+		w.WriteHeader(http.StatusForbidden)
+	case ErrIncompatibleSchema,
+		ErrConflict:
+		w.WriteHeader(http.StatusConflict)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
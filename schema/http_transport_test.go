@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DecodePutSchemaRequest(t *testing.T) {
+	var flagtests = []struct {
+		query string
+		body  string
+		out   interface{}
+		err   error
+	}{
+		{"", `{"type": "object"}`, PutSchemaRequest{TenantID: "t1", Definition: []byte(`{"type": "object"}`)}, nil},
+		{"?force=true", `{"type": "object"}`, PutSchemaRequest{TenantID: "t1", Definition: []byte(`{"type": "object"}`), Force: true}, nil},
+		{"?force=maybe", `{"type": "object"}`, nil, ErrInvalidForce},
+		{"", `{"type": `, nil, ErrInvalidBody},
+	}
+
+	for _, tt := range flagtests {
+		r, _ := http.NewRequest("PUT", "/schemas/accounts/t1"+tt.query, strings.NewReader(tt.body))
+		r = mux.SetURLVars(r, map[string]string{"tenant_id": "t1"})
+
+		req, err := decodePutSchemaRequest(context.Background(), r)
+
+		assert.Equal(t, tt.err, err)
+		assert.Equal(t, tt.out, req)
+	}
+}
+
+func Test_EncodeError(t *testing.T) {
+	var flagtests = []struct {
+		err  error
+		code int
+	}{
+		{ErrInvalidSchema, http.StatusBadRequest},
+		{ErrInvalidBody, http.StatusBadRequest},
+		{ErrNotFound, http.StatusNotFound},
+		{ErrIncompatibleSchema, http.StatusConflict},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range flagtests {
+		w := httptest.NewRecorder()
+		encodeError(context.Background(), tt.err, w)
+
+		assert.Equal(t, tt.code, w.Code)
+	}
+}
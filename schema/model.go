@@ -0,0 +1,17 @@
+package schema
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Schema is the JSON Schema the custom fields of the Accounts of a tenant must match.
+// IndexedFields lists the top level properties declared with "x-indexed": true,
+// the ones Accounts can be filtered on.
+type Schema struct {
+	TenantID      string          `json:"tenant_id" bson:"tenant_id"`
+	Definition    json.RawMessage `json:"schema" bson:"definition"`
+	IndexedFields []string        `json:"indexed_fields" bson:"indexed_fields"`
+	Version       int             `json:"version" bson:"version"`
+	UpdatedAt     time.Time       `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,118 @@
+package schema
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+type registry struct {
+	repository Repository
+
+	mtx      sync.Mutex
+	compiled map[string]compiled
+}
+
+// compiled is the Definition of a version of the schema of a tenant
+type compiled struct {
+	version    int
+	definition *Definition
+}
+
+// NewRegistry returns the account.Schemas backed by the schemas of the repository.
+// A schema is read on every call, its compiled Definition is kept until its version changes.
+func NewRegistry(r Repository) account.Schemas {
+	return &registry{
+		repository: r,
+		compiled:   map[string]compiled{},
+	}
+}
+
+// definition returns the Definition of the schema of a tenant, nil when it has none
+func (r *registry) definition(ctx context.Context, tenantID string) (*Definition, error) {
+	s, err := r.repository.GetSchema(ctx, tenantID)
+	if err != nil || s == nil {
+		return nil, err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if c, ok := r.compiled[tenantID]; ok && c.version == s.Version {
+		return c.definition, nil
+	}
+
+	d, err := Compile(s.Definition)
+	if err != nil {
+		return nil, err
+	}
+	r.compiled[tenantID] = compiled{version: s.Version, definition: d}
+
+	return d, nil
+}
+
+// ValidateCustom checks the custom fields against the schema of the tenant,
+// no custom field is allowed when the tenant has no schema
+func (r *registry) ValidateCustom(ctx context.Context, tenantID string, custom map[string]interface{}) error {
+	d, err := r.definition(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		if len(custom) > 0 {
+			return account.ErrInvalidCustomFields
+		}
+		return nil
+	}
+
+	if err := d.Validate(custom); err != nil {
+		if _, ok := err.(*ValidationError); ok {
+			return account.ErrInvalidCustomFields
+		}
+		return err
+	}
+	return nil
+}
+
+// CustomFilter converts the string values of the filter to the types of the indexed fields
+func (r *registry) CustomFilter(ctx context.Context, tenantID string, filter map[string]interface{}) (map[string]interface{}, error) {
+	d, err := r.definition(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, account.ErrInvalidCustomFilter
+	}
+
+	converted := make(map[string]interface{}, len(filter))
+	for field, value := range filter {
+		p, ok := d.Properties[field]
+		if !ok || !p.Indexed {
+			return nil, account.ErrInvalidCustomFilter
+		}
+
+		s, ok := value.(string)
+		if !ok {
+			converted[field] = value
+			continue
+		}
+
+		switch p.Type[0] {
+		case "string":
+			converted[field] = s
+		case "integer":
+			converted[field], err = strconv.ParseInt(s, 10, 64)
+		case "number":
+			converted[field], err = strconv.ParseFloat(s, 64)
+		case "boolean":
+			converted[field], err = strconv.ParseBool(s)
+		}
+		if err != nil {
+			return nil, account.ErrInvalidCustomFilter
+		}
+	}
+
+	return converted, nil
+}
@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+func Test_Registry_ValidateCustom(t *testing.T) {
+	r := &fakeRepository{schemas: map[string]Schema{"t1": {TenantID: "t1", Definition: []byte(planSchema), Version: 1}}}
+	registry := NewRegistry(r)
+
+	assert.Nil(t, registry.ValidateCustom(context.Background(), "t1", map[string]interface{}{"plan": "free"}))
+	assert.Equal(t, account.ErrInvalidCustomFields, registry.ValidateCustom(context.Background(), "t1", map[string]interface{}{"plan": "gold"}))
+	assert.Nil(t, registry.ValidateCustom(context.Background(), "t2", nil))
+	assert.Equal(t, account.ErrInvalidCustomFields, registry.ValidateCustom(context.Background(), "t2", map[string]interface{}{"plan": "free"}))
+}
+
+func Test_Registry_Should_Recompile_A_New_Version(t *testing.T) {
+	r := &fakeRepository{schemas: map[string]Schema{"t1": {TenantID: "t1", Definition: []byte(planSchema), Version: 1}}}
+	registry := NewRegistry(r)
+
+	assert.Nil(t, registry.ValidateCustom(context.Background(), "t1", map[string]interface{}{"plan": "free"}))
+
+	r.SaveSchema(context.Background(), Schema{TenantID: "t1", Definition: []byte(`{"type": "object", "required": ["region"]}`), Version: 2})
+
+	assert.Equal(t, account.ErrInvalidCustomFields, registry.ValidateCustom(context.Background(), "t1", map[string]interface{}{"plan": "free"}))
+}
+
+func Test_Registry_CustomFilter(t *testing.T) {
+	r := &fakeRepository{schemas: map[string]Schema{"t1": {TenantID: "t1", Definition: []byte(planSchema), Version: 1}}}
+	registry := NewRegistry(r)
+
+	var flagtests = []struct {
+		in  map[string]interface{}
+		out map[string]interface{}
+		err error
+	}{
+		{map[string]interface{}{"plan": "pro", "seats": "3"}, map[string]interface{}{"plan": "pro", "seats": int64(3)}, nil},
+		{map[string]interface{}{"seats": "many"}, nil, account.ErrInvalidCustomFilter},
+		{map[string]interface{}{"code": "ABC"}, nil, account.ErrInvalidCustomFilter},
+		{map[string]interface{}{"unknown": "x"}, nil, account.ErrInvalidCustomFilter},
+	}
+
+	for _, tt := range flagtests {
+		out, err := registry.CustomFilter(context.Background(), "t1", tt.in)
+
+		assert.Equal(t, tt.err, err)
+		assert.Equal(t, tt.out, out)
+	}
+
+	_, err := registry.CustomFilter(context.Background(), "t2", map[string]interface{}{"plan": "pro"})
+	assert.Equal(t, account.ErrInvalidCustomFilter, err)
+}
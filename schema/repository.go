@@ -0,0 +1,15 @@
+package schema
+
+import "context"
+
+// Repository stores the schemas of the tenants
+type Repository interface {
+	// GetSchema returns the schema of a tenant, nil when it has none
+	GetSchema(ctx context.Context, tenantID string) (*Schema, error)
+	GetSchemas(ctx context.Context) ([]*Schema, error)
+	// SaveSchema creates the schema of a tenant at version 1, or replaces the one
+	// at the previous version, and indexes its indexed fields. ErrConflict is
+	// returned when the stored schema is at another version.
+	SaveSchema(ctx context.Context, s Schema) error
+	DeleteSchema(ctx context.Context, tenantID string) error
+}
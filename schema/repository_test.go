@@ -0,0 +1,88 @@
+package schema
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// fakeRepository keeps schemas in memory
+type fakeRepository struct {
+	mu      sync.Mutex
+	schemas map[string]Schema
+}
+
+func (r *fakeRepository) GetSchema(ctx context.Context, tenantID string) (*Schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.schemas[tenantID]
+	if !ok {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+func (r *fakeRepository) GetSchemas(ctx context.Context) ([]*Schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	schemas := []*Schema{}
+	for _, s := range r.schemas {
+		s := s
+		schemas = append(schemas, &s)
+	}
+	return schemas, nil
+}
+
+func (r *fakeRepository) SaveSchema(ctx context.Context, s Schema) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.schemas == nil {
+		r.schemas = map[string]Schema{}
+	}
+	if before, ok := r.schemas[s.TenantID]; (ok && before.Version != s.Version-1) || (!ok && s.Version != 1) {
+		return ErrConflict
+	}
+	r.schemas[s.TenantID] = s
+	return nil
+}
+
+func (r *fakeRepository) DeleteSchema(ctx context.Context, tenantID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.schemas, tenantID)
+	return nil
+}
+
+// fakeAccounts only implements the account.Service methods used by the schema service
+type fakeAccounts struct {
+	account.Service
+	accounts []account.Account
+}
+
+func (f fakeAccounts) GetAccount(ctx context.Context, id string) (*account.Account, error) {
+	for _, a := range f.accounts {
+		if a.AccountID == id {
+			a := a
+			return &a, nil
+		}
+	}
+	return nil, account.ErrNotFound
+}
+
+func (f fakeAccounts) ExportAccounts(ctx context.Context, filter account.Filter, fn func(*account.Account) error) error {
+	for _, a := range f.accounts {
+		a := a
+		if a.TenantID != filter.TenantIDs[0] {
+			continue
+		}
+		if err := fn(&a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
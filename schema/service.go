@@ -0,0 +1,164 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// ErrNotFound is used when a tenant has no schema
+var ErrNotFound = errors.New("schema not found")
+
+// ErrConflict is used when a schema was changed by another write since it was read
+var ErrConflict = errors.New("schema changed concurrently, read it again")
+
+// ErrOtherTenant is used when the account making a request writes the schema of another tenant than its own
+var ErrOtherTenant = errors.New("schema belongs to another tenant")
+
+// ErrIncompatibleSchema is used when changing a schema would invalidate the custom fields of existing Accounts
+var ErrIncompatibleSchema = errors.New("schema does not match the custom fields of existing Accounts")
+
+// Service manages the schemas of the custom fields of the Accounts of each tenant
+type Service interface {
+	GetSchema(ctx context.Context, tenantID string) (*Schema, error)
+	GetSchemas(ctx context.Context) ([]*Schema, error)
+	PutSchema(ctx context.Context, tenantID string, definition json.RawMessage, force bool) (*Schema, error)
+	DeleteSchema(ctx context.Context, tenantID string, force bool) error
+}
+
+type service struct {
+	accounts   account.Service
+	repository Repository
+}
+
+// NewService returns a new instance of the schema Service
+func NewService(accounts account.Service, r Repository) Service {
+	return service{
+		accounts:   accounts,
+		repository: r,
+	}
+}
+
+// GetSchema returns the schema of a tenant
+func (s service) GetSchema(ctx context.Context, tenantID string) (*Schema, error) {
+	schema, err := s.repository.GetSchema(ctx, tenantID)
+	if err == nil && schema == nil {
+		err = ErrNotFound
+	}
+
+	return schema, err
+}
+
+// GetSchemas returns the schemas of every tenant
+func (s service) GetSchemas(ctx context.Context) ([]*Schema, error) {
+	return s.repository.GetSchemas(ctx)
+}
+
+// PutSchema creates or replaces the schema of a tenant. Unless forced, it
+// fails with ErrIncompatibleSchema when an Account of the tenant does not match it.
+// The schema is only replaced when it is still at the version read, ErrConflict
+// is returned otherwise.
+func (s service) PutSchema(ctx context.Context, tenantID string, definition json.RawMessage, force bool) (*Schema, error) {
+	d, err := Compile(definition)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTenant(ctx, tenantID); err != nil {
+		return nil, err
+	}
+
+	before, err := s.repository.GetSchema(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !force {
+		if err := s.check(ctx, tenantID, d); err != nil {
+			return nil, err
+		}
+	}
+
+	schema := Schema{
+		TenantID:      tenantID,
+		Definition:    definition,
+		IndexedFields: d.IndexedFields(),
+		Version:       1,
+		UpdatedAt:     time.Now().UTC(),
+	}
+	if before != nil {
+		schema.Version = before.Version + 1
+	}
+
+	if err := s.repository.SaveSchema(ctx, schema); err != nil {
+		return nil, err
+	}
+
+	return &schema, nil
+}
+
+// DeleteSchema removes the schema of a tenant. Unless forced, it fails with
+// ErrIncompatibleSchema when an Account of the tenant has custom fields.
+func (s service) DeleteSchema(ctx context.Context, tenantID string, force bool) error {
+	if err := s.checkTenant(ctx, tenantID); err != nil {
+		return err
+	}
+	if _, err := s.GetSchema(ctx, tenantID); err != nil {
+		return err
+	}
+
+	if !force {
+		if err := s.check(ctx, tenantID, nil); err != nil {
+			return err
+		}
+	}
+
+	return s.repository.DeleteSchema(ctx, tenantID)
+}
+
+// checkTenant returns ErrOtherTenant unless the tenant is the one of the
+// account making the request, if any, see account.CreatorFromContext
+func (s service) checkTenant(ctx context.Context, tenantID string) error {
+	id := account.CreatorFromContext(ctx)
+	if len(id) == 0 {
+		return nil
+	}
+
+	caller, err := s.accounts.GetAccount(ctx, id)
+	if err == account.ErrNotFound {
+		return ErrOtherTenant
+	}
+	if err != nil {
+		return err
+	}
+
+	if caller.TenantID != tenantID {
+		return ErrOtherTenant
+	}
+	return nil
+}
+
+// check returns ErrIncompatibleSchema when the custom fields of an Account of
+// the tenant do not match the definition, a nil definition allows none
+func (s service) check(ctx context.Context, tenantID string, d *Definition) error {
+	return s.accounts.ExportAccounts(ctx, account.Filter{TenantIDs: []string{tenantID}}, func(a *account.Account) error {
+		// a merged Account can not be written anymore, nor be invalidated
+		if a.Status == account.StatusMerged {
+			return nil
+		}
+
+		if d == nil {
+			if len(a.Custom) > 0 {
+				return ErrIncompatibleSchema
+			}
+			return nil
+		}
+
+		if err := d.Validate(a.Custom); err != nil {
+			return ErrIncompatibleSchema
+		}
+		return nil
+	})
+}
@@ -0,0 +1,89 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+func Test_PutSchema(t *testing.T) {
+	r := &fakeRepository{}
+	s := NewService(fakeAccounts{}, r)
+
+	created, err := s.PutSchema(context.Background(), "t1", json.RawMessage(planSchema), false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, created.Version)
+	assert.Equal(t, []string{"plan", "seats"}, created.IndexedFields)
+
+	updated, err := s.PutSchema(context.Background(), "t1", json.RawMessage(`{"type": "object"}`), false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, updated.Version)
+	assert.Equal(t, []string{}, updated.IndexedFields)
+
+	_, err = s.PutSchema(context.Background(), "t1", json.RawMessage(`{"type": "string"}`), false)
+	assert.Equal(t, ErrInvalidSchema, err)
+}
+
+func Test_PutSchema_Should_Reject_A_Schema_Invalidating_Accounts_Unless_Forced(t *testing.T) {
+	accounts := fakeAccounts{accounts: []account.Account{
+		{AccountID: "1", TenantID: "t1", Custom: map[string]interface{}{"plan": "free"}},
+		{AccountID: "2", TenantID: "t1", Status: account.StatusMerged, Custom: map[string]interface{}{"plan": "gold"}},
+		{AccountID: "3", TenantID: "t2", Custom: map[string]interface{}{"other": true}},
+	}}
+	r := &fakeRepository{}
+	s := NewService(accounts, r)
+
+	_, err := s.PutSchema(context.Background(), "t1", json.RawMessage(planSchema), false)
+	assert.Nil(t, err)
+
+	_, err = s.PutSchema(context.Background(), "t1", json.RawMessage(`{"type": "object", "required": ["seats"]}`), false)
+	assert.Equal(t, ErrIncompatibleSchema, err)
+	assert.Equal(t, 1, r.schemas["t1"].Version)
+
+	_, err = s.PutSchema(context.Background(), "t1", json.RawMessage(`{"type": "object", "required": ["seats"]}`), true)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, r.schemas["t1"].Version)
+}
+
+func Test_PutSchema_Should_Return_ErrConflict_When_The_Schema_Changed(t *testing.T) {
+	r := &fakeRepository{schemas: map[string]Schema{"t1": {TenantID: "t1", Version: 2}}}
+	s := NewService(fakeAccounts{}, r)
+
+	assert.Equal(t, ErrConflict, r.SaveSchema(context.Background(), Schema{TenantID: "t1", Version: 2}))
+	assert.Equal(t, ErrConflict, r.SaveSchema(context.Background(), Schema{TenantID: "t2", Version: 2}))
+
+	updated, err := s.PutSchema(context.Background(), "t1", json.RawMessage(planSchema), false)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, updated.Version)
+}
+
+func Test_Schema_Writes_Should_Return_ErrOtherTenant(t *testing.T) {
+	accounts := fakeAccounts{accounts: []account.Account{{AccountID: "1", TenantID: "t1"}}}
+	r := &fakeRepository{schemas: map[string]Schema{"t2": {TenantID: "t2", Version: 1}}}
+	s := NewService(accounts, r)
+
+	_, err := s.PutSchema(account.ContextWithCreator(context.Background(), "1"), "t2", json.RawMessage(planSchema), false)
+	assert.Equal(t, ErrOtherTenant, err)
+	assert.Equal(t, ErrOtherTenant, s.DeleteSchema(account.ContextWithCreator(context.Background(), "1"), "t2", true))
+	assert.Equal(t, ErrOtherTenant, s.DeleteSchema(account.ContextWithCreator(context.Background(), "9"), "t2", true))
+
+	_, err = s.PutSchema(account.ContextWithCreator(context.Background(), "1"), "t1", json.RawMessage(`{"type": "object"}`), false)
+	assert.Nil(t, err)
+}
+
+func Test_DeleteSchema(t *testing.T) {
+	accounts := fakeAccounts{accounts: []account.Account{{AccountID: "1", TenantID: "t1", Custom: map[string]interface{}{"plan": "free"}}}}
+	r := &fakeRepository{schemas: map[string]Schema{"t1": {TenantID: "t1"}, "t2": {TenantID: "t2"}}}
+	s := NewService(accounts, r)
+
+	assert.Equal(t, ErrIncompatibleSchema, s.DeleteSchema(context.Background(), "t1", false))
+	assert.Nil(t, s.DeleteSchema(context.Background(), "t1", true))
+	assert.Nil(t, s.DeleteSchema(context.Background(), "t2", false))
+	assert.Equal(t, ErrNotFound, s.DeleteSchema(context.Background(), "t3", false))
+	assert.Empty(t, r.schemas)
+}
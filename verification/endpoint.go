@@ -0,0 +1,42 @@
+package verification
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// Endpoints represent the email verification service endpoints
+type Endpoints struct {
+	Verify endpoint.Endpoint
+	Resend endpoint.Endpoint
+}
+
+// MakeVerifyEmailEndpoint returns an endpoint used for verifying the email of an account
+func MakeVerifyEmailEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(VerifyEmailRequest)
+
+		return nil, s.Verify(ctx, req.ID, req.Token)
+	}
+}
+
+// MakeResendEmailEndpoint returns an endpoint used for resending the verification email of an account
+func MakeResendEmailEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ResendEmailRequest)
+
+		return nil, s.Resend(ctx, req.ID)
+	}
+}
+
+// VerifyEmailRequest represents the request parameters used for verifying an email
+type VerifyEmailRequest struct {
+	ID    string `json:"-"`
+	Token string `json:"token"`
+}
+
+// ResendEmailRequest represents the request parameters used for resending a verification email
+type ResendEmailRequest struct {
+	ID string `json:"id"`
+}
@@ -0,0 +1,96 @@
+package verification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// ErrInvalidBody thrown when the body of a request can not be parsed
+var ErrInvalidBody = errors.New("invalid body")
+
+// MakeHTTPHandler returns all http handler for the email verification service
+func MakeHTTPHandler(logger log.Logger, endpoints Endpoints) http.Handler {
+	options := []kithttp.ServerOption{
+		kithttp.ServerErrorLogger(logger),
+		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(account.PopulateAuditContext),
+	}
+
+	verifyEmailHandler := kithttp.NewServer(
+		endpoints.Verify,
+		decodeVerifyEmailRequest,
+		encodeNoContentResponse,
+		options...,
+	)
+
+	resendEmailHandler := kithttp.NewServer(
+		endpoints.Resend,
+		decodeResendEmailRequest,
+		encodeAcceptedResponse,
+		options...,
+	)
+
+	r := mux.NewRouter().PathPrefix("/accounts/{id}/verify-email").Subrouter()
+
+	r.Handle("", verifyEmailHandler).Methods("POST")
+	r.Handle("/resend", resendEmailHandler).Methods("POST")
+
+	return r
+}
+
+func decodeVerifyEmailRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req VerifyEmailRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Token) == 0 {
+		return nil, ErrInvalidBody
+	}
+
+	req.ID = mux.Vars(r)["id"]
+
+	return req, nil
+}
+
+func decodeResendEmailRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	vars := mux.Vars(r)
+
+	return ResendEmailRequest{ID: vars["id"]}, nil
+}
+
+func encodeNoContentResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func encodeAcceptedResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+// encode errors from business-logic
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	switch err {
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	case ErrInvalidBody,
+		ErrInvalidToken:
+		w.WriteHeader(http.StatusBadRequest)
+	case account.ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case ErrNoEmail,
+		ErrAlreadyVerified:
+		w.WriteHeader(http.StatusConflict)
+	case ErrTooManyRequests:
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,73 @@
+package verification
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+func Test_MakeHTTPHandler_Should_Route_Resend(t *testing.T) {
+	var got interface{}
+	endpoints := Endpoints{
+		Resend: func(ctx context.Context, request interface{}) (interface{}, error) {
+			got = request
+			return nil, nil
+		},
+	}
+	h := MakeHTTPHandler(log.NewNopLogger(), endpoints)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/accounts/1/verify-email/resend", nil)
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, ResendEmailRequest{ID: "1"}, got)
+}
+
+func Test_DecodeVerifyEmailRequest(t *testing.T) {
+	expected := VerifyEmailRequest{ID: "1", Token: "abc"}
+	r, _ := http.NewRequest("POST", "/accounts/1/verify-email", bytes.NewBufferString(`{"token":"abc"}`))
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+
+	req, err := decodeVerifyEmailRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, req)
+}
+
+func Test_DecodeVerifyEmailRequest_Should_Return_ErrInvalidBody_When_Token_Is_Missing(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/accounts/1/verify-email", bytes.NewBufferString("{}"))
+
+	_, err := decodeVerifyEmailRequest(context.Background(), r)
+
+	assert.Equal(t, ErrInvalidBody, err)
+}
+
+func Test_EncodeError_Should_Correctly_Map_Error(t *testing.T) {
+	var flagtests = []struct {
+		in  error
+		out int
+	}{
+		{errors.New("not handled error"), http.StatusInternalServerError},
+		{ErrInvalidBody, http.StatusBadRequest},
+		{ErrInvalidToken, http.StatusBadRequest},
+		{account.ErrNotFound, http.StatusNotFound},
+		{ErrAlreadyVerified, http.StatusConflict},
+		{ErrTooManyRequests, http.StatusTooManyRequests},
+	}
+
+	for _, tt := range flagtests {
+		w := httptest.NewRecorder()
+		encodeError(context.Background(), tt.in, w)
+
+		assert.Equal(t, tt.out, w.Code)
+	}
+}
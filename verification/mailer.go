@@ -0,0 +1,94 @@
+package verification
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/smtp"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// Message is an email to send
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends Messages
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// bytes returns the message in the RFC 5322 format
+func (m Message) bytes(from string, date time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", m.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.Replace(m.Body, "\n", "\r\n", -1))
+	return b.Bytes()
+}
+
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer returns a Mailer sending through the SMTP server at addr,
+// auth is nil when the server does not require authentication
+func NewSMTPMailer(addr, from string, auth smtp.Auth) Mailer {
+	return smtpMailer{
+		addr: addr,
+		from: from,
+		auth: auth,
+	}
+}
+
+func (m smtpMailer) Send(ctx context.Context, msg Message) error {
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, msg.bytes(m.from, time.Now()))
+}
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer returns a Mailer writing every message as an .eml file in dir,
+// for a pickup directory or for tests
+func NewFileMailer(dir, from string) Mailer {
+	return fileMailer{
+		dir:  dir,
+		from: from,
+	}
+}
+
+func (m fileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	name := fmt.Sprintf("%d-%s.eml", now.UnixNano(), strings.Replace(msg.To, string(filepath.Separator), "_", -1))
+
+	return ioutil.WriteFile(filepath.Join(m.dir, name), msg.bytes(m.from, now), 0600)
+}
+
+type logMailer struct {
+	logger log.Logger
+}
+
+// NewLogMailer returns a Mailer only logging the messages, for development
+func NewLogMailer(logger log.Logger) Mailer {
+	return logMailer{
+		logger: logger,
+	}
+}
+
+func (m logMailer) Send(ctx context.Context, msg Message) error {
+	return m.logger.Log("mail_to", msg.To, "subject", msg.Subject, "body", msg.Body)
+}
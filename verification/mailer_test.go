@@ -0,0 +1,29 @@
+package verification
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FileMailer_Should_Write_An_Eml_File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mail")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	m := NewFileMailer(dir, "no-reply@example.com")
+	err = m.Send(context.Background(), Message{To: "a@example.com", Subject: "Hello", Body: "line 1\nline 2"})
+	assert.Nil(t, err)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.Len(t, files, 1)
+
+	b, _ := ioutil.ReadFile(files[0])
+	assert.Contains(t, string(b), "From: no-reply@example.com\r\n")
+	assert.Contains(t, string(b), "To: a@example.com\r\n")
+	assert.Contains(t, string(b), "\r\n\r\nline 1\r\nline 2")
+}
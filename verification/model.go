@@ -0,0 +1,29 @@
+package verification
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Token is an email verification token sent to an account. Only the hash of
+// the token is stored, the token itself is only known by the recipient.
+type Token struct {
+	Hash      string     `json:"-" bson:"_id"`
+	AccountID string     `json:"account_id" bson:"account_id"`
+	Email     string     `json:"email" bson:"email"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" bson:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" bson:"used_at,omitempty"`
+}
+
+// Usable tells whether the token can still verify an email at the given time
+func (t Token) Usable(now time.Time) bool {
+	return t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// HashToken returns the hash under which a token is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
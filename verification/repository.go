@@ -0,0 +1,18 @@
+package verification
+
+import (
+	"context"
+	"time"
+)
+
+// Repository is the storage of the verification Tokens
+type Repository interface {
+	// CreateToken stores a token and sets its expiration time
+	CreateToken(ctx context.Context, t *Token) error
+	// GetToken returns the token with the given hash, nil if there is none
+	GetToken(ctx context.Context, hash string) (*Token, error)
+	// UseToken marks a token as used, it returns ErrInvalidToken if it already was
+	UseToken(ctx context.Context, hash string, at time.Time) error
+	// LastToken returns the newest token of an account, nil if there is none
+	LastToken(ctx context.Context, accountID string) (*Token, error)
+}
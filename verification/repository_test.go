@@ -0,0 +1,72 @@
+package verification
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+type mockedRepository struct {
+	mock.Mock
+}
+
+func (m *mockedRepository) CreateToken(ctx context.Context, t *Token) error {
+	args := m.Called(t)
+	t.ExpiresAt = t.CreatedAt.Add(time.Hour)
+	return args.Error(0)
+}
+
+func (m *mockedRepository) GetToken(ctx context.Context, hash string) (*Token, error) {
+	args := m.Called(hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Token), args.Error(1)
+}
+
+func (m *mockedRepository) UseToken(ctx context.Context, hash string, at time.Time) error {
+	args := m.Called(hash)
+	return args.Error(0)
+}
+
+func (m *mockedRepository) LastToken(ctx context.Context, accountID string) (*Token, error) {
+	args := m.Called(accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Token), args.Error(1)
+}
+
+type recordingMailer struct {
+	messages []Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// fakeAccounts only implements the account.Service methods used by the verification service
+type fakeAccounts struct {
+	account.Service
+	account  account.Account
+	verified string
+}
+
+func (s *fakeAccounts) GetAccount(ctx context.Context, id string) (*account.Account, error) {
+	if id != s.account.AccountID {
+		return nil, account.ErrNotFound
+	}
+	a := s.account
+	return &a, nil
+}
+
+func (s *fakeAccounts) VerifyEmail(ctx context.Context, id string, email string) error {
+	if email != s.account.Email {
+		return account.ErrEmailMismatch
+	}
+	s.verified = email
+	return nil
+}
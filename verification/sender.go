@@ -0,0 +1,42 @@
+package verification
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/log"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// Sender is an account.Publisher sending a verification email whenever an
+// account is created with an email, or its email changes
+type Sender struct {
+	service Service
+	logger  log.Logger
+}
+
+// NewSender returns a new Sender
+func NewSender(s Service, logger log.Logger) *Sender {
+	return &Sender{
+		service: s,
+		logger:  logger,
+	}
+}
+
+// Publish sends the verification email the event calls for, if any. A failure
+// is only logged: retrying the event would notify the other subscribers again,
+// and the account can ask for the email to be resent.
+func (s *Sender) Publish(ctx context.Context, e account.Event) error {
+	envelope := e.Envelope()
+	if envelope.After == nil || len(envelope.After.Email) == 0 || envelope.After.EmailVerified {
+		return nil
+	}
+	if envelope.Before != nil && envelope.Before.Email == envelope.After.Email {
+		return nil
+	}
+
+	if err := s.service.Send(ctx, envelope.AccountID, envelope.After.Email); err != nil {
+		s.logger.Log("account_id", envelope.AccountID, "verification_error", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package verification
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// ErrInvalidToken is used when a verification token is unknown, expired, already used or not for this account
+var ErrInvalidToken = errors.New("invalid verification token")
+
+// ErrNoEmail is used when a verification is asked for an account without email
+var ErrNoEmail = errors.New("Account has no email")
+
+// ErrAlreadyVerified is used when a verification is asked for an email already verified
+var ErrAlreadyVerified = errors.New("email already verified")
+
+// ErrTooManyRequests is used when a verification email is asked again too soon
+var ErrTooManyRequests = errors.New("verification email sent too recently")
+
+// Service is the email verification service interface
+type Service interface {
+	Send(ctx context.Context, accountID, email string) error
+	Resend(ctx context.Context, accountID string) error
+	Verify(ctx context.Context, accountID, token string) error
+}
+
+type service struct {
+	accounts       account.Service
+	repository     Repository
+	mailer         Mailer
+	resendInterval time.Duration
+}
+
+// NewService return a new instance of the email verification service, a
+// verification email can be resent once every resendInterval
+func NewService(accounts account.Service, r Repository, m Mailer, resendInterval time.Duration) Service {
+	return service{
+		accounts:       accounts,
+		repository:     r,
+		mailer:         m,
+		resendInterval: resendInterval,
+	}
+}
+
+// Send mails a new verification token for the email of an account
+func (s service) Send(ctx context.Context, accountID, email string) error {
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	t := Token{
+		Hash:      HashToken(token),
+		AccountID: accountID,
+		Email:     email,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.repository.CreateToken(ctx, &t); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("To verify your email address, send this token to POST /accounts/%s/verify-email before %s:\n\n%s\n",
+			accountID, t.ExpiresAt.Format(time.RFC1123), token),
+	})
+}
+
+// Resend mails a new verification token, unless the last one was sent less than the resend interval ago
+func (s service) Resend(ctx context.Context, accountID string) error {
+	a, err := s.accounts.GetAccount(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	if len(a.Email) == 0 {
+		return ErrNoEmail
+	}
+	if a.EmailVerified {
+		return ErrAlreadyVerified
+	}
+
+	last, err := s.repository.LastToken(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	if last != nil && time.Since(last.CreatedAt) < s.resendInterval {
+		return ErrTooManyRequests
+	}
+
+	return s.Send(ctx, accountID, a.Email)
+}
+
+// Verify uses the token to mark the email it was sent to as verified
+func (s service) Verify(ctx context.Context, accountID, token string) error {
+	hash := HashToken(token)
+
+	t, err := s.repository.GetToken(ctx, hash)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if t == nil || t.AccountID != accountID || !t.Usable(now) {
+		return ErrInvalidToken
+	}
+
+	if err := s.repository.UseToken(ctx, hash, now); err != nil {
+		return err
+	}
+
+	err = s.accounts.VerifyEmail(ctx, accountID, t.Email)
+	if err == account.ErrEmailMismatch {
+		// the email changed since the token was sent
+		return ErrInvalidToken
+	}
+
+	return err
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
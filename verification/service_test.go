@@ -0,0 +1,120 @@
+package verification
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+func Test_Send_Should_Store_The_Hash_And_Mail_The_Token(t *testing.T) {
+	var stored *Token
+	fakeRepo := new(mockedRepository)
+	fakeRepo.On("CreateToken", mock.AnythingOfType("*verification.Token")).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*Token)
+	}).Return(nil)
+	mailer := new(recordingMailer)
+
+	svc := NewService(&fakeAccounts{}, fakeRepo, mailer, time.Minute)
+	err := svc.Send(context.Background(), "1", "a@example.com")
+
+	assert.Nil(t, err)
+	assert.Len(t, mailer.messages, 1)
+	assert.Equal(t, "a@example.com", mailer.messages[0].To)
+
+	lines := strings.Split(strings.TrimSpace(mailer.messages[0].Body), "\n")
+	token := lines[len(lines)-1]
+	assert.Equal(t, HashToken(token), stored.Hash)
+	assert.NotContains(t, stored.Hash, token)
+}
+
+func Test_Resend_Should_Return_ErrTooManyRequests(t *testing.T) {
+	fakeRepo := new(mockedRepository)
+	fakeRepo.On("LastToken", "1").Return(&Token{CreatedAt: time.Now().Add(-10 * time.Second)}, nil)
+	mailer := new(recordingMailer)
+	accounts := &fakeAccounts{account: account.Account{AccountID: "1", Email: "a@example.com"}}
+
+	svc := NewService(accounts, fakeRepo, mailer, time.Minute)
+	err := svc.Resend(context.Background(), "1")
+
+	assert.Equal(t, ErrTooManyRequests, err)
+	assert.Empty(t, mailer.messages)
+}
+
+func Test_Resend_Should_Return_ErrAlreadyVerified(t *testing.T) {
+	accounts := &fakeAccounts{account: account.Account{AccountID: "1", Email: "a@example.com", EmailVerified: true}}
+
+	svc := NewService(accounts, new(mockedRepository), new(recordingMailer), time.Minute)
+	err := svc.Resend(context.Background(), "1")
+
+	assert.Equal(t, ErrAlreadyVerified, err)
+}
+
+func Test_Verify_Should_Use_The_Token_And_Verify_The_Email(t *testing.T) {
+	hash := HashToken("token")
+	fakeRepo := new(mockedRepository)
+	fakeRepo.On("GetToken", hash).Return(&Token{Hash: hash, AccountID: "1", Email: "a@example.com", ExpiresAt: time.Now().Add(time.Hour)}, nil)
+	fakeRepo.On("UseToken", hash).Return(nil)
+	accounts := &fakeAccounts{account: account.Account{AccountID: "1", Email: "a@example.com"}}
+
+	svc := NewService(accounts, fakeRepo, new(recordingMailer), time.Minute)
+	err := svc.Verify(context.Background(), "1", "token")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "a@example.com", accounts.verified)
+	fakeRepo.AssertExpectations(t)
+}
+
+func Test_Verify_Should_Return_ErrInvalidToken(t *testing.T) {
+	used := time.Now()
+	var flagtests = []struct {
+		name  string
+		token *Token
+	}{
+		{"unknown", nil},
+		{"other account", &Token{AccountID: "2", ExpiresAt: time.Now().Add(time.Hour)}},
+		{"expired", &Token{AccountID: "1", ExpiresAt: time.Now().Add(-time.Second)}},
+		{"used", &Token{AccountID: "1", ExpiresAt: time.Now().Add(time.Hour), UsedAt: &used}},
+	}
+
+	for _, tt := range flagtests {
+		fakeRepo := new(mockedRepository)
+		if tt.token == nil {
+			fakeRepo.On("GetToken", HashToken("token")).Return(nil, nil)
+		} else {
+			fakeRepo.On("GetToken", HashToken("token")).Return(tt.token, nil)
+		}
+
+		svc := NewService(&fakeAccounts{}, fakeRepo, new(recordingMailer), time.Minute)
+		err := svc.Verify(context.Background(), "1", "token")
+
+		assert.Equal(t, ErrInvalidToken, err, tt.name)
+	}
+}
+
+func Test_Sender_Should_Only_Send_For_New_Emails(t *testing.T) {
+	fakeRepo := new(mockedRepository)
+	fakeRepo.On("CreateToken", mock.Anything).Return(nil)
+	mailer := new(recordingMailer)
+	sender := NewSender(NewService(&fakeAccounts{}, fakeRepo, mailer, time.Minute), log.NewNopLogger())
+
+	events := []account.Event{
+		account.AccountCreated{After: account.Account{AccountID: "1"}},
+		account.AccountCreated{After: account.Account{AccountID: "1", Email: "a@example.com"}},
+		account.AccountUpdated{Before: account.Account{AccountID: "1", Email: "a@example.com"}, After: account.Account{AccountID: "1", Email: "a@example.com", Status: account.StatusClosed}},
+		account.AccountUpdated{Before: account.Account{AccountID: "1", Email: "a@example.com"}, After: account.Account{AccountID: "1", Email: "b@example.com"}},
+		account.AccountDeleted{Before: account.Account{AccountID: "1", Email: "b@example.com"}},
+	}
+	for _, e := range events {
+		assert.Nil(t, sender.Publish(context.Background(), e))
+	}
+
+	assert.Len(t, mailer.messages, 2)
+	assert.Equal(t, "a@example.com", mailer.messages[0].To)
+	assert.Equal(t, "b@example.com", mailer.messages[1].To)
+}
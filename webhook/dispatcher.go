@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// Dispatcher is an account.Publisher queuing a Delivery for every subscription
+// matching the published event, the Worker then posts them
+type Dispatcher struct {
+	repository Repository
+	logger     log.Logger
+}
+
+// NewDispatcher returns a new Dispatcher
+func NewDispatcher(r Repository, logger log.Logger) *Dispatcher {
+	return &Dispatcher{
+		repository: r,
+		logger:     logger,
+	}
+}
+
+// Publish queues the event for the matching subscriptions. A failure is only
+// logged: retrying the event would notify the other subscribers again, and
+// queue the deliveries already created twice.
+func (d *Dispatcher) Publish(ctx context.Context, e account.Event) error {
+	envelope := e.Envelope()
+
+	subs, err := d.repository.FindSubscriptions(ctx, envelope)
+	if err != nil {
+		d.logger.Log("event", envelope.Type, "account_id", envelope.AccountID, "webhook_error", err)
+		return nil
+	}
+
+	now := time.Now().UTC()
+	for _, sub := range subs {
+		_, err := d.repository.CreateDelivery(ctx, Delivery{
+			SubscriptionID: sub.ID,
+			Event:          envelope,
+			Status:         DeliveryPending,
+			Attempts:       []Attempt{},
+			NextAttempt:    now,
+			CreatedAt:      now,
+		})
+		if err != nil {
+			d.logger.Log("event", envelope.Type, "subscription", sub.ID, "webhook_error", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// Endpoints represent the webhook service endpoints
+type Endpoints struct {
+	GetByID       endpoint.Endpoint
+	GetList       endpoint.Endpoint
+	Update        endpoint.Endpoint
+	Create        endpoint.Endpoint
+	Delete        endpoint.Endpoint
+	GetDeliveries endpoint.Endpoint
+}
+
+// MakeGetSubscriptionEndpoint returns an endpoint used for getting a subscription
+func MakeGetSubscriptionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetSubscriptionRequest)
+
+		return s.GetSubscription(ctx, req.ID)
+	}
+}
+
+// MakeGetSubscriptionsEndpoint returns an endpoint used for getting subscriptions
+func MakeGetSubscriptionsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetSubscriptionsRequest)
+
+		return s.GetSubscriptions(ctx, req.Pagination)
+	}
+}
+
+// MakeUpdateSubscriptionEndpoint returns an endpoint used for updating a subscription
+func MakeUpdateSubscriptionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(UpdateSubscriptionRequest)
+
+		return nil, s.UpdateSubscription(ctx, req.Subscription)
+	}
+}
+
+// MakeCreateSubscriptionEndpoint returns an endpoint used for creating a subscription
+func MakeCreateSubscriptionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(CreateSubscriptionRequest)
+
+		return s.CreateSubscription(ctx, req.Subscription)
+	}
+}
+
+// MakeDeleteSubscriptionEndpoint returns an endpoint used for deleting a subscription
+func MakeDeleteSubscriptionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DeleteSubscriptionRequest)
+
+		return nil, s.DeleteSubscription(ctx, req.ID)
+	}
+}
+
+// MakeGetDeliveriesEndpoint returns an endpoint used for querying the delivery log of a subscription
+func MakeGetDeliveriesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(GetDeliveriesRequest)
+
+		return s.GetDeliveries(ctx, req.SubscriptionID, req.Status, req.Pagination)
+	}
+}
+
+// GetSubscriptionRequest represents the request parameters used for getting one Subscription
+type GetSubscriptionRequest struct {
+	ID string `json:"id"`
+}
+
+// GetSubscriptionsRequest represents the request parameters used for getting Subscriptions
+type GetSubscriptionsRequest struct {
+	account.Pagination
+}
+
+// UpdateSubscriptionRequest represents the request parameters used for updating a Subscription
+type UpdateSubscriptionRequest struct {
+	Subscription
+}
+
+// CreateSubscriptionRequest represents the request parameters used for creating a Subscription
+type CreateSubscriptionRequest struct {
+	Subscription
+}
+
+// DeleteSubscriptionRequest represents the request parameters used for deleting a Subscription
+type DeleteSubscriptionRequest struct {
+	ID string `json:"id"`
+}
+
+// GetDeliveriesRequest represents the request parameters used for querying the delivery log
+type GetDeliveriesRequest struct {
+	SubscriptionID string
+	Status         string
+	account.Pagination
+}
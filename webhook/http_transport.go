@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-kit/kit/log"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// ErrInvalidBody thrown when the body of a request can not be parsed
+var ErrInvalidBody = errors.New("invalid body")
+
+// MakeHTTPHandler returns all http handler for the webhook service
+func MakeHTTPHandler(logger log.Logger, endpoints Endpoints) http.Handler {
+	options := []kithttp.ServerOption{
+		kithttp.ServerErrorLogger(logger),
+		kithttp.ServerErrorEncoder(encodeError),
+	}
+
+	getSubscriptionHandler := kithttp.NewServer(
+		endpoints.GetByID,
+		decodeGetSubscriptionRequest,
+		encodeResponse,
+		options...,
+	)
+
+	getSubscriptionsHandler := kithttp.NewServer(
+		endpoints.GetList,
+		decodeGetSubscriptionsRequest,
+		encodeResponse,
+		options...,
+	)
+
+	updateSubscriptionHandler := kithttp.NewServer(
+		endpoints.Update,
+		decodeUpdateSubscriptionRequest,
+		encodeNoContentResponse,
+		options...,
+	)
+
+	createSubscriptionHandler := kithttp.NewServer(
+		endpoints.Create,
+		decodeCreateSubscriptionRequest,
+		encodeCreateSubscriptionResponse,
+		options...,
+	)
+
+	deleteSubscriptionHandler := kithttp.NewServer(
+		endpoints.Delete,
+		decodeDeleteSubscriptionRequest,
+		encodeNoContentResponse,
+		options...,
+	)
+
+	getDeliveriesHandler := kithttp.NewServer(
+		endpoints.GetDeliveries,
+		decodeGetDeliveriesRequest,
+		encodeResponse,
+		options...,
+	)
+
+	r := mux.NewRouter().PathPrefix("/webhooks/").Subrouter().StrictSlash(true)
+
+	r.Handle("/", getSubscriptionsHandler).Methods("GET")
+	r.Handle("/{id}", getSubscriptionHandler).Methods("GET")
+	r.Handle("/{id}", updateSubscriptionHandler).Methods("PATCH")
+	r.Handle("/", createSubscriptionHandler).Methods("POST")
+	r.Handle("/{id}", deleteSubscriptionHandler).Methods("DELETE")
+	r.Handle("/{id}/deliveries", getDeliveriesHandler).Methods("GET")
+
+	return r
+}
+
+func decodeGetSubscriptionRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	vars := mux.Vars(r)
+
+	return GetSubscriptionRequest{ID: vars["id"]}, nil
+}
+
+func decodeGetSubscriptionsRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	return GetSubscriptionsRequest{Pagination: decodePagination(r)}, nil
+}
+
+func decodeUpdateSubscriptionRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req UpdateSubscriptionRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, ErrInvalidBody
+	}
+
+	vars := mux.Vars(r)
+
+	if len(req.ID) == 0 {
+		req.ID = vars["id"]
+	}
+
+	if req.ID != vars["id"] {
+		return nil, ErrInconsistentID
+	}
+
+	return req, nil
+}
+
+func decodeCreateSubscriptionRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	var req CreateSubscriptionRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, ErrInvalidBody
+	}
+
+	return req, nil
+}
+
+func decodeDeleteSubscriptionRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	vars := mux.Vars(r)
+
+	return DeleteSubscriptionRequest{ID: vars["id"]}, nil
+}
+
+func decodeGetDeliveriesRequest(ctx context.Context, r *http.Request) (request interface{}, err error) {
+	vars := mux.Vars(r)
+
+	return GetDeliveriesRequest{
+		SubscriptionID: vars["id"],
+		Status:         r.URL.Query().Get("status"),
+		Pagination:     decodePagination(r),
+	}, nil
+}
+
+// decodePagination reads the page and size query parameters, invalid values fall back to the defaults
+func decodePagination(r *http.Request) account.Pagination {
+	p := account.Pagination{Size: account.DefaultPaginationSize, Page: 0}
+
+	if size, err := strconv.Atoi(r.URL.Query().Get("size")); err == nil && size > 0 {
+		p.Size = size
+	}
+	if page, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && page > 0 {
+		p.Page = page
+	}
+
+	return p
+}
+
+func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func encodeNoContentResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func encodeCreateSubscriptionResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	sub, ok := response.(*Subscription)
+	if !ok {
+		return errors.New("An error occured while creating Subscription")
+	}
+	w.Header().Set("Location", fmt.Sprintf("/webhooks/%v", sub.ID))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	// the secret is only ever returned here
+	return json.NewEncoder(w).Encode(sub)
+}
+
+// encode errors from business-logic
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	switch err {
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	case ErrInconsistentID,
+		ErrInvalidBody,
+		ErrInvalidURL:
+		w.WriteHeader(http.StatusBadRequest)
+	case ErrNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": err.Error(),
+	})
+}
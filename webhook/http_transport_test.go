@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+func Test_MakeHTTPHandler(t *testing.T) {
+	h := MakeHTTPHandler(log.NewNopLogger(), Endpoints{})
+
+	assert.NotNil(t, h)
+}
+
+func Test_DecodeGetDeliveriesRequest(t *testing.T) {
+	expected := GetDeliveriesRequest{SubscriptionID: "1", Status: DeliveryDeadLetter, Pagination: account.Pagination{Size: 10, Page: 2}}
+	r, _ := http.NewRequest("GET", "/webhooks/1/deliveries?status=dead_letter&size=10&page=2", nil)
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+
+	req, err := decodeGetDeliveriesRequest(context.Background(), r)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, req)
+}
+
+func Test_DecodeUpdateSubscriptionRequest_Should_Return_ErrInconsistentID(t *testing.T) {
+	r, _ := http.NewRequest("PATCH", "/webhooks/1", bytes.NewBufferString("{\"id\":\"2\"}"))
+	r = mux.SetURLVars(r, map[string]string{"id": "1"})
+
+	_, err := decodeUpdateSubscriptionRequest(context.Background(), r)
+
+	assert.Equal(t, ErrInconsistentID, err)
+}
+
+func Test_EncodeCreateSubscriptionResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := encodeCreateSubscriptionResponse(context.Background(), w, &Subscription{ID: "1", Secret: "secret"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/webhooks/1", w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), "\"secret\":\"secret\"")
+}
+
+func Test_EncodeError_Should_Correctly_Map_Error(t *testing.T) {
+	var flagtests = []struct {
+		in  error
+		out int
+	}{
+		{errors.New("not handled error"), http.StatusInternalServerError},
+		{ErrInvalidBody, http.StatusBadRequest},
+		{ErrInvalidURL, http.StatusBadRequest},
+		{ErrNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range flagtests {
+		w := httptest.NewRecorder()
+		encodeError(context.Background(), tt.in, w)
+
+		assert.Equal(t, tt.out, w.Code)
+	}
+}
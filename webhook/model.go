@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// Subscription asks for the account events to be posted to an URL
+type Subscription struct {
+	ID  string `json:"id" bson:"id"`
+	URL string `json:"url" bson:"url"`
+	// Secret is the HMAC key used to sign the payloads, it is only returned when the subscription is created
+	Secret string `json:"secret,omitempty" bson:"secret"`
+	// Events restricts the event types delivered, all of them when empty
+	Events []string `json:"events,omitempty" bson:"events,omitempty"`
+	// AccountIDs restricts the accounts whose events are delivered, all of them when empty
+	AccountIDs []string  `json:"account_ids,omitempty" bson:"account_ids,omitempty"`
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+}
+
+// Matches tells if the event has to be delivered to the subscription
+func (s Subscription) Matches(e account.EventEnvelope) bool {
+	return (len(s.Events) == 0 || contains(s.Events, e.Type)) &&
+		(len(s.AccountIDs) == 0 || contains(s.AccountIDs, e.AccountID))
+}
+
+// Delivery statuses
+const (
+	DeliveryPending    = "pending"
+	DeliverySucceeded  = "succeeded"
+	DeliveryDeadLetter = "dead_letter"
+)
+
+// Delivery is one event to post to one subscription, along with the log of its attempts
+type Delivery struct {
+	ID             string                `json:"id" bson:"id"`
+	SubscriptionID string                `json:"subscription_id" bson:"subscription_id"`
+	Event          account.EventEnvelope `json:"event" bson:"event"`
+	Status         string                `json:"status" bson:"status"`
+	Attempts       []Attempt             `json:"attempts" bson:"attempts"`
+	NextAttempt    time.Time             `json:"next_attempt" bson:"next_attempt"`
+	CreatedAt      time.Time             `json:"created_at" bson:"created_at"`
+}
+
+// Attempt is the outcome of one POST of a Delivery
+type Attempt struct {
+	At         time.Time `json:"at" bson:"at"`
+	StatusCode int       `json:"status_code,omitempty" bson:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// Repository represents the webhook subscriptions and deliveries repository interface
+type Repository interface {
+	GetSubscription(ctx context.Context, id string) (*Subscription, error)
+	GetSubscriptions(ctx context.Context, pagination account.Pagination) ([]*Subscription, error)
+	// FindSubscriptions returns every subscription matching the event
+	FindSubscriptions(ctx context.Context, e account.EventEnvelope) ([]*Subscription, error)
+	CreateSubscription(ctx context.Context, s Subscription) (string, error)
+	UpdateSubscription(ctx context.Context, s Subscription) error
+	DeleteSubscription(ctx context.Context, id string) error
+
+	CreateDelivery(ctx context.Context, d Delivery) (string, error)
+	UpdateDelivery(ctx context.Context, d Delivery) error
+	// DueDeliveries returns the pending deliveries whose next attempt is before now
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]*Delivery, error)
+	GetDeliveries(ctx context.Context, subscriptionID string, status string, pagination account.Pagination) ([]*Delivery, error)
+}
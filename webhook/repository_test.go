@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+type mockedRepository struct {
+	mock.Mock
+}
+
+func (m *mockedRepository) GetSubscription(ctx context.Context, id string) (*Subscription, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Subscription), args.Error(1)
+}
+
+func (m *mockedRepository) GetSubscriptions(ctx context.Context, pagination account.Pagination) ([]*Subscription, error) {
+	args := m.Called(pagination)
+	return args.Get(0).([]*Subscription), args.Error(1)
+}
+
+func (m *mockedRepository) FindSubscriptions(ctx context.Context, e account.EventEnvelope) ([]*Subscription, error) {
+	args := m.Called(e)
+	return args.Get(0).([]*Subscription), args.Error(1)
+}
+
+func (m *mockedRepository) CreateSubscription(ctx context.Context, s Subscription) (string, error) {
+	args := m.Called(s)
+	return args.Get(0).(string), args.Error(1)
+}
+
+func (m *mockedRepository) UpdateSubscription(ctx context.Context, s Subscription) error {
+	args := m.Called(s)
+	return args.Error(0)
+}
+
+func (m *mockedRepository) DeleteSubscription(ctx context.Context, id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockedRepository) CreateDelivery(ctx context.Context, d Delivery) (string, error) {
+	args := m.Called(d)
+	return args.Get(0).(string), args.Error(1)
+}
+
+func (m *mockedRepository) UpdateDelivery(ctx context.Context, d Delivery) error {
+	args := m.Called(d)
+	return args.Error(0)
+}
+
+func (m *mockedRepository) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]*Delivery, error) {
+	args := m.Called(now, limit)
+	return args.Get(0).([]*Delivery), args.Error(1)
+}
+
+func (m *mockedRepository) GetDeliveries(ctx context.Context, subscriptionID string, status string, pagination account.Pagination) ([]*Delivery, error) {
+	args := m.Called(subscriptionID, status, pagination)
+	return args.Get(0).([]*Delivery), args.Error(1)
+}
@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+// ErrNotFound is used when a Subscription is not found
+var ErrNotFound = errors.New("Subscription not found")
+
+// ErrInconsistentID ...
+var ErrInconsistentID = errors.New("inconsistent Subscription id")
+
+// ErrInvalidURL is used when the URL of a Subscription is not an absolute http(s) URL
+var ErrInvalidURL = errors.New("invalid webhook url")
+
+// Service is the webhook subscription service interface
+type Service interface {
+	GetSubscription(ctx context.Context, id string) (*Subscription, error)
+	GetSubscriptions(ctx context.Context, pagination account.Pagination) ([]*Subscription, error)
+	CreateSubscription(ctx context.Context, s Subscription) (*Subscription, error)
+	UpdateSubscription(ctx context.Context, s Subscription) error
+	DeleteSubscription(ctx context.Context, id string) error
+	GetDeliveries(ctx context.Context, subscriptionID string, status string, pagination account.Pagination) ([]*Delivery, error)
+}
+
+type service struct {
+	repository Repository
+}
+
+// NewService return a new instance of the webhook service
+func NewService(r Repository) Service {
+	return service{
+		repository: r,
+	}
+}
+
+// GetSubscription returns a Subscription, without its secret
+func (s service) GetSubscription(ctx context.Context, id string) (sub *Subscription, err error) {
+	sub, err = s.repository.GetSubscription(ctx, id)
+
+	if sub == nil {
+		return nil, ErrNotFound
+	}
+
+	sub.Secret = ""
+	return
+}
+
+// GetSubscriptions returns a list of Subscriptions, without their secrets
+func (s service) GetSubscriptions(ctx context.Context, pagination account.Pagination) (subs []*Subscription, err error) {
+	subs, err = s.repository.GetSubscriptions(ctx, pagination)
+
+	for _, sub := range subs {
+		sub.Secret = ""
+	}
+
+	return
+}
+
+// CreateSubscription creates a Subscription, a secret is generated when none is given
+func (s service) CreateSubscription(ctx context.Context, sub Subscription) (*Subscription, error) {
+	if err := validateURL(sub.URL); err != nil {
+		return nil, err
+	}
+
+	if len(sub.Secret) == 0 {
+		secret, err := newSecret()
+		if err != nil {
+			return nil, err
+		}
+		sub.Secret = secret
+	}
+	sub.CreatedAt = time.Now().UTC()
+
+	id, err := s.repository.CreateSubscription(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.ID = id
+	return &sub, nil
+}
+
+// UpdateSubscription updates an existing Subscription, its secret is kept when none is given
+func (s service) UpdateSubscription(ctx context.Context, sub Subscription) error {
+	if err := validateURL(sub.URL); err != nil {
+		return err
+	}
+
+	existing, err := s.repository.GetSubscription(ctx, sub.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrNotFound
+	}
+
+	if len(sub.Secret) == 0 {
+		sub.Secret = existing.Secret
+	}
+	sub.CreatedAt = existing.CreatedAt
+
+	return s.repository.UpdateSubscription(ctx, sub)
+}
+
+// DeleteSubscription deletes a Subscription
+func (s service) DeleteSubscription(ctx context.Context, id string) error {
+	return s.repository.DeleteSubscription(ctx, id)
+}
+
+// GetDeliveries returns the delivery log of a Subscription, optionally restricted to one status
+func (s service) GetDeliveries(ctx context.Context, subscriptionID string, status string, pagination account.Pagination) ([]*Delivery, error) {
+	return s.repository.GetDeliveries(ctx, subscriptionID, status, pagination)
+}
+
+func validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
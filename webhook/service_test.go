@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+func Test_CreateSubscription_Should_Generate_A_Secret(t *testing.T) {
+	fakeRepo := new(mockedRepository)
+	fakeRepo.On("CreateSubscription", mock.AnythingOfType("Subscription")).Return("1", nil)
+
+	svc := NewService(fakeRepo)
+	sub, err := svc.CreateSubscription(context.Background(), Subscription{URL: "https://example.com/hook"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1", sub.ID)
+	assert.Len(t, sub.Secret, 64)
+}
+
+func Test_CreateSubscription_Should_Return_ErrInvalidURL(t *testing.T) {
+	for _, u := range []string{"", "example.com/hook", "ftp://example.com", "http://"} {
+		svc := NewService(new(mockedRepository))
+		_, err := svc.CreateSubscription(context.Background(), Subscription{URL: u})
+
+		assert.Equal(t, ErrInvalidURL, err)
+	}
+}
+
+func Test_GetSubscription_Should_Hide_The_Secret(t *testing.T) {
+	fakeRepo := new(mockedRepository)
+	fakeRepo.On("GetSubscription", "1").Return(&Subscription{ID: "1", Secret: "secret"}, nil)
+
+	svc := NewService(fakeRepo)
+	sub, err := svc.GetSubscription(context.Background(), "1")
+
+	assert.Nil(t, err)
+	assert.Empty(t, sub.Secret)
+}
+
+func Test_GetSubscription_Should_Return_ErrNotFound(t *testing.T) {
+	fakeRepo := new(mockedRepository)
+	fakeRepo.On("GetSubscription", "1").Return(nil, ErrNotFound)
+
+	svc := NewService(fakeRepo)
+	_, err := svc.GetSubscription(context.Background(), "1")
+
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func Test_UpdateSubscription_Should_Keep_The_Secret(t *testing.T) {
+	fakeRepo := new(mockedRepository)
+	fakeRepo.On("GetSubscription", "1").Return(&Subscription{ID: "1", Secret: "secret"}, nil)
+	fakeRepo.On("UpdateSubscription", Subscription{ID: "1", URL: "http://example.com", Secret: "secret"}).Return(nil)
+
+	svc := NewService(fakeRepo)
+	err := svc.UpdateSubscription(context.Background(), Subscription{ID: "1", URL: "http://example.com"})
+
+	assert.Nil(t, err)
+	fakeRepo.AssertExpectations(t)
+}
+
+func Test_Dispatcher_Should_Queue_A_Delivery_Per_Matching_Subscription(t *testing.T) {
+	e := account.AccountCreated{After: account.Account{AccountID: "1"}}
+	fakeRepo := new(mockedRepository)
+	fakeRepo.On("FindSubscriptions", e.Envelope()).Return([]*Subscription{{ID: "a"}, {ID: "b"}}, nil)
+	fakeRepo.On("CreateDelivery", mock.AnythingOfType("Delivery")).Return("d", nil)
+
+	err := NewDispatcher(fakeRepo, log.NewNopLogger()).Publish(context.Background(), e)
+
+	assert.Nil(t, err)
+	fakeRepo.AssertNumberOfCalls(t, "CreateDelivery", 2)
+}
+
+func Test_Dispatcher_Should_Not_Return_The_Delivery_Errors(t *testing.T) {
+	e := account.AccountCreated{After: account.Account{AccountID: "1"}}
+	fakeRepo := new(mockedRepository)
+	fakeRepo.On("FindSubscriptions", e.Envelope()).Return([]*Subscription{{ID: "a"}, {ID: "b"}}, nil)
+	fakeRepo.On("CreateDelivery", mock.AnythingOfType("Delivery")).Return("", errors.New("error"))
+
+	err := NewDispatcher(fakeRepo, log.NewNopLogger()).Publish(context.Background(), e)
+
+	assert.Nil(t, err)
+	fakeRepo.AssertNumberOfCalls(t, "CreateDelivery", 2)
+}
+
+func Test_Subscription_Matches(t *testing.T) {
+	e := account.AccountDeleted{Before: account.Account{AccountID: "1"}}.Envelope()
+
+	assert.True(t, Subscription{}.Matches(e))
+	assert.True(t, Subscription{Events: []string{account.EventAccountDeleted}, AccountIDs: []string{"1"}}.Matches(e))
+	assert.False(t, Subscription{Events: []string{account.EventAccountCreated}}.Matches(e))
+	assert.False(t, Subscription{AccountIDs: []string{"2"}}.Matches(e))
+}
@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+const (
+	pollInterval = time.Second
+	batchSize    = 50
+	minBackoff   = 10 * time.Second
+	maxBackoff   = time.Hour
+)
+
+// Worker posts the pending deliveries, retrying them with an exponential
+// backoff until they succeed or reach the maximum number of attempts, after
+// which they are dead-lettered
+type Worker struct {
+	repository  Repository
+	client      *http.Client
+	maxAttempts int
+	logger      log.Logger
+}
+
+// NewWorker returns a new Worker
+func NewWorker(r Repository, client *http.Client, maxAttempts int, logger log.Logger) *Worker {
+	return &Worker{
+		repository:  r,
+		client:      client,
+		maxAttempts: maxAttempts,
+		logger:      logger,
+	}
+}
+
+// Run polls the due deliveries until the context is done
+func (w *Worker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.deliverDue(ctx, time.Now().UTC()); err != nil {
+			w.logger.Log("webhook_worker_error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) deliverDue(ctx context.Context, now time.Time) error {
+	deliveries, err := w.repository.DueDeliveries(ctx, now, batchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deliveries {
+		if err := w.deliver(ctx, d, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deliver makes one attempt and records its outcome in the delivery log
+func (w *Worker) deliver(ctx context.Context, d *Delivery, now time.Time) error {
+	sub, err := w.repository.GetSubscription(ctx, d.SubscriptionID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	attempt := Attempt{At: now}
+	if sub == nil {
+		attempt.Error = ErrNotFound.Error()
+	} else {
+		attempt.StatusCode, err = w.post(ctx, sub, d)
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+	}
+	d.Attempts = append(d.Attempts, attempt)
+
+	switch {
+	case len(attempt.Error) == 0:
+		d.Status = DeliverySucceeded
+	case sub == nil || len(d.Attempts) >= w.maxAttempts:
+		d.Status = DeliveryDeadLetter
+		w.logger.Log("webhook_delivery", d.ID, "subscription", d.SubscriptionID, "dead_letter", attempt.Error)
+	default:
+		d.NextAttempt = now.Add(backoff(len(d.Attempts)))
+	}
+
+	return w.repository.UpdateDelivery(ctx, *d)
+}
+
+// post sends the signed event, any status other than 2xx is an error
+func (w *Worker) post(ctx context.Context, sub *Subscription, d *Delivery) (int, error) {
+	body, err := json.Marshal(d.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest("POST", sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set(HeaderEvent, d.Event.Type)
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, body))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// Sign returns the value of the signature header of a payload,
+// receivers compute it again with their secret to authenticate the call
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff doubles the delay after each failed attempt, up to maxBackoff
+func backoff(attempts int) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
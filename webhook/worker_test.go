@@ -0,0 +1,76 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/tkanos/go-rest-api-sample/account"
+)
+
+func Test_Worker_Should_Post_A_Signed_Payload(t *testing.T) {
+	var signature string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(HeaderSignature)
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	d := &Delivery{ID: "d", SubscriptionID: "1", Status: DeliveryPending, Event: account.AccountCreated{}.Envelope()}
+	fakeRepo := new(mockedRepository)
+	fakeRepo.On("GetSubscription", "1").Return(&Subscription{ID: "1", URL: server.URL, Secret: "secret"}, nil)
+	fakeRepo.On("UpdateDelivery", mock.MatchedBy(func(d Delivery) bool {
+		return d.Status == DeliverySucceeded && len(d.Attempts) == 1 && d.Attempts[0].StatusCode == http.StatusOK
+	})).Return(nil)
+
+	w := NewWorker(fakeRepo, server.Client(), 3, log.NewNopLogger())
+	err := w.deliver(context.Background(), d, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Sign("secret", body), signature)
+	fakeRepo.AssertExpectations(t)
+}
+
+func Test_Worker_Should_Retry_Then_Dead_Letter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	d := &Delivery{ID: "d", SubscriptionID: "1", Status: DeliveryPending}
+	fakeRepo := new(mockedRepository)
+	fakeRepo.On("GetSubscription", "1").Return(&Subscription{ID: "1", URL: server.URL}, nil)
+	fakeRepo.On("UpdateDelivery", mock.AnythingOfType("Delivery")).Return(nil)
+
+	w := NewWorker(fakeRepo, server.Client(), 2, log.NewNopLogger())
+
+	w.deliver(context.Background(), d, now)
+	assert.Equal(t, DeliveryPending, d.Status)
+	assert.Equal(t, now.Add(minBackoff), d.NextAttempt)
+
+	w.deliver(context.Background(), d, now)
+	assert.Equal(t, DeliveryDeadLetter, d.Status)
+	assert.Equal(t, http.StatusInternalServerError, d.Attempts[1].StatusCode)
+}
+
+func Test_Worker_Should_Dead_Letter_When_Subscription_Is_Deleted(t *testing.T) {
+	d := &Delivery{ID: "d", SubscriptionID: "1", Status: DeliveryPending}
+	fakeRepo := new(mockedRepository)
+	fakeRepo.On("GetSubscription", "1").Return(nil, ErrNotFound)
+	fakeRepo.On("UpdateDelivery", mock.AnythingOfType("Delivery")).Return(nil)
+
+	w := NewWorker(fakeRepo, http.DefaultClient, 5, log.NewNopLogger())
+	err := w.deliver(context.Background(), d, time.Now())
+
+	assert.Nil(t, err)
+	assert.Equal(t, DeliveryDeadLetter, d.Status)
+}